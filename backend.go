@@ -0,0 +1,61 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gshaderc
+
+// Backend is what a Compiler uses to actually compile shaders. The package
+// provides a libshaderc backend (built with the 'shaderc' build tag), a
+// backend which runs glslc and an in-memory fake for tests.
+type Backend interface {
+	// Compile runs a single compilation, it may be called from multiple
+	// goroutines at once. Failures are reported through the result.
+	Compile(job *CompileJob) *CompilationResult
+	// Release releases any resources held by the backend
+	Release()
+}
+
+// OutputKind is the kind of output a compilation produces
+type OutputKind int
+
+const (
+	// OutputSPV produces a SPIR-V binary
+	OutputSPV OutputKind = iota
+	// OutputPreprocessedText produces the preprocessed source
+	OutputPreprocessedText
+	// OutputAssembly produces SPIR-V assembly text
+	OutputAssembly
+)
+
+// CompileJob describes a single compilation handed to a Backend, see
+// Compiler.CompileIntoSPV for the meaning of each field. A ShaderType of
+// SPIRVAssembly means the source is SPIR-V assembly to be assembled.
+type CompileJob struct {
+	Source        string
+	ShaderType    ShaderType
+	InputFilename string
+	EntryPoint    string
+	Output        OutputKind
+	Options       *CompilerOptions
+}
+
+// withOptions returns the job, or a copy of it using the default options if
+// it has none, so backends can be called directly without options
+func (job *CompileJob) withOptions() *CompileJob {
+	if job.Options != nil {
+		return job
+	}
+	j := *job
+	j.Options = NewCompilerOptions()
+	return &j
+}
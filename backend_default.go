@@ -0,0 +1,30 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !cgo || (!shaderc && !shaderc_dynamic)
+// +build !cgo !shaderc,!shaderc_dynamic
+
+package gshaderc
+
+// Without libshaderc linked in the best we can do is glslc, if it isn't on
+// the PATH compilations will fail with BackendUnavailableError
+var newDefaultBackend = func() Backend {
+	return NewGlslcBackend()
+}
+
+// LoadShaderc makes sure libshaderc can be used, without the shaderc or
+// shaderc_dynamic build tags it never can
+func LoadShaderc() error {
+	return ErrShadercUnavailable
+}
@@ -0,0 +1,347 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gshaderc
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FakeBackend is an in-memory Backend for tests which need a compiler but
+// not real SPIR-V. It runs a small subset of the preprocessor (#include via
+// the include resolver, #define, #undef, #ifdef, #ifndef, #if, #elif, #else,
+// #endif and #error) and produces a minimal SPIR-V module, or its assembly,
+// which identifies the preprocessed source, so identical inputs produce
+// identical output.
+//
+// Sources without a #version directive fail to compile, as do sources
+// containing an active #error directive.
+type FakeBackend struct {
+	mu   sync.Mutex
+	jobs []CompileJob
+}
+
+// NewFakeBackend creates a new fake backend
+func NewFakeBackend() *FakeBackend {
+	return &FakeBackend{}
+}
+
+// Jobs returns every job the backend has been asked to compile
+func (f *FakeBackend) Jobs() []CompileJob {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]CompileJob(nil), f.jobs...)
+}
+
+// Compile implements Backend
+func (f *FakeBackend) Compile(job *CompileJob) *CompilationResult {
+	job = job.withOptions()
+	f.mu.Lock()
+	f.jobs = append(f.jobs, *job)
+	f.mu.Unlock()
+
+	if job.ShaderType == SPIRVAssembly {
+		return NewCompilationResult(fakeModule(job.Options, job.Source), nil, "", 0, 0)
+	}
+
+	p := &fakePreprocessor{
+		resolver: job.Options.IncludeResolver(),
+		defined:  make(map[string]string),
+	}
+	for _, m := range job.Options.Macros() {
+		p.defined[m.Name] = m.Value
+	}
+	p.run(job.Source, job.InputFilename, 0)
+
+	if !p.hasVersion {
+		p.errorf(job.InputFilename, 1, "'#version' : a #version directive is required")
+	}
+	if job.ShaderType == InferFromSource && !p.hasStage {
+		return NewCompilationResult(nil, InvalidStageError,
+			fmt.Sprintf("%s: error: #pragma shader_stage required when stage is not specified\n", job.InputFilename), 1, 0)
+	}
+	if len(p.errors) > 0 {
+		return NewCompilationResult(nil, CompilationError, strings.Join(p.errors, "\n")+"\n", len(p.errors), 0)
+	}
+
+	text := p.out.String()
+	if job.Output == OutputPreprocessedText {
+		return NewCompilationResult([]byte(text), nil, "", 0, 0)
+	}
+	level, _ := job.Options.OptimizationLevel()
+	key := fmt.Sprintf("%d\x00%s\x00%d\x00%s", job.ShaderType, job.EntryPoint, level, text)
+	if job.Output == OutputAssembly {
+		return NewCompilationResult(fakeAssembly(job.Options, key), nil, "", 0, 0)
+	}
+	return NewCompilationResult(fakeModule(job.Options, key), nil, "", 0, 0)
+}
+
+// Release implements Backend
+func (f *FakeBackend) Release() {
+}
+
+// fakeModule builds a minimal SPIR-V module which carries a hash of key
+func fakeModule(options *CompilerOptions, key string) []byte {
+	ext := []byte(fakeTag(key))
+	ext = append(ext, make([]byte, 4-len(ext)%4)...)
+
+	words := []uint32{
+		0x07230203, uint32(fakeVersion(options)), 0, 1, 0,
+		2<<16 | 17, 1, // OpCapability Shader
+		3<<16 | 14, 0, 1, // OpMemoryModel Logical GLSL450
+		uint32(1+len(ext)/4)<<16 | 4, // OpSourceExtension
+	}
+	for i := 0; i < len(ext); i += 4 {
+		words = append(words, binary.LittleEndian.Uint32(ext[i:]))
+	}
+
+	data := make([]byte, len(words)*4)
+	for i, w := range words {
+		binary.LittleEndian.PutUint32(data[i*4:], w)
+	}
+	return data
+}
+
+// fakeAssembly returns the assembly text of the module fakeModule builds
+func fakeAssembly(options *CompilerOptions, key string) []byte {
+	version := fakeVersion(options)
+	return []byte(fmt.Sprintf("; SPIR-V\n; Version: %d.%d\n; Generator: Khronos; 0\n; Bound: 1\n; Schema: 0\n"+
+		"               OpCapability Shader\n"+
+		"               OpMemoryModel Logical GLSL450\n"+
+		"               OpSourceExtension \"%s\"\n", (version>>16)&0xff, (version>>8)&0xff, fakeTag(key)))
+}
+
+func fakeVersion(options *CompilerOptions) SPIRVVersion {
+	if v, ok := options.SPIRVVersion(); ok {
+		return v
+	} else if target, env, ok := options.TargetEnv(); ok && target == Vulkan && env == Vulkan_1_1 {
+		return SPIRV_1_3
+	}
+	return SPIRV_1_0
+}
+
+func fakeTag(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "fake:" + hex.EncodeToString(sum[:16])
+}
+
+// fakeCond tracks a single #if/#ifdef/#ifndef block
+type fakeCond struct {
+	parentActive bool
+	active       bool
+	taken        bool
+}
+
+type fakePreprocessor struct {
+	resolver   IncludeResolver
+	defined    map[string]string
+	conds      []fakeCond
+	out        strings.Builder
+	errors     []string
+	hasVersion bool
+	hasStage   bool
+}
+
+func (p *fakePreprocessor) errorf(filename string, line int, format string, args ...interface{}) {
+	p.errors = append(p.errors, fmt.Sprintf("%s:%d: error: ", filename, line)+fmt.Sprintf(format, args...))
+}
+
+func (p *fakePreprocessor) active() bool {
+	return len(p.conds) == 0 || p.conds[len(p.conds)-1].active
+}
+
+func (p *fakePreprocessor) run(source, filename string, depth int) {
+	base := len(p.conds)
+	for i, line := range strings.Split(source, "\n") {
+		lineNo := i + 1
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "#") {
+			if p.active() {
+				p.out.WriteString(line + "\n")
+			}
+			continue
+		}
+
+		directive := strings.TrimSpace(trimmed[1:])
+		name := directive
+		rest := ""
+		if i := strings.IndexAny(directive, " \t"); i >= 0 {
+			name, rest = directive[:i], strings.TrimSpace(directive[i:])
+		}
+
+		switch name {
+		case "if", "ifdef", "ifndef":
+			parent := p.active()
+			c := fakeCond{parentActive: parent}
+			if parent {
+				switch name {
+				case "if":
+					c.active = p.eval(rest)
+				case "ifdef":
+					_, c.active = p.defined[rest]
+				case "ifndef":
+					_, defined := p.defined[rest]
+					c.active = !defined
+				}
+			}
+			c.taken = c.active
+			p.conds = append(p.conds, c)
+			continue
+		case "elif", "else", "endif":
+			if len(p.conds) <= base {
+				p.errorf(filename, lineNo, "'#%s' : unexpected directive", name)
+				continue
+			}
+			c := &p.conds[len(p.conds)-1]
+			switch name {
+			case "elif":
+				c.active = c.parentActive && !c.taken && p.eval(rest)
+				c.taken = c.taken || c.active
+			case "else":
+				c.active = c.parentActive && !c.taken
+				c.taken = true
+			case "endif":
+				p.conds = p.conds[:len(p.conds)-1]
+			}
+			continue
+		}
+
+		if !p.active() {
+			continue
+		}
+
+		switch name {
+		case "version":
+			p.hasVersion = true
+		case "pragma":
+			if strings.HasPrefix(rest, "shader_stage") {
+				p.hasStage = true
+			}
+		case "define":
+			fields := strings.SplitN(rest, " ", 2)
+			value := ""
+			if len(fields) > 1 {
+				value = strings.TrimSpace(fields[1])
+			}
+			p.defined[fields[0]] = value
+			continue
+		case "undef":
+			delete(p.defined, rest)
+			continue
+		case "error":
+			p.errorf(filename, lineNo, "'#error' : %s", rest)
+			continue
+		case "include":
+			p.include(line, filename, lineNo, depth)
+			continue
+		}
+		p.out.WriteString(line + "\n")
+	}
+	if len(p.conds) > base {
+		p.errorf(filename, strings.Count(source, "\n")+1, "'#if' : missing #endif")
+		p.conds = p.conds[:base]
+	}
+}
+
+func (p *fakePreprocessor) include(line, filename string, lineNo, depth int) {
+	requested, itype, ok := ParseIncludeDirective(line)
+	if !ok {
+		p.errorf(filename, lineNo, "'#include' : malformed include directive")
+		return
+	}
+	if p.resolver == nil {
+		p.errorf(filename, lineNo, "'#include' : include directive requires an include resolver")
+		return
+	}
+	if depth+1 > maxIncludeDepth {
+		p.errorf(filename, lineNo, "'#include' : include nested too deeply")
+		return
+	}
+	sourceName, content, err := p.resolver(requested, itype, filename, depth+1)
+	if err != nil {
+		p.errorf(filename, lineNo, "'%s' : %v", requested, err)
+		return
+	}
+	p.run(content, sourceName, depth+1)
+}
+
+// eval evaluates a preprocessor condition, only defined(), integers,
+// macro names, !, comparisons and the && and || operators are understood
+func (p *fakePreprocessor) eval(expr string) bool {
+	if parts := strings.Split(expr, "||"); len(parts) > 1 {
+		for _, part := range parts {
+			if p.eval(part) {
+				return true
+			}
+		}
+		return false
+	}
+	if parts := strings.Split(expr, "&&"); len(parts) > 1 {
+		for _, part := range parts {
+			if !p.eval(part) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if i := strings.Index(expr, op); i >= 0 {
+			a, b := p.value(expr[:i]), p.value(expr[i+len(op):])
+			switch op {
+			case "==":
+				return a == b
+			case "!=":
+				return a != b
+			case "<=":
+				return a <= b
+			case ">=":
+				return a >= b
+			case "<":
+				return a < b
+			}
+			return a > b
+		}
+	}
+
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "!") {
+		return !p.eval(expr[1:])
+	}
+	if strings.HasPrefix(expr, "defined") {
+		name := strings.Trim(strings.TrimSpace(expr[len("defined"):]), "() \t")
+		_, ok := p.defined[name]
+		return ok
+	}
+	if strings.HasPrefix(expr, "(") && strings.HasSuffix(expr, ")") {
+		return p.eval(expr[1 : len(expr)-1])
+	}
+	return p.value(expr) != 0
+}
+
+// value returns the integer value of a literal or macro, or 0
+func (p *fakePreprocessor) value(expr string) int64 {
+	expr = strings.TrimSpace(expr)
+	if v, ok := p.defined[expr]; ok {
+		expr = v
+	}
+	n, _ := strconv.ParseInt(expr, 0, 64)
+	return n
+}
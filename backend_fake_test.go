@@ -0,0 +1,149 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gshaderc
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFakeBackend(t *testing.T) {
+	backend := NewFakeBackend()
+	compiler := NewCompilerWithBackend(backend)
+	defer compiler.Release()
+	options := NewCompilerOptions()
+
+	res := compiler.CompileIntoSPV("void main(){}", VertexShader, "main.vert", "main", options)
+	if res.Error() != CompilationError {
+		t.Fatal("Expected compilation error without #version")
+	}
+
+	res = compiler.CompileIntoSPV("#version 450\n#error broken\nvoid main(){}", VertexShader, "main.vert", "main", options)
+	if res.Error() != CompilationError || res.NumErrors() != 1 {
+		t.Fatal("Expected a single compilation error")
+	}
+	if !strings.Contains(res.ErrorMessage(), "main.vert:2: error: '#error' : broken") {
+		t.Fatalf("Unexpected error message %q", res.ErrorMessage())
+	}
+
+	source := "#version 450\n#ifdef USE_FOO\nfoo();\n#else\nbar();\n#endif\nvoid main(){}"
+	plain := compiler.CompileIntoSPV(source, VertexShader, "main.vert", "main", options)
+	if plain.Error() != nil {
+		t.Fatalf("Didn't expect a compilation error: %s", plain.ErrorMessage())
+	}
+	if len(plain.Bytes()) < 20 || plain.Bytes()[0] != 0x03 || plain.Bytes()[3] != 0x07 {
+		t.Fatal("Expected a SPIR-V module")
+	}
+
+	unused := options.Clone()
+	unused.AddMacroDefinition("UNUSED", "1")
+	if !bytes.Equal(plain.Bytes(), compiler.CompileIntoSPV(source, VertexShader, "main.vert", "main", unused).Bytes()) {
+		t.Fatal("Expected an unused macro not to change the output")
+	}
+
+	foo := options.Clone()
+	foo.AddMacroDefinition("USE_FOO", "")
+	if bytes.Equal(plain.Bytes(), compiler.CompileIntoSPV(source, VertexShader, "main.vert", "main", foo).Bytes()) {
+		t.Fatal("Expected USE_FOO to change the output")
+	}
+
+	pre := compiler.CompileIntoPreProcessedText(source, VertexShader, "main.vert", "main", foo)
+	if !strings.Contains(string(pre.Bytes()), "foo();") || strings.Contains(string(pre.Bytes()), "bar();") {
+		t.Fatalf("Unexpected preprocessed output %q", pre.Bytes())
+	}
+
+	asm := compiler.CompileIntoSPVAssembly(source, VertexShader, "main.vert", "main", options)
+	if !strings.HasPrefix(string(asm.Bytes()), "; SPIR-V\n") || !strings.Contains(string(asm.Bytes()), "OpCapability Shader") {
+		t.Fatalf("Unexpected assembly output %q", asm.Bytes())
+	}
+
+	if len(backend.Jobs()) != 7 {
+		t.Fatalf("Expected 7 jobs, got %d", len(backend.Jobs()))
+	}
+
+	res = backend.Compile(&CompileJob{Source: source, ShaderType: VertexShader, InputFilename: "main.vert", EntryPoint: "main"})
+	if res.Error() != nil || !bytes.Equal(res.Bytes(), plain.Bytes()) {
+		t.Fatal("Expected a job without options to use the default options")
+	}
+}
+
+func TestFakeBackendConditions(t *testing.T) {
+	p := &fakePreprocessor{defined: map[string]string{"A": "1", "B": "0", "C": ""}}
+	tests := map[string]bool{
+		"A":                       true,
+		"B":                       false,
+		"0":                       false,
+		"defined(B)":              true,
+		"defined D":               false,
+		"!defined(D)":             true,
+		"defined(A) && B":         false,
+		"defined(D) || defined C": true,
+		"(A)":                     true,
+		"A == 1":                  true,
+		"A > 1":                   false,
+		"B != A":                  true,
+	}
+	for expr, expected := range tests {
+		if p.eval(expr) != expected {
+			t.Errorf("Expected %q to evaluate to %v", expr, expected)
+		}
+	}
+}
+
+func TestFakeBackendInclude(t *testing.T) {
+	compiler := NewCompilerWithBackend(NewFakeBackend())
+	defer compiler.Release()
+	options := NewCompilerOptions()
+
+	files := map[string]string{
+		"a.glsl": "#include \"b.glsl\"\nfloat a;\n",
+		"b.glsl": "float b;\n",
+	}
+	var requests []string
+	options.SetIncludeCallback(func(requestedSource string, iType IncludeType, requestingSource string, includeDepth int) (string, string, error) {
+		requests = append(requests, fmt.Sprintf("%s<-%s@%d", requestedSource, requestingSource, includeDepth))
+		content, ok := files[requestedSource]
+		if !ok {
+			return "", "", fmt.Errorf("no such file %s", requestedSource)
+		}
+		return requestedSource, content, nil
+	})
+
+	recorder := NewIncludeRecorder(options.IncludeResolver())
+	recorded := options.Clone()
+	recorded.SetIncludeCallback(recorder.Resolve)
+
+	res := compiler.CompileIntoPreProcessedText("#version 450\n#include \"a.glsl\"\n#include \"b.glsl\"\nvoid main(){}", FragmentShader, "main.frag", "main", recorded)
+	if res.Error() != nil {
+		t.Fatalf("Didn't expect a compilation error: %s", res.ErrorMessage())
+	}
+	if strings.Join(requests, ",") != "a.glsl<-main.frag@1,b.glsl<-a.glsl@2,b.glsl<-main.frag@1" {
+		t.Fatalf("Unexpected include requests %v", requests)
+	}
+	if strings.Join(recorder.Includes(), ",") != "a.glsl,b.glsl" {
+		t.Fatalf("Unexpected recorded includes %v", recorder.Includes())
+	}
+	text := string(res.Bytes())
+	if b, a := strings.Index(text, "float b;"), strings.Index(text, "float a;"); b < 0 || a < b {
+		t.Fatalf("Unexpected preprocessed output %q", res.Bytes())
+	}
+
+	res = compiler.CompileIntoSPV("#version 450\n#include <missing.glsl>\nvoid main(){}", FragmentShader, "main.frag", "main", options)
+	if res.Error() != CompilationError || !strings.Contains(res.ErrorMessage(), "no such file missing.glsl") {
+		t.Fatalf("Expected an include error, got %q", res.ErrorMessage())
+	}
+}
@@ -0,0 +1,303 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gshaderc
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxIncludeDepth is how deeply includes may nest before we give up
+const maxIncludeDepth = 64
+
+// GlslcBackend is a Backend which runs the glslc command line compiler, it
+// is a useful fallback on machines which don't have libshaderc available
+// for linking.
+//
+// glslc can't call back into Go, so if an include resolver is set the
+// backend expands #include directives itself before handing the source
+// to glslc, any include the resolver can't find is left for glslc to find
+// using IncludeDirs.
+type GlslcBackend struct {
+	// Path to the glslc executable, if empty glslc is looked up on the PATH
+	Path string
+	// IncludeDirs are passed to glslc with -I
+	IncludeDirs []string
+}
+
+// NewGlslcBackend creates a glslc backend which uses the glslc found on the PATH
+func NewGlslcBackend() *GlslcBackend {
+	return &GlslcBackend{}
+}
+
+// Compile implements Backend
+func (b *GlslcBackend) Compile(job *CompileJob) *CompilationResult {
+	job = job.withOptions()
+	path := b.Path
+	if path == "" {
+		var err error
+		path, err = exec.LookPath("glslc")
+		if err != nil {
+			return NewCompilationResult(nil, BackendUnavailableError, err.Error(), 1, 0)
+		}
+	}
+
+	source := job.Source
+	input := "-"
+	replaceName := "<stdin>"
+	if job.ShaderType == SPIRVAssembly {
+		// glslc only recognizes assembly by its file extension
+		dir, err := ioutil.TempDir("", "gshaderc")
+		if err != nil {
+			return NewCompilationResult(nil, InternalError, err.Error(), 1, 0)
+		}
+		defer os.RemoveAll(dir)
+		input = filepath.Join(dir, "input.spvasm")
+		if err := ioutil.WriteFile(input, []byte(source), 0644); err != nil {
+			return NewCompilationResult(nil, InternalError, err.Error(), 1, 0)
+		}
+		replaceName = input
+	} else if resolver := job.Options.IncludeResolver(); resolver != nil {
+		source = expandIncludes(source, job.InputFilename, resolver, 1)
+	}
+
+	args := append(b.args(job), input)
+	cmd := exec.Command(path, args...)
+	if input == "-" {
+		cmd.Stdin = strings.NewReader(source)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+
+	message := glslcMessage(stderr.String(), replaceName, job.InputFilename)
+	numErrors, numWarnings := countDiagnostics(message)
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return NewCompilationResult(nil, BackendUnavailableError, err.Error(), 1, 0)
+		}
+		if numErrors == 0 {
+			numErrors = 1
+		}
+		status := CompilationError
+		if job.ShaderType == SPIRVAssembly {
+			status = InvalidAssemblyError
+		}
+		return NewCompilationResult(nil, status, message, numErrors, numWarnings)
+	}
+	return NewCompilationResult(stdout.Bytes(), nil, message, numErrors, numWarnings)
+}
+
+// Release implements Backend
+func (b *GlslcBackend) Release() {
+}
+
+// args returns the glslc arguments for a job, not including the input
+func (b *GlslcBackend) args(job *CompileJob) []string {
+	args := []string{"-o", "-"}
+	switch job.Output {
+	case OutputPreprocessedText:
+		args = append(args, "-E")
+	case OutputAssembly:
+		args = append(args, "-S")
+	default:
+		args = append(args, "-c")
+	}
+
+	if job.ShaderType != SPIRVAssembly {
+		stype := job.ShaderType
+		if stype >= DefaultVertexShader && stype <= DefaultTessEcaluationShader {
+			// glslc has no notion of a fallback stage, so force it
+			stype -= DefaultVertexShader
+		}
+		if ext := GetShaderExtensionByType(stype); ext != "" {
+			args = append(args, "-fshader-stage="+ext)
+		}
+		if job.EntryPoint != "" {
+			args = append(args, "-fentry-point="+job.EntryPoint)
+		}
+		if job.InputFilename != "" {
+			// Relative includes are resolved against the directory of the
+			// including file, which glslc can't know when reading stdin
+			args = append(args, "-I", filepath.Dir(job.InputFilename))
+		}
+		for _, dir := range b.IncludeDirs {
+			args = append(args, "-I", dir)
+		}
+	}
+
+	o := job.Options
+	if target, version, ok := o.TargetEnv(); ok {
+		args = append(args, "--target-env="+glslcTargetEnv(target, version))
+	}
+	if version, ok := o.SPIRVVersion(); ok {
+		args = append(args, fmt.Sprintf("--target-spv=spv%d.%d", (version>>16)&0xff, (version>>8)&0xff))
+	}
+	if level, ok := o.OptimizationLevel(); ok {
+		switch level {
+		case Zero:
+			args = append(args, "-O0")
+		case Size:
+			args = append(args, "-Os")
+		case Performance:
+			args = append(args, "-O")
+		}
+	}
+	for _, m := range o.Macros() {
+		if m.Value == "" {
+			args = append(args, "-D"+m.Name)
+		} else {
+			args = append(args, "-D"+m.Name+"="+m.Value)
+		}
+	}
+	if o.WarningsSuppressed() {
+		args = append(args, "-w")
+	}
+	if o.WarningsAsErrors() {
+		args = append(args, "-Werror")
+	}
+	if o.AutoBindUniforms() {
+		args = append(args, "-fauto-bind-uniforms")
+	}
+	if o.InvertY() {
+		args = append(args, "-finvert-y")
+	}
+	if o.NanClamp() {
+		args = append(args, "-fnan-clamp")
+	}
+	if o.GenerateDebugInfo() {
+		args = append(args, "-g")
+	}
+
+	bases := o.BindingBases()
+	kinds := make([]int, 0, len(bases))
+	for kind := range bases {
+		kinds = append(kinds, int(kind))
+	}
+	sort.Ints(kinds)
+	for _, kind := range kinds {
+		if flag, ok := glslcBindingBaseFlags[UniformKind(kind)]; ok {
+			args = append(args, flag, strconv.FormatUint(uint64(bases[UniformKind(kind)]), 10))
+		}
+	}
+
+	limits := o.Limits()
+	if len(limits) > 0 {
+		names := make([]string, 0, len(limits))
+		for limit, value := range limits {
+			names = append(names, fmt.Sprintf("%s %d", limit, value))
+		}
+		sort.Strings(names)
+		args = append(args, "-flimit="+strings.Join(names, " "))
+	}
+
+	return args
+}
+
+var glslcBindingBaseFlags = map[UniformKind]string{
+	UniformKindImage:               "-fimage-binding-base",
+	UniformKindSampler:             "-fsampler-binding-base",
+	UniformKindTexture:             "-ftexture-binding-base",
+	UniformKindBuffer:              "-fubo-binding-base",
+	UniformKindStorageBuffer:       "-fssbo-binding-base",
+	UniformKindUnorderedAccessView: "-fuav-binding-base",
+}
+
+func glslcTargetEnv(target Target, version EnvVersion) string {
+	switch target {
+	case Vulkan:
+		switch version {
+		case Vulkan_1_0:
+			return "vulkan1.0"
+		case Vulkan_1_1:
+			return "vulkan1.1"
+		}
+		return "vulkan"
+	case OpenGL:
+		return "opengl4.5"
+	case OpenGLCompat:
+		return "opengl_compat"
+	case WebGPU:
+		return "webgpu"
+	}
+	return "vulkan"
+}
+
+// glslcMessage strips glslc's summary line and renames the input so the
+// message reads as if libshaderc produced it
+func glslcMessage(message, from, to string) string {
+	var out strings.Builder
+	for _, line := range strings.SplitAfter(message, "\n") {
+		if strings.HasSuffix(strings.TrimSpace(line), " generated.") {
+			continue
+		}
+		if to != "" {
+			line = strings.Replace(line, from, to, -1)
+		}
+		out.WriteString(line)
+	}
+	return out.String()
+}
+
+// countDiagnostics counts the errors and warnings in a compiler message
+func countDiagnostics(message string) (numErrors, numWarnings int) {
+	for _, line := range strings.Split(message, "\n") {
+		if strings.Contains(line, ": error: ") || strings.HasSuffix(line, ": error:") {
+			numErrors++
+		} else if strings.Contains(line, ": warning: ") {
+			numWarnings++
+		}
+	}
+	return numErrors, numWarnings
+}
+
+// expandIncludes replaces each #include directive in source with the
+// content returned by the resolver, wrapped in #line directives so that
+// diagnostics still refer to the right file and line. Directives the
+// resolver can't satisfy are left in place.
+func expandIncludes(source, filename string, resolver IncludeResolver, depth int) string {
+	if depth > maxIncludeDepth {
+		return source
+	}
+	var out strings.Builder
+	for i, line := range strings.Split(source, "\n") {
+		if i > 0 {
+			out.WriteString("\n")
+		}
+		name, itype, ok := ParseIncludeDirective(line)
+		if !ok {
+			out.WriteString(line)
+			continue
+		}
+		sourceName, content, err := resolver(name, itype, filename, depth)
+		if err != nil {
+			out.WriteString(line)
+			continue
+		}
+		fmt.Fprintf(&out, "#line 1 %q\n", sourceName)
+		out.WriteString(expandIncludes(content, sourceName, resolver, depth+1))
+		fmt.Fprintf(&out, "\n#line %d %q", i+2, filename)
+	}
+	return out.String()
+}
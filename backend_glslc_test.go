@@ -0,0 +1,110 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gshaderc
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestGlslcArgs(t *testing.T) {
+	options := NewCompilerOptions()
+	options.SetTargetEnv(Vulkan, Vulkan_1_1)
+	options.SetSPIRVVersion(SPIRV_1_3)
+	options.SetOptimizationLevel(Performance)
+	options.AddMacroDefinition("FOO", "1")
+	options.AddMacroDefinition("BAR", "")
+	options.SetBindingBase(UniformKindBuffer, 4)
+	options.SetLimit(MaxLights, 8)
+	options.SetWarningsAsErrors()
+	options.SetGenerateDebugInfo()
+
+	b := &GlslcBackend{IncludeDirs: []string{"include"}}
+	args := strings.Join(b.args(&CompileJob{
+		ShaderType:    DefaultFragmentShader,
+		InputFilename: "shaders/main.frag",
+		EntryPoint:    "main",
+		Options:       options,
+	}), " ")
+
+	expected := "-o - -c -fshader-stage=frag -fentry-point=main -I shaders -I include --target-env=vulkan1.1 --target-spv=spv1.3 -O -DFOO=1 -DBAR -Werror -g -fubo-binding-base 4 -flimit=MaxLights 8"
+	if args != expected {
+		t.Fatalf("Unexpected glslc arguments:\n%s\nexpected:\n%s", args, expected)
+	}
+	asm := b.args(&CompileJob{ShaderType: VertexShader, Output: OutputAssembly, Options: NewCompilerOptions()})
+	if asm[2] != "-S" {
+		t.Fatalf("Expected -S for assembly output, got %v", asm)
+	}
+}
+
+func TestGlslcExpandIncludes(t *testing.T) {
+	resolver := func(requestedSource string, iType IncludeType, requestingSource string, includeDepth int) (string, string, error) {
+		if requestedSource != "common.glsl" {
+			return "", "", os.ErrNotExist
+		}
+		return "/abs/common.glsl", "float x;", nil
+	}
+	out := expandIncludes("#version 450\n#include \"common.glsl\"\n#include <other.glsl>\nvoid main(){}", "main.frag", resolver, 1)
+	expected := "#version 450\n#line 1 \"/abs/common.glsl\"\nfloat x;\n#line 3 \"main.frag\"\n#include <other.glsl>\nvoid main(){}"
+	if out != expected {
+		t.Fatalf("Unexpected expansion:\n%s", out)
+	}
+}
+
+func writeScript(t *testing.T, dir, script string) string {
+	path := filepath.Join(dir, "glslc")
+	if err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGlslcBackend(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a shell")
+	}
+	dir, err := ioutil.TempDir("", "gshaderc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	compiler := NewCompilerWithBackend(&GlslcBackend{Path: writeScript(t, dir, "cat\n")})
+	res := compiler.CompileIntoSPV("#version 450\nvoid main(){}", VertexShader, "main.vert", "main", nil)
+	if res.Error() != nil || string(res.Bytes()) != "#version 450\nvoid main(){}" {
+		t.Fatalf("Expected the source to be passed on stdin, got %q", res.Bytes())
+	}
+
+	compiler = NewCompilerWithBackend(&GlslcBackend{Path: writeScript(t, dir,
+		"echo \"<stdin>:1: error: 'x' : undeclared identifier\" >&2\necho \"1 error generated.\" >&2\nexit 1\n")})
+	res = compiler.CompileIntoSPV("#version 450\nvoid main(){x;}", VertexShader, "main.vert", "main", nil)
+	if res.Error() != CompilationError || res.NumErrors() != 1 {
+		t.Fatal("Expected a compilation error")
+	}
+	if res.ErrorMessage() != "main.vert:1: error: 'x' : undeclared identifier\n" {
+		t.Fatalf("Unexpected error message %q", res.ErrorMessage())
+	}
+
+	compiler = NewCompilerWithBackend(&GlslcBackend{Path: filepath.Join(dir, "missing")})
+	res = compiler.CompileIntoSPV("#version 450\nvoid main(){}", VertexShader, "main.vert", "main", nil)
+	if !errors.Is(res.Error(), BackendUnavailableError) {
+		t.Fatal("Expected the backend to be unavailable")
+	}
+}
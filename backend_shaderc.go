@@ -0,0 +1,251 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build cgo && (shaderc || shaderc_dynamic)
+// +build cgo
+// +build shaderc shaderc_dynamic
+
+package gshaderc
+
+// #cgo !shaderc_dynamic LDFLAGS: -lshaderc_combined -lstdc++ -lm
+// #cgo shaderc_dynamic CFLAGS: -DGSHADERC_DYNAMIC
+// #cgo shaderc_dynamic,linux LDFLAGS: -ldl
+/*
+#ifdef GSHADERC_DYNAMIC
+#include "shaderc_dynamic.h"
+#else
+#include <shaderc/shaderc.h>
+#endif
+#include <stdlib.h>
+
+static shaderc_include_result* new_shader_include_result(){
+	return (shaderc_include_result*) calloc(1, sizeof(shaderc_include_result));
+}
+
+static void free_shader_include_result(void *user_data, shaderc_include_result *res){
+	free((void*) res->source_name);
+	free((void*) res->content);
+	free(res);
+}
+
+shaderc_include_result* cbIncludeResolver(void* user_data, char* requested_source, int type,
+    char* requesting_source, size_t include_depth);
+
+
+static void _register_callback(shaderc_compile_options_t options,void *userData){
+	shaderc_compile_options_set_include_callbacks(options, (shaderc_include_resolve_fn) cbIncludeResolver, free_shader_include_result, userData);
+}
+
+
+*/
+import "C"
+import (
+	"fmt"
+	"unsafe"
+
+	ptr "github.com/mattn/go-pointer"
+)
+
+var newDefaultBackend = func() Backend {
+	return NewShadercBackend()
+}
+
+type callback struct {
+	resolver IncludeResolver
+}
+
+//export cbIncludeResolver
+func cbIncludeResolver(userData unsafe.Pointer, requestedSource *C.char, itype C.int, requestingSource *C.char, includeDepth C.size_t) *C.shaderc_include_result {
+
+	rds := C.GoString(requestedSource)
+	ris := C.GoString(requestingSource)
+
+	callback := ptr.Restore(userData).(*callback)
+
+	sourceName, content, err := callback.resolver(rds, IncludeType(itype), ris, int(includeDepth))
+
+	result := C.new_shader_include_result()
+
+	if err == nil {
+		result.source_name = C.CString(sourceName)
+		result.source_name_length = C.size_t(len(sourceName))
+
+		result.content = C.CString(content)
+		result.content_length = C.size_t(len(content))
+
+	} else {
+		// An empty source name tells shaderc the content is an error message
+		msg := err.Error()
+		result.content = C.CString(msg)
+		result.content_length = C.size_t(len(msg))
+	}
+
+	return result
+}
+
+// LoadShaderc makes sure libshaderc can be used. When built with the
+// shaderc_dynamic tag the shared library is loaded the first time this is
+// called, and an error wrapping ErrShadercUnavailable is returned if it
+// can't be.
+func LoadShaderc() error {
+	return loadShaderc()
+}
+
+// ShadercBackend is a Backend which calls directly into libshaderc
+type ShadercBackend struct {
+	compiler C.shaderc_compiler_t
+	err      error
+}
+
+// NewShadercBackend creates a new libshaderc backend, if libshaderc can't be
+// loaded every compilation fails with ErrShadercUnavailable
+func NewShadercBackend() *ShadercBackend {
+	if err := loadShaderc(); err != nil {
+		return &ShadercBackend{err: err}
+	}
+	return &ShadercBackend{compiler: C.shaderc_compiler_initialize()}
+}
+
+// Compile implements Backend
+func (b *ShadercBackend) Compile(job *CompileJob) *CompilationResult {
+	if b.err != nil {
+		return NewCompilationResult(nil, ErrShadercUnavailable, b.err.Error(), 1, 0)
+	}
+
+	options := newShadercOptions(job.withOptions().Options)
+	defer options.release()
+
+	source := C.CString(job.Source)
+	defer C.free(unsafe.Pointer(source))
+	inputFilename := C.CString(job.InputFilename)
+	defer C.free(unsafe.Pointer(inputFilename))
+	entryPoint := C.CString(job.EntryPoint)
+	defer C.free(unsafe.Pointer(entryPoint))
+
+	var result C.shaderc_compilation_result_t
+	switch {
+	case job.ShaderType == SPIRVAssembly:
+		result = C.shaderc_assemble_into_spv(b.compiler, source, C.size_t(len(job.Source)), options.options)
+	case job.Output == OutputPreprocessedText:
+		result = C.shaderc_compile_into_preprocessed_text(b.compiler, source, C.size_t(len(job.Source)),
+			C.shaderc_shader_kind(job.ShaderType), inputFilename, entryPoint, options.options)
+	case job.Output == OutputAssembly:
+		result = C.shaderc_compile_into_spv_assembly(b.compiler, source, C.size_t(len(job.Source)),
+			C.shaderc_shader_kind(job.ShaderType), inputFilename, entryPoint, options.options)
+	default:
+		result = C.shaderc_compile_into_spv(b.compiler, source, C.size_t(len(job.Source)),
+			C.shaderc_shader_kind(job.ShaderType), inputFilename, entryPoint, options.options)
+	}
+	if result == nil {
+		return NewCompilationResult(nil, NullResultObjectError, "", 0, 0)
+	}
+	defer C.shaderc_result_release(result)
+
+	data := C.GoBytes(unsafe.Pointer(C.shaderc_result_get_bytes(result)), C.int(C.shaderc_result_get_length(result)))
+
+	return NewCompilationResult(data,
+		compilationStatusToError(C.shaderc_result_get_compilation_status(result)),
+		C.GoString(C.shaderc_result_get_error_message(result)),
+		int(C.shaderc_result_get_num_errors(result)),
+		int(C.shaderc_result_get_num_warnings(result)))
+}
+
+// Release implements Backend
+func (b *ShadercBackend) Release() {
+	if b.err == nil {
+		C.shaderc_compiler_release(b.compiler)
+	}
+}
+
+// shadercOptions is the libshaderc version of a CompilerOptions, it only
+// lives for the duration of a single compilation
+type shadercOptions struct {
+	options  C.shaderc_compile_options_t
+	userData unsafe.Pointer
+}
+
+func newShadercOptions(c *CompilerOptions) *shadercOptions {
+	o := &shadercOptions{options: C.shaderc_compile_options_initialize()}
+
+	if target, version, ok := c.TargetEnv(); ok {
+		C.shaderc_compile_options_set_target_env(o.options, C.shaderc_target_env(target), C.uint32_t(version))
+	}
+	if version, ok := c.SPIRVVersion(); ok {
+		C.shaderc_compile_options_set_target_spirv(o.options, C.shaderc_spirv_version(version))
+	}
+	if level, ok := c.OptimizationLevel(); ok {
+		C.shaderc_compile_options_set_optimization_level(o.options, C.shaderc_optimization_level(level))
+	}
+	for _, m := range c.Macros() {
+		name := C.CString(m.Name)
+		value := C.CString(m.Value)
+		C.shaderc_compile_options_add_macro_definition(o.options, name, C.size_t(len(m.Name)), value, C.size_t(len(m.Value)))
+		C.free(unsafe.Pointer(name))
+		C.free(unsafe.Pointer(value))
+	}
+	C.shaderc_compile_options_set_nan_clamp(o.options, C.bool(c.NanClamp()))
+	C.shaderc_compile_options_set_invert_y(o.options, C.bool(c.InvertY()))
+	for kind, base := range c.BindingBases() {
+		C.shaderc_compile_options_set_binding_base(o.options, C.shaderc_uniform_kind(kind), C.uint32_t(base))
+	}
+	if c.WarningsSuppressed() {
+		C.shaderc_compile_options_set_suppress_warnings(o.options)
+	}
+	if c.WarningsAsErrors() {
+		C.shaderc_compile_options_set_warnings_as_errors(o.options)
+	}
+	C.shaderc_compile_options_set_auto_bind_uniforms(o.options, C.bool(c.AutoBindUniforms()))
+	if c.GenerateDebugInfo() {
+		C.shaderc_compile_options_set_generate_debug_info(o.options)
+	}
+	for limit, value := range c.Limits() {
+		C.shaderc_compile_options_set_limit(o.options, C.shaderc_limit(limit), C.int(value))
+	}
+	if resolver := c.IncludeResolver(); resolver != nil {
+		o.userData = ptr.Save(&callback{resolver: resolver})
+		C._register_callback(o.options, o.userData)
+	}
+	return o
+}
+
+func (o *shadercOptions) release() {
+	C.shaderc_compile_options_release(o.options)
+	if o.userData != nil {
+		ptr.Unref(o.userData)
+	}
+}
+
+func compilationStatusToError(status C.shaderc_compilation_status) error {
+	switch status {
+	case C.shaderc_compilation_status_success:
+		return nil
+	case C.shaderc_compilation_status_invalid_stage:
+		return InvalidStageError
+	case C.shaderc_compilation_status_compilation_error:
+		return CompilationError
+	case C.shaderc_compilation_status_internal_error:
+		return InternalError
+	case C.shaderc_compilation_status_null_result_object:
+		return NullResultObjectError
+	case C.shaderc_compilation_status_invalid_assembly:
+		return InvalidAssemblyError
+	case C.shaderc_compilation_status_validation_error:
+		return ValidationError
+	case C.shaderc_compilation_status_transformation_error:
+		return TransformationError
+	case C.shaderc_compilation_status_configuration_error:
+		return ConfigurationError
+	}
+	return fmt.Errorf("unknown error %v", status)
+}
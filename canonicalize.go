@@ -0,0 +1,449 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gshaderc
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"hash/fnv"
+	"sort"
+)
+
+// CanonicalizeOptions selects what Canonicalize reorders besides the ids
+type CanonicalizeOptions struct {
+	// Functions orders the functions by their ids, declarations first
+	Functions bool
+	// Types orders the types, constants and global variables by their ids,
+	// as far as their dependencies allow, and the debug names and
+	// decorations by the ids they apply to
+	Types bool
+}
+
+// CanonicalizeAll renumbers the ids and orders everything that can be
+var CanonicalizeAll = CanonicalizeOptions{Functions: true, Types: true}
+
+// Canonicalize renumbers the ids of a module deterministically, like
+// spirv-remap, so that identical shaders end up as identical bytes however
+// they were numbered and a small change to a shader only renumbers the ids
+// it touches. Ids are hashed from names where there are any and from what
+// they are otherwise, and spread over a space a few times larger than the
+// number of ids, so the bound is higher than it needs to be.
+func Canonicalize(spirv []byte, options CanonicalizeOptions) ([]byte, error) {
+	m, err := parseSPIRVModule(spirv)
+	if err != nil {
+		return nil, err
+	}
+	c := newCanonicalizer(m)
+	ids := c.ids()
+	bound := uint32(1)
+	for _, id := range ids {
+		if id >= bound {
+			bound = id + 1
+		}
+	}
+	m.remap(func(id uint32) uint32 {
+		return ids[id]
+	})
+	m.Bound = bound
+
+	if options.Functions || options.Types {
+		sections := m.sections()
+		if options.Functions {
+			sections[spirvFunctions] = orderFunctions(sections[spirvFunctions])
+		}
+		if options.Types {
+			sections[spirvGlobals] = orderGlobals(sections[spirvGlobals])
+			sortByTarget(sections[spirvDebugNames])
+			if !hasDecorationGroups(sections[spirvAnnotations]) {
+				sortByTarget(sections[spirvAnnotations])
+			}
+		}
+		m.setSections(sections)
+	}
+	return m.bytes(), nil
+}
+
+// canonicalWindow is how many instructions either side of an unnamed
+// function local result are hashed along with it
+const canonicalWindow = 2
+
+// canonicalLocal is what an id local to a function is hashed from
+type canonicalLocal struct {
+	function uint32
+	window   []uint32
+}
+
+// canonicalizer hashes every id of a module
+type canonicalizer struct {
+	defs        map[uint32]*spirvModuleInstruction
+	names       map[uint32]string
+	decorations map[uint32][]*spirvModuleInstruction
+	entryPoints map[uint32][][]uint32
+	locals      map[uint32]canonicalLocal
+	// bodies holds the opcodes of every function
+	bodies map[uint32][]uint32
+	// order holds every id in the order it first appears
+	order    []uint32
+	hashes   map[uint32]uint64
+	visiting map[uint32]bool
+}
+
+func newCanonicalizer(m *spirvModule) *canonicalizer {
+	c := &canonicalizer{
+		defs:        make(map[uint32]*spirvModuleInstruction),
+		names:       make(map[uint32]string),
+		decorations: make(map[uint32][]*spirvModuleInstruction),
+		entryPoints: make(map[uint32][][]uint32),
+		locals:      make(map[uint32]canonicalLocal),
+		bodies:      make(map[uint32][]uint32),
+		hashes:      make(map[uint32]uint64),
+		visiting:    make(map[uint32]bool),
+	}
+	seen := make(map[uint32]bool)
+	var function uint32
+	var body []*spirvModuleInstruction
+	for _, inst := range m.Instructions {
+		for _, offset := range inst.IDs {
+			if id := inst.Words[offset]; !seen[id] {
+				seen[id] = true
+				c.order = append(c.order, id)
+			}
+		}
+		if id := inst.result(); id != 0 {
+			c.defs[id] = inst
+		}
+		switch inst.Opcode {
+		case opName:
+			c.names[inst.Words[1]], _ = spirvString(inst.Words[2:])
+		case opDecorate, opMemberDecorate, opDecorateID, opDecorateString, opMemberDecorateString:
+			c.decorations[inst.Words[1]] = append(c.decorations[inst.Words[1]], inst)
+		case opEntryPoint:
+			// The execution model and name, not the interface
+			_, n := spirvString(inst.Words[3:])
+			c.entryPoints[inst.Words[2]] = append(c.entryPoints[inst.Words[2]], inst.Words[1:2], inst.Words[3:3+n])
+		case opFunction:
+			function, body = inst.result(), nil
+		case opFunctionEnd:
+			c.addLocals(function, body)
+			function = 0
+		case opLine, opNoLine:
+			// Lines come and go with debug information
+		default:
+			if function != 0 {
+				body = append(body, inst)
+			}
+		}
+	}
+	return c
+}
+
+// addLocals records what the results in the body of a function are hashed
+// from, the opcodes around them
+func (c *canonicalizer) addLocals(function uint32, body []*spirvModuleInstruction) {
+	opcodes := make([]uint32, len(body))
+	for i, inst := range body {
+		opcodes[i] = inst.Opcode
+	}
+	c.bodies[function] = opcodes
+	for i, inst := range body {
+		if id := inst.result(); id != 0 {
+			start, end := i-canonicalWindow, i+canonicalWindow+1
+			if start < 0 {
+				start = 0
+			}
+			if end > len(opcodes) {
+				end = len(opcodes)
+			}
+			c.locals[id] = canonicalLocal{function: function, window: opcodes[start:end]}
+		}
+	}
+}
+
+// canonicalHash accumulates a hash
+type canonicalHash struct {
+	words []uint32
+}
+
+func (h *canonicalHash) add(words ...uint32) {
+	h.words = append(h.words, words...)
+}
+
+func (h *canonicalHash) addString(s string) {
+	h.add(uint32(len(s)))
+	for i := 0; i < len(s); i++ {
+		h.add(uint32(s[i]))
+	}
+}
+
+func (h *canonicalHash) addHash(v uint64) {
+	h.add(uint32(v), uint32(v>>32))
+}
+
+func (h *canonicalHash) sum() uint64 {
+	f := fnv.New64a()
+	b := make([]byte, 4)
+	for _, w := range h.words {
+		binary.LittleEndian.PutUint32(b, w)
+		f.Write(b)
+	}
+	return f.Sum64()
+}
+
+// hash returns the hash of an id
+func (c *canonicalizer) hash(id uint32) uint64 {
+	if v, ok := c.hashes[id]; ok {
+		return v
+	}
+	inst := c.defs[id]
+	h := &canonicalHash{}
+	switch {
+	case inst == nil:
+		// Undefined ids are only told apart by where they appear
+	case c.visiting[id]:
+		// Cycles, through forward pointers, are cut at the type
+		h.add(inst.Opcode)
+		return h.sum()
+	default:
+		c.visiting[id] = true
+		c.hashDefinition(h, id, inst)
+		delete(c.visiting, id)
+	}
+	v := h.sum()
+	c.hashes[id] = v
+	return v
+}
+
+func (c *canonicalizer) hashDefinition(h *canonicalHash, id uint32, inst *spirvModuleInstruction) {
+	h.add(inst.Opcode)
+	name, named := c.names[id]
+	if local, ok := c.locals[id]; ok {
+		h.addHash(c.hash(local.function))
+		if inst.Result == 2 {
+			h.addHash(c.hash(inst.Words[1]))
+		}
+		if named {
+			h.addString(name)
+		} else {
+			h.add(local.window...)
+		}
+		return
+	}
+	if named {
+		h.addString(name)
+		return
+	}
+	if inst.Opcode == opFunction {
+		h.add(inst.Words[3])
+		h.addHash(c.hash(inst.Words[4]))
+		if entryPoints, ok := c.entryPoints[id]; ok {
+			for _, words := range entryPoints {
+				h.add(words...)
+			}
+		} else {
+			h.add(c.bodies[id]...)
+		}
+		return
+	}
+	c.hashOperands(h, inst)
+
+	decorations := make([]uint64, len(c.decorations[id]))
+	for i, d := range c.decorations[id] {
+		dh := &canonicalHash{}
+		dh.add(d.Opcode)
+		c.hashOperands(dh, d)
+		decorations[i] = dh.sum()
+	}
+	sort.Slice(decorations, func(i, j int) bool { return decorations[i] < decorations[j] })
+	for _, d := range decorations {
+		h.addHash(d)
+	}
+}
+
+// hashOperands adds the operands of an instruction, other than its result
+// and the target of decorations, with ids replaced by their hashes
+func (c *canonicalizer) hashOperands(h *canonicalHash, inst *spirvModuleInstruction) {
+	ids := make(map[int]bool, len(inst.IDs))
+	for _, offset := range inst.IDs {
+		ids[offset] = true
+	}
+	target := spirvTarget(inst) != 0
+	for offset := 1; offset < len(inst.Words); offset++ {
+		switch {
+		case offset == inst.Result, offset == 1 && target:
+		case ids[offset]:
+			h.addHash(c.hash(inst.Words[offset]))
+		default:
+			h.add(inst.Words[offset])
+		}
+	}
+}
+
+// ids returns the new id of every id. Ids are placed by their hash, the
+// next free id is taken when it collides, in order of their hashes and
+// then of where they first appear so that collisions resolve the same way
+// every time.
+func (c *canonicalizer) ids() map[uint32]uint32 {
+	order := append([]uint32(nil), c.order...)
+	hashes := make(map[uint32]uint64, len(order))
+	for _, id := range order {
+		hashes[id] = c.hash(id)
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return hashes[order[i]] < hashes[order[j]]
+	})
+	space := canonicalIDSpace(len(order))
+	taken := make([]bool, space+1)
+	ids := make(map[uint32]uint32, len(order))
+	for _, id := range order {
+		n := 1 + hashes[id]%space
+		for taken[n] {
+			n = n%space + 1
+		}
+		taken[n] = true
+		ids[id] = uint32(n)
+	}
+	return ids
+}
+
+// canonicalIDSpaces are the primes ids are spread over, the space only
+// grows when a module has a lot more ids so most edits keep it
+var canonicalIDSpaces = []uint64{4093, 8191, 16381, 32749, 65521, 131071, 262139, 524287, 1048573, 2097143, 4194301}
+
+// canonicalIDSpace returns the number of ids n ids are spread over
+func canonicalIDSpace(n int) uint64 {
+	for _, space := range canonicalIDSpaces {
+		if space >= uint64(n)*4 {
+			return space
+		}
+	}
+	return uint64(n)*4 + 1
+}
+
+// orderFunctions orders functions by their ids, declarations come first as
+// they have to
+func orderFunctions(section []*spirvModuleInstruction) []*spirvModuleInstruction {
+	type function struct {
+		id          uint32
+		declaration bool
+		insts       []*spirvModuleInstruction
+	}
+	var functions []*function
+	var f *function
+	for _, inst := range section {
+		if inst.Opcode == opFunction || f == nil {
+			f = &function{id: inst.result(), declaration: true}
+			functions = append(functions, f)
+		}
+		if inst.Opcode == opLabel {
+			f.declaration = false
+		}
+		f.insts = append(f.insts, inst)
+	}
+	sort.SliceStable(functions, func(i, j int) bool {
+		if functions[i].declaration != functions[j].declaration {
+			return functions[i].declaration
+		}
+		return functions[i].id < functions[j].id
+	})
+	ordered := section[:0:0]
+	for _, f := range functions {
+		ordered = append(ordered, f.insts...)
+	}
+	return ordered
+}
+
+// globalQueue is a priority queue of global instructions by result id
+type globalQueue []*spirvModuleInstruction
+
+func (q globalQueue) Len() int            { return len(q) }
+func (q globalQueue) Less(i, j int) bool  { return q[i].result() < q[j].result() }
+func (q globalQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *globalQueue) Push(x interface{}) { *q = append(*q, x.(*spirvModuleInstruction)) }
+func (q *globalQueue) Pop() interface{} {
+	old := *q
+	inst := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return inst
+}
+
+// orderGlobals orders types, constants and global variables by their ids
+// while keeping every one after what it uses. Sections with forward
+// pointers or lines are left alone.
+func orderGlobals(section []*spirvModuleInstruction) []*spirvModuleInstruction {
+	defined := make(map[uint32]bool, len(section))
+	for _, inst := range section {
+		if inst.Result == 0 || inst.Opcode == opTypeForwardPointer {
+			return section
+		}
+		defined[inst.result()] = true
+	}
+	waiting := make(map[*spirvModuleInstruction]int)
+	dependents := make(map[uint32][]*spirvModuleInstruction)
+	q := &globalQueue{}
+	for _, inst := range section {
+		uses := make(map[uint32]bool)
+		for _, offset := range inst.IDs {
+			if id := inst.Words[offset]; offset != inst.Result && defined[id] && !uses[id] {
+				uses[id] = true
+				dependents[id] = append(dependents[id], inst)
+			}
+		}
+		if waiting[inst] = len(uses); len(uses) == 0 {
+			heap.Push(q, inst)
+		}
+	}
+	ordered := make([]*spirvModuleInstruction, 0, len(section))
+	for q.Len() > 0 {
+		inst := heap.Pop(q).(*spirvModuleInstruction)
+		ordered = append(ordered, inst)
+		for _, d := range dependents[inst.result()] {
+			if waiting[d]--; waiting[d] == 0 {
+				heap.Push(q, d)
+			}
+		}
+	}
+	if len(ordered) != len(section) {
+		return section
+	}
+	return ordered
+}
+
+// sortByTarget sorts debug names or decorations by the id they apply to
+// and then by their operands
+func sortByTarget(section []*spirvModuleInstruction) {
+	sort.SliceStable(section, func(i, j int) bool {
+		a, b := section[i].Words, section[j].Words
+		if ta, tb := spirvTarget(section[i]), spirvTarget(section[j]); ta != tb {
+			return ta < tb
+		}
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+}
+
+// hasDecorationGroups returns true if any decorations are applied through
+// groups, which have to stay after the group's decorations
+func hasDecorationGroups(section []*spirvModuleInstruction) bool {
+	for _, inst := range section {
+		if inst.Opcode == opDecorationGroup {
+			return true
+		}
+	}
+	return false
+}
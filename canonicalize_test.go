@@ -0,0 +1,154 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gshaderc
+
+import (
+	"bytes"
+	"testing"
+)
+
+// canonicalTestModule builds the same shader with its ids renumbered by id
+// and, if reordered, its types and functions in another valid order.
+// extra adds a constant and a function using it.
+func canonicalTestModule(id func(uint32) uint32, reordered, extra bool) []byte {
+	functions := [][][]uint32{
+		{
+			{opFunction, id(2), id(11), 0, id(3)},
+			{opLabel, id(30)},
+			{62, id(20), id(8)}, // OpStore
+			{253},               // OpReturn
+			{opFunctionEnd},
+		},
+		{
+			{opFunction, id(2), id(10), 0, id(3)},
+			{opLabel, id(31)},
+			{57, id(2), id(32), id(11)}, // OpFunctionCall
+			{253},
+			{opFunctionEnd},
+		},
+	}
+	types := [][]uint32{
+		{opTypeVoid, id(2)},
+		{opTypeFunction, id(3), id(2)},
+		{opTypeFloat, id(4), 32},
+		{opTypeVector, id(5), id(4), 4},
+		{opTypePointer, id(6), storageOutput, id(5)},
+		{opConstant, id(4), id(7), 0x3f800000},
+		{opConstantComposite, id(5), id(8), id(7), id(7), id(7), id(7)},
+		{opVariable, id(6), id(20), storageOutput},
+	}
+	names := [][]uint32{
+		cat([]uint32{opName, id(10)}, testString("main")),
+		cat([]uint32{opName, id(11)}, testString("helper(")),
+		cat([]uint32{opName, id(20)}, testString("color")),
+	}
+	if extra {
+		types = append(types, []uint32{opConstant, id(4), id(9), 0x40000000})
+		functions = append(functions, [][]uint32{
+			{opFunction, id(2), id(12), 0, id(3)},
+			{opLabel, id(33)},
+			{253},
+			{opFunctionEnd},
+		})
+		names = append(names, cat([]uint32{opName, id(12)}, testString("unused(")))
+	}
+	if reordered {
+		functions[0], functions[1] = functions[1], functions[0]
+		types[0], types[2] = types[2], types[0]
+		names[0], names[2] = names[2], names[0]
+	}
+
+	insts := [][]uint32{
+		{opCapability, 1},
+		cat([]uint32{opExtInstImport, id(1)}, testString("GLSL.std.450")),
+		{opMemoryModel, 0, 1},
+		cat([]uint32{opEntryPoint, 4, id(10)}, testString("main"), []uint32{id(20)}),
+		{opExecutionMode, id(10), 7},
+	}
+	insts = append(insts, names...)
+	insts = append(insts, []uint32{opDecorate, id(20), decorationLocation, 0})
+	insts = append(insts, types...)
+	for _, f := range functions {
+		insts = append(insts, f...)
+	}
+	return testModule(insts...)
+}
+
+// canonicalNames returns the ids of the names in a module
+func canonicalNames(t *testing.T, spirv []byte) map[string]uint32 {
+	t.Helper()
+	m, err := parseSPIRVModule(spirv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]uint32)
+	for _, inst := range m.Instructions {
+		if inst.Words[0]&0xffff == opName {
+			name, _ := spirvString(inst.Words[2:])
+			names[name] = inst.Words[1]
+		}
+		for _, offset := range inst.IDs {
+			if inst.Words[offset] >= m.Bound {
+				t.Fatalf("id %d is out of the bound %d", inst.Words[offset], m.Bound)
+			}
+		}
+	}
+	return names
+}
+
+func TestCanonicalize(t *testing.T) {
+	same := func(id uint32) uint32 { return id }
+	reversed := func(id uint32) uint32 { return 50 - id }
+	canonicalize := func(spirv []byte, options CanonicalizeOptions) []byte {
+		t.Helper()
+		c, err := Canonicalize(spirv, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Disassemble(c, DisassemblyOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	original := canonicalize(canonicalTestModule(same, false, false), CanonicalizeOptions{})
+	if c := canonicalize(canonicalTestModule(reversed, false, false), CanonicalizeOptions{}); !bytes.Equal(c, original) {
+		t.Fatal("expected renumbered modules to canonicalize to the same bytes")
+	}
+	if c := canonicalize(original, CanonicalizeOptions{}); !bytes.Equal(c, original) {
+		t.Fatal("expected canonicalizing to be idempotent")
+	}
+	if c := canonicalize(canonicalTestModule(reversed, true, false), CanonicalizeOptions{}); bytes.Equal(c, original) {
+		t.Fatal("expected reordered modules to stay in their order")
+	}
+
+	ordered := canonicalize(canonicalTestModule(same, false, false), CanonicalizeAll)
+	if c := canonicalize(canonicalTestModule(reversed, true, false), CanonicalizeAll); !bytes.Equal(c, ordered) {
+		t.Fatal("expected reordered modules to canonicalize to the same bytes")
+	}
+
+	// Adding to a shader leaves the ids of the rest alone
+	names := canonicalNames(t, original)
+	extra := canonicalNames(t, canonicalize(canonicalTestModule(same, false, true), CanonicalizeOptions{}))
+	for _, name := range []string{"main", "helper(", "color"} {
+		if names[name] != extra[name] {
+			t.Fatalf("expected %s to keep id %d, got %d", name, names[name], extra[name])
+		}
+	}
+
+	if _, err := Canonicalize(debugModule("OpenCL.DebugInfo.100"), CanonicalizeAll); err == nil {
+		t.Fatal("expected an error for instructions which can't be rewritten")
+	}
+}
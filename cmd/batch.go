@@ -0,0 +1,210 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	gs "github.com/celer/gshaderc"
+)
+
+// DefaultShaderExtensions are the extensions selected when walking directories
+var DefaultShaderExtensions = []string{".vert", ".frag", ".comp", ".geom", ".tesc", ".tese"}
+
+// SourceSelector decides which files a batch build picks up
+type SourceSelector struct {
+	// Extensions of the files to select, including the dot
+	Extensions []string
+	// Ignore are glob patterns, a file or directory is skipped if its name
+	// or its slash separated path relative to the root matches any of them
+	Ignore []string
+}
+
+// Source is a shader found by a batch build, Rel is its path relative to
+// the root it was found under
+type Source struct {
+	Path string
+	Rel  string
+}
+
+func (s *SourceSelector) ignored(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = path
+	}
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range s.Ignore {
+		if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *SourceSelector) selected(path string) bool {
+	ext := filepath.Ext(path)
+	for _, e := range s.Extensions {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// globRoot returns the directory part of a glob pattern before the first
+// wildcard
+func globRoot(pattern string) string {
+	i := strings.IndexAny(pattern, "*?[")
+	if i < 0 {
+		return filepath.Dir(pattern)
+	}
+	return filepath.Dir(pattern[:i] + "x")
+}
+
+// Collect returns every shader found in the given directories, files and
+// glob patterns. Directories are walked recursively and filtered by
+// extension, files named explicitly are always included unless ignored.
+func (s *SourceSelector) Collect(args []string) ([]Source, error) {
+	var sources []Source
+	seen := make(map[string]bool)
+	add := func(root, path string) {
+		path = filepath.Clean(path)
+		if seen[path] {
+			return
+		}
+		seen[path] = true
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			rel = filepath.Base(path)
+		}
+		sources = append(sources, Source{Path: path, Rel: rel})
+	}
+
+	for _, arg := range args {
+		matches := []string{arg}
+		root := arg
+		if strings.ContainsAny(arg, "*?[") {
+			var err error
+			matches, err = filepath.Glob(arg)
+			if err != nil {
+				return nil, err
+			}
+			root = globRoot(arg)
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				if root == match {
+					root = filepath.Dir(match)
+				}
+				if !s.ignored(root, match) {
+					add(root, match)
+				}
+				continue
+			}
+
+			dirRoot := root
+			if root == arg {
+				dirRoot = match
+			}
+			err = filepath.Walk(match, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if path != match && s.ignored(dirRoot, path) {
+					if info.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if !info.IsDir() && s.selected(path) {
+					add(dirRoot, path)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return sources, nil
+}
+
+// BatchUnits turns sources into build units, outputs are written next to
+// each source or, if outDir is set, into a tree under outDir mirroring the
+// source tree
+func BatchUnits(sources []Source, config CompileConfig, outDir string) ([]*BuildUnit, error) {
+	units := make([]*BuildUnit, len(sources))
+	for i, s := range sources {
+		stage := gs.GetShaderTypeByFilename(s.Path)
+		name, err := config.OutputFileName(s.Path, stage)
+		if err != nil {
+			return nil, err
+		}
+		output := filepath.Join(filepath.Dir(s.Path), name)
+		if outDir != "" {
+			output = filepath.Join(outDir, filepath.Dir(s.Rel), name)
+		}
+		units[i] = &BuildUnit{
+			Source: s.Path,
+			Stage:  stage,
+			Config: config,
+			Output: output,
+		}
+	}
+	return units, nil
+}
+
+// compileUnits compiles units on a pool of workers and returns the number
+// which failed
+func compileUnits(compiler *gs.Compiler, units []*BuildUnit, workers int, compile func(*gs.Compiler, *BuildUnit) error) int {
+	if workers < 1 {
+		workers = 1
+	}
+	var mu sync.Mutex
+	failed := 0
+
+	work := make(chan *BuildUnit)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for unit := range work {
+				if err := compile(compiler, unit); err != nil {
+					mu.Lock()
+					failed++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, unit := range units {
+		work <- unit
+	}
+	close(work)
+	wg.Wait()
+	return failed
+}
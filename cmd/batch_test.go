@@ -0,0 +1,101 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	gs "github.com/celer/gshaderc"
+)
+
+func TestBatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"shaders/a.vert":             "#version 450\nvoid main() {}\n",
+		"shaders/post/b.frag":        "#version 450\nvoid main() {}\n",
+		"shaders/post/broken.frag":   "void main() {}\n",
+		"shaders/post/notes.txt":     "",
+		"shaders/vendor/c.frag":      "",
+		"shaders/post/b.frag.bak":    "",
+		"other/d.comp":               "#version 450\nvoid main() {}\n",
+		"other/nested/skip/e.comp":   "",
+		"other/nested/keep/f.custom": "",
+	})
+
+	selector := &SourceSelector{
+		Extensions: DefaultShaderExtensions,
+		Ignore:     []string{"vendor", "broken.*", "nested/skip"},
+	}
+	sources, err := selector.Collect([]string{
+		filepath.Join(dir, "shaders"),
+		filepath.Join(dir, "other", "*.comp"),
+		filepath.Join(dir, "shaders", "a.vert"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rels []string
+	for _, s := range sources {
+		rels = append(rels, filepath.ToSlash(s.Rel))
+	}
+	sort.Strings(rels)
+	if !reflect.DeepEqual(rels, []string{"a.vert", "d.comp", "post/b.frag"}) {
+		t.Fatalf("unexpected sources %v", rels)
+	}
+
+	out := filepath.Join(dir, "build")
+	units, err := BatchUnits(sources, CompileConfig{}, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range units {
+		if u.Source == filepath.Join(dir, "shaders/post/b.frag") {
+			if u.Output != filepath.Join(out, "post/b.frag.spv") || u.Stage != gs.FragmentShader {
+				t.Fatalf("unexpected unit %+v", u)
+			}
+		}
+	}
+
+	broken, err := BatchUnits([]Source{{Path: filepath.Join(dir, "shaders/post/broken.frag"), Rel: "broken.frag"}}, CompileConfig{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	units = append(units, broken...)
+
+	compiler := gs.NewCompilerWithBackend(gs.NewFakeBackend())
+	defer compiler.Release()
+	failed := compileUnits(compiler, units, 4, compileUnit)
+	if failed != 1 {
+		t.Fatalf("expected 1 failure, got %d", failed)
+	}
+	for _, u := range units[:3] {
+		if _, err := os.Stat(u.Output); err != nil {
+			t.Fatalf("expected output for %s: %v", u.Source, err)
+		}
+	}
+	if _, err := os.Stat(broken[0].Output); err == nil {
+		t.Fatalf("didn't expect output for %s", broken[0].Source)
+	}
+}
@@ -0,0 +1,464 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	gs "github.com/celer/gshaderc"
+	"github.com/celer/gshaderc/hotreload"
+)
+
+// unitFlags are the flags choosing what to compile, shared by 'gsc build'
+// and 'gsc check'
+type unitFlags struct {
+	manifestPath string
+	outDir       string
+	extensions   StringList
+	ignore       StringList
+	config       CompileConfig
+}
+
+func addUnitFlags(flags *flag.FlagSet) *unitFlags {
+	u := &unitFlags{}
+	flags.StringVar(&u.manifestPath, "manifest", "", "project manifest (default gsc.json or gsc.toml in the current directory)")
+	flags.StringVar(&u.outDir, "out-dir", "", "outputs go into this directory, mirroring the source tree, instead of next to each source")
+	flags.Var(&u.extensions, "ext", "extension of the shaders to compile when walking directories, may be repeated (default "+strings.Join(DefaultShaderExtensions, ", ")+")")
+	flags.Var(&u.ignore, "ignore", "skip files and directories matching this glob pattern, may be repeated")
+	addConfigFlags(flags, &u.config)
+	return u
+}
+
+// selector returns the selector for the -ext and -ignore flags
+func (u *unitFlags) selector() *SourceSelector {
+	selector := &SourceSelector{Extensions: u.extensions, Ignore: u.ignore}
+	if len(selector.Extensions) == 0 {
+		selector.Extensions = DefaultShaderExtensions
+	}
+	return selector
+}
+
+// units returns the units to compile, the shaders found in args or, without
+// args, those in the manifest. If that fails it returns the exit code.
+func (u *unitFlags) units(args []string) ([]*BuildUnit, int) {
+	if len(args) > 0 {
+		sources, err := u.selector().Collect(args)
+		if err != nil {
+			log.Printf("error: %v", err)
+			return nil, ExitRead
+		}
+		if _, err := u.config.Options(); err != nil {
+			log.Printf("error: %v", err)
+			return nil, ExitConfig
+		}
+		units, err := BatchUnits(sources, u.config, u.outDir)
+		if err != nil {
+			log.Printf("error: %v", err)
+			return nil, ExitConfig
+		}
+		return units, ExitOK
+	}
+
+	// Which shaders a manifest compiles and where their outputs go is up
+	// to the manifest
+	for _, f := range []struct {
+		name  string
+		given bool
+	}{{"-ext", len(u.extensions) > 0}, {"-ignore", len(u.ignore) > 0}, {"-out-dir", u.outDir != ""}} {
+		if f.given {
+			log.Printf("error: %s can only be used with shaders given as arguments, not with a manifest", f.name)
+			return nil, ExitUsage
+		}
+	}
+
+	manifestPath := u.manifestPath
+	if manifestPath == "" {
+		p, err := FindManifest(".")
+		if err != nil {
+			log.Printf("error: %v", err)
+			return nil, ExitUsage
+		}
+		manifestPath = p
+	}
+
+	manifest, err := LoadManifest(manifestPath)
+	if err != nil {
+		log.Printf("error: %v", err)
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) {
+			return nil, ExitRead
+		}
+		return nil, ExitConfig
+	}
+	units, err := manifest.Units()
+	if err != nil {
+		log.Printf("error: %v", err)
+		return nil, ExitConfig
+	}
+	for _, unit := range units {
+		unit.Config = unit.Config.Merge(u.config)
+	}
+	return units, ExitOK
+}
+
+// buildMain implements 'gsc build'
+func buildMain(args []string) int {
+	flags := flag.NewFlagSet("build", flag.ContinueOnError)
+	workers := flags.Int("j", runtime.NumCPU(), "number of shaders to compile concurrently")
+	diagnosticsFormat := flags.String("diagnostics-format", "text", "how errors and warnings are printed to stdout: "+strings.Join(DiagnosticsFormats, ", "))
+	depfiles := flags.Bool("MD", false, "write a Makefile depfile, <output>.d, next to every output listing the source and everything it includes")
+	keepDebug := flags.Bool("keep-debug", false, "with -strip also write the unstripped SPIR-V of every output to <output name>.debug.spv")
+	selection := addUnitFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: gsc build [flags] [dirs/files/globs...]\n\n")
+		fmt.Fprintf(flags.Output(), "without arguments compiles every shader described by the project manifest,\n")
+		fmt.Fprintf(flags.Output(), "otherwise compiles every shader found in the given directories, files and globs.\n")
+		fmt.Fprintf(flags.Output(), "compile flags override the settings in the manifest\n\n")
+		flags.PrintDefaults()
+	}
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	diagnostics, err := NewDiagnosticsReporter(*diagnosticsFormat, os.Stdout)
+	if err != nil {
+		log.Printf("error: %v", err)
+		return ExitUsage
+	}
+
+	units, code := selection.units(flags.Args())
+	if code != ExitOK {
+		return code
+	}
+
+	for _, unit := range units {
+		if *depfiles {
+			unit.Depfile = depfileName(unit.Output)
+		}
+		if *keepDebug {
+			keepDebugOutput(unit)
+		}
+	}
+
+	compiler := gs.NewCompiler()
+	defer compiler.Release()
+
+	start := time.Now()
+	var mu sync.Mutex
+	failed := compileUnits(compiler, units, *workers, func(compiler *gs.Compiler, unit *BuildUnit) error {
+		err := compileUnitReporting(compiler, unit, diagnostics)
+		if err != nil {
+			log.Printf("error compiling shader '%s': %v", unit.Source, err)
+			mu.Lock()
+			code = worseExitCode(code, exitCode(err))
+			mu.Unlock()
+		}
+		return err
+	})
+
+	log.Printf("%d shaders compiled, %d failed in %v", len(units)-failed, failed, time.Since(start).Round(time.Millisecond))
+	if err := diagnostics.Close(); err != nil {
+		log.Printf("error: %v", err)
+		code = worseExitCode(code, ExitWrite)
+	}
+	return code
+}
+
+// Builder compiles shaders with a single configuration, using one compiler
+// and one set of options. One-shot compiles and watch mode both go through
+// a Builder so that they produce exactly the same output.
+type Builder struct {
+	Config   CompileConfig
+	Variants *gs.VariantSet
+	// Notify, if set, is called with the outcome of every output compiled
+	Notify func(hotreload.Event)
+	// Depfiles writes a depfile next to every output, see BuildUnit.Depfile
+	Depfiles bool
+	// Diagnostics receives the errors and warnings of every compile, nil
+	// prints compiler errors to stdout
+	Diagnostics *DiagnosticsReporter
+
+	compiler *gs.Compiler
+	options  *gs.CompilerOptions
+	format   *OutputFormat
+	post     PostProcessing
+}
+
+// NewBuilder checks the configuration and creates a builder which compiles
+// with compiler, variants may be nil
+func NewBuilder(compiler *gs.Compiler, config CompileConfig, variants *gs.VariantSet) (*Builder, error) {
+	options, err := config.Options()
+	if err != nil {
+		return nil, err
+	}
+	format, err := ParseOutputFormat(config.Format)
+	if err != nil {
+		return nil, err
+	}
+	post, err := config.PostProcessing()
+	if err != nil {
+		return nil, err
+	}
+	if variants != nil && format.Kind != gs.OutputSPV {
+		return nil, fmt.Errorf("variants can't be written as %s", format.Name)
+	}
+	if variants != nil {
+		if err := validateVariants(variants); err != nil {
+			return nil, err
+		}
+	}
+	return &Builder{
+		Config:   config,
+		Variants: variants,
+		compiler: compiler,
+		options:  options,
+		format:   format,
+		post:     post,
+	}, nil
+}
+
+// Unit returns the unit which compiles source into output, if output is
+// empty it's named by the output name template and written next to source
+func (b *Builder) Unit(source, output string) (*BuildUnit, error) {
+	unit := &BuildUnit{
+		Source:   source,
+		Stage:    gs.GetShaderTypeByFilename(source),
+		Config:   b.Config,
+		Output:   output,
+		Variants: b.Variants,
+	}
+	if unit.Output == "" {
+		name, err := b.Config.OutputFileName(source, unit.Stage)
+		if err != nil {
+			return nil, err
+		}
+		unit.Output = filepath.Join(filepath.Dir(source), name)
+	}
+	if b.Depfiles {
+		unit.Depfile = depfileName(unit.Output)
+	}
+	return unit, nil
+}
+
+// Compile compiles a unit returned by Unit
+func (b *Builder) Compile(unit *BuildUnit) error {
+	return compileUnitWith(b.compiler, b.options, b.format, b.post, unit, b.Notify, b.Diagnostics)
+}
+
+// compileUnit compiles a single unit, and all of its variants, writing the
+// outputs. Compiler errors are written to stdout.
+func compileUnit(compiler *gs.Compiler, unit *BuildUnit) error {
+	return compileUnitReporting(compiler, unit, nil)
+}
+
+// compileUnitReporting is like compileUnit but reports diagnostics to
+// diagnostics
+func compileUnitReporting(compiler *gs.Compiler, unit *BuildUnit, diagnostics *DiagnosticsReporter) error {
+	options, err := unit.Config.Options()
+	if err == nil {
+		var format *OutputFormat
+		format, err = ParseOutputFormat(unit.Config.Format)
+		if err == nil {
+			var post PostProcessing
+			post, err = unit.Config.PostProcessing()
+			if err == nil {
+				return compileUnitWith(compiler, options, format, post, unit, nil, diagnostics)
+			}
+		}
+	}
+	diagnostics.Error(unit, "", err)
+	return withExitCode(ExitConfig, err)
+}
+
+// hash returns the hex encoded SHA-256 of data
+func hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func compileUnitWith(compiler *gs.Compiler, options *gs.CompilerOptions, format *OutputFormat, post PostProcessing, unit *BuildUnit, notify func(hotreload.Event), diagnostics *DiagnosticsReporter) error {
+	if notify == nil {
+		notify = func(hotreload.Event) {}
+	}
+	// configError reports an error in the configuration of the unit
+	configError := func(err error) error {
+		diagnostics.Error(unit, "", err)
+		return withExitCode(ExitConfig, err)
+	}
+	var recorder *gs.IncludeRecorder
+	if unit.Depfile != "" {
+		if unit.Output == "-" {
+			return configError(fmt.Errorf("a depfile can't be written for output to stdout"))
+		}
+		options = options.Clone()
+		recorder = gs.NewIncludeRecorder(options.IncludeResolver())
+		options.SetIncludeCallback(recorder.Resolve)
+	}
+	data, err := ioutil.ReadFile(unit.Source)
+	if err != nil {
+		notify(hotreload.Event{Source: unit.Source, Diagnostics: err.Error()})
+		diagnostics.Error(unit, "", err)
+		return withExitCode(ExitRead, err)
+	}
+	info := &outputInfo{source: unit.Source, symbol: unit.Symbol, pkg: unit.Config.Package}
+
+	if unit.Variants != nil {
+		if format.Kind != gs.OutputSPV {
+			return configError(fmt.Errorf("variants can't be written as %s", format.Name))
+		}
+		if unit.Output == "-" {
+			return configError(fmt.Errorf("variants can't be written to stdout"))
+		}
+		results := compiler.CompileVariants(string(data), unit.Stage, unit.Source, unit.Config.GetEntryPoint(), options, unit.Variants)
+		var outputs []string
+		// A variant failing doesn't stop the rest being written, the worst
+		// failure is returned once they all have been
+		var failure error
+		for _, key := range results.Keys {
+			res := results.Results[key]
+			if res.Err != nil {
+				diagnostics.Compiler(unit, key, res.ErrorMessage, true)
+				notify(hotreload.Event{Source: unit.Source, Variant: key, Diagnostics: res.ErrorMessage})
+				continue
+			}
+			output := variantOutputName(unit.Output, key)
+			diagnostics.Compiler(unit, key, res.ErrorMessage, false)
+			spirv, err := postProcess(unit, post, key, res.Data)
+			if err == nil {
+				err = withExitCode(ExitWrite, writeOutput(format, output, spirv, info.forVariant(key)))
+			}
+			if err != nil {
+				notify(hotreload.Event{Source: unit.Source, Variant: key, Diagnostics: err.Error()})
+				diagnostics.Error(unit, key, err)
+				failure = worseError(failure, err)
+				continue
+			}
+			notify(hotreload.Event{Source: unit.Source, Output: output, Variant: key, Success: true, Diagnostics: res.ErrorMessage, Hash: hash(spirv)})
+			outputs = append(outputs, output)
+			if res.DuplicateOf != "" {
+				log.Printf("compiled %s [%s] -> %s (identical to '%s')", unit.Source, key, output, res.DuplicateOf)
+			} else {
+				log.Printf("compiled %s [%s] -> %s", unit.Source, key, output)
+			}
+		}
+		log.Printf("%s: %d variants, %d unique", unit.Source, len(results.Keys), len(results.Unique()))
+		if len(outputs) > 0 {
+			if err := writeDepfile(unit, outputs, recorder); err != nil {
+				diagnostics.Error(unit, "", err)
+				failure = worseError(failure, withExitCode(ExitWrite, err))
+			}
+		}
+		return worseError(failure, results.Err())
+	}
+
+	result := format.Compile(compiler, string(data), unit.Stage, unit.Source, unit.Config.GetEntryPoint(), options)
+	defer result.Release()
+	if result.Error() != nil {
+		diagnostics.Compiler(unit, "", result.ErrorMessage(), true)
+		notify(hotreload.Event{Source: unit.Source, Diagnostics: result.ErrorMessage()})
+		return result.Error()
+	}
+	diagnostics.Compiler(unit, "", result.ErrorMessage(), false)
+	output := result.Bytes()
+	if format.Kind == gs.OutputSPV {
+		if output, err = postProcess(unit, post, "", output); err != nil {
+			notify(hotreload.Event{Source: unit.Source, Diagnostics: err.Error()})
+			diagnostics.Error(unit, "", err)
+			return err
+		}
+	}
+	if err := writeOutput(format, unit.Output, output, info); err != nil {
+		notify(hotreload.Event{Source: unit.Source, Diagnostics: err.Error()})
+		diagnostics.Error(unit, "", err)
+		return withExitCode(ExitWrite, err)
+	}
+	notify(hotreload.Event{Source: unit.Source, Output: unit.Output, Success: true, Diagnostics: result.ErrorMessage(), Hash: hash(output)})
+	log.Printf("compiled %s -> %s", unit.Source, unit.Output)
+	if err := writeDepfile(unit, []string{unit.Output}, recorder); err != nil {
+		diagnostics.Error(unit, "", err)
+		return withExitCode(ExitWrite, err)
+	}
+	return nil
+}
+
+// postProcess writes the debug output of a unit's variant, if it has one,
+// and applies the post-processing of its config to the compiled SPIR-V
+func postProcess(unit *BuildUnit, post PostProcessing, key string, spirv []byte) ([]byte, error) {
+	if unit.DebugOutput != "" {
+		output := variantOutputName(unit.DebugOutput, key)
+		if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+			return nil, withExitCode(ExitWrite, err)
+		}
+		if err := ioutil.WriteFile(output, spirv, 0644); err != nil {
+			return nil, withExitCode(ExitWrite, err)
+		}
+	}
+	processed, err := post.Apply(spirv)
+	if err != nil {
+		return nil, fmt.Errorf("post-processing '%s': %w", unit.Source, err)
+	}
+	return processed, nil
+}
+
+// keepDebugOutput gives a unit the debug output of -keep-debug if it strips
+// SPIR-V written to a file
+func keepDebugOutput(unit *BuildUnit) {
+	format, err := ParseOutputFormat(unit.Config.Format)
+	if err == nil && format.Kind == gs.OutputSPV && unit.Config.Strip != "" && unit.Output != "-" {
+		unit.DebugOutput = debugOutputName(unit.Output)
+	}
+}
+
+// debugOutputName returns the debug output written for an output, the
+// output's extension is replaced with .debug.spv
+func debugOutputName(output string) string {
+	return strings.TrimSuffix(output, filepath.Ext(output)) + ".debug.spv"
+}
+
+// depfileName returns the depfile written for an output when one isn't
+// named explicitly
+func depfileName(output string) string {
+	return output + ".d"
+}
+
+// writeDepfile writes the depfile of a unit, if it has one, listing the
+// source and every include recorded as dependencies of the outputs
+func writeDepfile(unit *BuildUnit, outputs []string, recorder *gs.IncludeRecorder) error {
+	if unit.Depfile == "" {
+		return nil
+	}
+	var buf bytes.Buffer
+	deps := append([]string{unit.Source}, recorder.Includes()...)
+	if err := gs.WriteDepfile(&buf, outputs, deps); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(unit.Depfile), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(unit.Depfile, buf.Bytes(), 0644)
+}
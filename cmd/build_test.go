@@ -0,0 +1,219 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gs "github.com/celer/gshaderc"
+)
+
+func TestBuilderDepfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"a.frag":                "#version 450\n#include \"common.glsl\"\n#include <lighting.glsl>\nvoid main() {}\n",
+		"common.glsl":           "#include <lighting.glsl>\n",
+		"include/lighting.glsl": "float light;\n",
+	})
+	source := filepath.Join(dir, "a.frag")
+	common := filepath.Join(dir, "common.glsl")
+	lighting := filepath.Join(dir, "include/lighting.glsl")
+
+	compiler := gs.NewCompilerWithBackend(gs.NewFakeBackend())
+	defer compiler.Release()
+	builder, err := NewBuilder(compiler, CompileConfig{IncludePaths: []string{filepath.Join(dir, "include")}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder.Depfiles = true
+
+	depfile := func(unit *BuildUnit) string {
+		t.Helper()
+		if err := builder.Compile(unit); err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadFile(unit.Depfile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	unit, err := builder.Unit(source, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unit.Depfile != source+".spv.d" {
+		t.Fatalf("unexpected depfile name %s", unit.Depfile)
+	}
+	expected := source + ".spv: \\\n  " + source + " \\\n  " + common + " \\\n  " + lighting + "\n"
+	if got := depfile(unit); got != expected {
+		t.Fatalf("unexpected depfile %q", got)
+	}
+
+	// Every variant is a target
+	builder.Variants = &gs.VariantSet{Axes: []gs.VariantAxis{{Name: "FOG", Values: []string{""}, Optional: true}}}
+	unit, err = builder.Unit(source, filepath.Join(dir, "out/a.spv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit.Depfile = filepath.Join(dir, "deps/a.d")
+	expected = filepath.Join(dir, "out/a.spv") + " " + filepath.Join(dir, "out/a.FOG.spv") + ": \\\n  " + source + " \\\n  " + common + " \\\n  " + lighting + "\n"
+	if got := depfile(unit); got != expected {
+		t.Fatalf("unexpected variant depfile %q", got)
+	}
+
+	unit.Output = "-"
+	if err := builder.Compile(unit); err == nil {
+		t.Fatal("expected an error writing a depfile for stdout")
+	}
+}
+
+func TestBuilderStrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{"a.frag": "#version 450\nvoid main() {}\n"})
+	source := filepath.Join(dir, "a.frag")
+
+	compiler := gs.NewCompilerWithBackend(gs.NewFakeBackend())
+	defer compiler.Release()
+	builder, err := NewBuilder(compiler, CompileConfig{DebugInfo: switchOn(), Strip: "sources"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit, err := builder.Unit(source, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit.DebugOutput = debugOutputName(unit.Output)
+	if unit.DebugOutput != source+".debug.spv" {
+		t.Fatalf("unexpected debug output name %s", unit.DebugOutput)
+	}
+	if err := builder.Compile(unit); err != nil {
+		t.Fatal(err)
+	}
+	stripped, err := ioutil.ReadFile(unit.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	debug, err := ioutil.ReadFile(unit.DebugOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, err := gs.Strip(debug, gs.StripOptions{Sources: true}); err != nil || !bytes.Equal(stripped, expected) {
+		t.Fatalf("expected the output to be the stripped debug output (%v)", err)
+	}
+	if len(stripped) >= len(debug) {
+		t.Fatal("expected the OpSourceExtension to be stripped")
+	}
+
+	for _, config := range []CompileConfig{{Strip: "names,symbols"}, {Strip: "all", Format: "spvasm"}} {
+		if _, err := NewBuilder(compiler, config, nil); err == nil {
+			t.Fatalf("expected %+v to be rejected", config)
+		}
+	}
+}
+
+func TestUnitFlagsManifest(t *testing.T) {
+	for _, u := range []*unitFlags{
+		{manifestPath: "gsc.json", outDir: "build"},
+		{manifestPath: "gsc.json", extensions: StringList{".frag"}},
+		{manifestPath: "gsc.json", ignore: StringList{"*.glsl"}},
+	} {
+		if _, code := u.units(nil); code != ExitUsage {
+			t.Fatalf("expected %+v to be a usage error with a manifest, got %d", u, code)
+		}
+	}
+}
+
+func TestVariantFailures(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{"a.frag": "#version 450\n#ifdef BROKEN\n#error broken\n#endif\nvoid main() {}\n"})
+	source := filepath.Join(dir, "a.frag")
+
+	compiler := gs.NewCompilerWithBackend(gs.NewFakeBackend())
+	defer compiler.Release()
+	variants := &gs.VariantSet{Axes: []gs.VariantAxis{{Name: "FOG", Values: []string{""}, Optional: true}}}
+	builder, err := NewBuilder(compiler, CompileConfig{}, variants)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The base variant can't be written but the FOG variant still is
+	unit, err := builder.Unit(source, filepath.Join(dir, "out/a.spv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(unit.Output, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := builder.Compile(unit); exitCode(err) != ExitWrite {
+		t.Fatalf("expected a write error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out/a.FOG.spv")); err != nil {
+		t.Fatal("expected the FOG variant to be written")
+	}
+
+	// The BROKEN variant doesn't compile but the base variant is still
+	// checked
+	unit = &BuildUnit{
+		Source:   source,
+		Stage:    gs.FragmentShader,
+		Output:   filepath.Join(dir, "check/a.spv"),
+		Variants: &gs.VariantSet{Axes: []gs.VariantAxis{{Name: "BROKEN", Values: []string{""}, Optional: true}}},
+	}
+	checked, err := checkUnit(compiler, unit, false, nil)
+	if exitCode(err) != ExitCompile {
+		t.Fatalf("expected a compile error, got %v", err)
+	}
+	if len(checked) != 1 || checked[0].Output != unit.Output || checked[0].Status != OutputMissing {
+		t.Fatalf("unexpected outputs checked %+v", checked)
+	}
+}
+
+func TestVariantOutputNames(t *testing.T) {
+	// 1.5 and 1_5 are different macros but the same file name
+	if _, err := parseVariantSet([]string{"SCALE=1.5,1_5"}, nil); err == nil {
+		t.Fatal("expected variants written to the same file to be rejected")
+	}
+	set, err := parseVariantSet([]string{"SCALE=1.5,2"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compiler := gs.NewCompilerWithBackend(gs.NewFakeBackend())
+	defer compiler.Release()
+	if _, err := NewBuilder(compiler, CompileConfig{}, set); err != nil {
+		t.Fatal(err)
+	}
+	set.Axes[0].Values = append(set.Axes[0].Values, "1_5")
+	if _, err := NewBuilder(compiler, CompileConfig{}, set); err == nil {
+		t.Fatal("expected the builder to reject variants written to the same file")
+	}
+}
@@ -0,0 +1,410 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	gs "github.com/celer/gshaderc"
+)
+
+// OutputStatus is the result of checking an output against its source
+type OutputStatus string
+
+const (
+	OutputUpToDate OutputStatus = "ok"
+	// OutputStale outputs differ from what their source compiles to
+	OutputStale OutputStatus = "stale"
+	// OutputMissing outputs don't exist
+	OutputMissing OutputStatus = "missing"
+	// OutputOrphaned outputs exist but no source compiles to them
+	OutputOrphaned OutputStatus = "orphaned"
+)
+
+// CheckedOutput is an output and its status
+type CheckedOutput struct {
+	Output string
+	Source string
+	Status OutputStatus
+}
+
+// Comparisons are the values of 'gsc check -compare'
+var Comparisons = []string{"bytes", "semantic"}
+
+// checkMain implements 'gsc check'
+func checkMain(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	workers := flags.Int("j", runtime.NumCPU(), "number of shaders to compile concurrently")
+	diagnosticsFormat := flags.String("diagnostics-format", "text", "how errors and warnings are printed to stdout: "+strings.Join(DiagnosticsFormats, ", "))
+	compare := flags.String("compare", "bytes", "how outputs are compared: bytes, or semantic which ignores the SPIR-V generator, debug information and line endings")
+	keepDebug := flags.Bool("keep-debug", false, "also check the unstripped SPIR-V written by 'gsc build -keep-debug'")
+	selection := addUnitFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: gsc check [flags] [dirs/files/globs...]\n\n")
+		fmt.Fprintf(flags.Output(), "compiles the same shaders as 'gsc build' in memory and reports outputs which\n")
+		fmt.Fprintf(flags.Output(), "are stale, missing or orphaned, without writing anything\n\n")
+		flags.PrintDefaults()
+	}
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	semantic := false
+	switch *compare {
+	case "bytes":
+	case "semantic":
+		semantic = true
+	default:
+		log.Printf("error: unknown comparison '%s', expected one of %s", *compare, strings.Join(Comparisons, ", "))
+		return ExitUsage
+	}
+
+	diagnostics, err := NewDiagnosticsReporter(*diagnosticsFormat, os.Stdout)
+	if err != nil {
+		log.Printf("error: %v", err)
+		return ExitUsage
+	}
+
+	units, code := selection.units(flags.Args())
+	if code != ExitOK {
+		return code
+	}
+	if *keepDebug {
+		for _, unit := range units {
+			keepDebugOutput(unit)
+		}
+	}
+
+	var roots []string
+	if selection.outDir != "" {
+		roots = append(roots, selection.outDir)
+	}
+	for _, arg := range flags.Args() {
+		if info, err := os.Stat(arg); err == nil && info.IsDir() {
+			roots = append(roots, arg)
+		}
+	}
+
+	compiler := gs.NewCompiler()
+	defer compiler.Release()
+
+	checked, code := checkUnits(compiler, units, *workers, semantic, diagnostics)
+	orphans, err := orphanedOutputs(units, roots, selection.selector())
+	if err != nil {
+		log.Printf("error: %v", err)
+		code = worseExitCode(code, ExitRead)
+	}
+	checked = append(checked, orphans...)
+
+	// The statuses go to stdout with the diagnostics, unless those are a
+	// single document
+	status := func(format string, args ...interface{}) {
+		fmt.Printf(format+"\n", args...)
+	}
+	if !diagnostics.Streaming() {
+		status = log.Printf
+	}
+	counts := make(map[OutputStatus]int)
+	for _, c := range checked {
+		counts[c.Status]++
+		switch c.Status {
+		case OutputUpToDate:
+		case OutputOrphaned:
+			status("%s: %s", c.Status, c.Output)
+		default:
+			status("%s: %s (from %s)", c.Status, c.Output, c.Source)
+		}
+	}
+	log.Printf("%d outputs up to date, %d stale, %d missing, %d orphaned",
+		counts[OutputUpToDate], counts[OutputStale], counts[OutputMissing], counts[OutputOrphaned])
+	if counts[OutputStale]+counts[OutputMissing]+counts[OutputOrphaned] > 0 {
+		code = worseExitCode(code, ExitStale)
+	}
+	if err := diagnostics.Close(); err != nil {
+		log.Printf("error: %v", err)
+		code = worseExitCode(code, ExitWrite)
+	}
+	return code
+}
+
+// checkUnits compiles every unit in memory and compares the results with
+// their outputs, sorted by output. It also returns the exit code for units
+// which couldn't be compiled, whose errors are reported to diagnostics.
+func checkUnits(compiler *gs.Compiler, units []*BuildUnit, workers int, semantic bool, diagnostics *DiagnosticsReporter) ([]CheckedOutput, int) {
+	var mu sync.Mutex
+	var checked []CheckedOutput
+	code := ExitOK
+	compileUnits(compiler, units, workers, func(compiler *gs.Compiler, unit *BuildUnit) error {
+		c, err := checkUnit(compiler, unit, semantic, diagnostics)
+		mu.Lock()
+		defer mu.Unlock()
+		checked = append(checked, c...)
+		if err != nil {
+			if exitCode(err) != ExitCompile {
+				diagnostics.Error(unit, "", err)
+			}
+			log.Printf("error compiling shader '%s': %v", unit.Source, err)
+			code = worseExitCode(code, exitCode(err))
+		}
+		return err
+	})
+	sort.Slice(checked, func(i, j int) bool {
+		return checked[i].Output < checked[j].Output
+	})
+	return checked, code
+}
+
+// checkUnit compiles a unit, and all of its variants, in memory and
+// compares the results with the outputs on disk, reporting the compiler's
+// messages to diagnostics
+func checkUnit(compiler *gs.Compiler, unit *BuildUnit, semantic bool, diagnostics *DiagnosticsReporter) ([]CheckedOutput, error) {
+	options, err := unit.Config.Options()
+	if err != nil {
+		return nil, withExitCode(ExitConfig, err)
+	}
+	format, err := ParseOutputFormat(unit.Config.Format)
+	if err != nil {
+		return nil, withExitCode(ExitConfig, err)
+	}
+	post, err := unit.Config.PostProcessing()
+	if err != nil {
+		return nil, err
+	}
+	if unit.Output == "-" {
+		return nil, withExitCode(ExitConfig, fmt.Errorf("output to stdout can't be checked"))
+	}
+	data, err := ioutil.ReadFile(unit.Source)
+	if err != nil {
+		return nil, withExitCode(ExitRead, err)
+	}
+	info := &outputInfo{source: unit.Source, symbol: unit.Symbol, pkg: unit.Config.Package}
+
+	expected := make(map[string][]byte)
+	// debug holds the unstripped SPIR-V of the debug outputs
+	debug := make(map[string][]byte)
+	var failure error
+	if unit.Variants != nil {
+		if format.Kind != gs.OutputSPV {
+			return nil, withExitCode(ExitConfig, fmt.Errorf("variants can't be written as %s", format.Name))
+		}
+		results := compiler.CompileVariants(string(data), unit.Stage, unit.Source, unit.Config.GetEntryPoint(), options, unit.Variants)
+		// The variants which can't be compiled don't stop the rest being
+		// checked, the worst failure is returned after the comparisons
+		failure = results.Err()
+		for _, key := range results.Keys {
+			res := results.Results[key]
+			diagnostics.Compiler(unit, key, res.ErrorMessage, res.Err != nil)
+			if res.Err != nil {
+				continue
+			}
+			output := variantOutputName(unit.Output, key)
+			if unit.DebugOutput != "" {
+				debug[variantOutputName(unit.DebugOutput, key)] = res.Data
+			}
+			spirv, err := post.Apply(res.Data)
+			if err == nil {
+				expected[output], err = encodeOutput(format, output, spirv, info.forVariant(key))
+			}
+			if err != nil {
+				log.Printf("error: %s [%s]: %v", unit.Source, key, err)
+				delete(expected, output)
+				failure = worseError(failure, err)
+			}
+		}
+	} else {
+		result := format.Compile(compiler, string(data), unit.Stage, unit.Source, unit.Config.GetEntryPoint(), options)
+		defer result.Release()
+		diagnostics.Compiler(unit, "", result.ErrorMessage(), result.Error() != nil)
+		if result.Error() != nil {
+			return nil, result.Error()
+		}
+		output := result.Bytes()
+		if format.Kind == gs.OutputSPV {
+			if unit.DebugOutput != "" {
+				debug[unit.DebugOutput] = output
+			}
+			if output, err = post.Apply(output); err != nil {
+				return nil, err
+			}
+		}
+		if expected[unit.Output], err = encodeOutput(format, unit.Output, output, info); err != nil {
+			return nil, err
+		}
+	}
+
+	var checked []CheckedOutput
+	for _, outputs := range []struct {
+		format *OutputFormat
+		data   map[string][]byte
+	}{{format, expected}, {OutputFormats[0], debug}} {
+		for output, want := range outputs.data {
+			c := CheckedOutput{Output: output, Source: unit.Source, Status: OutputUpToDate}
+			got, err := ioutil.ReadFile(output)
+			switch {
+			case os.IsNotExist(err):
+				c.Status = OutputMissing
+			case err != nil:
+				return nil, withExitCode(ExitRead, err)
+			case !outputsEqual(outputs.format, got, want, semantic):
+				c.Status = OutputStale
+			}
+			checked = append(checked, c)
+		}
+	}
+	return checked, failure
+}
+
+// outputsEqual compares an output with what it should be
+func outputsEqual(format *OutputFormat, got, want []byte, semantic bool) bool {
+	if !semantic {
+		return bytes.Equal(got, want)
+	}
+	if format.Kind == gs.OutputSPV && format.encode == nil {
+		return spirvEquivalent(got, want)
+	}
+	return bytes.Equal(normalizeText(got), normalizeText(want))
+}
+
+// normalizeText removes carriage returns and trailing whitespace, which
+// checkouts and editors change without changing the meaning of the text
+func normalizeText(data []byte) []byte {
+	lines := bytes.Split(bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n")), []byte("\n"))
+	for i := range lines {
+		lines[i] = bytes.TrimRight(lines[i], " \t\r")
+	}
+	return bytes.TrimRight(bytes.Join(lines, []byte("\n")), "\n")
+}
+
+// spirvEquivalent compares two SPIR-V modules stripped of their debug
+// information, which also renumbers their ids, ignoring the generator.
+// Anything which doesn't parse as SPIR-V is compared byte for byte.
+func spirvEquivalent(a, b []byte) bool {
+	sa, err := gs.Strip(a, gs.StripAll)
+	if err != nil {
+		return bytes.Equal(a, b)
+	}
+	sb, err := gs.Strip(b, gs.StripAll)
+	if err != nil {
+		return false
+	}
+	// The generator, the third word, only says which tool wrote the module
+	copy(sb[8:12], sa[8:12])
+	return bytes.Equal(sa, sb)
+}
+
+// orphanedOutputs finds outputs which no unit writes. It looks through the
+// roots, recursively, and the directories units write to. Only files with
+// the extension of an output format in use are considered, and unless
+// that's spv or spvasm the name must also contain a shader extension, as in
+// pbr.frag.h, so sources and includes aren't mistaken for outputs.
+func orphanedOutputs(units []*BuildUnit, roots []string, selector *SourceSelector) ([]CheckedOutput, error) {
+	expected := make(map[string]bool)
+	exts := make(map[string]bool)
+	dirs := make(map[string]bool)
+	for _, unit := range units {
+		if format, err := ParseOutputFormat(unit.Config.Format); err == nil {
+			exts["."+format.Ext] = true
+		}
+		for _, output := range unit.Outputs() {
+			expected[absPath(output)] = true
+			dirs[filepath.Dir(output)] = true
+		}
+	}
+
+	isOutput := func(path string) bool {
+		ext := filepath.Ext(path)
+		if !exts[ext] || expected[absPath(path)] {
+			return false
+		}
+		if ext == ".spv" || ext == ".spvasm" {
+			return true
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ext)
+		for _, shader := range DefaultShaderExtensions {
+			if strings.Contains(name+".", shader+".") {
+				return true
+			}
+		}
+		return false
+	}
+
+	found := make(map[string]bool)
+	for _, root := range roots {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if path != root && (strings.HasPrefix(info.Name(), ".") || selector.ignored(root, path)) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !info.IsDir() && isOutput(path) {
+				found[filepath.Clean(path)] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for dir := range dirs {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, f := range files {
+			path := filepath.Join(dir, f.Name())
+			if !f.IsDir() && isOutput(path) {
+				found[filepath.Clean(path)] = true
+			}
+		}
+	}
+
+	// The same file may have been found through a root and a directory
+	seen := make(map[string]bool)
+	var orphans []CheckedOutput
+	for path := range found {
+		if abs := absPath(path); !seen[abs] {
+			seen[abs] = true
+			orphans = append(orphans, CheckedOutput{Output: path, Status: OutputOrphaned})
+		}
+	}
+	sort.Slice(orphans, func(i, j int) bool {
+		return orphans[i].Output < orphans[j].Output
+	})
+	return orphans, nil
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
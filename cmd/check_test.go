@@ -0,0 +1,191 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gs "github.com/celer/gshaderc"
+)
+
+func TestCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"a.frag":        "#version 450\nvoid main() {}\n",
+		"b.vert":        "#version 450\nvoid main() {}\n",
+		"sub/c.comp":    "#version 450\nvoid main() {}\n",
+		"common.glsl":   "",
+		"old.frag.spv":  "",
+		"sub/d.comp.h":  "",
+		"notes.spv.txt": "",
+	})
+
+	sources, err := (&SourceSelector{Extensions: DefaultShaderExtensions}).Collect([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	units, err := BatchUnits(sources, CompileConfig{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compiler := gs.NewCompilerWithBackend(gs.NewFakeBackend())
+	defer compiler.Release()
+	if failed := compileUnits(compiler, units, 2, compileUnit); failed != 0 {
+		t.Fatalf("%d units failed", failed)
+	}
+
+	status := func() map[string]OutputStatus {
+		t.Helper()
+		checked, code := checkUnits(compiler, units, 2, false, nil)
+		if code != ExitOK {
+			t.Fatalf("unexpected exit code %d", code)
+		}
+		orphans, err := orphanedOutputs(units, []string{dir}, &SourceSelector{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := make(map[string]OutputStatus)
+		for _, c := range append(checked, orphans...) {
+			rel, err := filepath.Rel(dir, c.Output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s[filepath.ToSlash(rel)] = c.Status
+		}
+		return s
+	}
+
+	s := status()
+	if len(s) != 4 || s["a.frag.spv"] != OutputUpToDate || s["sub/c.comp.spv"] != OutputUpToDate || s["old.frag.spv"] != OutputOrphaned {
+		t.Fatalf("unexpected status %v", s)
+	}
+
+	// Checking never writes, so stale and missing outputs stay that way
+	writeFiles(t, dir, map[string]string{"a.frag": "#version 450\nvoid main() { }\n"})
+	if err := os.Remove(filepath.Join(dir, "b.vert.spv")); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		s = status()
+		if s["a.frag.spv"] != OutputStale || s["b.vert.spv"] != OutputMissing {
+			t.Fatalf("unexpected status %v", s)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "b.vert.spv")); err == nil {
+		t.Fatal("check wrote an output")
+	}
+}
+
+func TestCheckKeepDebug(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"a.frag": "#version 450\nvoid main() {}\n",
+		"b.vert": "#version 450\nvoid main() {}\n",
+	})
+	compiler := gs.NewCompilerWithBackend(gs.NewFakeBackend())
+	defer compiler.Release()
+	// units returns the units of 'gsc build|check -strip all [-keep-debug]'
+	units := func(keepDebug bool) []*BuildUnit {
+		t.Helper()
+		sources, err := (&SourceSelector{Extensions: DefaultShaderExtensions}).Collect([]string{dir})
+		if err != nil {
+			t.Fatal(err)
+		}
+		units, err := BatchUnits(sources, CompileConfig{DebugInfo: switchOn(), Strip: "all"}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, unit := range units {
+			if filepath.Base(unit.Source) == "a.frag" {
+				unit.Variants = &gs.VariantSet{Axes: []gs.VariantAxis{{Name: "FOG", Values: []string{""}, Optional: true}}}
+			}
+			if keepDebug {
+				keepDebugOutput(unit)
+			}
+		}
+		return units
+	}
+
+	if failed := compileUnits(compiler, units(true), 2, compileUnit); failed != 0 {
+		t.Fatalf("%d units failed", failed)
+	}
+	for _, keepDebug := range []bool{true, false} {
+		checked, code := checkUnits(compiler, units(keepDebug), 2, false, nil)
+		if code != ExitOK {
+			t.Fatalf("unexpected exit code %d", code)
+		}
+		orphans, err := orphanedOutputs(units(keepDebug), []string{dir}, &SourceSelector{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := make(map[string]OutputStatus)
+		for _, c := range append(checked, orphans...) {
+			s[filepath.Base(c.Output)] = c.Status
+		}
+		expected := OutputUpToDate
+		if !keepDebug {
+			expected = OutputOrphaned
+		}
+		if len(s) != 6 || s["a.frag.FOG.spv"] != OutputUpToDate || s["a.frag.debug.FOG.spv"] != expected || s["b.vert.debug.spv"] != expected {
+			t.Fatalf("unexpected status with keep debug %v: %v", keepDebug, s)
+		}
+	}
+}
+
+func TestSemanticCompare(t *testing.T) {
+	module := func(generator, bound uint32, instructions ...[]uint32) []byte {
+		words := []uint32{gs.SPIRVMagic, 0x10000, generator, bound, 0}
+		for _, i := range instructions {
+			words = append(words, i...)
+		}
+		return gs.SPIRVBytes(words)
+	}
+	capability := []uint32{2<<16 | 17, 1}
+	memoryModel := []uint32{3<<16 | 14, 0, 1}
+	// -g adds an OpString, which takes an id and so renumbers the rest
+	str := []uint32{3<<16 | 7, 1, 0x78}
+	name := []uint32{3<<16 | 5, 2, 0x78}
+	typeVoid := func(id uint32) []uint32 { return []uint32{2<<16 | 19, id} }
+	typeBool := func(id uint32) []uint32 { return []uint32{2<<16 | 20, id} }
+
+	spv, _ := ParseOutputFormat("spv")
+	a := module(0x80001, 4, capability, memoryModel, str, name, typeVoid(2), typeBool(3))
+	b := module(0xd0000, 3, capability, memoryModel, typeVoid(1), typeBool(2))
+	if outputsEqual(spv, a, b, false) || !outputsEqual(spv, a, b, true) {
+		t.Fatal("expected modules differing in generator and debug information to be equal semantically only")
+	}
+	if outputsEqual(spv, a, module(0x80001, 3, memoryModel, typeVoid(1), typeBool(2)), true) {
+		t.Fatal("expected modules with different capabilities to differ")
+	}
+	if outputsEqual(spv, b, module(0xd0000, 3, capability, memoryModel, typeBool(1), typeVoid(2)), true) {
+		t.Fatal("expected modules with different types to differ")
+	}
+
+	asm, _ := ParseOutputFormat("spvasm")
+	if !outputsEqual(asm, []byte("OpCapability Shader\r\nOpMemoryModel Logical GLSL450  \r\n"), []byte("OpCapability Shader\nOpMemoryModel Logical GLSL450\n"), true) {
+		t.Fatal("expected line endings and trailing whitespace to be ignored")
+	}
+}
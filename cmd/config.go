@@ -0,0 +1,317 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	gs "github.com/celer/gshaderc"
+)
+
+// CompileConfig holds the settings used to compile a shader, it can come
+// from the command line or a manifest. Empty fields mean "use the default".
+type CompileConfig struct {
+	Target       string            `json:"target,omitempty" toml:"target"`
+	EntryPoint   string            `json:"entry_point,omitempty" toml:"entry_point"`
+	Optimize     string            `json:"optimize,omitempty" toml:"optimize"`
+	Macros       map[string]string `json:"macros,omitempty" toml:"macros"`
+	IncludePaths []string          `json:"include_paths,omitempty" toml:"include_paths"`
+	OutputDir    string            `json:"output_dir,omitempty" toml:"output_dir"`
+	// OutputName is the template for output file names, see OutputFileName
+	OutputName string `json:"output_name,omitempty" toml:"output_name"`
+	// Format is the output format, see OutputFormats
+	Format string `json:"format,omitempty" toml:"format"`
+	// Package is the package of generated Go files
+	Package string `json:"package,omitempty" toml:"package"`
+	// SPIRVVersion is the SPIR-V version to generate, e.g. "1.3"
+	SPIRVVersion string `json:"spirv_version,omitempty" toml:"spirv_version"`
+	// The switches are nil when they aren't given, so a config merged on
+	// top can turn them off as well as on
+	WarningsAsErrors *bool `json:"warnings_as_errors,omitempty" toml:"warnings_as_errors"`
+	SuppressWarnings *bool `json:"suppress_warnings,omitempty" toml:"suppress_warnings"`
+	AutoBindUniforms *bool `json:"auto_bind_uniforms,omitempty" toml:"auto_bind_uniforms"`
+	InvertY          *bool `json:"invert_y,omitempty" toml:"invert_y"`
+	NanClamp         *bool `json:"nan_clamp,omitempty" toml:"nan_clamp"`
+	DebugInfo        *bool `json:"debug_info,omitempty" toml:"debug_info"`
+	// Strip is a comma separated list of the debug information removed
+	// from the SPIR-V, see StripNames
+	Strip string `json:"strip,omitempty" toml:"strip"`
+	// Canonicalize renumbers and orders the SPIR-V deterministically, see
+	// gshaderc.Canonicalize
+	Canonicalize *bool `json:"canonicalize,omitempty" toml:"canonicalize"`
+	// BindingBases maps a uniform kind (image, sampler, texture, buffer,
+	// storage_buffer or uav) to the first binding used for it
+	BindingBases map[string]uint32 `json:"binding_bases,omitempty" toml:"binding_bases"`
+	// Limits maps resource limit names, as used by glslang, to values
+	Limits map[string]int `json:"limits,omitempty" toml:"limits"`
+}
+
+var spirvVersions = map[string]gs.SPIRVVersion{
+	"1.0": gs.SPIRV_1_0,
+	"1.1": gs.SPIRV_1_1,
+	"1.2": gs.SPIRV_1_2,
+	"1.3": gs.SPIRV_1_3,
+	"1.4": gs.SPIRV_1_4,
+	"1.5": gs.SPIRV_1_5,
+}
+
+var uniformKinds = map[string]gs.UniformKind{
+	"image":          gs.UniformKindImage,
+	"sampler":        gs.UniformKindSampler,
+	"texture":        gs.UniformKindTexture,
+	"buffer":         gs.UniformKindBuffer,
+	"storage_buffer": gs.UniformKindStorageBuffer,
+	"uav":            gs.UniformKindUnorderedAccessView,
+}
+
+// enabled returns true if a switch of the config is given and on
+func enabled(b *bool) bool {
+	return b != nil && *b
+}
+
+// StripNames are the values Strip may list
+var StripNames = []string{"names", "lines", "sources", "nonsemantic", "all"}
+
+// parseStrip parses the value of Strip, it returns nil if nothing is
+// stripped
+func parseStrip(value string) (*gs.StripOptions, error) {
+	if value == "" {
+		return nil, nil
+	}
+	options := &gs.StripOptions{}
+	for _, name := range strings.Split(value, ",") {
+		switch strings.TrimSpace(name) {
+		case "names":
+			options.Names = true
+		case "lines":
+			options.Lines = true
+		case "sources":
+			options.Sources = true
+		case "nonsemantic":
+			options.NonSemantic = true
+		case "all":
+			*options = gs.StripAll
+		default:
+			return nil, fmt.Errorf("unknown debug information '%s' to strip, expected one of %s", name, strings.Join(StripNames, ", "))
+		}
+	}
+	return options, nil
+}
+
+// Merge returns a copy of c with every field set in o overriding it, macros
+// are merged and o's include paths are searched first
+func (c CompileConfig) Merge(o CompileConfig) CompileConfig {
+	if o.Target != "" {
+		c.Target = o.Target
+	}
+	if o.EntryPoint != "" {
+		c.EntryPoint = o.EntryPoint
+	}
+	if o.Optimize != "" {
+		c.Optimize = o.Optimize
+	}
+	if len(o.Macros) > 0 {
+		macros := make(map[string]string, len(c.Macros)+len(o.Macros))
+		for k, v := range c.Macros {
+			macros[k] = v
+		}
+		for k, v := range o.Macros {
+			macros[k] = v
+		}
+		c.Macros = macros
+	}
+	if len(o.IncludePaths) > 0 {
+		c.IncludePaths = append(append([]string(nil), o.IncludePaths...), c.IncludePaths...)
+	}
+	if o.OutputDir != "" {
+		c.OutputDir = o.OutputDir
+	}
+	if o.OutputName != "" {
+		c.OutputName = o.OutputName
+	}
+	if o.Format != "" {
+		c.Format = o.Format
+	}
+	if o.Package != "" {
+		c.Package = o.Package
+	}
+	if o.SPIRVVersion != "" {
+		c.SPIRVVersion = o.SPIRVVersion
+	}
+	for _, b := range []struct{ c, o **bool }{
+		{&c.WarningsAsErrors, &o.WarningsAsErrors},
+		{&c.SuppressWarnings, &o.SuppressWarnings},
+		{&c.AutoBindUniforms, &o.AutoBindUniforms},
+		{&c.InvertY, &o.InvertY},
+		{&c.NanClamp, &o.NanClamp},
+		{&c.DebugInfo, &o.DebugInfo},
+		{&c.Canonicalize, &o.Canonicalize},
+	} {
+		if *b.o != nil {
+			*b.c = *b.o
+		}
+	}
+	if o.Strip != "" {
+		c.Strip = o.Strip
+	}
+	if len(o.BindingBases) > 0 {
+		bases := make(map[string]uint32, len(c.BindingBases)+len(o.BindingBases))
+		for k, v := range c.BindingBases {
+			bases[k] = v
+		}
+		for k, v := range o.BindingBases {
+			bases[k] = v
+		}
+		c.BindingBases = bases
+	}
+	if len(o.Limits) > 0 {
+		limits := make(map[string]int, len(c.Limits)+len(o.Limits))
+		for k, v := range c.Limits {
+			limits[k] = v
+		}
+		for k, v := range o.Limits {
+			limits[k] = v
+		}
+		c.Limits = limits
+	}
+	return c
+}
+
+// PostProcessing is what is done to SPIR-V after it's compiled
+type PostProcessing struct {
+	// Strip is nil if nothing is stripped
+	Strip        *gs.StripOptions
+	Canonicalize bool
+}
+
+// PostProcessing checks and returns the post-processing the config asks
+// for, errors have the ExitConfig exit code
+func (c CompileConfig) PostProcessing() (PostProcessing, error) {
+	strip, err := parseStrip(c.Strip)
+	if err != nil {
+		return PostProcessing{}, withExitCode(ExitConfig, err)
+	}
+	p := PostProcessing{Strip: strip, Canonicalize: enabled(c.Canonicalize)}
+	if p.Strip != nil || p.Canonicalize {
+		f, err := ParseOutputFormat(c.Format)
+		if err != nil {
+			return PostProcessing{}, withExitCode(ExitConfig, err)
+		}
+		if f.Kind != gs.OutputSPV {
+			return PostProcessing{}, withExitCode(ExitConfig, fmt.Errorf("SPIR-V written as %s can't be stripped or canonicalized", f.Name))
+		}
+	}
+	return p, nil
+}
+
+// Apply applies the post-processing to compiled SPIR-V, it returns spirv
+// unchanged if there is nothing to do
+func (p PostProcessing) Apply(spirv []byte) ([]byte, error) {
+	var err error
+	if p.Strip != nil {
+		if spirv, err = gs.Strip(spirv, *p.Strip); err != nil {
+			return nil, err
+		}
+	}
+	if p.Canonicalize {
+		if spirv, err = gs.Canonicalize(spirv, gs.CanonicalizeAll); err != nil {
+			return nil, err
+		}
+	}
+	return spirv, nil
+}
+
+// GetEntryPoint returns the entry point, defaulting to "main"
+func (c CompileConfig) GetEntryPoint() string {
+	if c.EntryPoint == "" {
+		return "main"
+	}
+	return c.EntryPoint
+}
+
+// Options creates the compiler options described by the config
+func (c CompileConfig) Options() (*gs.CompilerOptions, error) {
+	options := gs.NewCompilerOptions()
+
+	target := c.Target
+	if target == "" {
+		target = gs.TargetVulkan11
+	}
+	if err := options.SetTargetByName(target); err != nil {
+		return nil, err
+	}
+
+	switch c.Optimize {
+	case "", "none":
+	case "zero":
+		options.SetOptimizationLevel(gs.Zero)
+	case "size":
+		options.SetOptimizationLevel(gs.Size)
+	case "performance":
+		options.SetOptimizationLevel(gs.Performance)
+	default:
+		return nil, fmt.Errorf("unknown optimization level: %s", c.Optimize)
+	}
+
+	names := make([]string, 0, len(c.Macros))
+	for name := range c.Macros {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		options.AddMacroDefinition(name, c.Macros[name])
+	}
+
+	if c.SPIRVVersion != "" {
+		version, ok := spirvVersions[c.SPIRVVersion]
+		if !ok {
+			return nil, fmt.Errorf("unknown SPIR-V version: %s", c.SPIRVVersion)
+		}
+		options.SetSPIRVVersion(version)
+	}
+	if enabled(c.WarningsAsErrors) {
+		options.SetWarningsAsErrors()
+	}
+	if enabled(c.SuppressWarnings) {
+		options.SuppressWarnings()
+	}
+	options.SetAutoBindUniforms(enabled(c.AutoBindUniforms))
+	options.SetInvertY(enabled(c.InvertY))
+	options.SetNanClamp(enabled(c.NanClamp))
+	if enabled(c.DebugInfo) {
+		options.SetGenerateDebugInfo()
+	}
+
+	for name, base := range c.BindingBases {
+		kind, ok := uniformKinds[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown uniform kind: %s", name)
+		}
+		options.SetBindingBase(kind, base)
+	}
+	for name, value := range c.Limits {
+		limit, err := gs.ParseResourceLimit(name)
+		if err != nil {
+			return nil, err
+		}
+		options.SetLimit(limit, value)
+	}
+
+	options.SetIncludeCallback(gs.CreateDefaultIncludeResolver(c.IncludePaths))
+
+	return options, nil
+}
@@ -0,0 +1,184 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	gs "github.com/celer/gshaderc"
+)
+
+// switchOn returns a switch which is on
+func switchOn() *bool {
+	on := true
+	return &on
+}
+
+func TestConfigFlags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"shaders/a.frag":      "#version 450\n#include \"local.glsl\"\n#include <common.glsl>\nvoid main() {}\n",
+		"shaders/local.glsl":  "#ifndef QUALITY\n#error QUALITY not defined\n#endif\n",
+		"include/common.glsl": "#ifndef FOG\n#error FOG not defined\n#endif\n",
+	})
+
+	var config CompileConfig
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	addConfigFlags(flags, &config)
+	err = flags.Parse([]string{
+		"-target", "vulkan_1_0", "-spirv-version", "1.2",
+		"-D", "QUALITY=2", "-D", "FOG", "-I", filepath.Join(dir, "include"),
+		"-Werror", "-w", "-auto-bind-uniforms", "-invert-y", "-nan-clamp", "-g",
+		"-binding-base", "texture=4", "-binding-base", "uav=8",
+		"-limit", "MaxDrawBuffers=8",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options, err := config.Options()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target, env, _ := options.TargetEnv(); target != gs.Vulkan || env != gs.Vulkan_1_0 {
+		t.Fatalf("unexpected target %v %v", target, env)
+	}
+	if v, ok := options.SPIRVVersion(); !ok || v != gs.SPIRV_1_2 {
+		t.Fatalf("unexpected SPIR-V version %x", v)
+	}
+	if !reflect.DeepEqual(options.Macros(), []gs.Macro{{Name: "FOG"}, {Name: "QUALITY", Value: "2"}}) {
+		t.Fatalf("unexpected macros %v", options.Macros())
+	}
+	if !options.WarningsAsErrors() || !options.WarningsSuppressed() || !options.AutoBindUniforms() || !options.InvertY() || !options.NanClamp() || !options.GenerateDebugInfo() {
+		t.Fatal("expected boolean options to be set")
+	}
+	if !reflect.DeepEqual(options.BindingBases(), map[gs.UniformKind]uint32{gs.UniformKindTexture: 4, gs.UniformKindUnorderedAccessView: 8}) {
+		t.Fatalf("unexpected binding bases %v", options.BindingBases())
+	}
+	if !reflect.DeepEqual(options.Limits(), map[gs.ResourceLimit]int{gs.MaxDrawBuffers: 8}) {
+		t.Fatalf("unexpected limits %v", options.Limits())
+	}
+
+	// Relative includes are found next to the source, standard includes
+	// on the include path
+	compiler := gs.NewCompilerWithBackend(gs.NewFakeBackend())
+	defer compiler.Release()
+	source := filepath.Join(dir, "shaders/a.frag")
+	data, err := ioutil.ReadFile(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := compiler.CompileIntoSPV(string(data), gs.FragmentShader, source, config.GetEntryPoint(), options)
+	if result.Error() != nil {
+		t.Fatalf("unexpected error: %v %s", result.Error(), result.ErrorMessage())
+	}
+
+	for _, args := range [][]string{
+		{"-binding-base", "texture"},
+		{"-binding-base", "widget=1"},
+		{"-limit", "MaxWidgets=1"},
+		{"-D", "=1"},
+	} {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		flags.SetOutput(ioutil.Discard)
+		addConfigFlags(flags, &CompileConfig{})
+		if err := flags.Parse(args); err == nil {
+			t.Fatalf("expected an error for %s", strings.Join(args, " "))
+		}
+	}
+
+	if _, err := (CompileConfig{SPIRVVersion: "2.0"}).Options(); err == nil {
+		t.Fatal("expected an error for an unknown SPIR-V version")
+	}
+
+	// Switches given on the command line override those of a manifest,
+	// whichever way they are set
+	manifest := CompileConfig{InvertY: switchOn(), Canonicalize: switchOn()}
+	var cli CompileConfig
+	flags = flag.NewFlagSet("test", flag.ContinueOnError)
+	addConfigFlags(flags, &cli)
+	if err := flags.Parse([]string{"-canonicalize=false", "-nan-clamp"}); err != nil {
+		t.Fatal(err)
+	}
+	merged := manifest.Merge(cli)
+	if !enabled(merged.InvertY) || enabled(merged.Canonicalize) || !enabled(merged.NanClamp) {
+		t.Fatalf("unexpected switches %v %v %v", merged.InvertY, merged.Canonicalize, merged.NanClamp)
+	}
+}
+
+func TestConfigPostProcess(t *testing.T) {
+	compiler := gs.NewCompilerWithBackend(gs.NewFakeBackend())
+	defer compiler.Release()
+	result := compiler.CompileIntoSPV("#version 450\nvoid main() {}\n", gs.FragmentShader, "a.frag", "main", nil)
+	defer result.Release()
+	if result.Error() != nil {
+		t.Fatal(result.Error())
+	}
+	spirv := result.Bytes()
+
+	stripped, err := gs.Strip(spirv, gs.StripOptions{Names: true, Sources: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonical, err := gs.Canonicalize(stripped, gs.CanonicalizeAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		config   CompileConfig
+		expected []byte
+	}{
+		{CompileConfig{}, spirv},
+		{CompileConfig{Strip: "names,sources"}, stripped},
+		{CompileConfig{Strip: "names, sources", Canonicalize: switchOn()}, canonical},
+	} {
+		post, err := c.config.PostProcessing()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := post.Apply(spirv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, c.expected) {
+			t.Fatalf("unexpected SPIR-V post-processed with %+v", c.config)
+		}
+	}
+
+	for _, config := range []CompileConfig{
+		{Canonicalize: switchOn(), Format: "preprocessed"},
+		{Strip: "all", Format: "widgets"},
+		{Strip: "names,symbols"},
+	} {
+		if _, err := config.PostProcessing(); exitCode(err) != ExitConfig {
+			t.Fatalf("expected a config error for %+v, got %v", config, err)
+		}
+	}
+	// Callers which don't post-process don't care
+	if _, err := (CompileConfig{Strip: "all", Format: "spvasm"}).Options(); err != nil {
+		t.Fatal(err)
+	}
+}
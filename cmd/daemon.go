@@ -0,0 +1,267 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"runtime"
+	"sync"
+	"syscall"
+
+	gs "github.com/celer/gshaderc"
+)
+
+// daemonMain implements 'gsc daemon'
+func daemonMain(args []string) int {
+	flags := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	socket := flags.String("socket", "", "listen for connections on this Unix socket instead of reading stdin")
+	workers := flags.Int("j", runtime.NumCPU(), "number of requests to compile concurrently")
+	var config CompileConfig
+	addConfigFlags(flags, &config)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: gsc daemon [-socket path] [flags]\n\n")
+		fmt.Fprintf(flags.Output(), "compiles newline delimited JSON requests read from stdin, or from every\n")
+		fmt.Fprintf(flags.Output(), "connection to -socket, writing a JSON response line per request. The flags\n")
+		fmt.Fprintf(flags.Output(), "set the defaults of every request.\n\n")
+		flags.PrintDefaults()
+	}
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	if _, err := config.Options(); err != nil {
+		log.Printf("error: %v", err)
+		return ExitConfig
+	}
+	compiler := gs.NewCompiler()
+	defer compiler.Release()
+	daemon := NewDaemon(compiler, config, *workers)
+
+	if *socket == "" {
+		if err := daemon.Serve(os.Stdin, os.Stdout); err != nil {
+			log.Printf("error: %v", err)
+			return ExitRead
+		}
+		return ExitOK
+	}
+
+	// Remove the socket left behind by a previous run
+	if info, err := os.Stat(*socket); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(*socket)
+	}
+	l, err := net.Listen("unix", *socket)
+	if err != nil {
+		log.Printf("error: %v", err)
+		return ExitUsage
+	}
+	// Closing the listener on an interrupt removes the socket
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		l.Close()
+	}()
+	log.Printf("compiling requests on %s", *socket)
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return ExitOK
+		}
+		go func() {
+			defer conn.Close()
+			if err := daemon.Serve(conn, conn); err != nil {
+				log.Printf("error: %v", err)
+			}
+		}()
+	}
+}
+
+// DaemonRequest is a shader for the daemon to compile. Either Source or
+// Path is given, with Source Path is still used to find relative includes
+// and infer the stage. The compile settings override the daemon's defaults.
+type DaemonRequest struct {
+	// ID is copied into the response so requests answered out of order can
+	// be matched up
+	ID     json.RawMessage `json:"id,omitempty"`
+	Path   string          `json:"path,omitempty"`
+	Source *string         `json:"source,omitempty"`
+	// Stage is a shader extension, e.g. frag, by default it's inferred from
+	// Path
+	Stage string `json:"stage,omitempty"`
+	CompileConfig
+}
+
+// DaemonResponse is the result of a request, SPIRV is base64 encoded in
+// JSON and Text holds the output of text formats
+type DaemonResponse struct {
+	ID      json.RawMessage `json:"id,omitempty"`
+	Success bool            `json:"success"`
+	SPIRV   []byte          `json:"spirv,omitempty"`
+	Text    string          `json:"text,omitempty"`
+	// Error is set for requests which couldn't be compiled at all, such as
+	// invalid JSON or settings
+	Error        string       `json:"error,omitempty"`
+	Diagnostics  []Diagnostic `json:"diagnostics"`
+	Dependencies []string     `json:"dependencies"`
+}
+
+// Daemon compiles requests with a single compiler
+type Daemon struct {
+	// Config holds the defaults of every request
+	Config CompileConfig
+
+	compiler *gs.Compiler
+	workers  int
+}
+
+// NewDaemon creates a daemon compiling up to workers requests at a time
+func NewDaemon(compiler *gs.Compiler, config CompileConfig, workers int) *Daemon {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Daemon{Config: config, compiler: compiler, workers: workers}
+}
+
+// Serve reads requests from r, a line each, and writes a response line to
+// w for each as it completes. It returns once r is exhausted and every
+// request has been answered.
+func (d *Daemon) Serve(r io.Reader, w io.Writer) error {
+	var wmu sync.Mutex
+	enc := json.NewEncoder(w)
+	respond := func(resp *DaemonResponse) {
+		wmu.Lock()
+		defer wmu.Unlock()
+		if err := enc.Encode(resp); err != nil {
+			log.Printf("error: %v", err)
+		}
+	}
+
+	slots := make(chan struct{}, d.workers)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 && !isBlank(line) {
+			req := &DaemonRequest{}
+			if jsonErr := json.Unmarshal(line, req); jsonErr != nil {
+				respond(&DaemonResponse{ID: req.ID, Error: "invalid request: " + jsonErr.Error(), Diagnostics: []Diagnostic{}, Dependencies: []string{}})
+			} else {
+				slots <- struct{}{}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer func() { <-slots }()
+					respond(d.Compile(req))
+				}()
+			}
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func isBlank(line []byte) bool {
+	for _, c := range line {
+		if c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			return false
+		}
+	}
+	return true
+}
+
+// Compile compiles a single request
+func (d *Daemon) Compile(req *DaemonRequest) *DaemonResponse {
+	resp := &DaemonResponse{ID: req.ID, Diagnostics: []Diagnostic{}, Dependencies: []string{}}
+	fail := func(err error) *DaemonResponse {
+		resp.Error = err.Error()
+		return resp
+	}
+
+	config := d.Config.Merge(req.CompileConfig)
+	options, err := config.Options()
+	if err != nil {
+		return fail(err)
+	}
+	post, err := config.PostProcessing()
+	if err != nil {
+		return fail(err)
+	}
+	format, err := ParseOutputFormat(config.Format)
+	if err != nil {
+		return fail(err)
+	}
+	if format.encode != nil {
+		return fail(fmt.Errorf("the daemon can't write %s, only spv, spvasm and preprocessed", format.Name))
+	}
+
+	var source string
+	switch {
+	case req.Source != nil:
+		source = *req.Source
+	case req.Path != "":
+		data, err := ioutil.ReadFile(req.Path)
+		if err != nil {
+			return fail(err)
+		}
+		source = string(data)
+		resp.Dependencies = append(resp.Dependencies, req.Path)
+	default:
+		return fail(fmt.Errorf("a request needs a source or a path"))
+	}
+	filename := req.Path
+	if filename == "" {
+		filename = "<source>"
+	}
+	stage := gs.GetShaderTypeByFilename(filename)
+	if req.Stage != "" {
+		if stage = gs.GetShaderTypeByFilename("." + req.Stage); stage == gs.InferFromSource {
+			return fail(fmt.Errorf("unknown stage '%s'", req.Stage))
+		}
+	}
+
+	options = options.Clone()
+	recorder := gs.NewIncludeRecorder(options.IncludeResolver())
+	options.SetIncludeCallback(recorder.Resolve)
+	result := format.Compile(d.compiler, source, stage, filename, config.GetEntryPoint(), options)
+	defer result.Release()
+
+	resp.Success = result.Error() == nil
+	resp.Diagnostics = append(resp.Diagnostics, unitDiagnostics(&BuildUnit{Source: filename, Stage: stage}, "", result.ErrorMessage())...)
+	resp.Dependencies = append(resp.Dependencies, recorder.Includes()...)
+	if resp.Success {
+		if format.Kind == gs.OutputSPV {
+			if resp.SPIRV, err = post.Apply(result.Bytes()); err != nil {
+				resp.Success = false
+				return fail(err)
+			}
+		} else {
+			resp.Text = string(result.Bytes())
+		}
+	}
+	return resp
+}
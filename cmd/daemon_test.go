@@ -0,0 +1,96 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gs "github.com/celer/gshaderc"
+)
+
+func TestDaemon(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gsc-daemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"common.glsl": "float common;\n",
+		"a.frag":      "#version 450\n#include \"common.glsl\"\nvoid main() {}\n",
+	})
+
+	compiler := gs.NewCompilerWithBackend(gs.NewFakeBackend())
+	defer compiler.Release()
+	daemon := NewDaemon(compiler, CompileConfig{}, 4)
+
+	path, _ := json.Marshal(filepath.Join(dir, "a.frag"))
+	requests := strings.Join([]string{
+		`{"id": 1, "path": ` + string(path) + `}`,
+		`{"id": 2, "source": "#version 450\n#error broken\nvoid main() {}\n", "stage": "vert"}`,
+		`{"id": 3, "source": "#version 450\nvoid main() {}", "stage": "frag", "format": "spvasm"}`,
+		``,
+		`{"id": 4, "source": "void main() {}", "stage": "nope"}`,
+		`{"id": 5, "source": `,
+		`{"id": "six", "source": "void main() {}", "stage": "comp", "format": "c"}`,
+	}, "\n")
+	var out bytes.Buffer
+	if err := daemon.Serve(strings.NewReader(requests), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	responses := map[string]*DaemonResponse{}
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		resp := &DaemonResponse{}
+		if err := dec.Decode(resp); err != nil {
+			t.Fatal(err)
+		}
+		responses[string(resp.ID)] = resp
+	}
+	if len(responses) != 6 {
+		t.Fatalf("expected 6 responses, got %d", len(responses))
+	}
+
+	resp := responses["1"]
+	if !resp.Success || len(resp.SPIRV) == 0 || len(resp.Dependencies) != 2 || filepath.Base(resp.Dependencies[1]) != "common.glsl" {
+		t.Errorf("unexpected response to a path %+v", resp)
+	}
+	if _, err := gs.Reflect(resp.SPIRV); err != nil {
+		t.Errorf("response isn't SPIR-V: %v", err)
+	}
+
+	resp = responses["2"]
+	if resp.Success || resp.SPIRV != nil || len(resp.Diagnostics) != 1 || resp.Diagnostics[0].Line != 2 || resp.Diagnostics[0].Severity != "error" {
+		t.Errorf("unexpected response to a failing source %+v", resp)
+	}
+
+	resp = responses["3"]
+	if !resp.Success || !strings.Contains(resp.Text, "OpCapability Shader") {
+		t.Errorf("unexpected response to an assembly request %+v", resp)
+	}
+
+	// Requests which can't be compiled at all report an error
+	for _, id := range []string{"4", "", `"six"`} {
+		if resp := responses[id]; resp == nil || resp.Success || resp.Error == "" {
+			t.Errorf("expected an error responding to %s, got %+v", id, resp)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	gs "github.com/celer/gshaderc"
+)
+
+// Exit codes of gsc, the shell sees them modulo 256, e.g. -4 is 252
+const (
+	ExitOK = 0
+	// ExitUsage is returned for bad command lines
+	ExitUsage = -1
+	// ExitRead is returned when a source or manifest couldn't be read
+	ExitRead = -2
+	// ExitWrite is returned when an output couldn't be written
+	ExitWrite = -3
+	// ExitCompile is returned when a shader failed to compile
+	ExitCompile = -4
+	// ExitConfig is returned for invalid compile options or manifests
+	ExitConfig = -5
+	// ExitStale is returned by 'gsc check' when outputs are out of date
+	ExitStale = -6
+	// ExitWatch is returned when watch mode couldn't watch
+	ExitWatch = -7
+)
+
+// exitError is an error which decides the exit code gsc returns
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// withExitCode wraps err so that exitCode returns code for it
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: code, err: err}
+}
+
+// exitCode returns the exit code for an error, errors which weren't given
+// one are compile failures
+func exitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var e *exitError
+	if errors.As(err, &e) {
+		return e.code
+	}
+	return ExitCompile
+}
+
+// exitPriority orders exit codes when a batch fails for several reasons,
+// configuration errors win over I/O errors which win over compile failures
+// which win over stale outputs
+var exitPriority = map[int]int{ExitConfig: 5, ExitRead: 4, ExitWrite: 3, ExitCompile: 2, ExitStale: 1}
+
+// worseExitCode returns whichever of two exit codes takes priority
+func worseExitCode(a, b int) int {
+	if exitPriority[b] > exitPriority[a] {
+		return b
+	}
+	return a
+}
+
+// worseError returns whichever error has the worse exit code, a if they are
+// as bad as each other
+func worseError(a, b error) error {
+	if a == nil || exitPriority[exitCode(b)] > exitPriority[exitCode(a)] {
+		return b
+	}
+	return a
+}
+
+// DiagnosticsFormats are the values of -diagnostics-format
+var DiagnosticsFormats = []string{"text", "gcc", "json", "sarif"}
+
+// Diagnostic is an error or warning reported for a shader
+type Diagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Stage    string `json:"stage,omitempty"`
+	Variant  string `json:"variant,omitempty"`
+}
+
+// DiagnosticsReporter writes the errors and warnings of every shader
+// compiled. The text format prints the compiler's messages as they are, gcc
+// prints one 'file:line:column: severity: message' line per diagnostic and
+// json and sarif collect every diagnostic and write a single document on
+// Close. A nil reporter uses the text format on stdout.
+type DiagnosticsReporter struct {
+	format string
+	w      io.Writer
+
+	mu          sync.Mutex
+	diagnostics []Diagnostic
+}
+
+// NewDiagnosticsReporter creates a reporter writing format to w
+func NewDiagnosticsReporter(format string, w io.Writer) (*DiagnosticsReporter, error) {
+	for _, f := range DiagnosticsFormats {
+		if f == format {
+			return &DiagnosticsReporter{format: format, w: w}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown diagnostics format '%s', expected one of %s", format, strings.Join(DiagnosticsFormats, ", "))
+}
+
+// Streaming reports whether diagnostics are written as they're reported,
+// rather than as one document on Close
+func (r *DiagnosticsReporter) Streaming() bool {
+	return r == nil || r.format == "text" || r.format == "gcc"
+}
+
+// Compiler reports the messages of the compiler for a unit, failed is
+// true if the compile failed. The text format only prints failures.
+func (r *DiagnosticsReporter) Compiler(unit *BuildUnit, variant, messages string, failed bool) {
+	if r == nil || r.format == "text" {
+		if failed {
+			fmt.Printf("%s", messages)
+		}
+		return
+	}
+	r.add(unitDiagnostics(unit, variant, messages)...)
+}
+
+// unitDiagnostics parses the messages of the compiler for a unit,
+// diagnostics without a file are attributed to the unit's source
+func unitDiagnostics(unit *BuildUnit, variant, messages string) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, d := range gs.ParseDiagnostics(messages) {
+		if d.File == "" {
+			d.File = unit.Source
+		}
+		diagnostics = append(diagnostics, Diagnostic{
+			File:     d.File,
+			Line:     d.Line,
+			Column:   d.Column,
+			Severity: string(d.Severity),
+			Message:  d.Message,
+			Stage:    gs.GetShaderExtensionByType(unit.Stage),
+			Variant:  variant,
+		})
+	}
+	return diagnostics
+}
+
+// Error reports an error which didn't come from the compiler, such as a
+// source which couldn't be read. The text format leaves these to the log.
+func (r *DiagnosticsReporter) Error(unit *BuildUnit, variant string, err error) {
+	if r == nil || r.format == "text" {
+		return
+	}
+	r.add(Diagnostic{
+		File:     unit.Source,
+		Severity: string(gs.SeverityError),
+		Message:  err.Error(),
+		Stage:    gs.GetShaderExtensionByType(unit.Stage),
+		Variant:  variant,
+	})
+}
+
+func (r *DiagnosticsReporter) add(diagnostics ...Diagnostic) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.format == "gcc" {
+		for _, d := range diagnostics {
+			fmt.Fprintf(r.w, "%s: %s: %s\n", gccLocation(d), d.Severity, d.Message)
+		}
+		return
+	}
+	r.diagnostics = append(r.diagnostics, diagnostics...)
+}
+
+// gccLocation returns 'file:line:column', leaving out what isn't known
+func gccLocation(d Diagnostic) string {
+	switch {
+	case d.Line == 0:
+		return d.File
+	case d.Column == 0:
+		return fmt.Sprintf("%s:%d", d.File, d.Line)
+	}
+	return fmt.Sprintf("%s:%d:%d", d.File, d.Line, d.Column)
+}
+
+// Close writes the json and sarif documents
+func (r *DiagnosticsReporter) Close() error {
+	if r.Streaming() {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var doc interface{}
+	if r.format == "sarif" {
+		doc = sarifLog(r.diagnostics)
+	} else {
+		diagnostics := r.diagnostics
+		if diagnostics == nil {
+			diagnostics = []Diagnostic{}
+		}
+		doc = diagnostics
+	}
+	enc := json.NewEncoder(r.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// The subset of SARIF 2.1.0 needed to report diagnostics
+
+type sarifDocument struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string `json:"name"`
+	InformationURI string `json:"informationUri"`
+}
+
+type sarifResult struct {
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+func sarifLog(diagnostics []Diagnostic) *sarifDocument {
+	run := sarifRun{
+		Tool:    sarifTool{Driver: sarifDriver{Name: "gsc", InformationURI: "https://github.com/celer/gshaderc"}},
+		Results: []sarifResult{},
+	}
+	for _, d := range diagnostics {
+		location := sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(d.File)}}
+		if d.Line > 0 {
+			location.Region = &sarifRegion{StartLine: d.Line, StartColumn: d.Column}
+		}
+		result := sarifResult{
+			Level:     d.Severity,
+			Message:   sarifMessage{Text: d.Message},
+			Locations: []sarifLocation{{PhysicalLocation: location}},
+		}
+		if d.Stage != "" || d.Variant != "" {
+			result.Properties = make(map[string]string)
+			if d.Stage != "" {
+				result.Properties["stage"] = d.Stage
+			}
+			if d.Variant != "" {
+				result.Properties["variant"] = d.Variant
+			}
+		}
+		run.Results = append(run.Results, result)
+	}
+	return &sarifDocument{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gs "github.com/celer/gshaderc"
+)
+
+func TestDiagnostics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"a.frag":      "#version 450\nvoid main() {}\n",
+		"broken.vert": "#version 450\n#error broken\nvoid main() {}\n",
+	})
+	broken := filepath.Join(dir, "broken.vert")
+
+	compiler := gs.NewCompilerWithBackend(gs.NewFakeBackend())
+	defer compiler.Release()
+
+	report := func(format string) string {
+		t.Helper()
+		var buf bytes.Buffer
+		diagnostics, err := NewDiagnosticsReporter(format, &buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		units, err := BatchUnits([]Source{
+			{Path: filepath.Join(dir, "a.frag"), Rel: "a.frag"},
+			{Path: broken, Rel: "broken.vert"},
+			{Path: filepath.Join(dir, "missing.frag"), Rel: "missing.frag"},
+		}, CompileConfig{}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		code := ExitOK
+		for _, unit := range units {
+			code = worseExitCode(code, exitCode(compileUnitReporting(compiler, unit, diagnostics)))
+		}
+		if code != ExitRead {
+			t.Fatalf("expected the read error to take priority, got %d", code)
+		}
+		if err := diagnostics.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	gcc := report("gcc")
+	if !strings.Contains(gcc, broken+":2: error: ") || !strings.Contains(gcc, "missing.frag: error: ") {
+		t.Fatalf("unexpected gcc diagnostics %q", gcc)
+	}
+
+	var diagnostics []Diagnostic
+	if err := json.Unmarshal([]byte(report("json")), &diagnostics); err != nil {
+		t.Fatal(err)
+	}
+	if len(diagnostics) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %+v", diagnostics)
+	}
+	if d := diagnostics[0]; d.File != broken || d.Line != 2 || d.Severity != "error" || d.Stage != "vert" || d.Message == "" {
+		t.Fatalf("unexpected diagnostic %+v", d)
+	}
+
+	var sarif sarifDocument
+	if err := json.Unmarshal([]byte(report("sarif")), &sarif); err != nil {
+		t.Fatal(err)
+	}
+	results := sarif.Runs[0].Results
+	if sarif.Version != "2.1.0" || len(results) != 2 || results[0].Level != "error" ||
+		results[0].Locations[0].PhysicalLocation.Region.StartLine != 2 || results[0].Properties["stage"] != "vert" ||
+		results[1].Locations[0].PhysicalLocation.Region != nil {
+		t.Fatalf("unexpected sarif %+v", sarif)
+	}
+
+	if _, err := NewDiagnosticsReporter("xml", nil); err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	if exitCode(nil) != ExitOK || exitCode(gs.CompilationError) != ExitCompile {
+		t.Fatal("unexpected exit codes")
+	}
+	wrapped := withExitCode(ExitWrite, &os.PathError{Op: "open", Path: "a.spv", Err: os.ErrPermission})
+	if exitCode(wrapped) != ExitWrite || !errors.Is(wrapped, os.ErrPermission) {
+		t.Fatal("expected the wrapped error to keep its exit code and cause")
+	}
+	if worseExitCode(ExitCompile, ExitConfig) != ExitConfig || worseExitCode(ExitRead, ExitWrite) != ExitRead {
+		t.Fatal("unexpected exit code priority")
+	}
+
+	// A bad command line is a usage error rather than the flag package's
+	// exit code of 2, -h isn't an error at all
+	commands := map[string]func([]string) int{
+		"build": buildMain, "check": checkMain, "gen": genMain, "pack": packMain,
+		"lsp": lspMain, "serve": serveMain, "daemon": daemonMain, "dis": disMain,
+	}
+	for name, command := range commands {
+		if code := command([]string{"-bogus"}); code != ExitUsage {
+			t.Errorf("expected 'gsc %s -bogus' to exit with %d, got %d", name, ExitUsage, code)
+		}
+		if code := command([]string{"-h"}); code != ExitOK {
+			t.Errorf("expected 'gsc %s -h' to exit with %d, got %d", name, ExitOK, code)
+		}
+	}
+}
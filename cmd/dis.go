@@ -0,0 +1,136 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	gs "github.com/celer/gshaderc"
+	"github.com/celer/gshaderc/pack"
+)
+
+// disMain implements 'gsc dis'
+func disMain(args []string) int {
+	flags := flag.NewFlagSet("dis", flag.ContinueOnError)
+	out := flags.String("o", "-", "file to write the assembly to, '-' writes to stdout")
+	entry := flags.String("entry", "", "for packs, only disassemble this entry, 'NAME' or 'NAME:VARIANT'")
+	var options gs.DisassemblyOptions
+	flags.BoolVar(&options.RawIDs, "raw-id", false, "write ids as numbers rather than names derived from debug names, types and constants")
+	flags.BoolVar(&options.NoHeader, "no-header", false, "leave out the comments describing the module header")
+	flags.BoolVar(&options.NoIndent, "no-indent", false, "don't line up instructions")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: gsc dis [flags] shader.spv|shaders.gspk|-\n\n")
+		fmt.Fprintf(flags.Output(), "disassembles a SPIR-V module, read from stdin with '-', or every module of a\n")
+		fmt.Fprintf(flags.Output(), "shader pack into SPIRV-Tools assembly\n\n")
+		flags.PrintDefaults()
+	}
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return ExitUsage
+	}
+	text, err := disassembleFile(flags.Arg(0), *entry, options)
+	if err != nil {
+		log.Printf("error: %v", err)
+		return ExitRead
+	}
+	if *out == "-" {
+		_, err = os.Stdout.WriteString(text)
+	} else {
+		err = ioutil.WriteFile(*out, []byte(text), 0644)
+	}
+	if err != nil {
+		log.Printf("error: %v", err)
+		return ExitWrite
+	}
+	return ExitOK
+}
+
+// disassembleFile disassembles a module, or the modules of a pack, each
+// preceded by a comment naming the entry when there's more than one
+func disassembleFile(path, entry string, options gs.DisassemblyOptions) (string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if !bytes.HasPrefix(data, []byte(pack.Magic)) {
+		if entry != "" {
+			return "", fmt.Errorf("-entry given but '%s' isn't a shader pack", path)
+		}
+		text, err := gs.Disassemble(data, options)
+		if err != nil {
+			return "", fmt.Errorf("error disassembling '%s': %w", path, err)
+		}
+		return text, nil
+	}
+
+	p, err := pack.NewReaderBytes(data)
+	if err != nil {
+		return "", fmt.Errorf("error reading '%s': %w", path, err)
+	}
+	entries := p.Entries()
+	if entry != "" {
+		name, variant := entry, ""
+		if i := strings.Index(entry, ":"); i >= 0 {
+			name, variant = entry[:i], entry[i+1:]
+		}
+		e, ok := p.Lookup(name, variant)
+		if !ok {
+			return "", fmt.Errorf("no entry '%s' in '%s'", entry, path)
+		}
+		entries = []pack.Entry{*e}
+	}
+
+	var out strings.Builder
+	for i := range entries {
+		e := &entries[i]
+		label := e.Name
+		if e.Variant != "" {
+			label += ":" + e.Variant
+		}
+		spirv, err := p.SPIRV(e)
+		if err != nil {
+			return "", fmt.Errorf("error reading '%s' from '%s': %w", label, path, err)
+		}
+		text, err := gs.Disassemble(spirv, options)
+		if err != nil {
+			return "", fmt.Errorf("error disassembling '%s' from '%s': %w", label, path, err)
+		}
+		if len(entries) > 1 {
+			if i > 0 {
+				out.WriteString("\n")
+			}
+			fmt.Fprintf(&out, "; Entry: %s\n", label)
+		}
+		out.WriteString(text)
+	}
+	return out.String(), nil
+}
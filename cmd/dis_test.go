@@ -0,0 +1,101 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gs "github.com/celer/gshaderc"
+	"github.com/celer/gshaderc/pack"
+)
+
+func TestDisassembleFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	compiler := gs.NewCompilerWithBackend(gs.NewFakeBackend())
+	defer compiler.Release()
+	options := gs.NewCompilerOptions()
+	defer options.Release()
+	compile := func(source string) []byte {
+		res := compiler.CompileIntoSPV(source, gs.FragmentShader, "a.frag", "main", options)
+		defer res.Release()
+		if res.Error() != nil {
+			t.Fatal(res.ErrorMessage())
+		}
+		return append([]byte(nil), res.Bytes()...)
+	}
+	plain, fogged := compile("#version 450\nvoid main() {}\n"), compile("#version 450\nfloat fog;\nvoid main() {}\n")
+
+	var buf bytes.Buffer
+	w := pack.NewWriter(&buf)
+	for _, e := range []struct {
+		variant string
+		spirv   []byte
+	}{{"", plain}, {"FOG", fogged}} {
+		if err := w.Add(pack.Entry{Name: "a.frag", Variant: e.variant, Stage: gs.FragmentShader}, e.spirv, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, dir, map[string]string{"a.spv": string(plain), "shaders.gspk": buf.String(), "bad.spv": "not SPIR-V"})
+
+	text, err := disassembleFile(filepath.Join(dir, "a.spv"), "", gs.DisassemblyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := gs.Disassemble(plain, gs.DisassemblyOptions{})
+	if text != expected || !strings.Contains(text, "OpCapability Shader") {
+		t.Errorf("unexpected disassembly:\n%s", text)
+	}
+
+	text, err = disassembleFile(filepath.Join(dir, "shaders.gspk"), "", gs.DisassemblyOptions{NoHeader: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(text, "; Entry: a.frag\n") || !strings.Contains(text, "\n\n; Entry: a.frag:FOG\n") || strings.Contains(text, "; SPIR-V") {
+		t.Errorf("unexpected pack disassembly:\n%s", text)
+	}
+
+	text, err = disassembleFile(filepath.Join(dir, "shaders.gspk"), "a.frag:FOG", gs.DisassemblyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ = gs.Disassemble(fogged, gs.DisassemblyOptions{})
+	if text != expected {
+		t.Errorf("unexpected entry disassembly:\n%s", text)
+	}
+
+	for _, c := range []struct{ path, entry string }{
+		{"bad.spv", ""},
+		{"a.spv", "a.frag"},
+		{"shaders.gspk", "b.frag"},
+		{"missing.spv", ""},
+	} {
+		if _, err := disassembleFile(filepath.Join(dir, c.path), c.entry, gs.DisassemblyOptions{}); err == nil {
+			t.Errorf("expected an error disassembling %s %s", c.path, c.entry)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	gs "github.com/celer/gshaderc"
+)
+
+// parseFlags parses the command line of a flag set created with
+// flag.ContinueOnError, ok is false if the program should exit with code
+// instead of running: 0 after -h and ExitUsage for a bad command line
+func parseFlags(flags *flag.FlagSet, args []string) (code int, ok bool) {
+	switch err := flags.Parse(args); err {
+	case nil:
+		return ExitOK, true
+	case flag.ErrHelp:
+		return ExitOK, false
+	}
+	return ExitUsage, false
+}
+
+// keyValueFlag is a repeatable flag taking NAME=VALUE, set is called for
+// each value given
+type keyValueFlag struct {
+	values []string
+	set    func(name, value string, hasValue bool) error
+}
+
+func (f *keyValueFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(f.values, " ")
+}
+
+func (f *keyValueFlag) Set(value string) error {
+	f.values = append(f.values, value)
+	name, v := value, ""
+	hasValue := false
+	if i := strings.Index(value, "="); i >= 0 {
+		name, v, hasValue = value[:i], value[i+1:], true
+	}
+	if name == "" {
+		return fmt.Errorf("missing name in '%s'", value)
+	}
+	return f.set(name, v, hasValue)
+}
+
+// switchFlag is a boolean flag which leaves its config switch nil unless
+// it's given, so -flag=false can override a manifest
+type switchFlag struct {
+	b **bool
+}
+
+func (f switchFlag) String() string {
+	if f.b == nil {
+		return ""
+	}
+	return strconv.FormatBool(enabled(*f.b))
+}
+
+func (f switchFlag) Set(value string) error {
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	*f.b = &v
+	return nil
+}
+
+func (f switchFlag) IsBoolFlag() bool {
+	return true
+}
+
+func uniformKindNames() string {
+	var names []string
+	for name := range uniformKinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// addConfigFlags registers the flags which set the fields of a compile
+// config
+func addConfigFlags(flags *flag.FlagSet, c *CompileConfig) {
+	flags.StringVar(&c.Target, "target", "", "specify compilation target (vulkan_1_0, vulkan_1_1, opengl, opengl_compat, webgpu), default "+gs.TargetVulkan11)
+	flags.StringVar(&c.EntryPoint, "entry-point", "", "entry point to the shader (default main)")
+	flags.StringVar(&c.Optimize, "optimize", "", "optimization level: zero, size or performance")
+	flags.StringVar(&c.Format, "format", "", "output format: "+outputFormatNames())
+	flags.StringVar(&c.OutputName, "out-name", "", "output file name template using {file}, {name}, {ext}, {stage} and {format} (default "+DefaultOutputName+")")
+	flags.StringVar(&c.Package, "package", "", "package of generated Go files (default "+DefaultPackage+")")
+	flags.StringVar(&c.SPIRVVersion, "spirv-version", "", "SPIR-V version to generate (1.0 to 1.5), by default it depends on the target")
+
+	flags.Var(&keyValueFlag{set: func(name, value string, _ bool) error {
+		if c.Macros == nil {
+			c.Macros = make(map[string]string)
+		}
+		c.Macros[name] = value
+		return nil
+	}}, "D", "define a macro, 'NAME' or 'NAME=VALUE' (repeatable)")
+	flags.Var((*StringList)(&c.IncludePaths), "I", "add a directory to the include search path (repeatable)")
+
+	flags.Var(switchFlag{&c.WarningsAsErrors}, "Werror", "treat warnings as errors")
+	flags.Var(switchFlag{&c.SuppressWarnings}, "w", "suppress all warnings")
+	flags.Var(switchFlag{&c.AutoBindUniforms}, "auto-bind-uniforms", "automatically assign bindings to uniforms without an explicit binding")
+	flags.Var(switchFlag{&c.InvertY}, "invert-y", "invert position.Y output in vertex shaders")
+	flags.Var(switchFlag{&c.NanClamp}, "nan-clamp", "make min, max and clamp favour non-NaN operands")
+	flags.Var(switchFlag{&c.DebugInfo}, "g", "generate debug information")
+	flags.StringVar(&c.Strip, "strip", "", "strip debug information from the SPIR-V and compact its ids, a comma separated list of "+strings.Join(StripNames, ", "))
+	flags.Var(switchFlag{&c.Canonicalize}, "canonicalize", "renumber and order the SPIR-V deterministically so identical shaders give identical bytes")
+
+	flags.Var(&keyValueFlag{set: func(name, value string, hasValue bool) error {
+		if _, ok := uniformKinds[name]; !ok {
+			return fmt.Errorf("unknown uniform kind '%s', expected one of %s", name, uniformKindNames())
+		}
+		base, err := strconv.ParseUint(value, 10, 32)
+		if err != nil || !hasValue {
+			return fmt.Errorf("invalid binding base '%s' for %s", value, name)
+		}
+		if c.BindingBases == nil {
+			c.BindingBases = make(map[string]uint32)
+		}
+		c.BindingBases[name] = uint32(base)
+		return nil
+	}}, "binding-base", "first binding automatically assigned to a uniform kind, 'KIND=N' with KIND one of "+uniformKindNames()+" (repeatable)")
+
+	flags.Var(&keyValueFlag{set: func(name, value string, hasValue bool) error {
+		if _, err := gs.ParseResourceLimit(name); err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || !hasValue {
+			return fmt.Errorf("invalid value '%s' for limit %s", value, name)
+		}
+		if c.Limits == nil {
+			c.Limits = make(map[string]int)
+		}
+		c.Limits[name] = n
+		return nil
+	}}, "limit", "set a resource limit, 'NAME=N' using glslang names, e.g. MaxDrawBuffers=8 (repeatable)")
+}
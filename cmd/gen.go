@@ -0,0 +1,769 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	gs "github.com/celer/gshaderc"
+)
+
+// DefaultGenFile is the file 'gsc gen' writes into the package directory
+const DefaultGenFile = "shaders_gen.go"
+
+// genShader is a compiled shader, or variant, in a generated package
+type genShader struct {
+	Name       string
+	Variant    string
+	Stage      string
+	EntryPoint string
+	Hash       string
+	// SourceHash is the hash of the source and everything it includes
+	SourceHash string
+	Reflection *gs.Reflection
+	SPIRV      []byte
+	// Func is the name of the accessor
+	Func string
+}
+
+// genState is what a generated file records about how it was generated,
+// so 'gsc gen' can tell whether it's up to date without compiling
+type genState struct {
+	// Input is the hash of the settings of every shader and of gen itself
+	Input string
+	// Deps maps every source and include, relative to the package
+	// directory, to the hash of its contents
+	Deps map[string]string
+	// Embeds are the files embedded with //go:embed
+	Embeds []string
+}
+
+// genMain implements 'gsc gen'
+func genMain(args []string) int {
+	flags := flag.NewFlagSet("gen", flag.ContinueOnError)
+	workers := flags.Int("j", runtime.NumCPU(), "number of shaders to compile concurrently")
+	diagnosticsFormat := flags.String("diagnostics-format", "text", "how errors and warnings are printed to stdout: "+strings.Join(DiagnosticsFormats, ", "))
+	pkg := flags.String("pkg", "", "package name of the generated file (default $GOPACKAGE, or the name of the package directory)")
+	file := flags.String("file", DefaultGenFile, "name of the generated file in the package directory")
+	embed := flags.Bool("embed", false, "write the SPIR-V next to the generated file and embed it with //go:embed, which needs Go 1.16, instead of as []uint32 literals")
+	check := flags.Bool("check", false, "don't write anything, exit non-zero if the generated file is out of date")
+	force := flags.Bool("force", false, "regenerate even if no source changed")
+	selection := addUnitFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: gsc gen [flags] [dirs/files/globs...]\n\n")
+		fmt.Fprintf(flags.Output(), "compiles the same shaders as 'gsc build' into a Go package with an accessor\n")
+		fmt.Fprintf(flags.Output(), "per shader and variant, for use with //go:generate. -out-dir is the package\n")
+		fmt.Fprintf(flags.Output(), "directory, by default the current directory.\n\n")
+		flags.PrintDefaults()
+	}
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	if *check && *force {
+		log.Printf("error: -check and -force can't be used together")
+		return ExitUsage
+	}
+
+	diagnostics, err := NewDiagnosticsReporter(*diagnosticsFormat, os.Stdout)
+	if err != nil {
+		log.Printf("error: %v", err)
+		return ExitUsage
+	}
+
+	units, code := selection.units(flags.Args())
+	if code != ExitOK {
+		return code
+	}
+	g := &generator{
+		Dir:         selection.outDir,
+		File:        *file,
+		Package:     *pkg,
+		Embed:       *embed,
+		Workers:     *workers,
+		Diagnostics: diagnostics,
+	}
+	if *check {
+		code = g.Check(units)
+	} else {
+		code = g.Generate(gs.NewCompiler, units, *force)
+	}
+	if err := diagnostics.Close(); err != nil {
+		log.Printf("error: %v", err)
+		code = worseExitCode(code, ExitWrite)
+	}
+	return code
+}
+
+// generator writes the Go package of 'gsc gen'
+type generator struct {
+	// Dir is the package directory, empty for the current directory
+	Dir string
+	// File is the name of the generated file in Dir
+	File string
+	// Package is the name of the package, empty to use genPackageName
+	Package string
+	// Embed embeds the SPIR-V with //go:embed
+	Embed   bool
+	Workers int
+	// Diagnostics receives the errors and warnings of every compile, nil
+	// prints compiler errors to stdout
+	Diagnostics *DiagnosticsReporter
+}
+
+func (g *generator) dir() string {
+	if g.Dir == "" {
+		return "."
+	}
+	return g.Dir
+}
+
+func (g *generator) pkg() string {
+	if g.Package == "" {
+		return genPackageName(g.dir())
+	}
+	return g.Package
+}
+
+// stale reads the generated file and logs why it's out of date. It returns
+// the state it recorded and the hash of the inputs, or the exit code if
+// either can't be worked out.
+func (g *generator) stale(units []*BuildUnit) (previous *genState, input string, changed bool, code int) {
+	path := filepath.Join(g.dir(), g.File)
+	input, err := genInputHash(units, g.dir(), g.pkg(), g.Embed)
+	if err != nil {
+		log.Printf("error: %v", err)
+		return nil, "", false, ExitConfig
+	}
+	previous, err = readGenState(path)
+	if err != nil {
+		log.Printf("error: %v", err)
+		return nil, "", false, ExitRead
+	}
+	reasons := previous.changed(input, g.dir())
+	if len(reasons) == 0 {
+		log.Printf("%s is up to date", path)
+	}
+	for _, reason := range reasons {
+		log.Printf("%s: %s", path, reason)
+	}
+	return previous, input, len(reasons) > 0, ExitOK
+}
+
+// Check returns ExitStale if the package generated from units is out of
+// date, without compiling anything
+func (g *generator) Check(units []*BuildUnit) int {
+	_, _, changed, code := g.stale(units)
+	if code == ExitOK && changed {
+		return ExitStale
+	}
+	return code
+}
+
+// Generate compiles units and writes the package, unless it is up to date
+// and force is false. The compiler is only created if something changed.
+func (g *generator) Generate(newCompiler func() *gs.Compiler, units []*BuildUnit, force bool) int {
+	dir := g.dir()
+	path := filepath.Join(dir, g.File)
+	previous, input, changed, code := g.stale(units)
+	if code != ExitOK {
+		return code
+	}
+	if !changed && !force {
+		return ExitOK
+	}
+
+	compiler := newCompiler()
+	defer compiler.Release()
+	shaders, deps, code := genCompile(compiler, units, g.Workers, g.Diagnostics)
+	if code != ExitOK {
+		return code
+	}
+	if err := genNames(shaders); err != nil {
+		log.Printf("error: %v", err)
+		return ExitConfig
+	}
+
+	// Dependencies are recorded relative to the package, so the generated
+	// file is up to date wherever the repository is checked out
+	state := &genState{Input: input, Deps: make(map[string]string)}
+	for _, dep := range deps {
+		data, err := ioutil.ReadFile(dep)
+		if err != nil {
+			log.Printf("error: %v", err)
+			return ExitRead
+		}
+		state.Deps[relPath(dir, dep)] = hash(data)
+	}
+
+	var blobs map[string][]byte
+	if g.Embed {
+		blobs = make(map[string][]byte)
+	}
+	src, err := generatePackage(g.pkg(), state, shaders, blobs)
+	if err != nil {
+		log.Printf("error: %v", err)
+		return ExitConfig
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("error: %v", err)
+		return ExitWrite
+	}
+	for name, data := range blobs {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			log.Printf("error: %v", err)
+			return ExitWrite
+		}
+	}
+	if err := ioutil.WriteFile(path, src, 0644); err != nil {
+		log.Printf("error: %v", err)
+		return ExitWrite
+	}
+	// Remove what the previous generation embedded and this one doesn't
+	for _, name := range previous.Embeds {
+		if _, ok := blobs[name]; !ok && filepath.Base(name) == name {
+			os.Remove(filepath.Join(dir, name))
+		}
+	}
+	log.Printf("generated %s: %d shaders", path, len(shaders))
+	return ExitOK
+}
+
+// relPath returns path relative to dir with forward slashes, or absolute
+// if it can't be made relative
+func relPath(dir, path string) string {
+	if rel, err := filepath.Rel(absPath(dir), absPath(path)); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(absPath(path))
+}
+
+// genPackageName returns the package name of dir, $GOPACKAGE when
+// generating into the current directory
+func genPackageName(dir string) string {
+	if pkg := os.Getenv("GOPACKAGE"); pkg != "" && filepath.Clean(dir) == "." {
+		return pkg
+	}
+	name := strings.ToLower(strings.Join(identifierParts(filepath.Base(absPath(dir))), ""))
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		return DefaultPackage
+	}
+	return name
+}
+
+// genInputHash hashes everything other than file contents which changes
+// the generated package. Paths are hashed relative to the package
+// directory dir, so they don't depend on where gen is run from.
+func genInputHash(units []*BuildUnit, dir, pkg string, embed bool) (string, error) {
+	type input struct {
+		Source   string
+		Stage    gs.ShaderType
+		Config   CompileConfig
+		Variants *gs.VariantSet
+	}
+	inputs := struct {
+		Version int
+		Package string
+		Embed   bool
+		Units   []input
+	}{Version: 1, Package: pkg, Embed: embed}
+	for _, unit := range units {
+		config := unit.Config
+		config.IncludePaths = make([]string, len(unit.Config.IncludePaths))
+		for i, p := range unit.Config.IncludePaths {
+			config.IncludePaths[i] = relPath(dir, p)
+		}
+		inputs.Units = append(inputs.Units, input{relPath(dir, unit.Source), unit.Stage, config, unit.Variants})
+	}
+	data, err := json.Marshal(inputs)
+	if err != nil {
+		return "", err
+	}
+	return hash(data), nil
+}
+
+// readGenState reads the state recorded in a generated file, a file which
+// doesn't exist has an empty state
+func readGenState(path string) (*genState, error) {
+	state := &genState{Deps: make(map[string]string)}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		switch {
+		case len(fields) == 3 && fields[0] == "//" && fields[1] == "gsc:input":
+			state.Input = fields[2]
+		case len(fields) == 4 && fields[0] == "//" && fields[1] == "gsc:dep":
+			state.Deps[fields[2]] = fields[3]
+		case len(fields) == 2 && fields[0] == "//go:embed":
+			state.Embeds = append(state.Embeds, fields[1])
+		}
+	}
+	return state, scanner.Err()
+}
+
+// changed describes why a generated file in dir with this state is out of
+// date, it is empty if the file is up to date
+func (s *genState) changed(input, dir string) []string {
+	if s.Input == "" {
+		return []string{"not generated yet"}
+	}
+	var changed []string
+	if s.Input != input {
+		changed = append(changed, "the shaders or their settings changed")
+	}
+	var deps []string
+	for dep := range s.Deps {
+		deps = append(deps, dep)
+	}
+	sort.Strings(deps)
+	for _, dep := range deps {
+		p := filepath.FromSlash(dep)
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		data, err := ioutil.ReadFile(p)
+		switch {
+		case err != nil:
+			changed = append(changed, dep+" is gone")
+		case hash(data) != s.Deps[dep]:
+			changed = append(changed, dep+" changed")
+		}
+	}
+	for _, name := range s.Embeds {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			changed = append(changed, name+" is missing")
+		}
+	}
+	return changed
+}
+
+// genCompile compiles every unit, and every variant, into SPIR-V. It
+// returns the shaders and every file they were compiled from, or the exit
+// code if any failed.
+func genCompile(compiler *gs.Compiler, units []*BuildUnit, workers int, diagnostics *DiagnosticsReporter) ([]*genShader, []string, int) {
+	var mu sync.Mutex
+	compiled := make(map[*BuildUnit][]*genShader)
+	seen := make(map[string]bool)
+	var deps []string
+	code := ExitOK
+	compileUnits(compiler, units, workers, func(compiler *gs.Compiler, unit *BuildUnit) error {
+		shaders, unitDeps, err := genUnit(compiler, unit, diagnostics)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			if exitCode(err) != ExitCompile {
+				diagnostics.Error(unit, "", err)
+			}
+			log.Printf("error compiling shader '%s': %v", unit.Source, err)
+			code = worseExitCode(code, exitCode(err))
+			return err
+		}
+		compiled[unit] = shaders
+		for _, dep := range unitDeps {
+			if !seen[dep] {
+				seen[dep] = true
+				deps = append(deps, dep)
+			}
+		}
+		return nil
+	})
+	if code != ExitOK {
+		return nil, nil, code
+	}
+	var shaders []*genShader
+	for _, unit := range units {
+		shaders = append(shaders, compiled[unit]...)
+	}
+	sort.Strings(deps)
+	return shaders, deps, ExitOK
+}
+
+// genUnit compiles a unit and its variants and reflects the results,
+// reporting the compiler's messages to diagnostics
+func genUnit(compiler *gs.Compiler, unit *BuildUnit, diagnostics *DiagnosticsReporter) ([]*genShader, []string, error) {
+	options, err := unit.Config.Options()
+	if err != nil {
+		return nil, nil, withExitCode(ExitConfig, err)
+	}
+	post, err := unit.Config.PostProcessing()
+	if err != nil {
+		return nil, nil, err
+	}
+	options = options.Clone()
+	recorder := gs.NewIncludeRecorder(options.IncludeResolver())
+	options.SetIncludeCallback(recorder.Resolve)
+
+	data, err := ioutil.ReadFile(unit.Source)
+	if err != nil {
+		return nil, nil, withExitCode(ExitRead, err)
+	}
+	entryPoint := unit.Config.GetEntryPoint()
+	// the reflection comes from the SPIR-V before it's stripped of names
+	shader := func(variant string, spirv []byte) (*genShader, error) {
+		reflection, err := gs.Reflect(spirv)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't reflect '%s': %w", unit.Source, err)
+		}
+		if spirv, err = post.Apply(spirv); err != nil {
+			return nil, fmt.Errorf("post-processing '%s': %w", unit.Source, err)
+		}
+		return &genShader{
+			Variant:    variant,
+			Stage:      gs.GetShaderExtensionByType(unit.Stage),
+			EntryPoint: entryPoint,
+			Hash:       hash(spirv),
+			Reflection: reflection,
+			SPIRV:      spirv,
+		}, nil
+	}
+
+	var shaders []*genShader
+	if unit.Variants != nil {
+		results := compiler.CompileVariants(string(data), unit.Stage, unit.Source, entryPoint, options, unit.Variants)
+		for _, key := range results.Keys {
+			res := results.Results[key]
+			diagnostics.Compiler(unit, key, res.ErrorMessage, res.Err != nil)
+		}
+		if err := results.Err(); err != nil {
+			return nil, nil, err
+		}
+		for _, key := range results.Keys {
+			s, err := shader(key, results.Results[key].Data)
+			if err != nil {
+				return nil, nil, err
+			}
+			shaders = append(shaders, s)
+		}
+	} else {
+		result := compiler.CompileIntoSPV(string(data), unit.Stage, unit.Source, entryPoint, options)
+		defer result.Release()
+		diagnostics.Compiler(unit, "", result.ErrorMessage(), result.Error() != nil)
+		if result.Error() != nil {
+			return nil, nil, result.Error()
+		}
+		s, err := shader("", result.Bytes())
+		if err != nil {
+			return nil, nil, err
+		}
+		shaders = append(shaders, s)
+	}
+	deps := append([]string{unit.Source}, recorder.Includes()...)
+	sourceHash, err := hashFiles(deps)
+	if err != nil {
+		return nil, nil, withExitCode(ExitRead, err)
+	}
+	for _, s := range shaders {
+		s.Name = unit.Source
+		s.SourceHash = sourceHash
+	}
+	return shaders, deps, nil
+}
+
+// hashFiles returns the hex encoded SHA-256 of the contents of files
+func hashFiles(files []string) (string, error) {
+	h := sha256.New()
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%d:", len(data))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// genNames names the shaders by their path relative to the directory
+// containing every source and gives each an accessor
+func genNames(shaders []*genShader) error {
+	var root string
+	for i, s := range shaders {
+		dir := filepath.Dir(absPath(s.Name))
+		if i == 0 {
+			root = dir
+			continue
+		}
+		for root != filepath.Dir(root) && !strings.HasPrefix(dir+string(filepath.Separator), root+string(filepath.Separator)) {
+			root = filepath.Dir(root)
+		}
+	}
+	funcs := make(map[string]*genShader)
+	for _, s := range shaders {
+		if rel, err := filepath.Rel(root, absPath(s.Name)); err == nil {
+			s.Name = filepath.ToSlash(rel)
+		}
+		s.Func = goIdentifier(s.Name)
+		if s.Variant != "" {
+			s.Func += "_" + strings.Join(identifierParts(s.Variant), "_")
+		}
+		if other, ok := funcs[s.Func]; ok {
+			return fmt.Errorf("'%s' and '%s' would both be accessed with %s()", genDescription(other), genDescription(s), s.Func)
+		}
+		funcs[s.Func] = s
+	}
+	return nil
+}
+
+func genDescription(s *genShader) string {
+	if s.Variant == "" {
+		return s.Name
+	}
+	return s.Name + " [" + s.Variant + "]"
+}
+
+// genRuntime is the part of a generated package which doesn't depend on
+// the shaders
+const genRuntime = `
+// Shader is a compiled shader, or a variant of one
+type Shader struct {
+	// Name is the path of the source relative to the shader root
+	Name string
+	// Variant is the key of the variant's macros, e.g. "FOG,LIGHTS=4",
+	// empty for shaders without variants
+	Variant    string
+	Stage      string
+	EntryPoint string
+	// Hash is the hex encoded SHA-256 of the SPIR-V
+	Hash       string
+	Reflection Reflection
+
+	%s
+}
+
+// Reflection describes the interface of a shader
+type Reflection struct {
+	EntryPoints   []EntryPoint
+	Inputs        []InterfaceVariable
+	Outputs       []InterfaceVariable
+	Bindings      []DescriptorBinding
+	PushConstants []PushConstantBlock
+}
+
+// EntryPoint is an entry point of a shader, LocalSize is the workgroup
+// size of compute shaders
+type EntryPoint struct {
+	Name      string
+	Stage     string
+	LocalSize [3]uint32
+}
+
+// InterfaceVariable is a stage input or output
+type InterfaceVariable struct {
+	Name     string
+	Location uint32
+}
+
+// DescriptorBinding is a resource bound through a descriptor set, Count
+// is 0 for runtime sized arrays
+type DescriptorBinding struct {
+	Name    string
+	Set     uint32
+	Binding uint32
+	Kind    string
+	Count   uint32
+}
+
+// PushConstantBlock is a push constant block and its size in bytes
+type PushConstantBlock struct {
+	Name string
+	Size uint32
+}
+
+// Shaders returns every shader in the package
+func Shaders() []*Shader {
+	all := make([]*Shader, len(shaders))
+	for i := range shaders {
+		all[i] = &shaders[i]
+	}
+	return all
+}
+
+// Lookup returns the shader with the given name and variant key
+func Lookup(name, variant string) (*Shader, bool) {
+	for i := range shaders {
+		if shaders[i].Name == name && shaders[i].Variant == variant {
+			return &shaders[i], true
+		}
+	}
+	return nil, false
+}
+`
+
+// genEmbedAccessors return the SPIR-V of shaders embedded as bytes
+const genEmbedAccessors = `
+// SPIRV returns the SPIR-V as words, in a new slice
+func (s *Shader) SPIRV() []uint32 {
+	words := make([]uint32, len(s.spirv)/4)
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint32(s.spirv[i*4:])
+	}
+	return words
+}
+
+// Bytes returns the SPIR-V as little endian bytes, which must not be
+// modified
+func (s *Shader) Bytes() []byte {
+	return s.spirv
+}
+`
+
+// genLiteralAccessors return the SPIR-V of shaders written as words
+const genLiteralAccessors = `
+// SPIRV returns the SPIR-V as words, which must not be modified
+func (s *Shader) SPIRV() []uint32 {
+	return s.spirv
+}
+
+// Bytes returns the SPIR-V as little endian bytes, in a new slice
+func (s *Shader) Bytes() []byte {
+	data := make([]byte, len(s.spirv)*4)
+	for i, w := range s.spirv {
+		binary.LittleEndian.PutUint32(data[i*4:], w)
+	}
+	return data
+}
+`
+
+// generatePackage writes the Go source of a package holding shaders. If
+// blobs isn't nil the SPIR-V is embedded with //go:embed and blobs is
+// filled with the files to write next to the source.
+func generatePackage(pkg string, state *genState, shaders []*genShader, blobs map[string][]byte) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("// Code generated by gsc gen. DO NOT EDIT.\n")
+	b.WriteString("// gsc:input " + state.Input + "\n")
+	var deps []string
+	for dep := range state.Deps {
+		deps = append(deps, dep)
+	}
+	sort.Strings(deps)
+	for _, dep := range deps {
+		fmt.Fprintf(&b, "// gsc:dep %s %s\n", dep, state.Deps[dep])
+	}
+
+	fmt.Fprintf(&b, "\n// Package %s holds compiled shaders, regenerate it with go generate\npackage %s\n\n", pkg, pkg)
+	if blobs != nil {
+		b.WriteString("import (\n\t_ \"embed\"\n\t\"encoding/binary\"\n)\n")
+		fmt.Fprintf(&b, genRuntime, "spirv []byte")
+		b.WriteString(genEmbedAccessors)
+	} else {
+		b.WriteString("import \"encoding/binary\"\n")
+		fmt.Fprintf(&b, genRuntime, "spirv []uint32")
+		b.WriteString(genLiteralAccessors)
+	}
+
+	// Variants often compile to the same SPIR-V, which is only written once
+	vars := make(map[string]string)
+	b.WriteString("\nvar shaders = [...]Shader{\n")
+	for _, s := range shaders {
+		v, ok := vars[s.Hash]
+		if !ok {
+			v = "spirv" + s.Func
+			vars[s.Hash] = v
+		}
+		fmt.Fprintf(&b, "{Name: %q, Variant: %q, Stage: %q, EntryPoint: %q, Hash: %q, spirv: %s,\n", s.Name, s.Variant, s.Stage, s.EntryPoint, s.Hash, v)
+		writeReflection(&b, s.Reflection)
+		b.WriteString("},\n")
+	}
+	b.WriteString("}\n")
+
+	for i, s := range shaders {
+		fmt.Fprintf(&b, "\n// %s returns %s\nfunc %s() *Shader {\n\treturn &shaders[%d]\n}\n", s.Func, genDescription(s), s.Func, i)
+	}
+
+	written := make(map[string]bool)
+	for _, s := range shaders {
+		v := vars[s.Hash]
+		if written[v] {
+			continue
+		}
+		written[v] = true
+		if blobs != nil {
+			name := cIdentifier(genDescription(s)) + ".spv"
+			blobs[name] = s.SPIRV
+			fmt.Fprintf(&b, "\n//go:embed %s\nvar %s []byte\n", name, v)
+			continue
+		}
+		w := words(s.SPIRV)
+		values := make([]string, len(w))
+		for i, x := range w {
+			values[i] = fmt.Sprintf("0x%08x", x)
+		}
+		fmt.Fprintf(&b, "\nvar %s = []uint32{\n", v)
+		writeValues(&b, "\t", values, 8)
+		b.WriteString("}\n")
+	}
+	return format.Source(b.Bytes())
+}
+
+// writeReflection writes the Reflection field of a shader
+func writeReflection(b *bytes.Buffer, r *gs.Reflection) {
+	b.WriteString("Reflection: Reflection{\n")
+	if len(r.EntryPoints) > 0 {
+		b.WriteString("EntryPoints: []EntryPoint{\n")
+		for _, e := range r.EntryPoints {
+			fmt.Fprintf(b, "{Name: %q, Stage: %q, LocalSize: [3]uint32{%d, %d, %d}},\n", e.Name, gs.GetShaderExtensionByType(e.Stage), e.LocalSize[0], e.LocalSize[1], e.LocalSize[2])
+		}
+		b.WriteString("},\n")
+	}
+	for _, vars := range []struct {
+		field string
+		vars  []gs.InterfaceVariable
+	}{{"Inputs", r.Inputs}, {"Outputs", r.Outputs}} {
+		if len(vars.vars) == 0 {
+			continue
+		}
+		fmt.Fprintf(b, "%s: []InterfaceVariable{\n", vars.field)
+		for _, v := range vars.vars {
+			fmt.Fprintf(b, "{Name: %q, Location: %d},\n", v.Name, v.Location)
+		}
+		b.WriteString("},\n")
+	}
+	if len(r.Bindings) > 0 {
+		b.WriteString("Bindings: []DescriptorBinding{\n")
+		for _, d := range r.Bindings {
+			fmt.Fprintf(b, "{Name: %q, Set: %d, Binding: %d, Kind: %q, Count: %d},\n", d.Name, d.Set, d.Binding, d.Kind, d.Count)
+		}
+		b.WriteString("},\n")
+	}
+	if len(r.PushConstants) > 0 {
+		b.WriteString("PushConstants: []PushConstantBlock{\n")
+		for _, p := range r.PushConstants {
+			fmt.Fprintf(b, "{Name: %q, Size: %d},\n", p.Name, p.Size)
+		}
+		b.WriteString("},\n")
+	}
+	b.WriteString("},\n")
+}
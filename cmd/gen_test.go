@@ -0,0 +1,192 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gs "github.com/celer/gshaderc"
+)
+
+func TestGen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"shaders/common.glsl":     "vec4 fog() { return vec4(1); }\n",
+		"shaders/pbr.frag":        "#version 450\n#include \"common.glsl\"\n#ifdef FOG\nvoid fogged() {}\n#endif\nvoid main() {}\n",
+		"shaders/post/blur.comp":  "#version 450\nvoid main() {}\n",
+		"shaders/post/unused.txt": "",
+	})
+	sources, err := (&SourceSelector{Extensions: DefaultShaderExtensions}).Collect([]string{filepath.Join(dir, "shaders")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	units, err := BatchUnits(sources, CompileConfig{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, unit := range units {
+		if strings.HasSuffix(unit.Source, "pbr.frag") {
+			unit.Variants = &gs.VariantSet{Axes: []gs.VariantAxis{
+				{Name: "FOG", Values: []string{""}, Optional: true},
+				{Name: "DEBUG", Values: []string{""}, Optional: true},
+			}}
+		}
+	}
+
+	compiler := gs.NewCompilerWithBackend(gs.NewFakeBackend())
+	defer compiler.Release()
+	shaders, deps, code := genCompile(compiler, units, 2, nil)
+	if code != ExitOK {
+		t.Fatalf("unexpected exit code %d", code)
+	}
+	if err := genNames(shaders); err != nil {
+		t.Fatal(err)
+	}
+	var funcs []string
+	for _, s := range shaders {
+		funcs = append(funcs, s.Name+" "+s.Func)
+	}
+	expected := "pbr.frag PbrFrag,pbr.frag PbrFrag_DEBUG,pbr.frag PbrFrag_FOG,pbr.frag PbrFrag_DEBUG_FOG,post/blur.comp PostBlurComp"
+	if got := strings.Join(funcs, ","); got != expected {
+		t.Fatalf("unexpected shaders %s", got)
+	}
+	if len(deps) != 3 || !strings.HasSuffix(deps[0], "common.glsl") {
+		t.Fatalf("unexpected dependencies %v", deps)
+	}
+
+	input, err := genInputHash(units, dir, "shaders", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := &genState{Input: input, Deps: make(map[string]string)}
+	for _, dep := range deps {
+		data, err := ioutil.ReadFile(dep)
+		if err != nil {
+			t.Fatal(err)
+		}
+		state.Deps[filepath.ToSlash(dep)] = hash(data)
+	}
+
+	// DEBUG doesn't change the SPIR-V, so only three modules are written
+	src, err := generatePackage("shaders", state, shaders, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(src), "= []uint32{"); n != 3 {
+		t.Fatalf("expected 3 modules, got %d", n)
+	}
+	if !strings.Contains(string(src), "func PbrFrag_DEBUG_FOG() *Shader") {
+		t.Fatal("missing accessor")
+	}
+	blobs := make(map[string][]byte)
+	if _, err := generatePackage("shaders", state, shaders, blobs); err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 3 || blobs["pbr_frag_FOG.spv"] == nil {
+		t.Fatalf("unexpected blobs %v", blobs)
+	}
+
+	path := filepath.Join(dir, DefaultGenFile)
+	if err := ioutil.WriteFile(path, src, 0644); err != nil {
+		t.Fatal(err)
+	}
+	previous, err := readGenState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed := previous.changed(input, dir); len(changed) != 0 {
+		t.Fatalf("expected the package to be up to date, got %v", changed)
+	}
+	writeFiles(t, dir, map[string]string{"shaders/common.glsl": "vec4 fog() { return vec4(0); }\n"})
+	if changed := previous.changed(input, dir); len(changed) != 1 || !strings.HasSuffix(changed[0], "common.glsl changed") {
+		t.Fatalf("expected the include to have changed, got %v", changed)
+	}
+	if changed := previous.changed("other", dir); len(changed) != 2 {
+		t.Fatalf("expected the settings to have changed, got %v", changed)
+	}
+}
+
+func TestGenWorkingDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"shaders/common.glsl": "vec4 fog() { return vec4(1); }\n",
+		"shaders/a.frag":      "#version 450\n#include \"common.glsl\"\nvoid main() {}\n",
+	})
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	newCompiler := func() *gs.Compiler {
+		return gs.NewCompilerWithBackend(gs.NewFakeBackend())
+	}
+	// units returns the shaders given as an argument relative to the
+	// current directory, like 'gsc gen' does
+	units := func(arg string) []*BuildUnit {
+		sources, err := (&SourceSelector{Extensions: DefaultShaderExtensions}).Collect([]string{arg})
+		if err != nil {
+			t.Fatal(err)
+		}
+		units, err := BatchUnits(sources, CompileConfig{}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return units
+	}
+
+	// 'gsc gen -out-dir pkg shaders' from the root, then 'gsc gen -check
+	// ../shaders' from the package
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	g := &generator{Dir: "pkg", File: DefaultGenFile, Package: "pkg", Workers: 1}
+	if code := g.Generate(newCompiler, units("shaders"), false); code != ExitOK {
+		t.Fatalf("unexpected exit code %d", code)
+	}
+	src, err := ioutil.ReadFile(filepath.Join("pkg", DefaultGenFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(src), "// gsc:dep ../shaders/common.glsl ") || strings.Contains(string(src), dir) {
+		t.Fatalf("expected the dependencies relative to the package:\n%s", src)
+	}
+	if err := os.Chdir(filepath.Join(dir, "pkg")); err != nil {
+		t.Fatal(err)
+	}
+	g = &generator{File: DefaultGenFile, Package: "pkg", Workers: 1}
+	if code := g.Check(units("../shaders")); code != ExitOK {
+		t.Fatalf("expected the package to be up to date from its directory, got %d", code)
+	}
+	writeFiles(t, dir, map[string]string{"shaders/common.glsl": "vec4 fog() { return vec4(0); }\n"})
+	if code := g.Check(units("../shaders")); code != ExitStale {
+		t.Fatalf("expected the package to be stale after an include changed, got %d", code)
+	}
+
+	if code := genMain([]string{"-check", "-force", "../shaders"}); code != ExitUsage {
+		t.Fatalf("expected -check with -force to be a usage error, got %d", code)
+	}
+}
@@ -15,19 +15,31 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 
 	gs "github.com/celer/gshaderc"
-	"github.com/fsnotify/fsnotify"
+	"github.com/celer/gshaderc/hotreload"
 )
 
-var target = flag.String("target", gs.TargetVulkan11, "specify compilation target (vulkan_1_0, vulkan_1_1, opengl, opengl_compat, webgpu)")
 var input = flag.String("input", "", "input shader to compile")
-var entryPoint = flag.String("entry-point", "main", "entry point to the shader")
+var output = flag.String("o", "", "output file, '-' writes to stdout (default is the output name template in the input's directory)")
+var debounce = flag.Duration("debounce", DefaultDebounce, "how long watch mode waits for a burst of changes to finish before compiling")
+var notifyHTTP = flag.String("notify-http", "", "in watch mode, serve compile events as server-sent events at http://<addr>/events, e.g. localhost:9797")
+var notifySocket = flag.String("notify-socket", "", "in watch mode, stream compile events as newline delimited JSON on this Unix socket")
+var depfile = flag.Bool("MD", false, "write a Makefile depfile, <output>.d, listing the source and everything it includes")
+var depfilePath = flag.String("MF", "", "write the depfile of -input to this file, implies -MD")
+var diagnosticsFormat = flag.String("diagnostics-format", "text", "how errors and warnings are printed to stdout: "+strings.Join(DiagnosticsFormats, ", ")+", json and sarif can't be used with -watch")
+var symbol = flag.String("var", "", "name of the variable in C and Go outputs (default derived from the output name)")
 var forSize = flag.Bool("optimize-size", false, "optimize for size")
 var forPerf = flag.Bool("optimize-performance", false, "optimize for performance")
 
@@ -44,131 +56,249 @@ func (w *WatchDirs) Set(value string) error {
 
 var watchDirs WatchDirs
 
-func main() {
+// StringList is a flag which may be given multiple times
+type StringList []string
 
-	flag.Var(&watchDirs, "watch", "directory to watch for changes")
+func (s *StringList) String() string {
+	return strings.Join(*s, " ")
+}
 
-	flag.Parse()
+func (s *StringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
 
-	if len(watchDirs) > 0 {
-		watcher, err := NewWatcher(watchDirs)
-		if err != nil {
-			log.Printf("%v", err)
-			os.Exit(-7)
+var variantAxes StringList
+var variantExcludes StringList
+
+var config CompileConfig
+
+func main() {
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "build":
+			os.Exit(buildMain(os.Args[2:]))
+		case "check":
+			os.Exit(checkMain(os.Args[2:]))
+		case "gen":
+			os.Exit(genMain(os.Args[2:]))
+		case "pack":
+			os.Exit(packMain(os.Args[2:]))
+		case "lsp":
+			os.Exit(lspMain(os.Args[2:]))
+		case "serve":
+			os.Exit(serveMain(os.Args[2:]))
+		case "daemon":
+			os.Exit(daemonMain(os.Args[2:]))
+		case "dis":
+			os.Exit(disMain(os.Args[2:]))
 		}
-		watcher.Run()
-		os.Exit(0)
 	}
 
-	if *input == "" {
-		flag.PrintDefaults()
-		os.Exit(-1)
-	}
+	addConfigFlags(flag.CommandLine, &config)
+	flag.Var(&watchDirs, "watch", "directory to watch for changes, subdirectories are watched too (repeatable)")
+	flag.Var(&variantAxes, "variant", "compile a variant per value of a macro, 'NAME=V1,V2' or just 'NAME' to toggle whether it's defined (repeatable)")
+	flag.Var(&variantExcludes, "variant-exclude", "exclude variants matching all of 'NAME=V,...' (repeatable)")
 
-	options := gs.NewCompilerOptions()
-	defer options.Release()
-	compiler := gs.NewCompiler()
-	defer compiler.Release()
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	if code, ok := parseFlags(flag.CommandLine, os.Args[1:]); !ok {
+		os.Exit(code)
+	}
 
-	shaderType := gs.GetShaderTypeByFilename(*input)
+	if len(watchDirs) == 0 && *input == "" {
+		flag.PrintDefaults()
+		os.Exit(ExitUsage)
+	}
 
-	err := options.SetTargetByName(*target)
+	diagnostics, err := NewDiagnosticsReporter(*diagnosticsFormat, os.Stdout)
 	if err != nil {
 		log.Printf("error: %v", err)
-		os.Exit(-5)
+		os.Exit(ExitUsage)
+	}
+	if !diagnostics.Streaming() && (len(watchDirs) > 0 || *output == "-") {
+		log.Printf("error: -diagnostics-format %s can't be used with -watch or output to stdout", *diagnosticsFormat)
+		os.Exit(ExitUsage)
 	}
-
-	log.Printf("Target: %s", *target)
 
 	if *forSize {
-		options.SetOptimizationLevel(gs.Size)
+		config.Optimize = "size"
 	} else if *forPerf {
-		options.SetOptimizationLevel(gs.Performance)
+		config.Optimize = "performance"
 	}
 
-	data, err := ioutil.ReadFile(*input)
-	if err != nil {
-		log.Printf("error reading file: %v\n", err)
-		os.Exit(-2)
+	var variants *gs.VariantSet
+	if len(variantAxes) > 0 {
+		variants, err = parseVariantSet(variantAxes, variantExcludes)
+		if err != nil {
+			log.Printf("error: %v", err)
+			os.Exit(ExitConfig)
+		}
 	}
 
-	result := compiler.CompileIntoSPV(string(data), shaderType, *input, *entryPoint, options)
-	defer result.Release()
+	compiler := gs.NewCompiler()
+	defer compiler.Release()
 
-	if result.Error() == nil {
-		err := ioutil.WriteFile(*input+".spv", result.Bytes(), 0644)
-		if err != nil {
-			log.Printf("error writing output: %v", err)
-			os.Exit(-3)
-		}
-		os.Exit(0)
+	builder, err := NewBuilder(compiler, config, variants)
+	if err != nil {
+		log.Printf("error: %v", err)
+		os.Exit(ExitConfig)
+	}
+	builder.Depfiles = *depfile
+	builder.Diagnostics = diagnostics
+
+	if config.Target != "" {
+		log.Printf("Target: %s", config.Target)
 	} else {
-		log.Printf("error compiling shader: %v\n", result.Error())
-		fmt.Printf("%s\n", result.ErrorMessage())
-		os.Exit(-4)
+		log.Printf("Target: %s", gs.TargetVulkan11)
 	}
 
-}
+	if len(watchDirs) > 0 {
+		watcher, err := NewWatcher(watchDirs, builder)
+		if err != nil {
+			log.Printf("%v", err)
+			os.Exit(ExitWatch)
+		}
+		watcher.Debounce = *debounce
+		if *notifyHTTP != "" || *notifySocket != "" {
+			server, err := startNotifications(*notifyHTTP, *notifySocket)
+			if err != nil {
+				log.Printf("error: %v", err)
+				os.Exit(ExitWatch)
+			}
+			defer server.Close()
+			builder.Notify = server.Publish
+		}
 
-type Watcher struct {
-	watcher *fsnotify.Watcher
-}
+		// Stop watching cleanly on an interrupt, so the notification socket
+		// is removed
+		ctx, cancel := context.WithCancel(context.Background())
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-signals
+			cancel()
+		}()
+		if err := watcher.Run(ctx); err != nil {
+			log.Printf("error: %v", err)
+		}
+		return
+	}
 
-func NewWatcher(dirs []string) (*Watcher, error) {
-	w := &Watcher{}
+	if _, err := os.Stat(*input); err != nil {
+		log.Printf("error reading file: %v\n", err)
+		os.Exit(ExitRead)
+	}
 
-	var err error
+	unit, err := builder.Unit(*input, *output)
+	if err != nil {
+		log.Printf("error: %v", err)
+		os.Exit(ExitConfig)
+	}
+	unit.Symbol = *symbol
+	if *depfilePath != "" {
+		unit.Depfile = *depfilePath
+	}
 
-	w.watcher, err = fsnotify.NewWatcher()
+	err = builder.Compile(unit)
 	if err != nil {
-		return nil, err
+		log.Printf("error compiling shader: %v\n", err)
+	}
+	code := exitCode(err)
+	if err := diagnostics.Close(); err != nil {
+		log.Printf("error: %v", err)
+		code = worseExitCode(code, ExitWrite)
+	}
+	os.Exit(code)
+}
+
+func parseVariantSet(axes, excludes []string) (*gs.VariantSet, error) {
+	set := &gs.VariantSet{}
+	for _, a := range axes {
+		axis, err := gs.ParseVariantAxis(a)
+		if err != nil {
+			return nil, err
+		}
+		set.Axes = append(set.Axes, axis)
 	}
-	for _, dir := range dirs {
-		err := w.watcher.Add(dir)
+	for _, e := range excludes {
+		rule, err := gs.ParseVariantRule(e)
 		if err != nil {
-			return nil, fmt.Errorf("error watching directory '%s': %w", dir, err)
+			return nil, err
 		}
-		log.Printf("watching directory %s for changes", dir)
+		set.Excludes = append(set.Excludes, rule)
 	}
-	return w, nil
+	if err := validateVariants(set); err != nil {
+		return nil, err
+	}
+	return set, nil
 }
 
-func (w *Watcher) Run() {
-	for {
-		select {
-		case event, ok := <-w.watcher.Events:
-			if !ok {
-				return
-			}
-			if event.Op&fsnotify.Write == fsnotify.Write {
-				stype := gs.GetShaderTypeByFilename(event.Name)
-				if stype != gs.InferFromSource {
-					data, err := ioutil.ReadFile(event.Name)
-					if err == nil {
-						out, err := gs.CompileShader(string(data), event.Name, "")
-						if err != nil {
-							log.Printf("error compiling shader '%s': %v", event.Name, err)
-						} else {
-							err = ioutil.WriteFile(event.Name+".spv", out, 0644)
-							if err != nil {
-								log.Printf("error writing file '%s': %v", event.Name, err)
-							} else {
-								log.Printf("compiled %s -> %s", event.Name, event.Name+".spv")
-							}
-						}
-					} else {
-						log.Printf("error reading file '%s': %v", event.Name, err)
-					}
-				}
-			}
+// validateVariants validates a variant set and checks its variants are
+// written to different files, variantOutputName replaces characters which
+// may make the names of different keys the same
+func validateVariants(set *gs.VariantSet) error {
+	if err := set.Validate(); err != nil {
+		return err
+	}
+	keys := make(map[string]string)
+	for _, v := range set.Variants() {
+		key := v.Key()
+		name := variantOutputName("", key)
+		if other, ok := keys[name]; ok {
+			return fmt.Errorf("variants '%s' and '%s' would be written to the same file", other, key)
+		}
+		keys[name] = key
+	}
+	return nil
+}
 
-		case err, ok := <-w.watcher.Errors:
-			if !ok {
-				log.Printf("error: %v", err)
-				return
-			}
+// variantOutputName returns the output file for a variant by inserting the
+// variant key before the extension of the output, the base variant is
+// written to the output itself
+func variantOutputName(output, key string) string {
+	if key == "" {
+		return output
+	}
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-', r == '=':
+			return r
+		case r == ',':
+			return '.'
+		}
+		return '_'
+	}, key)
+	ext := filepath.Ext(output)
+	return strings.TrimSuffix(output, ext) + "." + name + ext
+}
 
+// startNotifications starts serving hot reload events over HTTP and/or a
+// Unix socket
+func startNotifications(httpAddr, socket string) (*hotreload.Server, error) {
+	server := hotreload.NewServer()
+	if httpAddr != "" {
+		l, err := net.Listen("tcp", httpAddr)
+		if err != nil {
+			return nil, err
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/events", server)
+		go http.Serve(l, mux)
+		log.Printf("serving compile events at http://%s/events", l.Addr())
+	}
+	if socket != "" {
+		// Remove the socket left behind by a previous run
+		if info, err := os.Stat(socket); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(socket)
+		}
+		l, err := net.Listen("unix", socket)
+		if err != nil {
+			server.Close()
+			return nil, err
 		}
+		go server.Serve(l)
+		log.Printf("streaming compile events on %s", socket)
 	}
-	w.watcher.Close()
+	return server, nil
 }
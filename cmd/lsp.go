@@ -0,0 +1,608 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+
+	gs "github.com/celer/gshaderc"
+)
+
+// lspMain implements 'gsc lsp'
+func lspMain(args []string) int {
+	flags := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	manifestPath := flags.String("manifest", "", "project manifest giving the stage and settings of shaders (default gsc.json or gsc.toml in the workspace)")
+	var config CompileConfig
+	addConfigFlags(flags, &config)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: gsc lsp [flags]\n\n")
+		fmt.Fprintf(flags.Output(), "runs a Language Server Protocol server on stdin and stdout which compiles\n")
+		fmt.Fprintf(flags.Output(), "shaders as they're edited. The flags set the defaults for shaders the manifest\n")
+		fmt.Fprintf(flags.Output(), "doesn't list.\n\n")
+		flags.PrintDefaults()
+	}
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	if _, err := config.Options(); err != nil {
+		log.Printf("error: %v", err)
+		return ExitConfig
+	}
+	compiler := gs.NewCompiler()
+	defer compiler.Release()
+	server := NewLanguageServer(compiler, config)
+	if *manifestPath != "" {
+		if err := server.LoadManifest(*manifestPath); err != nil {
+			log.Printf("error: %v", err)
+			return ExitConfig
+		}
+	}
+	if err := server.Serve(os.Stdin, os.Stdout); err != nil {
+		log.Printf("error: %v", err)
+		return ExitRead
+	}
+	return ExitOK
+}
+
+// LanguageServer is a Language Server Protocol server which compiles open
+// shaders whenever they, or an open file they include, change and
+// publishes the diagnostics on the files they refer to. Unsaved contents
+// are seen by the compiler through an include overlay. It also resolves
+// #include directives for go-to-definition.
+type LanguageServer struct {
+	// Config is used for shaders the manifest doesn't list
+	Config CompileConfig
+
+	compiler *gs.Compiler
+	overlay  *gs.IncludeOverlay
+	units    map[string]*BuildUnit
+
+	wmu sync.Mutex
+	w   io.Writer
+
+	// open are the paths of the open documents
+	open map[string]bool
+	// includes are the files each compiled document included
+	includes map[string][]string
+	// diagnostics are those of each compiled document, by file
+	diagnostics map[string]map[string][]lspDiagnostic
+	shutdown    bool
+}
+
+// NewLanguageServer creates a server compiling with compiler
+func NewLanguageServer(compiler *gs.Compiler, config CompileConfig) *LanguageServer {
+	return &LanguageServer{
+		Config:      config,
+		compiler:    compiler,
+		overlay:     gs.NewIncludeOverlay(),
+		units:       make(map[string]*BuildUnit),
+		open:        make(map[string]bool),
+		includes:    make(map[string][]string),
+		diagnostics: make(map[string]map[string][]lspDiagnostic),
+	}
+}
+
+// LoadManifest takes the stage and settings of shaders from a manifest
+func (s *LanguageServer) LoadManifest(path string) error {
+	manifest, err := LoadManifest(path)
+	if err != nil {
+		return err
+	}
+	units, err := manifest.Units()
+	if err != nil {
+		return err
+	}
+	s.units = make(map[string]*BuildUnit)
+	for _, unit := range units {
+		s.units[absPath(unit.Source)] = unit
+	}
+	return nil
+}
+
+// The subset of the Language Server Protocol the server speaks
+
+type lspRequest struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+}
+
+type lspResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  interface{}      `json:"result"`
+}
+
+type lspErrorResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Error   lspError         `json:"error"`
+}
+
+type lspNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// JSON-RPC error codes
+const (
+	lspParseError     = -32700
+	lspInvalidParams  = -32602
+	lspMethodNotFound = -32601
+)
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspTextDocument struct {
+	URI     string `json:"uri"`
+	Version int    `json:"version,omitempty"`
+	Text    string `json:"text,omitempty"`
+}
+
+type lspDidOpenParams struct {
+	TextDocument lspTextDocument `json:"textDocument"`
+}
+
+type lspDidChangeParams struct {
+	TextDocument   lspTextDocument `json:"textDocument"`
+	ContentChanges []struct {
+		Range *lspRange `json:"range,omitempty"`
+		Text  string    `json:"text"`
+	} `json:"contentChanges"`
+}
+
+type lspDidSaveParams struct {
+	TextDocument lspTextDocument `json:"textDocument"`
+	Text         *string         `json:"text,omitempty"`
+}
+
+type lspPositionParams struct {
+	TextDocument lspTextDocument `json:"textDocument"`
+	Position     lspPosition     `json:"position"`
+}
+
+type lspInitializeParams struct {
+	RootURI string `json:"rootUri"`
+}
+
+type lspPublishDiagnosticsParams struct {
+	URI         string          `json:"uri"`
+	Diagnostics []lspDiagnostic `json:"diagnostics"`
+}
+
+// lspSeverities maps diagnostic severities to LSP's DiagnosticSeverity
+var lspSeverities = map[gs.Severity]int{gs.SeverityError: 1, gs.SeverityWarning: 2, gs.SeverityNote: 3}
+
+// Serve reads messages from r and writes responses and notifications to w
+// until the client sends exit or closes r
+func (s *LanguageServer) Serve(r io.Reader, w io.Writer) error {
+	s.w = w
+	br := bufio.NewReader(r)
+	for {
+		body, err := readLSPMessage(br)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var req lspRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			s.replyError(nil, lspParseError, err.Error())
+			continue
+		}
+		if req.Method == "exit" {
+			if !s.shutdown {
+				log.Printf("exit without shutdown")
+			}
+			return nil
+		}
+		result, rpcErr := s.handle(&req)
+		if req.ID == nil {
+			if rpcErr != nil {
+				log.Printf("%s: %s", req.Method, rpcErr.Message)
+			}
+			continue
+		}
+		if rpcErr != nil {
+			s.replyError(req.ID, rpcErr.Code, rpcErr.Message)
+		} else {
+			s.send(lspResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
+		}
+	}
+}
+
+// readLSPMessage reads a message framed with a Content-Length header
+func readLSPMessage(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && line == "" && length < 0 {
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if i := strings.Index(line, ":"); i > 0 && strings.EqualFold(line[:i], "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(line[i+1:])); err != nil {
+				return nil, fmt.Errorf("invalid Content-Length '%s'", line[i+1:])
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("message without a Content-Length")
+	}
+	body := make([]byte, length)
+	_, err := io.ReadFull(r, body)
+	return body, err
+}
+
+func (s *LanguageServer) send(msg interface{}) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("error: %v", err)
+		return
+	}
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	fmt.Fprintf(s.w, "Content-Length: %d\r\n\r\n%s", len(data), data)
+}
+
+func (s *LanguageServer) replyError(id *json.RawMessage, code int, message string) {
+	s.send(lspErrorResponse{JSONRPC: "2.0", ID: id, Error: lspError{Code: code, Message: message}})
+}
+
+// handle handles a request or notification, returning the result of
+// requests
+func (s *LanguageServer) handle(req *lspRequest) (interface{}, *lspError) {
+	decode := func(params interface{}) *lspError {
+		if err := json.Unmarshal(req.Params, params); err != nil {
+			return &lspError{Code: lspInvalidParams, Message: err.Error()}
+		}
+		return nil
+	}
+
+	switch req.Method {
+	case "initialize":
+		var params lspInitializeParams
+		if err := decode(&params); err != nil {
+			return nil, err
+		}
+		if len(s.units) == 0 && params.RootURI != "" {
+			if path, err := FindManifest(uriToPath(params.RootURI)); err == nil {
+				if err := s.LoadManifest(path); err != nil {
+					log.Printf("error: %v", err)
+				}
+			}
+		}
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync": map[string]interface{}{
+					"openClose": true,
+					"change":    1, // full documents
+					"save":      map[string]bool{"includeText": false},
+				},
+				"definitionProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "gsc"},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params lspDidOpenParams
+		if err := decode(&params); err != nil {
+			return nil, err
+		}
+		path := uriToPath(params.TextDocument.URI)
+		s.open[path] = true
+		s.overlay.Set(path, params.TextDocument.Text)
+		s.changed(path)
+	case "textDocument/didChange":
+		var params lspDidChangeParams
+		if err := decode(&params); err != nil {
+			return nil, err
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			path := uriToPath(params.TextDocument.URI)
+			s.overlay.Set(path, params.ContentChanges[n-1].Text)
+			s.changed(path)
+		}
+	case "textDocument/didSave":
+		var params lspDidSaveParams
+		if err := decode(&params); err != nil {
+			return nil, err
+		}
+		path := uriToPath(params.TextDocument.URI)
+		if params.Text != nil {
+			s.overlay.Set(path, *params.Text)
+		}
+		s.changed(path)
+	case "textDocument/didClose":
+		var params lspDidOpenParams
+		if err := decode(&params); err != nil {
+			return nil, err
+		}
+		path := uriToPath(params.TextDocument.URI)
+		delete(s.open, path)
+		s.overlay.Remove(path)
+		// Shaders including the file now see what's on disk
+		s.changed(path)
+		s.publish(path, nil)
+	case "textDocument/definition":
+		var params lspPositionParams
+		if err := decode(&params); err != nil {
+			return nil, err
+		}
+		return s.definition(uriToPath(params.TextDocument.URI), params.Position), nil
+	default:
+		if req.ID != nil && !strings.HasPrefix(req.Method, "$/") {
+			return nil, &lspError{Code: lspMethodNotFound, Message: "method not found: " + req.Method}
+		}
+	}
+	return nil, nil
+}
+
+// unit returns the unit for a document, the one from the manifest or one
+// using the server's config, and false if the document isn't a shader
+// which can be compiled on its own
+func (s *LanguageServer) unit(path string) (*BuildUnit, bool) {
+	if unit, ok := s.units[path]; ok {
+		return unit, true
+	}
+	unit := &BuildUnit{Source: path, Stage: gs.GetShaderTypeByFilename(path), Config: s.Config}
+	if unit.Stage == gs.InferFromSource {
+		content, err := s.overlay.ReadFile(path)
+		if err != nil || !strings.Contains(content, "#pragma shader_stage") {
+			return nil, false
+		}
+	}
+	return unit, true
+}
+
+// changed recompiles the document at path, if it's a shader, and every
+// open shader which included it
+func (s *LanguageServer) changed(path string) {
+	var compile []string
+	for doc := range s.open {
+		if doc == path {
+			continue
+		}
+		for _, include := range s.includes[doc] {
+			if include == path {
+				compile = append(compile, doc)
+				break
+			}
+		}
+	}
+	sort.Strings(compile)
+	if s.open[path] {
+		compile = append([]string{path}, compile...)
+	}
+	for _, doc := range compile {
+		s.compile(doc)
+	}
+}
+
+// compile compiles an open document and publishes its diagnostics
+func (s *LanguageServer) compile(path string) {
+	unit, ok := s.unit(path)
+	if !ok {
+		return
+	}
+	byFile := make(map[string][]lspDiagnostic)
+	report := func(d gs.Diagnostic) {
+		file := path
+		if d.File != "" && d.File != "<stdin>" {
+			file = absPath(d.File)
+		}
+		byFile[file] = append(byFile[file], lspDiagnostic{
+			Range:    s.diagnosticRange(file, d),
+			Severity: lspSeverities[d.Severity],
+			Source:   "gsc",
+			Message:  d.Message,
+		})
+	}
+
+	content, err := s.overlay.ReadFile(path)
+	options, optionsErr := unit.Config.Options()
+	switch {
+	case err != nil:
+		report(gs.Diagnostic{Severity: gs.SeverityError, Message: err.Error()})
+	case optionsErr != nil:
+		report(gs.Diagnostic{Severity: gs.SeverityError, Message: optionsErr.Error()})
+	default:
+		options = options.Clone()
+		recorder := gs.NewIncludeRecorder(s.overlay.IncludeResolver(unit.Config.IncludePaths))
+		options.SetIncludeCallback(recorder.Resolve)
+		result := s.compiler.CompileIntoSPV(content, unit.Stage, path, unit.Config.GetEntryPoint(), options)
+		for _, d := range gs.ParseDiagnostics(result.ErrorMessage()) {
+			report(d)
+		}
+		result.Release()
+		s.includes[path] = recorder.Includes()
+	}
+	s.publish(path, byFile)
+}
+
+// publish replaces the diagnostics of a compiled document and publishes
+// the diagnostics of every file affected, merged with those other
+// documents reported for the same files
+func (s *LanguageServer) publish(path string, byFile map[string][]lspDiagnostic) {
+	// The document itself is always published, to clear what it had
+	files := map[string]bool{path: true}
+	for file := range s.diagnostics[path] {
+		files[file] = true
+	}
+	for file := range byFile {
+		files[file] = true
+	}
+	if byFile == nil {
+		delete(s.diagnostics, path)
+		delete(s.includes, path)
+	} else {
+		s.diagnostics[path] = byFile
+	}
+
+	var sorted []string
+	for file := range files {
+		sorted = append(sorted, file)
+	}
+	sort.Strings(sorted)
+	for _, file := range sorted {
+		diagnostics := []lspDiagnostic{}
+		seen := make(map[lspDiagnostic]bool)
+		for _, doc := range s.diagnosticSources() {
+			for _, d := range s.diagnostics[doc][file] {
+				if !seen[d] {
+					seen[d] = true
+					diagnostics = append(diagnostics, d)
+				}
+			}
+		}
+		s.send(lspNotification{
+			JSONRPC: "2.0",
+			Method:  "textDocument/publishDiagnostics",
+			Params:  lspPublishDiagnosticsParams{URI: pathToURI(file), Diagnostics: diagnostics},
+		})
+	}
+}
+
+// diagnosticSources returns the documents with diagnostics in a stable order
+func (s *LanguageServer) diagnosticSources() []string {
+	var docs []string
+	for doc := range s.diagnostics {
+		docs = append(docs, doc)
+	}
+	sort.Strings(docs)
+	return docs
+}
+
+// diagnosticRange returns the range a diagnostic covers, from its column,
+// or the start of the line, to the end of the line
+func (s *LanguageServer) diagnosticRange(file string, d gs.Diagnostic) lspRange {
+	if d.Line == 0 {
+		return lspRange{}
+	}
+	line := d.Line - 1
+	end := 0
+	if content, err := s.overlay.ReadFile(file); err == nil {
+		lines := strings.Split(content, "\n")
+		if line < len(lines) {
+			end = len(utf16.Encode([]rune(strings.TrimRight(lines[line], "\r"))))
+		}
+	}
+	start := 0
+	if d.Column > 0 {
+		start = d.Column - 1
+	}
+	if end < start {
+		end = start
+	}
+	return lspRange{Start: lspPosition{Line: line, Character: start}, End: lspPosition{Line: line, Character: end}}
+}
+
+// definition resolves the #include directive at a position
+func (s *LanguageServer) definition(path string, pos lspPosition) interface{} {
+	content, err := s.overlay.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	lines := strings.Split(content, "\n")
+	if pos.Line < 0 || pos.Line >= len(lines) {
+		return nil
+	}
+	name, itype, ok := gs.ParseIncludeDirective(lines[pos.Line])
+	if !ok {
+		return nil
+	}
+	config := s.Config
+	if unit, ok := s.units[path]; ok {
+		config = unit.Config
+	}
+	resolved, _, err := s.overlay.IncludeResolver(config.IncludePaths)(name, itype, path, 1)
+	if err != nil {
+		return nil
+	}
+	return []lspLocation{{URI: pathToURI(resolved)}}
+}
+
+// uriToPath converts a file URI to an absolute path
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return absPath(uri)
+	}
+	path := u.Path
+	// file:///C:/dir on Windows
+	if len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return absPath(filepath.FromSlash(path))
+}
+
+// pathToURI converts a path to a file URI
+func pathToURI(path string) string {
+	path = filepath.ToSlash(absPath(path))
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
@@ -0,0 +1,165 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gs "github.com/celer/gshaderc"
+)
+
+// lspSession runs a server over the given messages and returns what it
+// wrote
+func lspSession(t *testing.T, s *LanguageServer, messages ...interface{}) []map[string]interface{} {
+	var in bytes.Buffer
+	for i, m := range messages {
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i%2 == 0 {
+			fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(data), data)
+		} else {
+			fmt.Fprintf(&in, "Content-Type: application/vscode-jsonrpc; charset=utf-8\r\ncontent-length: %d\r\n\r\n%s", len(data), data)
+		}
+	}
+	var out bytes.Buffer
+	if err := s.Serve(&in, &out); err != nil {
+		t.Fatal(err)
+	}
+	var replies []map[string]interface{}
+	r := bufio.NewReader(&out)
+	for {
+		body, err := readLSPMessage(r)
+		if err == io.EOF {
+			return replies
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		var reply map[string]interface{}
+		if err := json.Unmarshal(body, &reply); err != nil {
+			t.Fatal(err)
+		}
+		replies = append(replies, reply)
+	}
+}
+
+func TestLanguageServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"common.glsl": "float k;\n",
+	})
+	source := "#version 450\n#include \"common.glsl\"\nvoid main() {}\n"
+	a, common := pathToURI(filepath.Join(dir, "a.frag")), pathToURI(filepath.Join(dir, "common.glsl"))
+
+	notify := func(method string, params interface{}) interface{} {
+		return map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params}
+	}
+	request := func(id int, method string, params interface{}) interface{} {
+		return map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params}
+	}
+	doc := func(uri, text string) interface{} {
+		return map[string]interface{}{"textDocument": map[string]interface{}{"uri": uri, "version": 1, "text": text}}
+	}
+
+	s := NewLanguageServer(gs.NewCompilerWithBackend(gs.NewFakeBackend()), CompileConfig{})
+	replies := lspSession(t, s,
+		request(1, "initialize", map[string]interface{}{"rootUri": pathToURI(dir)}),
+		notify("initialized", map[string]interface{}{}),
+		notify("textDocument/didOpen", doc(a, source)),
+		// An unsaved error in the include is reported on the include
+		notify("textDocument/didOpen", doc(common, "float k;\n#error broken\n")),
+		request(2, "textDocument/definition", map[string]interface{}{
+			"textDocument": map[string]string{"uri": a},
+			"position":     map[string]int{"line": 1, "character": 3},
+		}),
+		notify("textDocument/didClose", doc(common, "")),
+		request(3, "textDocument/hover", map[string]interface{}{}),
+		request(4, "shutdown", nil),
+		notify("exit", nil),
+	)
+
+	type published struct {
+		uri   string
+		lines []int
+	}
+	var got []published
+	results := make(map[float64]map[string]interface{})
+	for _, r := range replies {
+		if id, ok := r["id"].(float64); ok {
+			results[id] = r
+			continue
+		}
+		if r["method"] != "textDocument/publishDiagnostics" {
+			t.Fatalf("unexpected notification %v", r)
+		}
+		params := r["params"].(map[string]interface{})
+		p := published{uri: params["uri"].(string)}
+		for _, d := range params["diagnostics"].([]interface{}) {
+			start := d.(map[string]interface{})["range"].(map[string]interface{})["start"].(map[string]interface{})
+			p.lines = append(p.lines, int(start["line"].(float64)))
+		}
+		got = append(got, p)
+	}
+
+	expected := []published{
+		{a, nil},           // a.frag opened
+		{a, nil},           // a.frag recompiled with the unsaved include
+		{common, []int{1}}, // which has an error on its second line
+		{a, nil},           // common.glsl closed, a.frag recompiled with the include on disk
+		{common, nil},      // clearing the error on the include
+		{common, nil},      // and whatever common.glsl had itself
+	}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Fatalf("unexpected diagnostics\n%v\nexpected\n%v", got, expected)
+	}
+
+	if caps := results[1]["result"].(map[string]interface{})["capabilities"].(map[string]interface{}); caps["definitionProvider"] != true {
+		t.Fatalf("unexpected capabilities %v", caps)
+	}
+	locations := results[2]["result"].([]interface{})
+	if len(locations) != 1 || locations[0].(map[string]interface{})["uri"] != common {
+		t.Fatalf("unexpected definition %v", results[2])
+	}
+	if results[3]["error"].(map[string]interface{})["code"].(float64) != lspMethodNotFound {
+		t.Fatalf("expected hover to be unsupported, got %v", results[3])
+	}
+	if _, ok := results[4]["result"]; !ok || results[4]["result"] != nil {
+		t.Fatalf("expected a null shutdown result, got %v", results[4])
+	}
+
+	// Files without a stage are only compiled if they declare one
+	lit := pathToURI(filepath.Join(dir, "lit.glsl"))
+	s = NewLanguageServer(gs.NewCompilerWithBackend(gs.NewFakeBackend()), CompileConfig{})
+	replies = lspSession(t, s,
+		notify("textDocument/didOpen", doc(common, "float k;\n")),
+		notify("textDocument/didOpen", doc(lit, "#pragma shader_stage(fragment)\n#version 450\n#error stage\n")),
+	)
+	if len(replies) != 1 || replies[0]["params"].(map[string]interface{})["uri"] != lit {
+		t.Fatalf("expected diagnostics for lit.glsl only, got %v", replies)
+	}
+}
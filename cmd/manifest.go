@@ -0,0 +1,226 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	gs "github.com/celer/gshaderc"
+)
+
+// DefaultManifests are the manifest files gsc build looks for when none
+// is given
+var DefaultManifests = []string{"gsc.json", "gsc.toml"}
+
+// Manifest describes every shader in a project, it is read from JSON or
+// TOML depending on the file extension. Paths are relative to the directory
+// containing the manifest.
+type Manifest struct {
+	// Defaults apply to every shader
+	Defaults CompileConfig `json:"defaults" toml:"defaults"`
+	// Directories override the defaults for shaders in a directory and its
+	// subdirectories, deeper directories override shallower ones
+	Directories map[string]CompileConfig `json:"directories,omitempty" toml:"directories"`
+	Shaders     []ManifestShader         `json:"shaders" toml:"shaders"`
+
+	dir string
+}
+
+// ManifestShader is a shader, or a glob of shaders, to compile
+type ManifestShader struct {
+	CompileConfig
+	// Source is the shader source, or a glob pattern
+	Source string `json:"source" toml:"source"`
+	// Stage overrides the stage inferred from the file extension, e.g. "frag"
+	Stage string `json:"stage,omitempty" toml:"stage"`
+	// Output is the output file, it can only be used when Source names a
+	// single file
+	Output   string         `json:"output,omitempty" toml:"output"`
+	Variants *gs.VariantSet `json:"variants,omitempty" toml:"variants"`
+}
+
+// BuildUnit is a single shader to compile, with its settings resolved
+type BuildUnit struct {
+	Source   string
+	Stage    gs.ShaderType
+	Config   CompileConfig
+	Output   string
+	Variants *gs.VariantSet
+	// Symbol is the name of the variable in C and Go outputs, by default
+	// it's derived from the output file name
+	Symbol string
+	// Depfile, if set, is where a Makefile rule listing the files the
+	// outputs depend on is written
+	Depfile string
+	// DebugOutput, if set, is where the SPIR-V is written as compiled,
+	// before the config's post-processing strips it
+	DebugOutput string
+}
+
+// Outputs returns every file the unit writes, an output per variant if it
+// has variants, followed by the debug outputs
+func (u *BuildUnit) Outputs() []string {
+	keys := []string{""}
+	if u.Variants != nil {
+		keys = keys[:0]
+		for _, v := range u.Variants.Variants() {
+			keys = append(keys, v.Key())
+		}
+	}
+	var outputs []string
+	for _, key := range keys {
+		outputs = append(outputs, variantOutputName(u.Output, key))
+	}
+	if u.DebugOutput != "" {
+		for _, key := range keys {
+			outputs = append(outputs, variantOutputName(u.DebugOutput, key))
+		}
+	}
+	return outputs
+}
+
+// LoadManifest reads a manifest from a JSON or TOML file
+func LoadManifest(path string) (*Manifest, error) {
+	m := &Manifest{dir: filepath.Dir(path)}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch filepath.Ext(path) {
+	case ".toml":
+		_, err = toml.Decode(string(data), m)
+	case ".json":
+		err = json.Unmarshal(data, m)
+	default:
+		return nil, fmt.Errorf("unknown manifest format '%s', expected .json or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifest '%s': %w", path, err)
+	}
+	return m, nil
+}
+
+// FindManifest returns the first of DefaultManifests which exists in dir
+func FindManifest(dir string) (string, error) {
+	for _, name := range DefaultManifests {
+		p := filepath.Join(dir, name)
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("no manifest found, expected one of %s", strings.Join(DefaultManifests, ", "))
+}
+
+func (m *Manifest) path(p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(m.dir, p)
+}
+
+// configFor returns the settings for a source file before applying the
+// settings of the shader entry itself
+func (m *Manifest) configFor(source string) CompileConfig {
+	config := m.Defaults
+
+	var dirs []string
+	for dir := range m.Directories {
+		rel, err := filepath.Rel(m.path(dir), source)
+		if err == nil && !strings.HasPrefix(rel, "..") {
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		return len(filepath.Clean(dirs[i])) < len(filepath.Clean(dirs[j]))
+	})
+	for _, dir := range dirs {
+		config = config.Merge(m.Directories[dir])
+	}
+	return config
+}
+
+// Units resolves every shader in the manifest into the units to compile
+func (m *Manifest) Units() ([]*BuildUnit, error) {
+	var units []*BuildUnit
+	for _, shader := range m.Shaders {
+		sources, err := filepath.Glob(m.path(shader.Source))
+		if err != nil {
+			return nil, fmt.Errorf("invalid source '%s': %w", shader.Source, err)
+		}
+		if len(sources) == 0 {
+			return nil, fmt.Errorf("no shaders match '%s'", shader.Source)
+		}
+		if shader.Variants != nil {
+			if err := validateVariants(shader.Variants); err != nil {
+				return nil, fmt.Errorf("variants of '%s': %w", shader.Source, err)
+			}
+		}
+		if shader.Output != "" && len(sources) > 1 {
+			return nil, fmt.Errorf("output '%s' given for '%s' which matches %d shaders", shader.Output, shader.Source, len(sources))
+		}
+
+		for _, source := range sources {
+			unit := &BuildUnit{
+				Source:   source,
+				Config:   m.configFor(source).Merge(shader.CompileConfig),
+				Variants: shader.Variants,
+			}
+			includes := make([]string, len(unit.Config.IncludePaths))
+			for i, p := range unit.Config.IncludePaths {
+				includes[i] = m.path(p)
+			}
+			unit.Config.IncludePaths = includes
+
+			unit.Stage = gs.GetShaderTypeByFilename(source)
+			if shader.Stage != "" {
+				unit.Stage = gs.GetShaderTypeByFilename("." + shader.Stage)
+				if unit.Stage == gs.InferFromSource {
+					return nil, fmt.Errorf("unknown stage '%s' for '%s'", shader.Stage, source)
+				}
+			}
+
+			switch {
+			case shader.Output != "":
+				unit.Output = m.path(shader.Output)
+			case unit.Config.OutputDir != "":
+				rel, err := filepath.Rel(m.dir, source)
+				if err != nil {
+					return nil, err
+				}
+				name, err := unit.Config.OutputFileName(source, unit.Stage)
+				if err != nil {
+					return nil, err
+				}
+				unit.Output = filepath.Join(m.path(unit.Config.OutputDir), filepath.Dir(rel), name)
+			default:
+				name, err := unit.Config.OutputFileName(source, unit.Stage)
+				if err != nil {
+					return nil, err
+				}
+				unit.Output = filepath.Join(filepath.Dir(source), name)
+			}
+
+			units = append(units, unit)
+		}
+	}
+	return units, nil
+}
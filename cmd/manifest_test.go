@@ -0,0 +1,129 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	gs "github.com/celer/gshaderc"
+)
+
+const tomlManifest = `
+[defaults]
+target = "vulkan_1_0"
+include_paths = ["include"]
+output_dir = "build"
+nan_clamp = true
+
+[directories."shaders/post"]
+optimize = "size"
+nan_clamp = false
+[directories."shaders/post".macros]
+POST = "1"
+
+[[shaders]]
+source = "shaders/a.vert"
+output = "out/a.spv"
+
+[[shaders]]
+source = "shaders/post/*.frag"
+stage = "comp"
+include_paths = ["post/include"]
+[shaders.macros]
+EXTRA = "2"
+[shaders.variants]
+axes = [{name = "FOG", values = [""], optional = true}]
+`
+
+const jsonManifest = `{
+	"defaults": {"target": "vulkan_1_0", "include_paths": ["include"], "output_dir": "build", "nan_clamp": true},
+	"directories": {"shaders/post": {"optimize": "size", "nan_clamp": false, "macros": {"POST": "1"}}},
+	"shaders": [
+		{"source": "shaders/a.vert", "output": "out/a.spv"},
+		{"source": "shaders/post/*.frag", "stage": "comp", "include_paths": ["post/include"], "macros": {"EXTRA": "2"},
+		 "variants": {"axes": [{"name": "FOG", "values": [""], "optional": true}]}}
+	]
+}`
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestManifest(t *testing.T) {
+	for name, content := range map[string]string{"gsc.toml": tomlManifest, "gsc.json": jsonManifest} {
+		dir, err := ioutil.TempDir("", "gsc")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		writeFiles(t, dir, map[string]string{
+			name:                  content,
+			"shaders/a.vert":      "",
+			"shaders/post/b.frag": "",
+		})
+
+		p, err := FindManifest(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := LoadManifest(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		units, err := m.Units()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(units) != 2 {
+			t.Fatalf("%s: expected 2 units, got %d", name, len(units))
+		}
+
+		a, b := units[0], units[1]
+		if a.Output != filepath.Join(dir, "out/a.spv") || a.Stage != gs.VertexShader || a.Config.Target != "vulkan_1_0" {
+			t.Fatalf("%s: unexpected unit %+v", name, a)
+		}
+		if !enabled(a.Config.NanClamp) || enabled(b.Config.NanClamp) {
+			t.Fatalf("%s: expected the directory to turn nan_clamp off", name)
+		}
+		if a.Config.Optimize != "" || len(a.Config.Macros) != 0 {
+			t.Fatalf("%s: didn't expect directory settings to apply to %s", name, a.Source)
+		}
+
+		if b.Output != filepath.Join(dir, "build/shaders/post/b.frag.spv") || b.Stage != gs.ComputeShader {
+			t.Fatalf("%s: unexpected unit %+v", name, b)
+		}
+		if b.Config.Optimize != "size" || !reflect.DeepEqual(b.Config.Macros, map[string]string{"POST": "1", "EXTRA": "2"}) {
+			t.Fatalf("%s: unexpected config %+v", name, b.Config)
+		}
+		if !reflect.DeepEqual(b.Config.IncludePaths, []string{filepath.Join(dir, "post/include"), filepath.Join(dir, "include")}) {
+			t.Fatalf("%s: unexpected include paths %v", name, b.Config.IncludePaths)
+		}
+		if b.Variants == nil || len(b.Variants.Variants()) != 2 {
+			t.Fatalf("%s: expected 2 variants", name)
+		}
+	}
+}
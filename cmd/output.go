@@ -0,0 +1,298 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	gs "github.com/celer/gshaderc"
+)
+
+// DefaultOutputName is the output name template used when none is given
+const DefaultOutputName = "{file}.{format}"
+
+// DefaultPackage is the package of generated Go files
+const DefaultPackage = "shaders"
+
+// OutputFormat is a way of writing a compiled shader
+type OutputFormat struct {
+	Name string
+	// Ext is the extension of output files, without the dot
+	Ext string
+	// Kind is what the compiler has to produce for the format
+	Kind gs.OutputKind
+	// Description is shown in the usage
+	Description string
+
+	encode func(data []byte, o *outputInfo) ([]byte, error)
+}
+
+// outputInfo is what encoders know about the output they are writing
+type outputInfo struct {
+	source  string
+	symbol  string
+	pkg     string
+	variant string
+}
+
+// forVariant returns the info for the output of a variant, an explicit
+// symbol gets the variant key appended
+func (o outputInfo) forVariant(key string) *outputInfo {
+	o.variant = key
+	if o.symbol != "" && key != "" {
+		o.symbol += "_" + key
+	}
+	return &o
+}
+
+// OutputFormats are the supported output formats, the first is the default
+var OutputFormats = []*OutputFormat{
+	{Name: "spv", Ext: "spv", Kind: gs.OutputSPV, Description: "SPIR-V binary"},
+	{Name: "spvasm", Ext: "spvasm", Kind: gs.OutputAssembly, Description: "SPIR-V assembly text"},
+	{Name: "preprocessed", Ext: "glsl", Kind: gs.OutputPreprocessedText, Description: "preprocessed GLSL"},
+	{Name: "c", Ext: "h", Kind: gs.OutputSPV, Description: "C header with a uint32_t array", encode: encodeC},
+	{Name: "go", Ext: "go", Kind: gs.OutputSPV, Description: "Go source with a []uint32 variable", encode: encodeGoWords},
+	{Name: "go-bytes", Ext: "go", Kind: gs.OutputSPV, Description: "Go source with a []byte variable", encode: encodeGoBytes},
+}
+
+func outputFormatNames() string {
+	var names []string
+	for _, f := range OutputFormats {
+		names = append(names, f.Name+" ("+f.Description+")")
+	}
+	return strings.Join(names, ", ")
+}
+
+// ParseOutputFormat returns the format with the given name, an empty name
+// is the default format
+func ParseOutputFormat(name string) (*OutputFormat, error) {
+	if name == "" {
+		return OutputFormats[0], nil
+	}
+	for _, f := range OutputFormats {
+		if f.Name == name {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown output format: %s", name)
+}
+
+// Encode converts what the compiler produced into the output file
+func (f *OutputFormat) Encode(data []byte, o *outputInfo) ([]byte, error) {
+	if f.encode == nil {
+		return data, nil
+	}
+	return f.encode(data, o)
+}
+
+// Compile compiles source into what the format needs
+func (f *OutputFormat) Compile(compiler *gs.Compiler, source string, stage gs.ShaderType, filename, entryPoint string, options *gs.CompilerOptions) *gs.CompilationResult {
+	switch f.Kind {
+	case gs.OutputAssembly:
+		return compiler.CompileIntoSPVAssembly(source, stage, filename, entryPoint, options)
+	case gs.OutputPreprocessedText:
+		return compiler.CompileIntoPreProcessedText(source, stage, filename, entryPoint, options)
+	}
+	return compiler.CompileIntoSPV(source, stage, filename, entryPoint, options)
+}
+
+// OutputFileName expands the output name template for a source file. The
+// template may use {file} (e.g. pbr.frag), {name} (pbr), {ext} (frag),
+// {stage} (the stage being compiled, e.g. frag) and {format} (the extension
+// of the output format, e.g. spv).
+func (c CompileConfig) OutputFileName(source string, stage gs.ShaderType) (string, error) {
+	f, err := ParseOutputFormat(c.Format)
+	if err != nil {
+		return "", err
+	}
+	template := c.OutputName
+	if template == "" {
+		template = DefaultOutputName
+	}
+
+	file := filepath.Base(source)
+	ext := filepath.Ext(file)
+	values := map[string]string{
+		"file":   file,
+		"name":   strings.TrimSuffix(file, ext),
+		"ext":    strings.TrimPrefix(ext, "."),
+		"stage":  gs.GetShaderExtensionByType(stage),
+		"format": f.Ext,
+	}
+
+	var name strings.Builder
+	for template != "" {
+		i := strings.Index(template, "{")
+		if i < 0 {
+			name.WriteString(template)
+			break
+		}
+		name.WriteString(template[:i])
+		j := strings.Index(template[i:], "}")
+		if j < 0 {
+			return "", fmt.Errorf("unterminated placeholder in output name '%s'", c.OutputName)
+		}
+		value, ok := values[template[i+1:i+j]]
+		if !ok {
+			return "", fmt.Errorf("unknown placeholder %s in output name '%s'", template[i:i+j+1], c.OutputName)
+		}
+		name.WriteString(value)
+		template = template[i+j+1:]
+	}
+	return filepath.FromSlash(name.String()), nil
+}
+
+// encodeOutput encodes compiler output as it's written to output, the
+// symbol defaults to the output's name without the format's extension
+func encodeOutput(f *OutputFormat, output string, data []byte, o *outputInfo) ([]byte, error) {
+	if o.symbol == "" {
+		name := output
+		if output == "-" {
+			name = o.source
+		}
+		o.symbol = strings.TrimSuffix(filepath.Base(name), "."+f.Ext)
+	}
+	return f.Encode(data, o)
+}
+
+// writeOutput encodes and writes an output file, an output of "-" is
+// written to stdout
+func writeOutput(f *OutputFormat, output string, data []byte, o *outputInfo) error {
+	data, err := encodeOutput(f, output, data, o)
+	if err != nil {
+		return err
+	}
+	if output == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(output, data, 0644)
+}
+
+// words returns SPIR-V as little endian words
+func words(data []byte) []uint32 {
+	if len(data)%4 != 0 {
+		data = append(data, make([]byte, 4-len(data)%4)...)
+	}
+	w := make([]uint32, len(data)/4)
+	for i := range w {
+		w[i] = binary.LittleEndian.Uint32(data[i*4:])
+	}
+	return w
+}
+
+// identifierParts splits a name into the alphanumeric runs which make up
+// an identifier
+func identifierParts(name string) []string {
+	return strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func cIdentifier(name string) string {
+	id := strings.Join(identifierParts(name), "_")
+	if id == "" || unicode.IsDigit(rune(id[0])) {
+		id = "_" + id
+	}
+	return id
+}
+
+func goIdentifier(name string) string {
+	var id strings.Builder
+	for _, part := range identifierParts(name) {
+		id.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	if id.Len() == 0 || unicode.IsDigit(rune(id.String()[0])) {
+		return "Shader" + id.String()
+	}
+	return id.String()
+}
+
+func generatedHeader(o *outputInfo) string {
+	from := filepath.ToSlash(o.source)
+	if o.variant != "" {
+		from += " [" + o.variant + "]"
+	}
+	return "// Code generated by gsc from " + from + ". DO NOT EDIT.\n"
+}
+
+// writeValues writes comma terminated values, perLine to a line
+func writeValues(b *bytes.Buffer, indent string, values []string, perLine int) {
+	for i := 0; i < len(values); i += perLine {
+		end := i + perLine
+		if end > len(values) {
+			end = len(values)
+		}
+		b.WriteString(indent + strings.Join(values[i:end], ", ") + ",\n")
+	}
+}
+
+func encodeC(data []byte, o *outputInfo) ([]byte, error) {
+	id := cIdentifier(o.symbol)
+	var b bytes.Buffer
+	b.WriteString(generatedHeader(o))
+	b.WriteString("#pragma once\n\n#include <stddef.h>\n#include <stdint.h>\n\n")
+	fmt.Fprintf(&b, "static const uint32_t %s[] = {\n", id)
+	w := words(data)
+	values := make([]string, len(w))
+	for i, v := range w {
+		values[i] = fmt.Sprintf("0x%08x", v)
+	}
+	writeValues(&b, "    ", values, 8)
+	fmt.Fprintf(&b, "};\nstatic const size_t %s_size = sizeof(%s);\n", id, id)
+	return b.Bytes(), nil
+}
+
+func encodeGo(o *outputInfo, typ string, values []string, perLine int) ([]byte, error) {
+	pkg := o.pkg
+	if pkg == "" {
+		pkg = DefaultPackage
+	}
+	id := goIdentifier(o.symbol)
+	var b bytes.Buffer
+	b.WriteString(generatedHeader(o))
+	fmt.Fprintf(&b, "\npackage %s\n\n// %s is the SPIR-V of %s\nvar %s = []%s{\n", pkg, id, filepath.Base(o.source), id, typ)
+	writeValues(&b, "\t", values, perLine)
+	b.WriteString("}\n")
+	return format.Source(b.Bytes())
+}
+
+func encodeGoWords(data []byte, o *outputInfo) ([]byte, error) {
+	w := words(data)
+	values := make([]string, len(w))
+	for i, v := range w {
+		values[i] = fmt.Sprintf("0x%08x", v)
+	}
+	return encodeGo(o, "uint32", values, 8)
+}
+
+func encodeGoBytes(data []byte, o *outputInfo) ([]byte, error) {
+	values := make([]string, len(data))
+	for i, v := range data {
+		values[i] = fmt.Sprintf("0x%02x", v)
+	}
+	return encodeGo(o, "byte", values, 16)
+}
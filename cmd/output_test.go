@@ -0,0 +1,117 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gs "github.com/celer/gshaderc"
+)
+
+func TestOutputFileName(t *testing.T) {
+	for _, test := range []struct {
+		config   CompileConfig
+		expected string
+	}{
+		{CompileConfig{}, "pbr.frag.spv"},
+		{CompileConfig{Format: "c"}, "pbr.frag.h"},
+		{CompileConfig{Format: "spvasm", OutputName: "{stage}/{name}.{format}"}, "frag/pbr.spvasm"},
+		{CompileConfig{OutputName: "{name}_{ext}.bin"}, "pbr_frag.bin"},
+	} {
+		name, err := test.config.OutputFileName("shaders/pbr.frag", gs.FragmentShader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != filepath.FromSlash(test.expected) {
+			t.Fatalf("expected %s, got %s", test.expected, name)
+		}
+	}
+
+	for _, config := range []CompileConfig{{OutputName: "{nope}.spv"}, {OutputName: "{name"}, {Format: "nope"}} {
+		if _, err := config.OutputFileName("pbr.frag", gs.FragmentShader); err == nil {
+			t.Fatalf("expected an error for %+v", config)
+		}
+	}
+}
+
+func TestOutputFormats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	source := filepath.Join(dir, "pbr.frag")
+	writeFiles(t, dir, map[string]string{"pbr.frag": "#version 450\n#ifdef FOG\nfog();\n#endif\nvoid main() {}\n"})
+
+	compiler := gs.NewCompilerWithBackend(gs.NewFakeBackend())
+	defer compiler.Release()
+
+	expected := map[string][]string{
+		"spv":          {"\x03\x02\x23\x07"},
+		"spvasm":       {"; SPIR-V", "OpCapability Shader"},
+		"preprocessed": {"fog();", "void main() {}"},
+		"c":            {"#include <stdint.h>", "static const uint32_t pbr_frag[] = {\n    0x07230203, 0x00010300,", "pbr_frag_size"},
+		"go":           {"// Code generated by gsc", "package shaders", "var PbrFrag = []uint32{\n\t0x07230203, 0x00010300,"},
+		"go-bytes":     {"package shaders", "var PbrFrag = []byte{\n\t0x03, 0x02, 0x23, 0x07,"},
+	}
+	for _, f := range OutputFormats {
+		config := CompileConfig{Format: f.Name, Macros: map[string]string{"FOG": ""}}
+		name, err := config.OutputFileName(source, gs.FragmentShader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		unit := &BuildUnit{Source: source, Stage: gs.FragmentShader, Config: config, Output: filepath.Join(dir, name)}
+		if err := compileUnit(compiler, unit); err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		data, err := ioutil.ReadFile(unit.Output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range expected[f.Name] {
+			if !strings.Contains(string(data), s) {
+				t.Fatalf("%s: expected %q in output:\n%s", f.Name, s, data)
+			}
+		}
+	}
+
+	unit := &BuildUnit{
+		Source:   source,
+		Stage:    gs.FragmentShader,
+		Config:   CompileConfig{Format: "go", Package: "assets"},
+		Output:   filepath.Join(dir, "pbr.go"),
+		Symbol:   "PBR",
+		Variants: &gs.VariantSet{Axes: []gs.VariantAxis{{Name: "FOG", Values: []string{""}, Optional: true}}},
+	}
+	if err := compileUnit(compiler, unit); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "pbr.FOG.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "package assets") || !strings.Contains(string(data), "var PBRFOG = []uint32{") {
+		t.Fatalf("unexpected variant output:\n%s", data)
+	}
+
+	unit.Config.Format = "spvasm"
+	if err := compileUnit(compiler, unit); err == nil {
+		t.Fatal("expected an error compiling variants as assembly")
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	gs "github.com/celer/gshaderc"
+	"github.com/celer/gshaderc/pack"
+)
+
+// packMain implements 'gsc pack'
+func packMain(args []string) int {
+	flags := flag.NewFlagSet("pack", flag.ContinueOnError)
+	workers := flags.Int("j", runtime.NumCPU(), "number of shaders to compile concurrently")
+	out := flags.String("o", "", "the pack to write, e.g. shaders.gspk")
+	compression := flags.String("compress", "deflate", "how SPIR-V is stored: none, or deflate which stores modules which get smaller compressed")
+	list := flags.String("list", "", "verify this pack and list its contents instead of writing one")
+	selection := addUnitFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: gsc pack -o shaders.gspk [flags] [dirs/files/globs...]\n")
+		fmt.Fprintf(flags.Output(), "       gsc pack -list shaders.gspk\n\n")
+		fmt.Fprintf(flags.Output(), "compiles the same shaders as 'gsc build' into a single shader pack, read with\n")
+		fmt.Fprintf(flags.Output(), "the github.com/celer/gshaderc/pack package\n\n")
+		flags.PrintDefaults()
+	}
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	if *list != "" {
+		return listPack(*list)
+	}
+	if *out == "" {
+		flags.Usage()
+		return ExitUsage
+	}
+	c, err := pack.ParseCompression(*compression)
+	if err != nil {
+		log.Printf("error: %v", err)
+		return ExitUsage
+	}
+
+	units, code := selection.units(flags.Args())
+	if code != ExitOK {
+		return code
+	}
+	compiler := gs.NewCompiler()
+	defer compiler.Release()
+	shaders, _, code := genCompile(compiler, units, *workers, nil)
+	if code != ExitOK {
+		return code
+	}
+	if err := genNames(shaders); err != nil {
+		log.Printf("error: %v", err)
+		return ExitConfig
+	}
+
+	data, err := writePack(shaders, c)
+	if err != nil {
+		log.Printf("error: %v", err)
+		return ExitConfig
+	}
+	if err := os.MkdirAll(filepath.Dir(*out), 0755); err != nil {
+		log.Printf("error: %v", err)
+		return ExitWrite
+	}
+	if err := ioutil.WriteFile(*out, data, 0644); err != nil {
+		log.Printf("error: %v", err)
+		return ExitWrite
+	}
+	log.Printf("packed %d shaders into %s, %d bytes", len(shaders), *out, len(data))
+	return ExitOK
+}
+
+// writePack returns a pack holding shaders
+func writePack(shaders []*genShader, compression pack.Compression) ([]byte, error) {
+	var buf bytes.Buffer
+	w := pack.NewWriter(&buf)
+	w.Compression = compression
+	for _, s := range shaders {
+		e := pack.Entry{
+			Name:       s.Name,
+			Variant:    s.Variant,
+			Stage:      gs.GetShaderTypeByFilename("." + s.Stage),
+			SourceHash: s.SourceHash,
+		}
+		if err := w.Add(e, s.SPIRV, s.Reflection); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// listPack verifies a pack and prints its entries
+func listPack(path string) int {
+	p, err := pack.Open(path)
+	if err != nil {
+		log.Printf("error: %v", err)
+		return ExitRead
+	}
+	defer p.Close()
+	if err := p.Verify(); err != nil {
+		log.Printf("error: %v", err)
+		return ExitRead
+	}
+	for _, e := range p.Entries() {
+		name := e.Name
+		if e.Variant != "" {
+			name += " [" + e.Variant + "]"
+		}
+		fmt.Printf("%s\t%s\t%d bytes\t%d stored (%s)\t%.12s\n", name, gs.GetShaderExtensionByType(e.Stage), e.Size, e.StoredSize, e.Compression, e.SourceHash)
+	}
+	return ExitOK
+}
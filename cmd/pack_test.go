@@ -0,0 +1,88 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gs "github.com/celer/gshaderc"
+	"github.com/celer/gshaderc/pack"
+)
+
+func TestWritePack(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"common.glsl": "float k;\n",
+		"a.frag":      "#version 450\n#include \"common.glsl\"\n#ifdef FOG\nvoid fogged() {}\n#endif\nvoid main() {}\n",
+		"b.comp":      "#version 450\nvoid main() {}\n",
+	})
+	units, err := BatchUnits([]Source{{Path: filepath.Join(dir, "a.frag")}, {Path: filepath.Join(dir, "b.comp")}}, CompileConfig{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	units[0].Variants = &gs.VariantSet{Axes: []gs.VariantAxis{{Name: "FOG", Values: []string{""}, Optional: true}}}
+
+	compiler := gs.NewCompilerWithBackend(gs.NewFakeBackend())
+	defer compiler.Release()
+	shaders, _, code := genCompile(compiler, units, 2, nil)
+	if code != ExitOK {
+		t.Fatalf("unexpected exit code %d", code)
+	}
+	if err := genNames(shaders); err != nil {
+		t.Fatal(err)
+	}
+	data, err := writePack(shaders, pack.CompressionDeflate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := pack.NewReaderBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Entries()) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(p.Entries()))
+	}
+	for _, s := range shaders {
+		e, ok := p.Lookup(s.Name, s.Variant)
+		if !ok {
+			t.Fatalf("missing %s [%s]", s.Name, s.Variant)
+		}
+		spirv, err := p.SPIRV(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(spirv, s.SPIRV) || e.SourceHash != s.SourceHash || gs.GetShaderExtensionByType(e.Stage) != s.Stage {
+			t.Fatalf("unexpected entry %+v", e)
+		}
+	}
+
+	plain, _ := p.Lookup("a.frag", "")
+	fog, _ := p.Lookup("a.frag", "FOG")
+	compute, _ := p.Lookup("b.comp", "")
+	if plain.SourceHash != fog.SourceHash || plain.SourceHash == compute.SourceHash {
+		t.Fatal("expected variants to share the hash of their source")
+	}
+}
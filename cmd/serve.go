@@ -0,0 +1,361 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	gs "github.com/celer/gshaderc"
+)
+
+// DefaultServeAddr is where 'gsc serve' listens by default
+const DefaultServeAddr = "localhost:8765"
+
+// maxExplorerSource is the largest source the explorer compiles
+const maxExplorerSource = 1 << 20
+
+// serveMain implements 'gsc serve'
+func serveMain(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := flags.String("addr", DefaultServeAddr, "address to listen on")
+	var config CompileConfig
+	addConfigFlags(flags, &config)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: gsc serve [flags]\n\n")
+		fmt.Fprintf(flags.Output(), "serves a page for editing a shader and seeing its SPIR-V assembly, preprocessed\n")
+		fmt.Fprintf(flags.Output(), "source, diagnostics, reflection and size as you type. The flags set the\n")
+		fmt.Fprintf(flags.Output(), "defaults, -I is how the page's shaders find their includes and nothing outside\n")
+		fmt.Fprintf(flags.Output(), "those directories is read.\n\n")
+		flags.PrintDefaults()
+	}
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	if _, err := config.Options(); err != nil {
+		log.Printf("error: %v", err)
+		return ExitConfig
+	}
+	compiler := gs.NewCompiler()
+	defer compiler.Release()
+
+	log.Printf("serving the shader explorer at http://%s/", *addr)
+	if err := http.ListenAndServe(*addr, NewExplorer(compiler, config)); err != nil {
+		log.Printf("error: %v", err)
+		return ExitUsage
+	}
+	return ExitOK
+}
+
+// ExplorerRequest is a shader for the explorer to compile, the settings
+// override the server's defaults
+type ExplorerRequest struct {
+	Source string `json:"source"`
+	// Stage is a shader extension, e.g. frag
+	Stage        string            `json:"stage"`
+	Target       string            `json:"target,omitempty"`
+	Optimize     string            `json:"optimize,omitempty"`
+	EntryPoint   string            `json:"entry_point,omitempty"`
+	SPIRVVersion string            `json:"spirv_version,omitempty"`
+	Macros       map[string]string `json:"macros,omitempty"`
+}
+
+// ExplorerResult is everything the explorer shows for a shader
+type ExplorerResult struct {
+	// Size is the size of the SPIR-V in bytes, 0 if it didn't compile
+	Size         int            `json:"size"`
+	Assembly     string         `json:"assembly"`
+	Preprocessed string         `json:"preprocessed"`
+	Diagnostics  []Diagnostic   `json:"diagnostics"`
+	Reflection   *gs.Reflection `json:"reflection,omitempty"`
+	Milliseconds float64        `json:"milliseconds"`
+}
+
+// Explorer serves the shader explorer page and compiles what it sends
+type Explorer struct {
+	// Config holds the defaults of every compile
+	Config CompileConfig
+
+	compiler *gs.Compiler
+	mux      *http.ServeMux
+}
+
+// NewExplorer creates the explorer's handler
+func NewExplorer(compiler *gs.Compiler, config CompileConfig) *Explorer {
+	e := &Explorer{Config: config, compiler: compiler, mux: http.NewServeMux()}
+	e.mux.HandleFunc("/", e.page)
+	e.mux.HandleFunc("/compile", e.compileHandler)
+	return e
+}
+
+func (e *Explorer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// A page on another site can point its own name at 127.0.0.1 and then
+	// talk to the explorer as if it were the same site, which its Host
+	// header gives away
+	if !loopbackHost(r.Host) {
+		http.Error(w, "the explorer is only served to localhost", http.StatusForbidden)
+		return
+	}
+	e.mux.ServeHTTP(w, r)
+}
+
+// loopbackHost reports whether the host of a request, with or without a
+// port, is localhost or a loopback address
+func loopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (e *Explorer) page(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, explorerPage)
+}
+
+func (e *Explorer) compileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST a JSON request", http.StatusMethodNotAllowed)
+		return
+	}
+	// Requiring JSON keeps other sites from posting forms to the server
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		http.Error(w, "expected application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+	var req ExplorerRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxExplorerSource)).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result, err := e.Compile(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// Compile compiles a request into SPIR-V, assembly and preprocessed
+// source. Compile errors are part of the result, the error is for
+// settings which aren't valid.
+func (e *Explorer) Compile(req *ExplorerRequest) (*ExplorerResult, error) {
+	config := e.Config.Merge(CompileConfig{
+		Target:       req.Target,
+		Optimize:     req.Optimize,
+		EntryPoint:   req.EntryPoint,
+		SPIRVVersion: req.SPIRVVersion,
+		Macros:       req.Macros,
+	})
+	options, err := config.Options()
+	if err != nil {
+		return nil, err
+	}
+	stage := gs.InferFromSource
+	if req.Stage != "" {
+		if stage = gs.GetShaderTypeByFilename("." + req.Stage); stage == gs.InferFromSource {
+			return nil, fmt.Errorf("unknown stage '%s'", req.Stage)
+		}
+	}
+	filename := "shader." + req.Stage
+	if req.Stage == "" {
+		filename = "shader.glsl"
+	}
+	unit := &BuildUnit{Source: filename, Stage: stage}
+
+	// The page's shader isn't a file, so even its relative includes are
+	// only looked for in the include paths, and nothing outside them is
+	// read. Includes which aren't found expand to an error rather than
+	// being left for a backend, such as glslc, to look for elsewhere.
+	resolve := gs.CreateConfinedIncludeResolver(config.IncludePaths)
+	options = options.Clone()
+	options.SetIncludeCallback(func(requestedSource string, itype gs.IncludeType, requestingSource string, includeDepth int) (string, string, error) {
+		if requestingSource == filename {
+			itype = gs.IncludeStandard
+		}
+		if name, content, err := resolve(requestedSource, itype, requestingSource, includeDepth); err == nil {
+			return name, content, nil
+		}
+		return requestedSource, "#error not found in the include paths\n", nil
+	})
+	entryPoint := config.GetEntryPoint()
+
+	start := time.Now()
+	result := &ExplorerResult{Diagnostics: []Diagnostic{}}
+	spv := e.compiler.CompileIntoSPV(req.Source, stage, filename, entryPoint, options)
+	defer spv.Release()
+	if spv.Error() == nil {
+		result.Size = len(spv.Bytes())
+		if r, err := gs.Reflect(spv.Bytes()); err == nil {
+			result.Reflection = r
+		}
+		asm := e.compiler.CompileIntoSPVAssembly(req.Source, stage, filename, entryPoint, options)
+		result.Assembly = string(asm.Bytes())
+		asm.Release()
+	}
+	pre := e.compiler.CompileIntoPreProcessedText(req.Source, stage, filename, entryPoint, options)
+	result.Preprocessed = string(pre.Bytes())
+	pre.Release()
+	result.Milliseconds = float64(time.Since(start).Microseconds()) / 1000
+
+	result.Diagnostics = append(result.Diagnostics, unitDiagnostics(unit, "", spv.ErrorMessage())...)
+	return result, nil
+}
+
+// explorerPage is the explorer's user interface, it posts the shader to
+// /compile as it's edited
+const explorerPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>gsc shader explorer</title>
+<style>
+body { margin: 0; font: 13px sans-serif; display: flex; flex-direction: column; height: 100vh; }
+header { padding: 6px 10px; background: #223; color: #eee; display: flex; gap: 12px; align-items: center; flex-wrap: wrap; }
+header input, header select { font: inherit; }
+main { flex: 1; display: grid; grid-template-columns: 1fr 1fr; grid-template-rows: 1fr 1fr; gap: 4px; padding: 4px; min-height: 0; }
+section { display: flex; flex-direction: column; min-height: 0; }
+section.source { grid-row: span 2; }
+h2 { font-size: 12px; margin: 0 0 2px; display: flex; gap: 8px; }
+h2 button { font: inherit; border: 0; background: none; cursor: pointer; color: #557; }
+h2 button.active { color: #000; font-weight: bold; }
+textarea, pre { flex: 1; margin: 0; font: 12px monospace; border: 1px solid #ccc; padding: 4px; overflow: auto; white-space: pre; }
+#diagnostics { flex: 0 0 auto; max-height: 40%; color: #900; }
+#status { margin-left: auto; }
+</style>
+</head>
+<body>
+<header>
+<strong>gsc</strong>
+<label>stage <select id="stage">
+<option>vert</option><option selected>frag</option><option>comp</option><option>geom</option><option>tesc</option><option>tese</option>
+</select></label>
+<label>target <select id="target">
+<option value="">default</option><option>vulkan_1_0</option><option>vulkan_1_1</option><option>opengl</option><option>opengl_compat</option><option>webgpu</option>
+</select></label>
+<label>optimize <select id="optimize">
+<option value="">zero</option><option>size</option><option>performance</option>
+</select></label>
+<label>macros <input id="macros" placeholder="FOG LIGHTS=4" size="24"></label>
+<label>entry point <input id="entry_point" placeholder="main" size="8"></label>
+<span id="status"></span>
+</header>
+<main>
+<section class="source">
+<h2>GLSL</h2>
+<textarea id="source" spellcheck="false">#version 450
+
+layout(location = 0) in vec2 uv;
+layout(location = 0) out vec4 color;
+layout(set = 0, binding = 0) uniform sampler2D tex;
+
+void main() {
+    color = texture(tex, uv);
+}
+</textarea>
+<pre id="diagnostics"></pre>
+</section>
+<section>
+<h2><button class="active" data-view="assembly">SPIR-V assembly</button><button data-view="preprocessed">preprocessed</button></h2>
+<pre id="output"></pre>
+</section>
+<section>
+<h2>reflection</h2>
+<pre id="reflection"></pre>
+</section>
+</main>
+<script>
+var $ = function(id) { return document.getElementById(id); };
+var view = "assembly", last = null, timer = null, pending = 0;
+
+function macros() {
+	var m = {};
+	$("macros").value.split(/[\s,]+/).forEach(function(d) {
+		if (!d) return;
+		var i = d.indexOf("=");
+		if (i < 0) m[d] = ""; else m[d.slice(0, i)] = d.slice(i + 1);
+	});
+	return m;
+}
+
+function show() {
+	if (!last) return;
+	$("output").textContent = last[view] || "";
+	$("reflection").textContent = last.reflection ? JSON.stringify(last.reflection, null, 2) : "";
+	$("diagnostics").textContent = last.diagnostics.map(function(d) {
+		return (d.line ? "line " + d.line + (d.column ? ":" + d.column : "") + ": " : "") + d.severity + ": " + d.message;
+	}).join("\n");
+}
+
+function compile() {
+	var id = ++pending;
+	var req = {
+		source: $("source").value, stage: $("stage").value, target: $("target").value,
+		optimize: $("optimize").value, entry_point: $("entry_point").value, macros: macros()
+	};
+	fetch("compile", {method: "POST", headers: {"Content-Type": "application/json"}, body: JSON.stringify(req)})
+		.then(function(r) {
+			if (!r.ok) return r.text().then(function(t) { throw new Error(t); });
+			return r.json();
+		})
+		.then(function(res) {
+			if (id != pending) return;
+			last = res;
+			$("status").textContent = (res.size ? res.size + " bytes of SPIR-V" : "failed") + " in " + res.milliseconds.toFixed(1) + " ms";
+			show();
+		})
+		.catch(function(err) {
+			if (id == pending) $("status").textContent = err.message;
+		});
+}
+
+function schedule() {
+	clearTimeout(timer);
+	timer = setTimeout(compile, 250);
+}
+
+["source", "macros", "entry_point"].forEach(function(id) { $(id).addEventListener("input", schedule); });
+["stage", "target", "optimize"].forEach(function(id) { $(id).addEventListener("change", compile); });
+document.querySelectorAll("h2 button").forEach(function(b) {
+	b.addEventListener("click", function() {
+		document.querySelectorAll("h2 button").forEach(function(o) { o.classList.remove("active"); });
+		b.classList.add("active");
+		view = b.dataset.view;
+		show();
+	});
+});
+compile();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,151 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	gs "github.com/celer/gshaderc"
+)
+
+func TestExplorer(t *testing.T) {
+	compiler := gs.NewCompilerWithBackend(gs.NewFakeBackend())
+	defer compiler.Release()
+	server := httptest.NewServer(NewExplorer(compiler, CompileConfig{}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Fatalf("unexpected page response %s", resp.Status)
+	}
+
+	compile := func(body string) (*ExplorerResult, int) {
+		t.Helper()
+		resp, err := http.Post(server.URL+"/compile", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, resp.StatusCode
+		}
+		result := &ExplorerResult{}
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			t.Fatal(err)
+		}
+		return result, resp.StatusCode
+	}
+
+	result, _ := compile(`{"source": "#version 450\n#ifdef FOG\nfloat fog;\n#endif\nvoid main() {}\n", "stage": "frag", "macros": {"FOG": ""}, "optimize": "size"}`)
+	if result.Size == 0 || !strings.Contains(result.Assembly, "OpCapability Shader") || !strings.Contains(result.Preprocessed, "float fog;") || len(result.Diagnostics) != 0 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	result, _ = compile(`{"source": "#version 450\n#error broken\n", "stage": "vert"}`)
+	if result.Size != 0 || result.Assembly != "" || len(result.Diagnostics) != 1 || result.Diagnostics[0].Line != 2 || result.Diagnostics[0].Stage != "vert" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	if _, status := compile(`{"source": "", "stage": "glsl"}`); status != http.StatusBadRequest {
+		t.Fatalf("expected an unknown stage to be rejected, got %d", status)
+	}
+	if _, status := compile(`{"source": "", "optimize": "fast"}`); status != http.StatusBadRequest {
+		t.Fatalf("expected an unknown optimization level to be rejected, got %d", status)
+	}
+
+	// Another site can't get at the explorer by pointing its name at the
+	// loopback address
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/compile", strings.NewReader(`{"source": ""}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Host = "attacker.example:8765"
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected a foreign host to be refused, got %s", resp.Status)
+	}
+	for _, host := range []string{"localhost:8765", "LOCALHOST", "127.0.0.1:80", "[::1]:8765"} {
+		if !loopbackHost(host) {
+			t.Fatalf("expected %s to be a loopback host", host)
+		}
+	}
+
+	resp, err = http.Post(server.URL+"/compile", "application/x-www-form-urlencoded", strings.NewReader("source=x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected forms to be rejected, got %s", resp.Status)
+	}
+}
+
+func TestExplorerIncludes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"include/common.glsl": "float common_value;\n",
+		"secret.txt":          "float secret_value;\n",
+	})
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	compiler := gs.NewCompilerWithBackend(gs.NewFakeBackend())
+	defer compiler.Release()
+	e := NewExplorer(compiler, CompileConfig{IncludePaths: []string{"include"}})
+	compile := func(include string) *ExplorerResult {
+		t.Helper()
+		result, err := e.Compile(&ExplorerRequest{Source: "#version 450\n#include \"" + include + "\"\nvoid main() {}\n", Stage: "frag"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	if result := compile("common.glsl"); !strings.Contains(result.Preprocessed, "common_value") || len(result.Diagnostics) != 0 {
+		t.Fatalf("expected the include path to be searched, got %+v", result)
+	}
+	// Neither a relative include of the page's shader nor one escaping the
+	// include path reads the file
+	for _, include := range []string{"secret.txt", "../secret.txt", "../include/../secret.txt"} {
+		result := compile(include)
+		if strings.Contains(result.Preprocessed, "secret_value") || result.Size != 0 || len(result.Diagnostics) == 0 {
+			t.Fatalf("expected %s not to be read, got %+v", include, result)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	gs "github.com/celer/gshaderc"
+	"github.com/celer/gshaderc/hotreload"
+	"github.com/celer/gshaderc/watch"
+)
+
+// DefaultDebounce is how long the watcher waits for a burst of events to
+// finish before compiling
+const DefaultDebounce = watch.DefaultDebounce
+
+// Watcher compiles shaders in a set of directories, and their
+// subdirectories, when they change, using the same Builder as a one-shot
+// compile. Watching, and tracking what every shader includes, is done by a
+// watch.ShaderWatcher, the Watcher writes the outputs and removes them when
+// their source is deleted.
+type Watcher struct {
+	// Debounce is how long to wait for events to stop before compiling
+	Debounce time.Duration
+
+	shaders *watch.ShaderWatcher
+	builder *Builder
+}
+
+func NewWatcher(dirs []string, builder *Builder) (*Watcher, error) {
+	w := &Watcher{Debounce: DefaultDebounce, builder: builder}
+
+	var err error
+	for _, dir := range dirs {
+		if w.shaders == nil {
+			w.shaders, err = watch.NewShaderWatcherWithCompiler(builder.compiler, dir, builder.options, nil)
+		} else {
+			err = w.shaders.Add(dir)
+		}
+		if err != nil {
+			if w.shaders != nil {
+				w.shaders.Close()
+			}
+			return nil, err
+		}
+		log.Printf("watching directory %s for changes", dir)
+	}
+	if w.shaders == nil {
+		return nil, fmt.Errorf("no directories to watch")
+	}
+	w.shaders.EntryPoint = builder.Config.GetEntryPoint()
+	w.shaders.Compile = w.compile
+	return w, nil
+}
+
+// Run compiles shaders as they change until ctx is cancelled
+func (w *Watcher) Run(ctx context.Context) error {
+	w.shaders.Debounce = w.Debounce
+	for event := range w.shaders.Start(ctx) {
+		switch e := event.(type) {
+		case *watch.Removed:
+			w.removeOutputs(e.Path)
+		case *watch.Diagnostics:
+			if e.Path == "" {
+				log.Printf("error: %v", e.Err)
+			} else {
+				log.Printf("error compiling shader '%s': %v", e.Path, e.Err)
+			}
+		}
+	}
+	return w.shaders.Close()
+}
+
+// removeOutputs removes the outputs of a shader which has been deleted
+func (w *Watcher) removeOutputs(path string) {
+	unit, err := w.builder.Unit(path, "")
+	if err != nil {
+		return
+	}
+	for _, output := range unit.Outputs() {
+		if err := os.Remove(output); err == nil {
+			log.Printf("removed %s, %s was deleted", output, path)
+			if w.builder.Notify != nil {
+				w.builder.Notify(hotreload.Event{Source: path, Output: output, Removed: true})
+			}
+		} else if !os.IsNotExist(err) {
+			log.Printf("error removing '%s': %v", output, err)
+		}
+	}
+}
+
+// compile compiles a shader and writes its outputs, options are the
+// builder's options with includes recorded by the ShaderWatcher
+func (w *Watcher) compile(path string, options *gs.CompilerOptions) watch.Event {
+	unit, err := w.builder.Unit(path, "")
+	if err == nil {
+		err = compileUnitWith(w.builder.compiler, options, w.builder.format, w.builder.post, unit, w.builder.Notify, w.builder.Diagnostics)
+	}
+	if err != nil {
+		return &watch.Diagnostics{Path: path, Message: err.Error(), Err: err}
+	}
+	return &watch.Compiled{Path: path, Stage: unit.Stage}
+}
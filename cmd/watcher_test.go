@@ -0,0 +1,206 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gs "github.com/celer/gshaderc"
+	"github.com/celer/gshaderc/hotreload"
+	"github.com/celer/gshaderc/watch"
+)
+
+func TestWatcherMatchesOneShot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	source := filepath.Join(dir, "main.frag")
+	writeFiles(t, dir, map[string]string{"main.frag": "#version 450\n#ifdef FOG\nfog();\n#endif\nvoid main() {}\n"})
+
+	backend := gs.NewFakeBackend()
+	compiler := gs.NewCompilerWithBackend(backend)
+	defer compiler.Release()
+	config := CompileConfig{Target: gs.TargetVulkan10, Optimize: "size", EntryPoint: "entry", Macros: map[string]string{"FOG": ""}}
+	builder, err := NewBuilder(compiler, config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unit, err := builder.Unit(source, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := builder.Compile(unit); err != nil {
+		t.Fatal(err)
+	}
+	oneShot, err := ioutil.ReadFile(unit.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(unit.Output); err != nil {
+		t.Fatal(err)
+	}
+
+	w := &Watcher{builder: builder}
+	if _, ok := w.compile(source, builder.options).(*watch.Compiled); !ok {
+		t.Fatal("expected the shader to compile")
+	}
+	watched, err := ioutil.ReadFile(unit.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(oneShot, watched) {
+		t.Fatal("expected watch mode to produce the same output as a one-shot compile")
+	}
+
+	options, err := config.Options()
+	if err != nil {
+		t.Fatal(err)
+	}
+	direct := compiler.CompileIntoSPV("#version 450\n#ifdef FOG\nfog();\n#endif\nvoid main() {}\n", gs.FragmentShader, source, "entry", options)
+	if !bytes.Equal(direct.Bytes(), watched) {
+		t.Fatal("expected watch mode to honour the compile options")
+	}
+	for _, job := range backend.Jobs() {
+		if level, ok := job.Options.OptimizationLevel(); !ok || level != gs.Size || job.EntryPoint != "entry" {
+			t.Fatalf("unexpected job %+v", job)
+		}
+	}
+
+	if _, err := NewBuilder(compiler, CompileConfig{Format: "spvasm"}, &gs.VariantSet{}); err == nil {
+		t.Fatal("expected an error for variants written as assembly")
+	}
+}
+
+func TestWatcherRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{"sub/a.frag": "#version 450\nvoid main() {}\n"})
+
+	compiler := gs.NewCompilerWithBackend(gs.NewFakeBackend())
+	defer compiler.Release()
+	builder, err := NewBuilder(compiler, CompileConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewWatcher([]string{dir}, builder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Debounce = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- w.Run(ctx)
+	}()
+
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		for i := 0; !cond(); i++ {
+			if i == 500 {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// Save like vim does, write a new file and rename it over the old one
+	tmp := filepath.Join(dir, "sub/a.frag.tmp")
+	if err := ioutil.WriteFile(tmp, []byte("#version 450\nvoid main() { }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, "sub/a.frag")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("the shader to compile", func() bool { return exists("sub/a.frag.spv") })
+
+	// Deleting the source removes its output
+	if err := os.Remove(filepath.Join(dir, "sub/a.frag")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("the output to be removed", func() bool { return !exists("sub/a.frag.spv") })
+
+	// Cancelling stops the watcher
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewWatcher([]string{filepath.Join(dir, "missing")}, builder); err == nil {
+		t.Fatal("expected an error watching a missing directory")
+	}
+}
+
+func TestWatcherNotify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"a.frag":      "#version 450\nvoid main() {}\n",
+		"broken.vert": "void main() {}\n",
+	})
+
+	compiler := gs.NewCompilerWithBackend(gs.NewFakeBackend())
+	defer compiler.Release()
+	builder, err := NewBuilder(compiler, CompileConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []hotreload.Event
+	builder.Notify = func(e hotreload.Event) {
+		events = append(events, e)
+	}
+	w := &Watcher{builder: builder}
+
+	source := filepath.Join(dir, "a.frag")
+	w.compile(source, builder.options)
+	if _, ok := w.compile(filepath.Join(dir, "broken.vert"), builder.options).(*watch.Diagnostics); !ok {
+		t.Fatal("expected diagnostics for broken.vert")
+	}
+	if err := os.Remove(source); err != nil {
+		t.Fatal(err)
+	}
+	w.removeOutputs(source)
+
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %+v", events)
+	}
+	if e := events[0]; !e.Success || e.Source != source || e.Output != source+".spv" || len(e.Hash) != 64 {
+		t.Fatalf("unexpected success event %+v", e)
+	}
+	if e := events[1]; e.Success || e.Output != "" || e.Diagnostics == "" {
+		t.Fatalf("unexpected failure event %+v", e)
+	}
+	if e := events[2]; !e.Removed || e.Output != source+".spv" {
+		t.Fatalf("unexpected removal event %+v", e)
+	}
+}
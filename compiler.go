@@ -14,22 +14,27 @@
 
 package gshaderc
 
-// #cgo LDFLAGS: -lshaderc_combined -lstdc++ -lm
-// #include <shaderc/shaderc.h>
-import "C"
-import ()
-
+// Compiler compiles shaders using a Backend
 type Compiler struct {
-	compiler C.shaderc_compiler_t
+	backend Backend
 }
 
-// NewCompiler creates a new compiler
+// NewCompiler creates a new compiler using the default backend, which is
+// libshaderc when built with the 'shaderc' or 'shaderc_dynamic' build tags
+// and glslc otherwise
 func NewCompiler() *Compiler {
-	c := &Compiler{}
+	return NewCompilerWithBackend(newDefaultBackend())
+}
 
-	c.compiler = C.shaderc_compiler_initialize()
+// NewCompilerWithBackend creates a new compiler which uses the given backend,
+// the backend is released when the compiler is released
+func NewCompilerWithBackend(backend Backend) *Compiler {
+	return &Compiler{backend: backend}
+}
 
-	return c
+// Backend returns the backend used by the compiler
+func (c *Compiler) Backend() Backend {
+	return c.backend
 }
 
 // CompileIntoSPV
@@ -53,29 +58,43 @@ func NewCompiler() *Compiler {
 // synchronization. If there was failure in allocating the compiler object,
 // null will be returned.
 func (c *Compiler) CompileIntoSPV(source string, shaderType ShaderType, inputFilename string, entryPoint string, options *CompilerOptions) *CompilationResult {
-	cr := &CompilationResult{}
-	cr.result = C.shaderc_compile_into_spv(c.compiler,
-		C.CString(source),
-		C.ulong(len(source)),
-		C.shaderc_shader_kind(shaderType),
-		C.CString(inputFilename),
-		C.CString(entryPoint), options.options)
-	return cr
+	return c.compile(&CompileJob{
+		Source:        source,
+		ShaderType:    shaderType,
+		InputFilename: inputFilename,
+		EntryPoint:    entryPoint,
+		Output:        OutputSPV,
+		Options:       options,
+	})
 }
 
 // CompileIntoPreProcessedText
+// Like shaderc_compile_into_spv, but the result contains preprocessed source
+// code instead of a SPIR-V binary module.
+func (c *Compiler) CompileIntoPreProcessedText(source string, shaderType ShaderType, inputFilename string, entryPoint string, options *CompilerOptions) *CompilationResult {
+	return c.compile(&CompileJob{
+		Source:        source,
+		ShaderType:    shaderType,
+		InputFilename: inputFilename,
+		EntryPoint:    entryPoint,
+		Output:        OutputPreprocessedText,
+		Options:       options,
+	})
+}
+
+// CompileIntoSPVAssembly
 // Like shaderc_compile_into_spv, but the result contains SPIR-V assembly text
 // instead of a SPIR-V binary module.  The SPIR-V assembly syntax is as defined
 // by the SPIRV-Tools open source project.
-func (c *Compiler) CompileIntoPreProcessedText(source string, shaderType ShaderType, inputFilename string, entryPoint string, options *CompilerOptions) *CompilationResult {
-	cr := &CompilationResult{}
-	cr.result = C.shaderc_compile_into_preprocessed_text(c.compiler,
-		C.CString(source),
-		C.ulong(len(source)),
-		C.shaderc_shader_kind(shaderType),
-		C.CString(inputFilename),
-		C.CString(entryPoint), options.options)
-	return cr
+func (c *Compiler) CompileIntoSPVAssembly(source string, shaderType ShaderType, inputFilename string, entryPoint string, options *CompilerOptions) *CompilationResult {
+	return c.compile(&CompileJob{
+		Source:        source,
+		ShaderType:    shaderType,
+		InputFilename: inputFilename,
+		EntryPoint:    entryPoint,
+		Output:        OutputAssembly,
+		Options:       options,
+	})
 }
 
 // AssembleIntoSPV
@@ -89,16 +108,22 @@ func (c *Compiler) CompileIntoPreProcessedText(source string, shaderType ShaderT
 // If there was failure in allocating the compiler object, null will be
 // returned.
 func (c *Compiler) AssembleIntoSPV(source string, options *CompilerOptions) *CompilationResult {
-	cr := &CompilationResult{}
-	cr.result = C.shaderc_assemble_into_spv(c.compiler,
-		C.CString(source),
-		C.ulong(len(source)),
-		options.options)
+	return c.compile(&CompileJob{
+		Source:     source,
+		ShaderType: SPIRVAssembly,
+		Output:     OutputSPV,
+		Options:    options,
+	})
+}
 
-	return cr
+func (c *Compiler) compile(job *CompileJob) *CompilationResult {
+	if job.Options == nil {
+		job.Options = NewCompilerOptions()
+	}
+	return c.backend.Compile(job)
 }
 
 // Release the compiler instance
 func (c *Compiler) Release() {
-	C.shaderc_compiler_release(c.compiler)
+	c.backend.Release()
 }
@@ -15,6 +15,7 @@
 package gshaderc
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 )
@@ -41,6 +42,9 @@ func TestCompilerInclude(t *testing.T) {
 
 	goodSource := "#version 450\n#include <foo>\nvoid main() {}"
 	res := compiler.CompileIntoSPV(goodSource, VertexShader, "main.vert", "main", options)
+	if errors.Is(res.Error(), BackendUnavailableError) {
+		t.Skip("requires libshaderc or glslc")
+	}
 
 	if includeRequests != 1 {
 		t.Fatal("Expected 1 inclusion request")
@@ -56,6 +60,9 @@ func TestCompilerInclude(t *testing.T) {
 func TestCompilerSimple(t *testing.T) {
 	badSource := "void main(){}"
 	_, err := CompileShader(badSource, "main.vert", "")
+	if errors.Is(err, BackendUnavailableError) {
+		t.Skip("requires libshaderc or glslc")
+	}
 	if err != CompilationError {
 		t.Fatal("Expected compilation error")
 	}
@@ -76,6 +83,9 @@ func TestCompiler(t *testing.T) {
 
 	badSource := "void main(){}"
 	res := compiler.CompileIntoSPV(badSource, VertexShader, "main.vert", "main", options)
+	if errors.Is(res.Error(), BackendUnavailableError) {
+		t.Skip("requires libshaderc or glslc")
+	}
 
 	if res.Error() != CompilationError {
 		t.Fatal("Expected compilation error")
@@ -14,23 +14,38 @@
 
 package gshaderc
 
-// #cgo LDFLAGS: -lshaderc_combined -lstdc++ -lm
-// #include <shaderc/shaderc.h>
-import "C"
-import ()
-
-// CommpilerOptions allows specific compiler options to be set
+// CommpilerOptions allows specific compiler options to be set, the options
+// are plain Go values which each Backend translates into whatever its
+// compiler understands
 type CompilerOptions struct {
-	options C.shaderc_compile_options_t
+	target           Target
+	envVersion       EnvVersion
+	hasTarget        bool
+	spirvVersion     SPIRVVersion
+	hasSPIRVVersion  bool
+	optimization     OptimizationLevel
+	hasOptimization  bool
+	macros           []Macro
+	nanClamp         bool
+	invertY          bool
+	bindingBases     map[UniformKind]uint32
+	suppressWarnings bool
+	warningsAsErrors bool
+	autoBindUniforms bool
+	debugInfo        bool
+	limits           map[ResourceLimit]int
+	includeResolver  IncludeResolver
+}
+
+// Macro is a predefined macro, see AddMacroDefinition
+type Macro struct {
+	Name  string
+	Value string
 }
 
 // NewCompilerOptions creates a new compiler options object
 func NewCompilerOptions() *CompilerOptions {
-	c := &CompilerOptions{}
-
-	c.options = C.shaderc_compile_options_initialize()
-
-	return c
+	return &CompilerOptions{}
 }
 
 // SetNanClamp
@@ -39,13 +54,13 @@ func NewCompilerOptions() *CompilerOptions {
 // builtin will favour the non-NaN operands, as if clamp were implemented
 // as a composition of max and min.
 func (c *CompilerOptions) SetNanClamp(enabled bool) {
-	C.shaderc_compile_options_set_nan_clamp(c.options, C.bool(enabled))
+	c.nanClamp = enabled
 }
 
 // SetInvertY
 // Sets whether the compiler should invert position.Y output in vertex shader.
 func (c *CompilerOptions) SetInvertY(enabled bool) {
-	C.shaderc_compile_options_set_invert_y(c.options, C.bool(enabled))
+	c.invertY = enabled
 }
 
 // SetBindingBase
@@ -54,26 +69,34 @@ func (c *CompilerOptions) SetInvertY(enabled bool) {
 // automatically assigned number.  For HLSL compilation, the regsiter number
 // assigned to the resource is added to this specified base.
 func (c *CompilerOptions) SetBindingBase(kind UniformKind, base uint32) {
-	C.shaderc_compile_options_set_binding_base(c.options, C.shaderc_uniform_kind(kind), C.uint(base))
+	if c.bindingBases == nil {
+		c.bindingBases = make(map[UniformKind]uint32)
+	}
+	c.bindingBases[kind] = base
 }
 
 // AddMacroDefinition
 // Adds a predefined macro to the compilation options. This has the same
 // effect as passing -Dname=value to the command-line compiler.  If value
-// is NULL, it has the same effect as passing -Dname to the command-line
+// is empty, it has the same effect as passing -Dname to the command-line
 // compiler. If a macro definition with the same name has previously been
-// added, the value is replaced with the new value. The macro name and
-// value are passed in with char pointers, which point to their data, and
-// the lengths of their data.
+// added, the value is replaced with the new value.
 func (c *CompilerOptions) AddMacroDefinition(name, value string) {
-	C.shaderc_compile_options_add_macro_definition(c.options, C.CString(name), C.ulong(len(name)), C.CString(value), C.ulong(len(value)))
+	for i := range c.macros {
+		if c.macros[i].Name == name {
+			c.macros[i].Value = value
+			return
+		}
+	}
+	c.macros = append(c.macros, Macro{Name: name, Value: value})
 }
 
 // SetOptimizationLevel
 // Sets the compiler optimization level to the given level. Only the last one
 // takes effect if multiple calls of this function exist.
 func (c *CompilerOptions) SetOptimizationLevel(level OptimizationLevel) {
-	C.shaderc_compile_options_set_optimization_level(c.options, C.shaderc_optimization_level(level))
+	c.optimization = level
+	c.hasOptimization = true
 }
 
 // SuppressWarnings
@@ -82,19 +105,34 @@ func (c *CompilerOptions) SetOptimizationLevel(level OptimizationLevel) {
 // turned on, warning messages will be inhibited, and will not be emitted
 // as error messages.
 func (c *CompilerOptions) SuppressWarnings() {
-	C.shaderc_compile_options_set_suppress_warnings(c.options)
+	c.suppressWarnings = true
 }
 
 // Clone clones a copy of the compiler options
 func (c *CompilerOptions) Clone() *CompilerOptions {
-	n := &CompilerOptions{}
-	n.options = C.shaderc_compile_options_clone(c.options)
-	return n
+	n := *c
+	n.macros = append([]Macro(nil), c.macros...)
+	if c.bindingBases != nil {
+		n.bindingBases = make(map[UniformKind]uint32, len(c.bindingBases))
+		for k, v := range c.bindingBases {
+			n.bindingBases[k] = v
+		}
+	}
+	if c.limits != nil {
+		n.limits = make(map[ResourceLimit]int, len(c.limits))
+		for k, v := range c.limits {
+			n.limits[k] = v
+		}
+	}
+	return &n
 }
 
 // SetLimit sets a resource limit
 func (c *CompilerOptions) SetLimit(limit ResourceLimit, value int) {
-	C.shaderc_compile_options_set_limit(c.options, C.shaderc_limit(limit), C.int(value))
+	if c.limits == nil {
+		c.limits = make(map[ResourceLimit]int)
+	}
+	c.limits[limit] = value
 }
 
 // SetTargetEnv
@@ -104,7 +142,9 @@ func (c *CompilerOptions) SetLimit(limit ResourceLimit, value int) {
 // a value listed in shaderc_env_version.  The 0 value maps to Vulkan 1.0 if
 // |target| is Vulkan, and it maps to OpenGL 4.5 if |target| is OpenGL.
 func (c *CompilerOptions) SetTargetEnv(target Target, version EnvVersion) {
-	C.shaderc_compile_options_set_target_env(c.options, C.shaderc_target_env(target), C.uint(version))
+	c.target = target
+	c.envVersion = version
+	c.hasTarget = true
 }
 
 // SetSPIRVVersion
@@ -114,7 +154,8 @@ func (c *CompilerOptions) SetTargetEnv(target Target, version EnvVersion) {
 // required to be supported by the target environment.  E.g. Default to SPIR-V
 // 1.0 for Vulkan 1.0 and SPIR-V 1.3 for Vulkan 1.1.
 func (c *CompilerOptions) SetSPIRVVersion(version SPIRVVersion) {
-	C.shaderc_compile_options_set_target_spirv(c.options, C.shaderc_spirv_version(version))
+	c.spirvVersion = version
+	c.hasSPIRVVersion = true
 }
 
 // SetWarningsAsErrors
@@ -123,17 +164,100 @@ func (c *CompilerOptions) SetSPIRVVersion(version SPIRVVersion) {
 // warning-as-errors and suppress-warnings modes are set, warnings will not
 // be emitted as error messages.
 func (c *CompilerOptions) SetWarningsAsErrors() {
-	C.shaderc_compile_options_set_warnings_as_errors(c.options)
+	c.warningsAsErrors = true
 }
 
 // SetAutoBindUniforms
 // Sets whether the compiler should automatically assign bindings to uniforms
 // that aren't already explicitly bound in the shader source.
 func (c *CompilerOptions) SetAutoBindUniforms(auto bool) {
-	C.shaderc_compile_options_set_auto_bind_uniforms(c.options, C.bool(auto))
+	c.autoBindUniforms = auto
 }
 
-// Releases the compiler options
+// SetGenerateDebugInfo
+// Sets the compiler mode to generate debug information in the output.
+func (c *CompilerOptions) SetGenerateDebugInfo() {
+	c.debugInfo = true
+}
+
+// Releases the compiler options, options no longer hold on to any native
+// resources so this is safe to skip
 func (c *CompilerOptions) Release() {
-	C.shaderc_compile_options_release(c.options)
+}
+
+// TargetEnv returns the target environment and version, ok is false if
+// SetTargetEnv has not been called
+func (c *CompilerOptions) TargetEnv() (target Target, version EnvVersion, ok bool) {
+	return c.target, c.envVersion, c.hasTarget
+}
+
+// SPIRVVersion returns the target SPIR-V version, ok is false if
+// SetSPIRVVersion has not been called
+func (c *CompilerOptions) SPIRVVersion() (version SPIRVVersion, ok bool) {
+	return c.spirvVersion, c.hasSPIRVVersion
+}
+
+// OptimizationLevel returns the optimization level, ok is false if
+// SetOptimizationLevel has not been called
+func (c *CompilerOptions) OptimizationLevel() (level OptimizationLevel, ok bool) {
+	return c.optimization, c.hasOptimization
+}
+
+// Macros returns the predefined macros in the order they were first added
+func (c *CompilerOptions) Macros() []Macro {
+	return append([]Macro(nil), c.macros...)
+}
+
+// NanClamp returns the value set by SetNanClamp
+func (c *CompilerOptions) NanClamp() bool {
+	return c.nanClamp
+}
+
+// InvertY returns the value set by SetInvertY
+func (c *CompilerOptions) InvertY() bool {
+	return c.invertY
+}
+
+// BindingBases returns the binding bases set by SetBindingBase
+func (c *CompilerOptions) BindingBases() map[UniformKind]uint32 {
+	bases := make(map[UniformKind]uint32, len(c.bindingBases))
+	for k, v := range c.bindingBases {
+		bases[k] = v
+	}
+	return bases
+}
+
+// WarningsSuppressed returns true if SuppressWarnings has been called
+func (c *CompilerOptions) WarningsSuppressed() bool {
+	return c.suppressWarnings
+}
+
+// WarningsAsErrors returns true if SetWarningsAsErrors has been called
+func (c *CompilerOptions) WarningsAsErrors() bool {
+	return c.warningsAsErrors
+}
+
+// AutoBindUniforms returns the value set by SetAutoBindUniforms
+func (c *CompilerOptions) AutoBindUniforms() bool {
+	return c.autoBindUniforms
+}
+
+// GenerateDebugInfo returns true if SetGenerateDebugInfo has been called
+func (c *CompilerOptions) GenerateDebugInfo() bool {
+	return c.debugInfo
+}
+
+// Limits returns the resource limits set by SetLimit
+func (c *CompilerOptions) Limits() map[ResourceLimit]int {
+	limits := make(map[ResourceLimit]int, len(c.limits))
+	for k, v := range c.limits {
+		limits[k] = v
+	}
+	return limits
+}
+
+// IncludeResolver returns the include resolver set by SetIncludeCallback,
+// or nil if there isn't one
+func (c *CompilerOptions) IncludeResolver() IncludeResolver {
+	return c.includeResolver
 }
@@ -14,75 +14,53 @@
 
 package gshaderc
 
-// #cgo LDFLAGS: -lshaderc_combined -lstdc++ -lm
-// #include <shaderc/shaderc.h>
-import "C"
-import (
-	"fmt"
-	"unsafe"
-)
-
 // CompilationResult the result of compiling stuff
 type CompilationResult struct {
-	result C.shaderc_compilation_result_t
+	data        []byte
+	err         error
+	message     string
+	numErrors   int
+	numWarnings int
 }
 
-func compilationStatusToError(status C.shaderc_compilation_status) error {
-	switch status {
-	case C.shaderc_compilation_status_success:
-		return nil
-	case C.shaderc_compilation_status_invalid_stage:
-		return InvalidStageError
-	case C.shaderc_compilation_status_compilation_error:
-		return CompilationError
-	case C.shaderc_compilation_status_internal_error:
-		return InternalError
-	case C.shaderc_compilation_status_null_result_object:
-		return NullResultObjectError
-	case C.shaderc_compilation_status_invalid_assembly:
-		return InvalidAssemblyError
-	case C.shaderc_compilation_status_validation_error:
-		return ValidationError
-	case C.shaderc_compilation_status_transformation_error:
-		return TransformationError
-	case C.shaderc_compilation_status_configuration_error:
-		return ConfigurationError
+// NewCompilationResult creates a compilation result, this is intended for
+// Backend implementations
+func NewCompilationResult(data []byte, err error, message string, numErrors, numWarnings int) *CompilationResult {
+	return &CompilationResult{
+		data:        data,
+		err:         err,
+		message:     message,
+		numErrors:   numErrors,
+		numWarnings: numWarnings,
 	}
-	return fmt.Errorf("unknown error %v", status)
 }
 
 // ErrorMessage returns a specific error message
 func (c *CompilationResult) ErrorMessage() string {
-	em := C.shaderc_result_get_error_message(c.result)
-	return C.GoString(em)
+	return c.message
 }
 
 // Error returns a generic error message
 func (c *CompilationResult) Error() error {
-	status := C.shaderc_result_get_compilation_status(c.result)
-	return compilationStatusToError(status)
+	return c.err
 }
 
 // NumErrors returns the number of errors
 func (c *CompilationResult) NumErrors() int {
-	return int(C.shaderc_result_get_num_errors(c.result))
+	return c.numErrors
 }
 
 // NumWarnings returns the number of warnings
 func (c *CompilationResult) NumWarnings() int {
-	return int(C.shaderc_result_get_num_warnings(c.result))
+	return c.numWarnings
 }
 
 // Bytes returns the resulting compiled item
 func (c *CompilationResult) Bytes() []byte {
-	dataLen := C.shaderc_result_get_length(c.result)
-	data := C.shaderc_result_get_bytes(c.result)
-
-	b := C.GoBytes(unsafe.Pointer(data), C.int(dataLen))
-	return b
+	return c.data
 }
 
-// Release releases the compilation results
+// Release releases the compilation results, results no longer hold on to
+// any native resources so this is safe to skip
 func (c *CompilationResult) Release() {
-	C.shaderc_result_release(c.result)
 }
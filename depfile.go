@@ -0,0 +1,46 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gshaderc
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// WriteDepfile writes a Makefile rule saying that targets depend on deps,
+// in the format written by 'gcc -MD' which Make and Ninja both read. deps
+// are usually the source followed by IncludeRecorder.Includes().
+func WriteDepfile(w io.Writer, targets []string, deps []string) error {
+	b := bufio.NewWriter(w)
+	for i, target := range targets {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(escapeDepfilePath(target))
+	}
+	b.WriteString(":")
+	for _, dep := range deps {
+		b.WriteString(" \\\n  ")
+		b.WriteString(escapeDepfilePath(dep))
+	}
+	b.WriteString("\n")
+	return b.Flush()
+}
+
+// escapeDepfilePath escapes the characters Make treats specially in a rule
+func escapeDepfilePath(path string) string {
+	return strings.NewReplacer(" ", "\\ ", "#", "\\#", "$", "$$").Replace(path)
+}
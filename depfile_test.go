@@ -0,0 +1,32 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gshaderc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteDepfile(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteDepfile(&buf, []string{"out/a.frag.spv", "out/a.frag.FOG.spv"}, []string{"a.frag", "my include/light#1.glsl", "$HOME.glsl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "out/a.frag.spv out/a.frag.FOG.spv: \\\n  a.frag \\\n  my\\ include/light\\#1.glsl \\\n  $$HOME.glsl\n"
+	if buf.String() != expected {
+		t.Fatalf("unexpected depfile %q", buf.String())
+	}
+}
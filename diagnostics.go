@@ -0,0 +1,114 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gshaderc
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Severity is how serious a diagnostic is
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityNote    Severity = "note"
+)
+
+// Diagnostic is a single error or warning from the compiler, Line and
+// Column are 1 based and 0 if the compiler didn't report them
+type Diagnostic struct {
+	File     string
+	Line     int
+	Column   int
+	Severity Severity
+	Message  string
+}
+
+// ParseDiagnostics splits the messages returned by
+// CompilationResult.ErrorMessage into diagnostics. Messages are expected to
+// look like 'file:line:column: severity: message', where the line and
+// column are optional. Lines which don't look like that are appended to the
+// message before them, and summaries such as '1 error generated.' are
+// dropped.
+func ParseDiagnostics(messages string) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, line := range strings.Split(messages, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" || isDiagnosticsSummary(line) {
+			continue
+		}
+		if d, ok := parseDiagnostic(line); ok {
+			diagnostics = append(diagnostics, d)
+			continue
+		}
+		if len(diagnostics) > 0 {
+			last := &diagnostics[len(diagnostics)-1]
+			last.Message += "\n" + line
+			continue
+		}
+		diagnostics = append(diagnostics, Diagnostic{Severity: SeverityError, Message: strings.TrimSpace(line)})
+	}
+	return diagnostics
+}
+
+// parseDiagnostic parses a single 'location: severity: message' line, the
+// severity is the first one in the line as messages may quote others
+func parseDiagnostic(line string) (Diagnostic, bool) {
+	first, found := -1, Diagnostic{}
+	for _, severity := range []Severity{SeverityError, SeverityWarning, SeverityNote} {
+		sep := ": " + string(severity) + ":"
+		i := strings.Index(line, sep)
+		if i <= 0 || (first >= 0 && i > first) {
+			continue
+		}
+		first = i
+		found = Diagnostic{Severity: severity, Message: strings.TrimSpace(line[i+len(sep):])}
+		found.File, found.Line, found.Column = parseDiagnosticLocation(line[:i])
+	}
+	return found, first >= 0
+}
+
+// parseDiagnosticLocation splits 'file:line:column' where the line and
+// column are optional, the file name may itself contain colons
+func parseDiagnosticLocation(location string) (file string, line, column int) {
+	var numbers []int
+	for len(numbers) < 2 {
+		i := strings.LastIndex(location, ":")
+		if i < 0 {
+			break
+		}
+		n, err := strconv.Atoi(location[i+1:])
+		if err != nil {
+			break
+		}
+		numbers = append([]int{n}, numbers...)
+		location = location[:i]
+	}
+	switch len(numbers) {
+	case 1:
+		line = numbers[0]
+	case 2:
+		line, column = numbers[0], numbers[1]
+	}
+	return location, line, column
+}
+
+// isDiagnosticsSummary reports whether line is a summary such as
+// '2 errors generated.' or '1 warning and 1 error generated.'
+func isDiagnosticsSummary(line string) bool {
+	return strings.HasSuffix(strings.TrimSpace(line), " generated.")
+}
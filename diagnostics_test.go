@@ -0,0 +1,43 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gshaderc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDiagnostics(t *testing.T) {
+	messages := "shaders/sdf.comp:336: error: '' :  syntax error, unexpected INT\n" +
+		"C:\\shaders\\a.frag:12:7: warning: 'x' : unused variable\n" +
+		"  x = 1;\n" +
+		"b.vert: error: #pragma shader_stage required when stage is not specified\n" +
+		"c.frag:3: warning: '#warning' : see c.glsl:1: error: for details\n" +
+		"1 warning and 2 errors generated.\n"
+	expected := []Diagnostic{
+		{File: "shaders/sdf.comp", Line: 336, Severity: SeverityError, Message: "'' :  syntax error, unexpected INT"},
+		{File: "C:\\shaders\\a.frag", Line: 12, Column: 7, Severity: SeverityWarning, Message: "'x' : unused variable\n  x = 1;"},
+		{File: "b.vert", Severity: SeverityError, Message: "#pragma shader_stage required when stage is not specified"},
+		{File: "c.frag", Line: 3, Severity: SeverityWarning, Message: "'#warning' : see c.glsl:1: error: for details"},
+	}
+	if got := ParseDiagnostics(messages); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("unexpected diagnostics %+v", got)
+	}
+
+	expected = []Diagnostic{{Severity: SeverityError, Message: "glslc: something went wrong"}}
+	if got := ParseDiagnostics("glslc: something went wrong\n"); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("unexpected diagnostics %+v", got)
+	}
+}
@@ -0,0 +1,423 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gshaderc
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DisassemblyOptions controls the assembly Disassemble writes, the zero
+// value matches spirv-dis
+type DisassemblyOptions struct {
+	// RawIDs writes ids as %<number> rather than names derived from debug
+	// names, built-ins, types and constants
+	RawIDs bool
+	// NoHeader leaves out the comments describing the module header
+	NoHeader bool
+	// NoIndent leaves instructions unaligned, by default the '=' of every
+	// instruction with a result lines up
+	NoIndent bool
+}
+
+// disassemblyIndent is the column instructions start at when indenting
+const disassemblyIndent = 15
+
+// spirvGenerators names the tools in the SPIR-V generator registry
+var spirvGenerators = []string{
+	"Khronos", "LunarG", "Valve", "Codeplay", "NVIDIA", "ARM", "Khronos LLVM/SPIR-V Translator",
+	"Khronos SPIR-V Tools Assembler", "Khronos Glslang Reference Front End", "Qualcomm", "AMD", "Intel",
+	"Imagination", "Google Shaderc over Glslang", "Google spiregg", "Google rspirv",
+	"X-LEGEND Mesa-IR/SPIR-V Translator", "Khronos SPIR-V Tools Linker", "Wine VKD3D Shader Compiler",
+	"Clay Clay Shader Compiler", "W3C WebGPU Group WHLSL Shader Translator", "Google Clspv",
+	"Google MLIR SPIR-V Serializer", "Google Tint Compiler", "Google ANGLE Shader Compiler",
+	"Netease Games Messiah Shader Compiler", "Xenia Xenia Emulator Microcode Translator",
+	"Embark Studios Rust GPU Compiler Backend", "gfx-rs community Naga",
+}
+
+// builtInNames are the names given to variables decorated as built-ins
+// which have no debug name
+var builtInNames = map[uint32]string{
+	0: "gl_Position", 1: "gl_PointSize", 3: "gl_ClipDistance", 4: "gl_CullDistance", 5: "gl_VertexID",
+	6: "gl_InstanceID", 7: "gl_PrimitiveID", 8: "gl_InvocationID", 9: "gl_Layer", 10: "gl_ViewportIndex",
+	11: "gl_TessLevelOuter", 12: "gl_TessLevelInner", 13: "gl_TessCoord", 14: "gl_PatchVertices",
+	15: "gl_FragCoord", 16: "gl_PointCoord", 17: "gl_FrontFacing", 18: "gl_SampleID", 19: "gl_SamplePosition",
+	20: "gl_SampleMask", 22: "gl_FragDepth", 23: "gl_HelperInvocation", 24: "gl_NumWorkGroups",
+	25: "gl_WorkGroupSize", 26: "gl_WorkGroupID", 27: "gl_LocalInvocationID", 28: "gl_GlobalInvocationID",
+	29: "gl_LocalInvocationIndex", 30: "WorkDim", 31: "GlobalSize", 32: "EnqueuedWorkgroupSize",
+	33: "GlobalOffset", 34: "GlobalLinearId", 36: "SubgroupSize", 37: "SubgroupMaxSize", 38: "NumSubgroups",
+	39: "NumEnqueuedSubgroups", 40: "SubgroupId", 41: "SubgroupLocalInvocationId", 42: "gl_VertexIndex",
+	43: "gl_InstanceIndex",
+}
+
+// Disassemble converts a SPIR-V module to the assembly language of
+// SPIRV-Tools. Instructions gshaderc doesn't know are written as literal
+// words, e.g. '!0x00020123 !7', which assemblers accept in any position.
+func Disassemble(spirv []byte, options DisassemblyOptions) (string, error) {
+	words, err := SPIRVWords(spirv)
+	if err != nil {
+		return "", err
+	}
+	instructions, err := spirvInstructions(words)
+	if err != nil {
+		return "", err
+	}
+
+	d := &disassembler{options: options, extSets: make(map[uint32]string)}
+	parser := newSPIRVParser()
+	parsed := make([][]spirvParsedOperand, len(instructions))
+	for i, inst := range instructions {
+		// Instructions which can't be parsed are written as words
+		parsed[i], _ = parser.parse(inst)
+		if inst.Opcode == opExtInstImport && parsed[i] != nil {
+			d.extSets[inst.Words[1]], _ = spirvString(inst.Words[2:])
+		}
+	}
+	if !options.RawIDs {
+		d.names = friendlyNames(instructions, parsed)
+	}
+
+	var out strings.Builder
+	if !options.NoHeader {
+		version, generator := words[1], words[2]
+		tool := fmt.Sprintf("Unknown(%d)", generator>>16)
+		if int(generator>>16) < len(spirvGenerators) {
+			tool = spirvGenerators[generator>>16]
+		}
+		fmt.Fprintf(&out, "; SPIR-V\n; Version: %d.%d\n; Generator: %s; %d\n; Bound: %d\n; Schema: %d\n",
+			(version>>16)&0xff, (version>>8)&0xff, tool, generator&0xffff, words[3], words[4])
+	}
+	for i, inst := range instructions {
+		d.instruction(&out, inst, parsed[i])
+	}
+	return out.String(), nil
+}
+
+type disassembler struct {
+	options DisassemblyOptions
+	names   map[uint32]string
+	extSets map[uint32]string
+}
+
+func (d *disassembler) id(id uint32) string {
+	if name, ok := d.names[id]; ok {
+		return "%" + name
+	}
+	return "%" + strconv.FormatUint(uint64(id), 10)
+}
+
+func (d *disassembler) instruction(out *strings.Builder, inst spirvInstruction, operands []spirvParsedOperand) {
+	if operands == nil {
+		if !d.options.NoIndent {
+			out.WriteString(strings.Repeat(" ", disassemblyIndent))
+		}
+		for i, w := range inst.Words {
+			if i == 0 {
+				fmt.Fprintf(out, "!0x%08x", w)
+			} else {
+				fmt.Fprintf(out, " !%d", w)
+			}
+		}
+		out.WriteString("\n")
+		return
+	}
+
+	var result string
+	for _, o := range operands {
+		if o.Kind == "IdResult" {
+			result = d.id(o.Words[0])
+		}
+	}
+	switch {
+	case result != "" && !d.options.NoIndent:
+		if pad := disassemblyIndent - 3 - len(result); pad > 0 {
+			out.WriteString(strings.Repeat(" ", pad))
+		}
+		out.WriteString(result + " = ")
+	case result != "":
+		out.WriteString(result + " = ")
+	case !d.options.NoIndent:
+		out.WriteString(strings.Repeat(" ", disassemblyIndent))
+	}
+
+	out.WriteString(spirvOpcodes[inst.Opcode].Name)
+	var extSet string
+	if inst.Opcode == opExtInst {
+		extSet = d.extSets[inst.Words[3]]
+	}
+	for _, o := range operands {
+		if o.Kind == "IdResult" {
+			continue
+		}
+		out.WriteString(" ")
+		switch o.Kind {
+		case "IdResultType", "IdRef", "IdScope", "IdMemorySemantics":
+			out.WriteString(d.id(o.Words[0]))
+		case "LiteralInteger":
+			out.WriteString(strconv.FormatUint(uint64(o.Words[0]), 10))
+		case "LiteralString":
+			s, _ := spirvString(o.Words)
+			out.WriteString(quoteSPIRVString(s))
+		case "LiteralContextDependentNumber":
+			out.WriteString(spirvNumber(o))
+		case "LiteralExtInstInteger":
+			if n := o.Words[0]; extSet == "GLSL.std.450" && n > 0 && int(n) < len(glslStd450Instructions) {
+				out.WriteString(glslStd450Instructions[n])
+			} else {
+				out.WriteString(strconv.FormatUint(uint64(n), 10))
+			}
+		case "LiteralSpecConstantOpInteger":
+			out.WriteString(strings.TrimPrefix(spirvOpcodes[o.Words[0]].Name, "Op"))
+		default:
+			out.WriteString(spirvEnums[o.Kind].name(o.Words[0]))
+		}
+	}
+	out.WriteString("\n")
+}
+
+// quoteSPIRVString quotes a literal string, escaping quotes and backslashes
+func quoteSPIRVString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// spirvNumber formats a literal number of its type the way SPIRV-Tools
+// does, floats in decimal if they are normal or zero and in hex otherwise
+func spirvNumber(o spirvParsedOperand) string {
+	bits := uint64(o.Words[0])
+	if len(o.Words) > 1 {
+		bits |= uint64(o.Words[1]) << 32
+	}
+	t := o.Number
+	switch {
+	case t.Float && t.Width == 16:
+		return hexFloat(bits&0xffff, 10, 5)
+	case t.Float && t.Width == 32:
+		if exponent := bits >> 23 & 0xff; exponent == 0xff || exponent == 0 && bits&0x7fffff != 0 {
+			return hexFloat(bits, 23, 8)
+		}
+		return strconv.FormatFloat(float64(math.Float32frombits(uint32(bits))), 'g', 9, 64)
+	case t.Float:
+		if exponent := bits >> 52 & 0x7ff; exponent == 0x7ff || exponent == 0 && bits&(1<<52-1) != 0 {
+			return hexFloat(bits, 52, 11)
+		}
+		return strconv.FormatFloat(math.Float64frombits(bits), 'g', 17, 64)
+	case t.Signed && t.Width > 32:
+		return strconv.FormatInt(int64(bits), 10)
+	case t.Signed:
+		return strconv.FormatInt(int64(int32(bits)), 10)
+	}
+	return strconv.FormatUint(bits, 10)
+}
+
+// hexFloat formats a float in the hex notation of SPIRV-Tools, with
+// denormals normalized and trailing zero nibbles of the fraction dropped
+func hexFloat(bits uint64, fractionBits, exponentBits uint) string {
+	sign := ""
+	if bits>>(fractionBits+exponentBits)&1 != 0 {
+		sign = "-"
+	}
+	bias := int(1)<<(exponentBits-1) - 1
+	biased := int(bits >> fractionBits & (1<<exponentBits - 1))
+	nibbles := (fractionBits + 3) / 4
+	// Align the fraction to whole nibbles
+	fraction := bits & (1<<fractionBits - 1) << (nibbles*4 - fractionBits)
+	width := nibbles * 4
+
+	exponent := biased - bias
+	switch {
+	case biased == 0 && fraction == 0:
+		exponent = 0
+	case biased == 0:
+		// Normalize denormals, the leading 1 becomes implicit
+		for fraction&(1<<(width-1)) == 0 {
+			fraction <<= 1
+			exponent--
+		}
+		fraction = fraction << 1 & (1<<width - 1)
+	}
+
+	lead := "1"
+	if biased == 0 && bits&(1<<fractionBits-1) == 0 {
+		lead = "0"
+	}
+	for nibbles > 0 && fraction&0xf == 0 {
+		fraction >>= 4
+		nibbles--
+	}
+	s := sign + "0x" + lead
+	if nibbles > 0 {
+		s += fmt.Sprintf(".%0*x", nibbles, fraction)
+	}
+	if exponent >= 0 {
+		return s + "p+" + strconv.Itoa(exponent)
+	}
+	return s + "p" + strconv.Itoa(exponent)
+}
+
+// friendlyNames names ids the way spirv-dis does, after their debug name,
+// the built-in they are decorated with or for types and constants after
+// what they are. Names are made unique by adding a suffix.
+func friendlyNames(instructions []spirvInstruction, parsed [][]spirvParsedOperand) map[uint32]string {
+	n := &idNamer{names: make(map[uint32]string), used: make(map[string]bool)}
+	for i, inst := range instructions {
+		if parsed[i] == nil {
+			continue
+		}
+		w := inst.Words
+		switch inst.Opcode {
+		case opName:
+			name, _ := spirvString(w[2:])
+			n.save(w[1], name)
+		case opDecorate:
+			if w[2] == decorationBuiltIn && len(w) > 3 {
+				if name, ok := builtInNames[w[3]]; ok {
+					n.save(w[1], name)
+				}
+			}
+		case opTypeVoid:
+			n.save(w[1], "void")
+		case opTypeBool:
+			n.save(w[1], "bool")
+		case opTypeInt:
+			root, signedness := "", ""
+			switch w[2] {
+			case 8:
+				root = "char"
+			case 16:
+				root = "short"
+			case 32:
+				root = "int"
+			case 64:
+				root = "long"
+			default:
+				root, signedness = strconv.FormatUint(uint64(w[2]), 10), "i"
+			}
+			if w[3] == 0 {
+				signedness = "u"
+			}
+			n.save(w[1], signedness+root)
+		case opTypeFloat:
+			switch w[2] {
+			case 16:
+				n.save(w[1], "half")
+			case 32:
+				n.save(w[1], "float")
+			case 64:
+				n.save(w[1], "double")
+			default:
+				n.save(w[1], "fp"+strconv.FormatUint(uint64(w[2]), 10))
+			}
+		case opTypeVector:
+			n.save(w[1], "v"+strconv.FormatUint(uint64(w[3]), 10)+n.name(w[2]))
+		case opTypeMatrix:
+			n.save(w[1], "mat"+strconv.FormatUint(uint64(w[3]), 10)+n.name(w[2]))
+		case opTypeArray:
+			n.save(w[1], "_arr_"+n.name(w[2])+"_"+n.name(w[3]))
+		case opTypeRuntimeArray:
+			n.save(w[1], "_runtimearr_"+n.name(w[2]))
+		case opTypePointer:
+			n.save(w[1], "_ptr_"+spirvEnums["StorageClass"].name(w[2])+"_"+n.name(w[3]))
+		case opTypePipe:
+			n.save(w[1], "Pipe"+spirvEnums["AccessQualifier"].name(w[2]))
+		case opTypeEvent:
+			n.save(w[1], "Event")
+		case opTypeDeviceEvent:
+			n.save(w[1], "DeviceEvent")
+		case opTypeReserveID:
+			n.save(w[1], "ReserveId")
+		case opTypeQueue:
+			n.save(w[1], "Queue")
+		case opTypeOpaque:
+			name, _ := spirvString(w[2:])
+			n.save(w[1], "Opaque_"+name)
+		case opTypePipeStorage:
+			n.save(w[1], "PipeStorage")
+		case opTypeNamedBarrier:
+			n.save(w[1], "NamedBarrier")
+		case opTypeStruct:
+			n.save(w[1], "_struct_"+strconv.FormatUint(uint64(w[1]), 10))
+		case opConstantTrue:
+			n.save(w[2], "true")
+		case opConstantFalse:
+			n.save(w[2], "false")
+		case opConstant:
+			value := strings.Replace(spirvNumber(parsed[i][2]), "-", "n", -1)
+			n.save(w[2], n.name(w[1])+"_"+value)
+		}
+	}
+	return n.names
+}
+
+type idNamer struct {
+	names map[uint32]string
+	used  map[string]bool
+}
+
+func (n *idNamer) name(id uint32) string {
+	if name, ok := n.names[id]; ok {
+		return name
+	}
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+// save names an id unless it already has a name
+func (n *idNamer) save(id uint32, suggested string) {
+	if _, ok := n.names[id]; ok {
+		return
+	}
+	base := sanitizeSPIRVName(suggested)
+	name := base
+	for i := 0; n.used[name]; i++ {
+		name = base + "_" + strconv.Itoa(i)
+	}
+	n.used[name] = true
+	n.names[id] = name
+}
+
+// sanitizeSPIRVName replaces the characters an assembler doesn't accept in
+// an id name, names which are numbers are prefixed so they can't be mistaken
+// for an id without a name
+func sanitizeSPIRVName(name string) string {
+	if name == "" {
+		return "_"
+	}
+	b := []byte(name)
+	digits := true
+	for i, c := range b {
+		switch {
+		case c >= '0' && c <= '9':
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+			digits = false
+		default:
+			b[i] = '_'
+			digits = false
+		}
+	}
+	if digits {
+		return "_" + string(b)
+	}
+	return string(b)
+}
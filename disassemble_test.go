@@ -0,0 +1,267 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gshaderc
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func cat(parts ...[]uint32) []uint32 {
+	var w []uint32
+	for _, p := range parts {
+		w = append(w, p...)
+	}
+	return w
+}
+
+// fragmentModule is what glslang produces for a fragment shader writing a
+// constant color
+func fragmentModule() []byte {
+	data := testModule(
+		[]uint32{17, 1},
+		cat([]uint32{11, 1}, testString("GLSL.std.450")),
+		[]uint32{14, 0, 1},
+		cat([]uint32{15, 4, 4}, testString("main"), []uint32{9}),
+		[]uint32{16, 4, 7},
+		[]uint32{3, 2, 450},
+		cat([]uint32{5, 4}, testString("main")),
+		cat([]uint32{5, 9}, testString("color")),
+		[]uint32{71, 9, 30, 0},
+		[]uint32{19, 2},
+		[]uint32{33, 3, 2},
+		[]uint32{22, 6, 32},
+		[]uint32{23, 7, 6, 4},
+		[]uint32{32, 8, 3, 7},
+		[]uint32{59, 8, 9, 3},
+		[]uint32{43, 6, 10, 0x3f800000},
+		[]uint32{44, 7, 11, 10, 10, 10, 10},
+		[]uint32{54, 2, 4, 0, 3},
+		[]uint32{248, 5},
+		[]uint32{62, 9, 11},
+		[]uint32{253},
+		[]uint32{56},
+	)
+	words, _ := SPIRVWords(data)
+	words[2], words[3] = 8<<16|10, 12
+	return SPIRVBytes(words)
+}
+
+func TestDisassemble(t *testing.T) {
+	text, err := Disassemble(fragmentModule(), DisassemblyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `; SPIR-V
+; Version: 1.0
+; Generator: Khronos Glslang Reference Front End; 10
+; Bound: 12
+; Schema: 0
+               OpCapability Shader
+          %1 = OpExtInstImport "GLSL.std.450"
+               OpMemoryModel Logical GLSL450
+               OpEntryPoint Fragment %main "main" %color
+               OpExecutionMode %main OriginUpperLeft
+               OpSource GLSL 450
+               OpName %main "main"
+               OpName %color "color"
+               OpDecorate %color Location 0
+       %void = OpTypeVoid
+          %3 = OpTypeFunction %void
+      %float = OpTypeFloat 32
+    %v4float = OpTypeVector %float 4
+%_ptr_Output_v4float = OpTypePointer Output %v4float
+      %color = OpVariable %_ptr_Output_v4float Output
+    %float_1 = OpConstant %float 1
+         %11 = OpConstantComposite %v4float %float_1 %float_1 %float_1 %float_1
+       %main = OpFunction %void None %3
+          %5 = OpLabel
+               OpStore %color %11
+               OpReturn
+               OpFunctionEnd
+`
+	if text != expected {
+		t.Errorf("unexpected disassembly:\n%s", text)
+	}
+
+	text, err = Disassemble(fragmentModule(), DisassemblyOptions{RawIDs: true, NoHeader: true, NoIndent: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(text, "OpCapability Shader\n%1 = OpExtInstImport") || !strings.Contains(text, "\n%10 = OpConstant %6 1\n") {
+		t.Errorf("unexpected raw disassembly:\n%s", text)
+	}
+
+	if _, err := Disassemble([]byte{1, 2, 3, 4}, DisassemblyOptions{}); !errors.Is(err, InvalidSPIRVError) {
+		t.Errorf("expected invalid SPIR-V, got %v", err)
+	}
+}
+
+func TestDisassembleOperands(t *testing.T) {
+	module := testModule(
+		[]uint32{17, 1},
+		cat([]uint32{11, 1}, testString("GLSL.std.450")),
+		cat([]uint32{5, 30}, testString("x")),
+		cat([]uint32{5, 31}, testString("x")),
+		cat([]uint32{5, 32}, testString("7")),
+		cat([]uint32{5, 33}, testString("a.b")),
+		[]uint32{71, 34, 11, 15},
+		cat([]uint32{71, 30, 41}, testString("say \"hi\""), []uint32{1}),
+		[]uint32{21, 2, 32, 1},
+		[]uint32{21, 3, 64, 0},
+		[]uint32{21, 4, 16, 1},
+		[]uint32{22, 5, 32},
+		[]uint32{22, 6, 16},
+		[]uint32{22, 7, 64},
+		[]uint32{43, 2, 10, 0xffffffff},
+		[]uint32{43, 3, 11, 0, 1},
+		[]uint32{43, 5, 12, 0x3fc00000},
+		[]uint32{43, 5, 13, 0xbfc00000},
+		[]uint32{43, 5, 14, 0x3dcccccd},
+		[]uint32{43, 5, 15, 0x7f800000},
+		[]uint32{43, 5, 16, 1},
+		[]uint32{43, 6, 17, 0x3c00},
+		[]uint32{43, 7, 18, 0x9999999a, 0x3fb99999},
+		[]uint32{43, 5, 19, 0x80000000},
+		[]uint32{43, 4, 20, 0xffffff85},
+		[]uint32{20, 21},
+		[]uint32{41, 21, 22},
+		[]uint32{61, 5, 23, 30, 2, 4},
+		[]uint32{12, 5, 24, 1, 31, 23},
+		[]uint32{61, 2, 25, 30},
+		[]uint32{251, 25, 26, 0xfffffffe, 27, 3, 28},
+		[]uint32{246, 26, 27, 0x9, 8},
+		[]uint32{52, 2, 29, 128, 10, 10},
+		[]uint32{9999, 1, 2},
+	)
+	text, err := Disassemble(module, DisassemblyOptions{NoHeader: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"OpName %x \"x\"",
+		"OpName %x_0 \"x\"",
+		"OpName %_7 \"7\"",
+		"OpName %a_b \"a.b\"",
+		"OpDecorate %gl_FragCoord BuiltIn FragCoord",
+		"OpDecorate %x LinkageAttributes \"say \\\"hi\\\"\" Import",
+		"%int = OpTypeInt 32 1",
+		"%ulong = OpTypeInt 64 0",
+		"%short = OpTypeInt 16 1",
+		"%half = OpTypeFloat 16",
+		"%double = OpTypeFloat 64",
+		"%int_n1 = OpConstant %int -1",
+		"%ulong_4294967296 = OpConstant %ulong 4294967296",
+		"%float_1_5 = OpConstant %float 1.5",
+		"%float_n1_5 = OpConstant %float -1.5",
+		"%float_0_100000001 = OpConstant %float 0.100000001",
+		"%float_0x1p_128 = OpConstant %float 0x1p+128",
+		"%float_0x1pn149 = OpConstant %float 0x1p-149",
+		"%half_0x1p_0 = OpConstant %half 0x1p+0",
+		"%double_0_10000000000000001 = OpConstant %double 0.10000000000000001",
+		"%float_n0 = OpConstant %float -0",
+		"%short_n123 = OpConstant %short -123",
+		"%true = OpConstantTrue %bool",
+		"%23 = OpLoad %float %x Aligned 4",
+		"%24 = OpExtInst %float %1 Sqrt %23",
+		"OpSwitch %25 %26 -2 %27 3 %28",
+		"OpLoopMerge %26 %27 Unroll|DependencyLength 8",
+		"%29 = OpSpecConstantOp %int IAdd %int_n1 %int_n1",
+		"!0x0003270f !1 !2",
+	} {
+		if !strings.Contains(text, expected+"\n") {
+			t.Errorf("expected %s in:\n%s", expected, text)
+		}
+	}
+}
+
+// normalizeSPIRV renumbers the ids of a module in the order they first
+// appear and clears the header, so modules which differ only in numbering
+// compare equal
+func normalizeSPIRV(t *testing.T, data []byte) []uint32 {
+	t.Helper()
+	words, err := SPIRVWords(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	instructions, err := spirvInstructions(words)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[uint32]uint32)
+	parser := newSPIRVParser()
+	out := []uint32{}
+	for _, inst := range instructions {
+		operands, err := parser.parse(inst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, inst.Words[0])
+		for _, o := range operands {
+			for _, w := range o.Words {
+				if o.isID() {
+					if _, ok := ids[w]; !ok {
+						ids[w] = uint32(len(ids) + 1)
+					}
+					w = ids[w]
+				}
+				out = append(out, w)
+			}
+		}
+	}
+	return out
+}
+
+func TestDisassembleRoundTrip(t *testing.T) {
+	compiler := NewCompiler()
+	defer compiler.Release()
+
+	options := NewCompilerOptions()
+	defer options.Release()
+	source := "#version 450\nlayout(location = 0) in vec2 uv;\nlayout(location = 0) out vec4 color;\n" +
+		"layout(binding = 0) uniform sampler2D tex;\nlayout(binding = 1) uniform Params { float scale; int count; };\n" +
+		"void main() {\n  vec4 c = vec4(0.0);\n  for (int i = 0; i < count; i++) {\n" +
+		"    c += texture(tex, uv * float(i)) * sqrt(scale);\n  }\n  color = c.x > 0.5 ? c : vec4(-1.25e-3);\n}\n"
+	res := compiler.CompileIntoSPV(source, FragmentShader, "round.frag", "main", options)
+	defer res.Release()
+	if errors.Is(res.Error(), BackendUnavailableError) {
+		t.Skip("requires libshaderc or glslc")
+	}
+	if res.Error() != nil {
+		t.Fatal(res.ErrorMessage())
+	}
+
+	for _, o := range []DisassemblyOptions{{}, {RawIDs: true}, {NoHeader: true, NoIndent: true}} {
+		text, err := Disassemble(res.Bytes(), o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assembled := compiler.AssembleIntoSPV(text, options)
+		if assembled.Error() != nil {
+			t.Fatalf("%+v: %s\n%s", o, assembled.ErrorMessage(), text)
+		}
+		original, roundTrip := normalizeSPIRV(t, res.Bytes()), normalizeSPIRV(t, assembled.Bytes())
+		if len(original) != len(roundTrip) {
+			t.Fatalf("%+v: round trip changed the module:\n%s", o, text)
+		}
+		for i := range original {
+			if original[i] != roundTrip[i] {
+				t.Fatalf("%+v: round trip changed word %d:\n%s", o, i, text)
+			}
+		}
+		assembled.Release()
+	}
+}
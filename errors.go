@@ -14,9 +14,6 @@
 
 package gshaderc
 
-// #cgo LDFLAGS: -lshaderc_combined -lstdc++ -lm
-// #include <shaderc/shaderc.h>
-import "C"
 import (
 	"fmt"
 )
@@ -29,3 +26,20 @@ var InvalidAssemblyError = fmt.Errorf("invalid assembly error")
 var ValidationError = fmt.Errorf("validation error")
 var TransformationError = fmt.Errorf("transformation error")
 var ConfigurationError = fmt.Errorf("configuration error")
+
+// BackendUnavailableError is returned when the selected backend has no way to
+// reach a compiler, e.g. glslc isn't on the PATH
+var BackendUnavailableError = fmt.Errorf("compiler backend unavailable")
+
+// ErrShadercUnavailable is returned when libshaderc is loaded at runtime
+// (the shaderc_dynamic build tag) and the shared library couldn't be loaded,
+// or when libshaderc is requested from a build which doesn't include it
+var ErrShadercUnavailable = fmt.Errorf("libshaderc unavailable: %w", BackendUnavailableError)
+
+// InvalidSPIRVError is returned when a module handed to the pure Go SPIR-V
+// tools isn't well formed
+var InvalidSPIRVError = fmt.Errorf("invalid SPIR-V")
+
+// LinkError is returned by Link when the modules can't be linked, such as
+// when a symbol is imported but never exported or exported twice
+var LinkError = fmt.Errorf("link error")
@@ -3,6 +3,7 @@ module github.com/celer/gshaderc
 go 1.13
 
 require (
+	github.com/BurntSushi/toml v0.3.1
 	github.com/fsnotify/fsnotify v1.4.7
 	github.com/mattn/go-pointer v0.0.0-20190911064623-a0a44394634f
 	golang.org/x/sys v0.0.0-20200107162124-548cf772de50 // indirect
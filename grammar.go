@@ -0,0 +1,844 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gshaderc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// spirvOperand is an operand in the grammar of an instruction, Kind is the
+// operand kind of the SPIR-V grammar, e.g. IdRef, LiteralString or
+// StorageClass, Quantifier is 0, '?' for an optional operand or '*' for
+// any number of them
+type spirvOperand struct {
+	Kind       string
+	Quantifier byte
+}
+
+// spirvOpcode is the name and operands of an opcode
+type spirvOpcode struct {
+	Name     string
+	Operands []spirvOperand
+}
+
+// spirvEnumValue is a value of an enumerated operand kind, Params are the
+// kinds of the operands which follow an operand with this value
+type spirvEnumValue struct {
+	Name   string
+	Params []string
+}
+
+// spirvEnum is an enumerated operand kind, the values of a bit mask kind
+// are single bits which may be combined
+type spirvEnum struct {
+	BitMask bool
+	Values  map[uint32]spirvEnumValue
+	names   map[string]uint32
+}
+
+const (
+	unaryOperands  = "IdResultType IdResult IdRef"
+	binaryOperands = unaryOperands + " IdRef"
+)
+
+// spirvGrammar lists the core instructions and the extension instructions
+// compilers commonly produce, in the notation of spirvOperands
+var spirvGrammar = []struct {
+	Opcode   uint32
+	Name     string
+	Operands string
+}{
+	{0, "OpNop", ""},
+	{1, "OpUndef", "IdResultType IdResult"},
+	{2, "OpSourceContinued", "LiteralString"},
+	{3, "OpSource", "SourceLanguage LiteralInteger IdRef? LiteralString?"},
+	{4, "OpSourceExtension", "LiteralString"},
+	{5, "OpName", "IdRef LiteralString"},
+	{6, "OpMemberName", "IdRef LiteralInteger LiteralString"},
+	{7, "OpString", "IdResult LiteralString"},
+	{8, "OpLine", "IdRef LiteralInteger LiteralInteger"},
+	{10, "OpExtension", "LiteralString"},
+	{11, "OpExtInstImport", "IdResult LiteralString"},
+	{12, "OpExtInst", "IdResultType IdResult IdRef LiteralExtInstInteger IdRef*"},
+	{14, "OpMemoryModel", "AddressingModel MemoryModel"},
+	{15, "OpEntryPoint", "ExecutionModel IdRef LiteralString IdRef*"},
+	{16, "OpExecutionMode", "IdRef ExecutionMode"},
+	{17, "OpCapability", "Capability"},
+	{19, "OpTypeVoid", "IdResult"},
+	{20, "OpTypeBool", "IdResult"},
+	{21, "OpTypeInt", "IdResult LiteralInteger LiteralInteger"},
+	{22, "OpTypeFloat", "IdResult LiteralInteger"},
+	{23, "OpTypeVector", "IdResult IdRef LiteralInteger"},
+	{24, "OpTypeMatrix", "IdResult IdRef LiteralInteger"},
+	{25, "OpTypeImage", "IdResult IdRef Dim LiteralInteger LiteralInteger LiteralInteger LiteralInteger ImageFormat AccessQualifier?"},
+	{26, "OpTypeSampler", "IdResult"},
+	{27, "OpTypeSampledImage", "IdResult IdRef"},
+	{28, "OpTypeArray", "IdResult IdRef IdRef"},
+	{29, "OpTypeRuntimeArray", "IdResult IdRef"},
+	{30, "OpTypeStruct", "IdResult IdRef*"},
+	{31, "OpTypeOpaque", "IdResult LiteralString"},
+	{32, "OpTypePointer", "IdResult StorageClass IdRef"},
+	{33, "OpTypeFunction", "IdResult IdRef IdRef*"},
+	{34, "OpTypeEvent", "IdResult"},
+	{35, "OpTypeDeviceEvent", "IdResult"},
+	{36, "OpTypeReserveId", "IdResult"},
+	{37, "OpTypeQueue", "IdResult"},
+	{38, "OpTypePipe", "IdResult AccessQualifier"},
+	{39, "OpTypeForwardPointer", "IdRef StorageClass"},
+	{41, "OpConstantTrue", "IdResultType IdResult"},
+	{42, "OpConstantFalse", "IdResultType IdResult"},
+	{43, "OpConstant", "IdResultType IdResult LiteralContextDependentNumber"},
+	{44, "OpConstantComposite", "IdResultType IdResult IdRef*"},
+	{45, "OpConstantSampler", "IdResultType IdResult SamplerAddressingMode LiteralInteger SamplerFilterMode"},
+	{46, "OpConstantNull", "IdResultType IdResult"},
+	{48, "OpSpecConstantTrue", "IdResultType IdResult"},
+	{49, "OpSpecConstantFalse", "IdResultType IdResult"},
+	{50, "OpSpecConstant", "IdResultType IdResult LiteralContextDependentNumber"},
+	{51, "OpSpecConstantComposite", "IdResultType IdResult IdRef*"},
+	{52, "OpSpecConstantOp", "IdResultType IdResult LiteralSpecConstantOpInteger"},
+	{54, "OpFunction", "IdResultType IdResult FunctionControl IdRef"},
+	{55, "OpFunctionParameter", "IdResultType IdResult"},
+	{56, "OpFunctionEnd", ""},
+	{57, "OpFunctionCall", "IdResultType IdResult IdRef IdRef*"},
+	{59, "OpVariable", "IdResultType IdResult StorageClass IdRef?"},
+	{60, "OpImageTexelPointer", "IdResultType IdResult IdRef IdRef IdRef"},
+	{61, "OpLoad", "IdResultType IdResult IdRef MemoryAccess?"},
+	{62, "OpStore", "IdRef IdRef MemoryAccess?"},
+	{63, "OpCopyMemory", "IdRef IdRef MemoryAccess? MemoryAccess?"},
+	{64, "OpCopyMemorySized", "IdRef IdRef IdRef MemoryAccess? MemoryAccess?"},
+	{65, "OpAccessChain", "IdResultType IdResult IdRef IdRef*"},
+	{66, "OpInBoundsAccessChain", "IdResultType IdResult IdRef IdRef*"},
+	{67, "OpPtrAccessChain", "IdResultType IdResult IdRef IdRef IdRef*"},
+	{68, "OpArrayLength", "IdResultType IdResult IdRef LiteralInteger"},
+	{69, "OpGenericPtrMemSemantics", unaryOperands},
+	{70, "OpInBoundsPtrAccessChain", "IdResultType IdResult IdRef IdRef IdRef*"},
+	{71, "OpDecorate", "IdRef Decoration"},
+	{72, "OpMemberDecorate", "IdRef LiteralInteger Decoration"},
+	{73, "OpDecorationGroup", "IdResult"},
+	{74, "OpGroupDecorate", "IdRef IdRef*"},
+	{75, "OpGroupMemberDecorate", "IdRef PairIdRefLiteralInteger*"},
+	{77, "OpVectorExtractDynamic", binaryOperands},
+	{78, "OpVectorInsertDynamic", binaryOperands + " IdRef"},
+	{79, "OpVectorShuffle", binaryOperands + " LiteralInteger*"},
+	{80, "OpCompositeConstruct", "IdResultType IdResult IdRef*"},
+	{81, "OpCompositeExtract", unaryOperands + " LiteralInteger*"},
+	{82, "OpCompositeInsert", binaryOperands + " LiteralInteger*"},
+	{83, "OpCopyObject", unaryOperands},
+	{84, "OpTranspose", unaryOperands},
+	{86, "OpSampledImage", binaryOperands},
+	{87, "OpImageSampleImplicitLod", binaryOperands + " ImageOperands?"},
+	{88, "OpImageSampleExplicitLod", binaryOperands + " ImageOperands"},
+	{89, "OpImageSampleDrefImplicitLod", binaryOperands + " IdRef ImageOperands?"},
+	{90, "OpImageSampleDrefExplicitLod", binaryOperands + " IdRef ImageOperands"},
+	{91, "OpImageSampleProjImplicitLod", binaryOperands + " ImageOperands?"},
+	{92, "OpImageSampleProjExplicitLod", binaryOperands + " ImageOperands"},
+	{93, "OpImageSampleProjDrefImplicitLod", binaryOperands + " IdRef ImageOperands?"},
+	{94, "OpImageSampleProjDrefExplicitLod", binaryOperands + " IdRef ImageOperands"},
+	{95, "OpImageFetch", binaryOperands + " ImageOperands?"},
+	{96, "OpImageGather", binaryOperands + " IdRef ImageOperands?"},
+	{97, "OpImageDrefGather", binaryOperands + " IdRef ImageOperands?"},
+	{98, "OpImageRead", binaryOperands + " ImageOperands?"},
+	{99, "OpImageWrite", "IdRef IdRef IdRef ImageOperands?"},
+	{100, "OpImage", unaryOperands},
+	{101, "OpImageQueryFormat", unaryOperands},
+	{102, "OpImageQueryOrder", unaryOperands},
+	{103, "OpImageQuerySizeLod", binaryOperands},
+	{104, "OpImageQuerySize", unaryOperands},
+	{105, "OpImageQueryLod", binaryOperands},
+	{106, "OpImageQueryLevels", unaryOperands},
+	{107, "OpImageQuerySamples", unaryOperands},
+	{109, "OpConvertFToU", unaryOperands},
+	{110, "OpConvertFToS", unaryOperands},
+	{111, "OpConvertSToF", unaryOperands},
+	{112, "OpConvertUToF", unaryOperands},
+	{113, "OpUConvert", unaryOperands},
+	{114, "OpSConvert", unaryOperands},
+	{115, "OpFConvert", unaryOperands},
+	{116, "OpQuantizeToF16", unaryOperands},
+	{117, "OpConvertPtrToU", unaryOperands},
+	{118, "OpSatConvertSToU", unaryOperands},
+	{119, "OpSatConvertUToS", unaryOperands},
+	{120, "OpConvertUToPtr", unaryOperands},
+	{121, "OpPtrCastToGeneric", unaryOperands},
+	{122, "OpGenericCastToPtr", unaryOperands},
+	{123, "OpGenericCastToPtrExplicit", unaryOperands + " StorageClass"},
+	{124, "OpBitcast", unaryOperands},
+	{126, "OpSNegate", unaryOperands},
+	{127, "OpFNegate", unaryOperands},
+	{128, "OpIAdd", binaryOperands},
+	{129, "OpFAdd", binaryOperands},
+	{130, "OpISub", binaryOperands},
+	{131, "OpFSub", binaryOperands},
+	{132, "OpIMul", binaryOperands},
+	{133, "OpFMul", binaryOperands},
+	{134, "OpUDiv", binaryOperands},
+	{135, "OpSDiv", binaryOperands},
+	{136, "OpFDiv", binaryOperands},
+	{137, "OpUMod", binaryOperands},
+	{138, "OpSRem", binaryOperands},
+	{139, "OpSMod", binaryOperands},
+	{140, "OpFRem", binaryOperands},
+	{141, "OpFMod", binaryOperands},
+	{142, "OpVectorTimesScalar", binaryOperands},
+	{143, "OpMatrixTimesScalar", binaryOperands},
+	{144, "OpVectorTimesMatrix", binaryOperands},
+	{145, "OpMatrixTimesVector", binaryOperands},
+	{146, "OpMatrixTimesMatrix", binaryOperands},
+	{147, "OpOuterProduct", binaryOperands},
+	{148, "OpDot", binaryOperands},
+	{149, "OpIAddCarry", binaryOperands},
+	{150, "OpISubBorrow", binaryOperands},
+	{151, "OpUMulExtended", binaryOperands},
+	{152, "OpSMulExtended", binaryOperands},
+	{154, "OpAny", unaryOperands},
+	{155, "OpAll", unaryOperands},
+	{156, "OpIsNan", unaryOperands},
+	{157, "OpIsInf", unaryOperands},
+	{158, "OpIsFinite", unaryOperands},
+	{159, "OpIsNormal", unaryOperands},
+	{160, "OpSignBitSet", unaryOperands},
+	{161, "OpLessOrGreater", binaryOperands},
+	{162, "OpOrdered", binaryOperands},
+	{163, "OpUnordered", binaryOperands},
+	{164, "OpLogicalEqual", binaryOperands},
+	{165, "OpLogicalNotEqual", binaryOperands},
+	{166, "OpLogicalOr", binaryOperands},
+	{167, "OpLogicalAnd", binaryOperands},
+	{168, "OpLogicalNot", unaryOperands},
+	{169, "OpSelect", binaryOperands + " IdRef"},
+	{170, "OpIEqual", binaryOperands},
+	{171, "OpINotEqual", binaryOperands},
+	{172, "OpUGreaterThan", binaryOperands},
+	{173, "OpSGreaterThan", binaryOperands},
+	{174, "OpUGreaterThanEqual", binaryOperands},
+	{175, "OpSGreaterThanEqual", binaryOperands},
+	{176, "OpULessThan", binaryOperands},
+	{177, "OpSLessThan", binaryOperands},
+	{178, "OpULessThanEqual", binaryOperands},
+	{179, "OpSLessThanEqual", binaryOperands},
+	{180, "OpFOrdEqual", binaryOperands},
+	{181, "OpFUnordEqual", binaryOperands},
+	{182, "OpFOrdNotEqual", binaryOperands},
+	{183, "OpFUnordNotEqual", binaryOperands},
+	{184, "OpFOrdLessThan", binaryOperands},
+	{185, "OpFUnordLessThan", binaryOperands},
+	{186, "OpFOrdGreaterThan", binaryOperands},
+	{187, "OpFUnordGreaterThan", binaryOperands},
+	{188, "OpFOrdLessThanEqual", binaryOperands},
+	{189, "OpFUnordLessThanEqual", binaryOperands},
+	{190, "OpFOrdGreaterThanEqual", binaryOperands},
+	{191, "OpFUnordGreaterThanEqual", binaryOperands},
+	{194, "OpShiftRightLogical", binaryOperands},
+	{195, "OpShiftRightArithmetic", binaryOperands},
+	{196, "OpShiftLeftLogical", binaryOperands},
+	{197, "OpBitwiseOr", binaryOperands},
+	{198, "OpBitwiseXor", binaryOperands},
+	{199, "OpBitwiseAnd", binaryOperands},
+	{200, "OpNot", unaryOperands},
+	{201, "OpBitFieldInsert", binaryOperands + " IdRef IdRef"},
+	{202, "OpBitFieldSExtract", binaryOperands + " IdRef"},
+	{203, "OpBitFieldUExtract", binaryOperands + " IdRef"},
+	{204, "OpBitReverse", unaryOperands},
+	{205, "OpBitCount", unaryOperands},
+	{207, "OpDPdx", unaryOperands},
+	{208, "OpDPdy", unaryOperands},
+	{209, "OpFwidth", unaryOperands},
+	{210, "OpDPdxFine", unaryOperands},
+	{211, "OpDPdyFine", unaryOperands},
+	{212, "OpFwidthFine", unaryOperands},
+	{213, "OpDPdxCoarse", unaryOperands},
+	{214, "OpDPdyCoarse", unaryOperands},
+	{215, "OpFwidthCoarse", unaryOperands},
+	{218, "OpEmitVertex", ""},
+	{219, "OpEndPrimitive", ""},
+	{220, "OpEmitStreamVertex", "IdRef"},
+	{221, "OpEndStreamPrimitive", "IdRef"},
+	{224, "OpControlBarrier", "IdScope IdScope IdMemorySemantics"},
+	{225, "OpMemoryBarrier", "IdScope IdMemorySemantics"},
+	{227, "OpAtomicLoad", "IdResultType IdResult IdRef IdScope IdMemorySemantics"},
+	{228, "OpAtomicStore", "IdRef IdScope IdMemorySemantics IdRef"},
+	{229, "OpAtomicExchange", "IdResultType IdResult IdRef IdScope IdMemorySemantics IdRef"},
+	{230, "OpAtomicCompareExchange", "IdResultType IdResult IdRef IdScope IdMemorySemantics IdMemorySemantics IdRef IdRef"},
+	{231, "OpAtomicCompareExchangeWeak", "IdResultType IdResult IdRef IdScope IdMemorySemantics IdMemorySemantics IdRef IdRef"},
+	{232, "OpAtomicIIncrement", "IdResultType IdResult IdRef IdScope IdMemorySemantics"},
+	{233, "OpAtomicIDecrement", "IdResultType IdResult IdRef IdScope IdMemorySemantics"},
+	{234, "OpAtomicIAdd", "IdResultType IdResult IdRef IdScope IdMemorySemantics IdRef"},
+	{235, "OpAtomicISub", "IdResultType IdResult IdRef IdScope IdMemorySemantics IdRef"},
+	{236, "OpAtomicSMin", "IdResultType IdResult IdRef IdScope IdMemorySemantics IdRef"},
+	{237, "OpAtomicUMin", "IdResultType IdResult IdRef IdScope IdMemorySemantics IdRef"},
+	{238, "OpAtomicSMax", "IdResultType IdResult IdRef IdScope IdMemorySemantics IdRef"},
+	{239, "OpAtomicUMax", "IdResultType IdResult IdRef IdScope IdMemorySemantics IdRef"},
+	{240, "OpAtomicAnd", "IdResultType IdResult IdRef IdScope IdMemorySemantics IdRef"},
+	{241, "OpAtomicOr", "IdResultType IdResult IdRef IdScope IdMemorySemantics IdRef"},
+	{242, "OpAtomicXor", "IdResultType IdResult IdRef IdScope IdMemorySemantics IdRef"},
+	{245, "OpPhi", "IdResultType IdResult PairIdRefIdRef*"},
+	{246, "OpLoopMerge", "IdRef IdRef LoopControl"},
+	{247, "OpSelectionMerge", "IdRef SelectionControl"},
+	{248, "OpLabel", "IdResult"},
+	{249, "OpBranch", "IdRef"},
+	{250, "OpBranchConditional", "IdRef IdRef IdRef LiteralInteger*"},
+	{251, "OpSwitch", "IdRef IdRef PairLiteralIntegerIdRef*"},
+	{252, "OpKill", ""},
+	{253, "OpReturn", ""},
+	{254, "OpReturnValue", "IdRef"},
+	{255, "OpUnreachable", ""},
+	{256, "OpLifetimeStart", "IdRef LiteralInteger"},
+	{257, "OpLifetimeStop", "IdRef LiteralInteger"},
+	{259, "OpGroupAsyncCopy", "IdResultType IdResult IdScope IdRef IdRef IdRef IdRef IdRef"},
+	{260, "OpGroupWaitEvents", "IdScope IdRef IdRef"},
+	{261, "OpGroupAll", "IdResultType IdResult IdScope IdRef"},
+	{262, "OpGroupAny", "IdResultType IdResult IdScope IdRef"},
+	{263, "OpGroupBroadcast", "IdResultType IdResult IdScope IdRef IdRef"},
+	{264, "OpGroupIAdd", "IdResultType IdResult IdScope GroupOperation IdRef"},
+	{265, "OpGroupFAdd", "IdResultType IdResult IdScope GroupOperation IdRef"},
+	{266, "OpGroupFMin", "IdResultType IdResult IdScope GroupOperation IdRef"},
+	{267, "OpGroupUMin", "IdResultType IdResult IdScope GroupOperation IdRef"},
+	{268, "OpGroupSMin", "IdResultType IdResult IdScope GroupOperation IdRef"},
+	{269, "OpGroupFMax", "IdResultType IdResult IdScope GroupOperation IdRef"},
+	{270, "OpGroupUMax", "IdResultType IdResult IdScope GroupOperation IdRef"},
+	{271, "OpGroupSMax", "IdResultType IdResult IdScope GroupOperation IdRef"},
+	{274, "OpReadPipe", binaryOperands + " IdRef IdRef"},
+	{275, "OpWritePipe", binaryOperands + " IdRef IdRef"},
+	{276, "OpReservedReadPipe", binaryOperands + " IdRef IdRef IdRef IdRef"},
+	{277, "OpReservedWritePipe", binaryOperands + " IdRef IdRef IdRef IdRef"},
+	{278, "OpReserveReadPipePackets", binaryOperands + " IdRef IdRef"},
+	{279, "OpReserveWritePipePackets", binaryOperands + " IdRef IdRef"},
+	{280, "OpCommitReadPipe", "IdRef IdRef IdRef IdRef"},
+	{281, "OpCommitWritePipe", "IdRef IdRef IdRef IdRef"},
+	{282, "OpIsValidReserveId", unaryOperands},
+	{283, "OpGetNumPipePackets", binaryOperands + " IdRef"},
+	{284, "OpGetMaxPipePackets", binaryOperands + " IdRef"},
+	{285, "OpGroupReserveReadPipePackets", "IdResultType IdResult IdScope IdRef IdRef IdRef IdRef"},
+	{286, "OpGroupReserveWritePipePackets", "IdResultType IdResult IdScope IdRef IdRef IdRef IdRef"},
+	{287, "OpGroupCommitReadPipe", "IdScope IdRef IdRef IdRef IdRef"},
+	{288, "OpGroupCommitWritePipe", "IdScope IdRef IdRef IdRef IdRef"},
+	{291, "OpEnqueueMarker", binaryOperands + " IdRef IdRef"},
+	{292, "OpEnqueueKernel", binaryOperands + " IdRef IdRef IdRef IdRef IdRef IdRef IdRef IdRef IdRef IdRef*"},
+	{293, "OpGetKernelNDrangeSubGroupCount", binaryOperands + " IdRef IdRef IdRef"},
+	{294, "OpGetKernelNDrangeMaxSubGroupSize", binaryOperands + " IdRef IdRef IdRef"},
+	{295, "OpGetKernelWorkGroupSize", binaryOperands + " IdRef IdRef"},
+	{296, "OpGetKernelPreferredWorkGroupSizeMultiple", binaryOperands + " IdRef IdRef"},
+	{297, "OpRetainEvent", "IdRef"},
+	{298, "OpReleaseEvent", "IdRef"},
+	{299, "OpCreateUserEvent", "IdResultType IdResult"},
+	{300, "OpIsValidEvent", unaryOperands},
+	{301, "OpSetUserEventStatus", "IdRef IdRef"},
+	{302, "OpCaptureEventProfilingInfo", "IdRef IdRef IdRef"},
+	{303, "OpGetDefaultQueue", "IdResultType IdResult"},
+	{304, "OpBuildNDRange", binaryOperands + " IdRef"},
+	{305, "OpImageSparseSampleImplicitLod", binaryOperands + " ImageOperands?"},
+	{306, "OpImageSparseSampleExplicitLod", binaryOperands + " ImageOperands"},
+	{307, "OpImageSparseSampleDrefImplicitLod", binaryOperands + " IdRef ImageOperands?"},
+	{308, "OpImageSparseSampleDrefExplicitLod", binaryOperands + " IdRef ImageOperands"},
+	{309, "OpImageSparseSampleProjImplicitLod", binaryOperands + " ImageOperands?"},
+	{310, "OpImageSparseSampleProjExplicitLod", binaryOperands + " ImageOperands"},
+	{311, "OpImageSparseSampleProjDrefImplicitLod", binaryOperands + " IdRef ImageOperands?"},
+	{312, "OpImageSparseSampleProjDrefExplicitLod", binaryOperands + " IdRef ImageOperands"},
+	{313, "OpImageSparseFetch", binaryOperands + " ImageOperands?"},
+	{314, "OpImageSparseGather", binaryOperands + " IdRef ImageOperands?"},
+	{315, "OpImageSparseDrefGather", binaryOperands + " IdRef ImageOperands?"},
+	{316, "OpImageSparseTexelsResident", unaryOperands},
+	{317, "OpNoLine", ""},
+	{318, "OpAtomicFlagTestAndSet", "IdResultType IdResult IdRef IdScope IdMemorySemantics"},
+	{319, "OpAtomicFlagClear", "IdRef IdScope IdMemorySemantics"},
+	{320, "OpImageSparseRead", binaryOperands + " ImageOperands?"},
+	{321, "OpSizeOf", unaryOperands},
+	{322, "OpTypePipeStorage", "IdResult"},
+	{323, "OpConstantPipeStorage", "IdResultType IdResult LiteralInteger LiteralInteger LiteralInteger"},
+	{324, "OpCreatePipeFromPipeStorage", unaryOperands},
+	{325, "OpGetKernelLocalSizeForSubgroupCount", binaryOperands + " IdRef IdRef IdRef"},
+	{326, "OpGetKernelMaxNumSubgroups", binaryOperands + " IdRef IdRef"},
+	{327, "OpTypeNamedBarrier", "IdResult"},
+	{328, "OpNamedBarrierInitialize", unaryOperands},
+	{329, "OpMemoryNamedBarrier", "IdRef IdScope IdMemorySemantics"},
+	{330, "OpModuleProcessed", "LiteralString"},
+	{331, "OpExecutionModeId", "IdRef ExecutionMode"},
+	{332, "OpDecorateId", "IdRef Decoration"},
+	{333, "OpGroupNonUniformElect", "IdResultType IdResult IdScope"},
+	{334, "OpGroupNonUniformAll", "IdResultType IdResult IdScope IdRef"},
+	{335, "OpGroupNonUniformAny", "IdResultType IdResult IdScope IdRef"},
+	{336, "OpGroupNonUniformAllEqual", "IdResultType IdResult IdScope IdRef"},
+	{337, "OpGroupNonUniformBroadcast", "IdResultType IdResult IdScope IdRef IdRef"},
+	{338, "OpGroupNonUniformBroadcastFirst", "IdResultType IdResult IdScope IdRef"},
+	{339, "OpGroupNonUniformBallot", "IdResultType IdResult IdScope IdRef"},
+	{340, "OpGroupNonUniformInverseBallot", "IdResultType IdResult IdScope IdRef"},
+	{341, "OpGroupNonUniformBallotBitExtract", "IdResultType IdResult IdScope IdRef IdRef"},
+	{342, "OpGroupNonUniformBallotBitCount", "IdResultType IdResult IdScope GroupOperation IdRef"},
+	{343, "OpGroupNonUniformBallotFindLSB", "IdResultType IdResult IdScope IdRef"},
+	{344, "OpGroupNonUniformBallotFindMSB", "IdResultType IdResult IdScope IdRef"},
+	{345, "OpGroupNonUniformShuffle", "IdResultType IdResult IdScope IdRef IdRef"},
+	{346, "OpGroupNonUniformShuffleXor", "IdResultType IdResult IdScope IdRef IdRef"},
+	{347, "OpGroupNonUniformShuffleUp", "IdResultType IdResult IdScope IdRef IdRef"},
+	{348, "OpGroupNonUniformShuffleDown", "IdResultType IdResult IdScope IdRef IdRef"},
+	{349, "OpGroupNonUniformIAdd", "IdResultType IdResult IdScope GroupOperation IdRef IdRef?"},
+	{350, "OpGroupNonUniformFAdd", "IdResultType IdResult IdScope GroupOperation IdRef IdRef?"},
+	{351, "OpGroupNonUniformIMul", "IdResultType IdResult IdScope GroupOperation IdRef IdRef?"},
+	{352, "OpGroupNonUniformFMul", "IdResultType IdResult IdScope GroupOperation IdRef IdRef?"},
+	{353, "OpGroupNonUniformSMin", "IdResultType IdResult IdScope GroupOperation IdRef IdRef?"},
+	{354, "OpGroupNonUniformUMin", "IdResultType IdResult IdScope GroupOperation IdRef IdRef?"},
+	{355, "OpGroupNonUniformFMin", "IdResultType IdResult IdScope GroupOperation IdRef IdRef?"},
+	{356, "OpGroupNonUniformSMax", "IdResultType IdResult IdScope GroupOperation IdRef IdRef?"},
+	{357, "OpGroupNonUniformUMax", "IdResultType IdResult IdScope GroupOperation IdRef IdRef?"},
+	{358, "OpGroupNonUniformFMax", "IdResultType IdResult IdScope GroupOperation IdRef IdRef?"},
+	{359, "OpGroupNonUniformBitwiseAnd", "IdResultType IdResult IdScope GroupOperation IdRef IdRef?"},
+	{360, "OpGroupNonUniformBitwiseOr", "IdResultType IdResult IdScope GroupOperation IdRef IdRef?"},
+	{361, "OpGroupNonUniformBitwiseXor", "IdResultType IdResult IdScope GroupOperation IdRef IdRef?"},
+	{362, "OpGroupNonUniformLogicalAnd", "IdResultType IdResult IdScope GroupOperation IdRef IdRef?"},
+	{363, "OpGroupNonUniformLogicalOr", "IdResultType IdResult IdScope GroupOperation IdRef IdRef?"},
+	{364, "OpGroupNonUniformLogicalXor", "IdResultType IdResult IdScope GroupOperation IdRef IdRef?"},
+	{365, "OpGroupNonUniformQuadBroadcast", "IdResultType IdResult IdScope IdRef IdRef"},
+	{366, "OpGroupNonUniformQuadSwap", "IdResultType IdResult IdScope IdRef IdRef"},
+	{400, "OpCopyLogical", unaryOperands},
+	{401, "OpPtrEqual", binaryOperands},
+	{402, "OpPtrNotEqual", binaryOperands},
+	{403, "OpPtrDiff", binaryOperands},
+	{4416, "OpTerminateInvocation", ""},
+	{4421, "OpSubgroupBallotKHR", unaryOperands},
+	{4422, "OpSubgroupFirstInvocationKHR", unaryOperands},
+	{4428, "OpSubgroupAllKHR", unaryOperands},
+	{4429, "OpSubgroupAnyKHR", unaryOperands},
+	{4430, "OpSubgroupAllEqualKHR", unaryOperands},
+	{4431, "OpGroupNonUniformRotateKHR", "IdResultType IdResult IdScope IdRef IdRef IdRef?"},
+	{4432, "OpSubgroupReadInvocationKHR", binaryOperands},
+	{4445, "OpTraceRayKHR", "IdRef IdRef IdRef IdRef IdRef IdRef IdRef IdRef IdRef IdRef IdRef"},
+	{4446, "OpExecuteCallableKHR", "IdRef IdRef"},
+	{4447, "OpConvertUToAccelerationStructureKHR", unaryOperands},
+	{4448, "OpIgnoreIntersectionKHR", ""},
+	{4449, "OpTerminateRayKHR", ""},
+	{4450, "OpSDot", binaryOperands + " PackedVectorFormat?"},
+	{4451, "OpUDot", binaryOperands + " PackedVectorFormat?"},
+	{4452, "OpSUDot", binaryOperands + " PackedVectorFormat?"},
+	{4453, "OpSDotAccSat", binaryOperands + " IdRef PackedVectorFormat?"},
+	{4454, "OpUDotAccSat", binaryOperands + " IdRef PackedVectorFormat?"},
+	{4455, "OpSUDotAccSat", binaryOperands + " IdRef PackedVectorFormat?"},
+	{4472, "OpTypeRayQueryKHR", "IdResult"},
+	{4473, "OpRayQueryInitializeKHR", "IdRef IdRef IdRef IdRef IdRef IdRef IdRef IdRef"},
+	{4474, "OpRayQueryTerminateKHR", "IdRef"},
+	{4475, "OpRayQueryGenerateIntersectionKHR", "IdRef IdRef"},
+	{4476, "OpRayQueryConfirmIntersectionKHR", "IdRef"},
+	{4477, "OpRayQueryProceedKHR", unaryOperands},
+	{4479, "OpRayQueryGetIntersectionTypeKHR", binaryOperands},
+	{5294, "OpEmitMeshTasksEXT", "IdRef IdRef IdRef IdRef?"},
+	{5295, "OpSetMeshOutputsEXT", "IdRef IdRef"},
+	{5334, "OpReportIntersectionKHR", binaryOperands},
+	{5341, "OpTypeAccelerationStructureKHR", "IdResult"},
+	{5364, "OpBeginInvocationInterlockEXT", ""},
+	{5365, "OpEndInvocationInterlockEXT", ""},
+	{5380, "OpDemoteToHelperInvocation", ""},
+	{5381, "OpIsHelperInvocationEXT", "IdResultType IdResult"},
+	{5614, "OpAtomicFMinEXT", "IdResultType IdResult IdRef IdScope IdMemorySemantics IdRef"},
+	{5615, "OpAtomicFMaxEXT", "IdResultType IdResult IdRef IdScope IdMemorySemantics IdRef"},
+	{5632, "OpDecorateString", "IdRef Decoration"},
+	{5633, "OpMemberDecorateString", "IdRef LiteralInteger Decoration"},
+	{6016, "OpRayQueryGetRayTMinKHR", unaryOperands},
+	{6017, "OpRayQueryGetRayFlagsKHR", unaryOperands},
+	{6018, "OpRayQueryGetIntersectionTKHR", binaryOperands},
+	{6019, "OpRayQueryGetIntersectionInstanceCustomIndexKHR", binaryOperands},
+	{6020, "OpRayQueryGetIntersectionInstanceIdKHR", binaryOperands},
+	{6021, "OpRayQueryGetIntersectionInstanceShaderBindingTableRecordOffsetKHR", binaryOperands},
+	{6022, "OpRayQueryGetIntersectionGeometryIndexKHR", binaryOperands},
+	{6023, "OpRayQueryGetIntersectionPrimitiveIndexKHR", binaryOperands},
+	{6024, "OpRayQueryGetIntersectionBarycentricsKHR", binaryOperands},
+	{6025, "OpRayQueryGetIntersectionFrontFaceKHR", binaryOperands},
+	{6026, "OpRayQueryGetIntersectionCandidateAABBOpaqueKHR", unaryOperands},
+	{6027, "OpRayQueryGetIntersectionObjectRayDirectionKHR", binaryOperands},
+	{6028, "OpRayQueryGetIntersectionObjectRayOriginKHR", binaryOperands},
+	{6029, "OpRayQueryGetWorldRayDirectionKHR", unaryOperands},
+	{6030, "OpRayQueryGetWorldRayOriginKHR", unaryOperands},
+	{6031, "OpRayQueryGetIntersectionObjectToWorldKHR", binaryOperands},
+	{6032, "OpRayQueryGetIntersectionWorldToObjectKHR", binaryOperands},
+	{6035, "OpAtomicFAddEXT", "IdResultType IdResult IdRef IdScope IdMemorySemantics IdRef"},
+}
+
+// spirvEnumGrammar lists the values of the enumerated operand kinds as
+// space separated value=Name pairs, with the kinds of any operands the
+// value takes in brackets
+var spirvEnumGrammar = map[string]string{
+	"SourceLanguage": "0=Unknown 1=ESSL 2=GLSL 3=OpenCL_C 4=OpenCL_CPP 5=HLSL 6=CPP_for_OpenCL 7=SYCL 8=HERO_C 9=NZSL 10=WGSL 11=Slang 12=Zig",
+	"ExecutionModel": "0=Vertex 1=TessellationControl 2=TessellationEvaluation 3=Geometry 4=Fragment 5=GLCompute 6=Kernel " +
+		"5267=TaskNV 5268=MeshNV 5313=RayGenerationKHR 5314=IntersectionKHR 5315=AnyHitKHR 5316=ClosestHitKHR 5317=MissKHR " +
+		"5318=CallableKHR 5364=TaskEXT 5365=MeshEXT",
+	"AddressingModel": "0=Logical 1=Physical32 2=Physical64 5348=PhysicalStorageBuffer64",
+	"MemoryModel":     "0=Simple 1=GLSL450 2=OpenCL 3=Vulkan",
+	"ExecutionMode": "0=Invocations(LiteralInteger) 1=SpacingEqual 2=SpacingFractionalEven 3=SpacingFractionalOdd 4=VertexOrderCw " +
+		"5=VertexOrderCcw 6=PixelCenterInteger 7=OriginUpperLeft 8=OriginLowerLeft 9=EarlyFragmentTests 10=PointMode 11=Xfb " +
+		"12=DepthReplacing 14=DepthGreater 15=DepthLess 16=DepthUnchanged " +
+		"17=LocalSize(LiteralInteger,LiteralInteger,LiteralInteger) 18=LocalSizeHint(LiteralInteger,LiteralInteger,LiteralInteger) " +
+		"19=InputPoints 20=InputLines 21=InputLinesAdjacency 22=Triangles 23=InputTrianglesAdjacency 24=Quads 25=Isolines " +
+		"26=OutputVertices(LiteralInteger) 27=OutputPoints 28=OutputLineStrip 29=OutputTriangleStrip 30=VecTypeHint(LiteralInteger) " +
+		"31=ContractionOff 33=Initializer 34=Finalizer 35=SubgroupSize(LiteralInteger) 36=SubgroupsPerWorkgroup(LiteralInteger) " +
+		"37=SubgroupsPerWorkgroupId(IdRef) 38=LocalSizeId(IdRef,IdRef,IdRef) 39=LocalSizeHintId(IdRef,IdRef,IdRef) " +
+		"4421=SubgroupUniformControlFlowKHR 4446=PostDepthCoverage 4459=DenormPreserve(LiteralInteger) " +
+		"4460=DenormFlushToZero(LiteralInteger) 4461=SignedZeroInfNanPreserve(LiteralInteger) 4462=RoundingModeRTE(LiteralInteger) " +
+		"4463=RoundingModeRTZ(LiteralInteger) 5027=StencilRefReplacingEXT 5269=OutputLinesEXT 5270=OutputPrimitivesEXT(LiteralInteger) " +
+		"5298=OutputTrianglesEXT 5366=PixelInterlockOrderedEXT 5367=PixelInterlockUnorderedEXT 5368=SampleInterlockOrderedEXT " +
+		"5369=SampleInterlockUnorderedEXT 5370=ShadingRateInterlockOrderedEXT 5371=ShadingRateInterlockUnorderedEXT",
+	"StorageClass": "0=UniformConstant 1=Input 2=Uniform 3=Output 4=Workgroup 5=CrossWorkgroup 6=Private 7=Function 8=Generic " +
+		"9=PushConstant 10=AtomicCounter 11=Image 12=StorageBuffer 5328=CallableDataKHR 5329=IncomingCallableDataKHR " +
+		"5338=RayPayloadKHR 5339=HitAttributeKHR 5342=IncomingRayPayloadKHR 5343=ShaderRecordBufferKHR " +
+		"5349=PhysicalStorageBuffer 5402=TaskPayloadWorkgroupEXT",
+	"Dim":                   "0=1D 1=2D 2=3D 3=Cube 4=Rect 5=Buffer 6=SubpassData",
+	"SamplerAddressingMode": "0=None 1=ClampToEdge 2=Clamp 3=Repeat 4=RepeatMirrored",
+	"SamplerFilterMode":     "0=Nearest 1=Linear",
+	"ImageFormat": "0=Unknown 1=Rgba32f 2=Rgba16f 3=R32f 4=Rgba8 5=Rgba8Snorm 6=Rg32f 7=Rg16f 8=R11fG11fB10f 9=R16f 10=Rgba16 " +
+		"11=Rgb10A2 12=Rg16 13=Rg8 14=R16 15=R8 16=Rgba16Snorm 17=Rg16Snorm 18=Rg8Snorm 19=R16Snorm 20=R8Snorm 21=Rgba32i " +
+		"22=Rgba16i 23=Rgba8i 24=R32i 25=Rg32i 26=Rg16i 27=Rg8i 28=R16i 29=R8i 30=Rgba32ui 31=Rgba16ui 32=Rgba8ui 33=R32ui " +
+		"34=Rgb10a2ui 35=Rg32ui 36=Rg16ui 37=Rg8ui 38=R16ui 39=R8ui 40=R64ui 41=R64i",
+	"FPRoundingMode":             "0=RTE 1=RTZ 2=RTP 3=RTN",
+	"LinkageType":                "0=Export 1=Import 2=LinkOnceODR",
+	"AccessQualifier":            "0=ReadOnly 1=WriteOnly 2=ReadWrite",
+	"FunctionParameterAttribute": "0=Zext 1=Sext 2=ByVal 3=Sret 4=NoAlias 5=NoCapture 6=NoWrite 7=NoReadWrite",
+	"Decoration": "0=RelaxedPrecision 1=SpecId(LiteralInteger) 2=Block 3=BufferBlock 4=RowMajor 5=ColMajor " +
+		"6=ArrayStride(LiteralInteger) 7=MatrixStride(LiteralInteger) 8=GLSLShared 9=GLSLPacked 10=CPacked 11=BuiltIn(BuiltIn) " +
+		"13=NoPerspective 14=Flat 15=Patch 16=Centroid 17=Sample 18=Invariant 19=Restrict 20=Aliased 21=Volatile 22=Constant " +
+		"23=Coherent 24=NonWritable 25=NonReadable 26=Uniform 27=UniformId(IdScope) 28=SaturatedConversion " +
+		"29=Stream(LiteralInteger) 30=Location(LiteralInteger) 31=Component(LiteralInteger) 32=Index(LiteralInteger) " +
+		"33=Binding(LiteralInteger) 34=DescriptorSet(LiteralInteger) 35=Offset(LiteralInteger) 36=XfbBuffer(LiteralInteger) " +
+		"37=XfbStride(LiteralInteger) 38=FuncParamAttr(FunctionParameterAttribute) 39=FPRoundingMode(FPRoundingMode) " +
+		"40=FPFastMathMode(FPFastMathMode) 41=LinkageAttributes(LiteralString,LinkageType) 42=NoContraction " +
+		"43=InputAttachmentIndex(LiteralInteger) 44=Alignment(LiteralInteger) 45=MaxByteOffset(LiteralInteger) " +
+		"46=AlignmentId(IdRef) 47=MaxByteOffsetId(IdRef) 4469=NoSignedWrap 4470=NoUnsignedWrap 4999=ExplicitInterpAMD " +
+		"5019=OverrideCoverageNV 5020=PassthroughNV 5021=ViewportRelativeNV 5022=SecondaryViewportRelativeNV(LiteralInteger) " +
+		"5271=PerPrimitiveEXT 5272=PerViewNV 5273=PerTaskNV 5285=PerVertexKHR 5300=NonUniform 5355=RestrictPointer " +
+		"5356=AliasedPointer 5634=CounterBuffer(IdRef) 5635=UserSemantic(LiteralString) 5636=UserTypeGOOGLE(LiteralString)",
+	"BuiltIn": "0=Position 1=PointSize 3=ClipDistance 4=CullDistance 5=VertexId 6=InstanceId 7=PrimitiveId 8=InvocationId " +
+		"9=Layer 10=ViewportIndex 11=TessLevelOuter 12=TessLevelInner 13=TessCoord 14=PatchVertices 15=FragCoord 16=PointCoord " +
+		"17=FrontFacing 18=SampleId 19=SamplePosition 20=SampleMask 22=FragDepth 23=HelperInvocation 24=NumWorkgroups " +
+		"25=WorkgroupSize 26=WorkgroupId 27=LocalInvocationId 28=GlobalInvocationId 29=LocalInvocationIndex 30=WorkDim " +
+		"31=GlobalSize 32=EnqueuedWorkgroupSize 33=GlobalOffset 34=GlobalLinearId 36=SubgroupSize 37=SubgroupMaxSize " +
+		"38=NumSubgroups 39=NumEnqueuedSubgroups 40=SubgroupId 41=SubgroupLocalInvocationId 42=VertexIndex 43=InstanceIndex " +
+		"4416=SubgroupEqMask 4417=SubgroupGeMask 4418=SubgroupGtMask 4419=SubgroupLeMask 4420=SubgroupLtMask 4424=BaseVertex " +
+		"4425=BaseInstance 4426=DrawIndex 4432=PrimitiveShadingRateKHR 4438=DeviceIndex 4440=ViewIndex 4444=ShadingRateKHR " +
+		"5014=FragStencilRefEXT 5264=FullyCoveredEXT 5286=BaryCoordKHR 5287=BaryCoordNoPerspKHR 5292=FragSizeEXT " +
+		"5293=FragInvocationCountEXT 5294=PrimitivePointIndicesEXT 5295=PrimitiveLineIndicesEXT 5296=PrimitiveTriangleIndicesEXT " +
+		"5299=CullPrimitiveEXT 5319=LaunchIdKHR 5320=LaunchSizeKHR 5321=WorldRayOriginKHR 5322=WorldRayDirectionKHR " +
+		"5323=ObjectRayOriginKHR 5324=ObjectRayDirectionKHR 5325=RayTminKHR 5326=RayTmaxKHR 5327=InstanceCustomIndexKHR " +
+		"5330=ObjectToWorldKHR 5331=WorldToObjectKHR 5333=HitKindKHR 5351=IncomingRayFlagsKHR 5352=RayGeometryIndexKHR",
+	"GroupOperation":     "0=Reduce 1=InclusiveScan 2=ExclusiveScan 3=ClusteredReduce",
+	"PackedVectorFormat": "0=PackedVectorFormat4x8Bit",
+	"Capability": "0=Matrix 1=Shader 2=Geometry 3=Tessellation 4=Addresses 5=Linkage 6=Kernel 7=Vector16 8=Float16Buffer " +
+		"9=Float16 10=Float64 11=Int64 12=Int64Atomics 13=ImageBasic 14=ImageReadWrite 15=ImageMipmap 17=Pipes 18=Groups " +
+		"19=DeviceEnqueue 20=LiteralSampler 21=AtomicStorage 22=Int16 23=TessellationPointSize 24=GeometryPointSize " +
+		"25=ImageGatherExtended 27=StorageImageMultisample 28=UniformBufferArrayDynamicIndexing " +
+		"29=SampledImageArrayDynamicIndexing 30=StorageBufferArrayDynamicIndexing 31=StorageImageArrayDynamicIndexing " +
+		"32=ClipDistance 33=CullDistance 34=ImageCubeArray 35=SampleRateShading 36=ImageRect 37=SampledRect 38=GenericPointer " +
+		"39=Int8 40=InputAttachment 41=SparseResidency 42=MinLod 43=Sampled1D 44=Image1D 45=SampledCubeArray 46=SampledBuffer " +
+		"47=ImageBuffer 48=ImageMSArray 49=StorageImageExtendedFormats 50=ImageQuery 51=DerivativeControl " +
+		"52=InterpolationFunction 53=TransformFeedback 54=GeometryStreams 55=StorageImageReadWithoutFormat " +
+		"56=StorageImageWriteWithoutFormat 57=MultiViewport 58=SubgroupDispatch 59=NamedBarrier 60=PipeStorage " +
+		"61=GroupNonUniform 62=GroupNonUniformVote 63=GroupNonUniformArithmetic 64=GroupNonUniformBallot " +
+		"65=GroupNonUniformShuffle 66=GroupNonUniformShuffleRelative 67=GroupNonUniformClustered 68=GroupNonUniformQuad " +
+		"69=ShaderLayer 70=ShaderViewportIndex 71=UniformDecoration 4422=FragmentShadingRateKHR 4423=SubgroupBallotKHR " +
+		"4427=DrawParameters 4428=WorkgroupMemoryExplicitLayoutKHR 4429=WorkgroupMemoryExplicitLayout8BitAccessKHR " +
+		"4430=WorkgroupMemoryExplicitLayout16BitAccessKHR 4431=SubgroupVoteKHR 4433=StorageBuffer16BitAccess " +
+		"4434=UniformAndStorageBuffer16BitAccess 4435=StoragePushConstant16 4436=StorageInputOutput16 4437=DeviceGroup " +
+		"4439=MultiView 4441=VariablePointersStorageBuffer 4442=VariablePointers 4445=AtomicStorageOps " +
+		"4447=SampleMaskPostDepthCoverage 4448=StorageBuffer8BitAccess 4449=UniformAndStorageBuffer8BitAccess " +
+		"4450=StoragePushConstant8 4464=DenormPreserve 4465=DenormFlushToZero 4466=SignedZeroInfNanPreserve " +
+		"4467=RoundingModeRTE 4468=RoundingModeRTZ 4471=RayQueryProvisionalKHR 4472=RayQueryKHR " +
+		"4478=RayTraversalPrimitiveCullingKHR 4479=RayTracingKHR 5008=Float16ImageAMD 5009=ImageGatherBiasLodAMD " +
+		"5010=FragmentMaskAMD 5013=StencilExportEXT 5015=ImageReadWriteLodAMD 5016=Int64ImageEXT 5055=ShaderClockKHR " +
+		"5249=SampleMaskOverrideCoverageNV 5251=GeometryShaderPassthroughNV 5254=ShaderViewportIndexLayerEXT " +
+		"5255=ShaderViewportMaskNV 5259=ShaderStereoViewNV 5260=PerViewAttributesNV 5265=FragmentFullyCoveredEXT " +
+		"5266=MeshShadingNV 5282=ImageFootprintNV 5283=MeshShadingEXT 5284=FragmentBarycentricKHR " +
+		"5291=FragmentDensityEXT 5297=GroupNonUniformPartitionedNV 5301=ShaderNonUniform 5302=RuntimeDescriptorArray " +
+		"5303=InputAttachmentArrayDynamicIndexing 5304=UniformTexelBufferArrayDynamicIndexing " +
+		"5305=StorageTexelBufferArrayDynamicIndexing 5306=UniformBufferArrayNonUniformIndexing " +
+		"5307=SampledImageArrayNonUniformIndexing 5308=StorageBufferArrayNonUniformIndexing " +
+		"5309=StorageImageArrayNonUniformIndexing 5310=InputAttachmentArrayNonUniformIndexing " +
+		"5311=UniformTexelBufferArrayNonUniformIndexing 5312=StorageTexelBufferArrayNonUniformIndexing 5340=RayTracingNV " +
+		"5341=RayTracingMotionBlurNV 5345=VulkanMemoryModel 5346=VulkanMemoryModelDeviceScope " +
+		"5347=PhysicalStorageBufferAddresses 5353=RayTracingProvisionalKHR 5357=CooperativeMatrixNV " +
+		"5363=FragmentShaderSampleInterlockEXT 5372=FragmentShaderShadingRateInterlockEXT 5373=ShaderSMBuiltinsNV " +
+		"5378=FragmentShaderPixelInterlockEXT 5379=DemoteToHelperInvocation 6016=DotProductInputAll " +
+		"6017=DotProductInput4x8Bit 6018=DotProductInput4x8BitPacked 6019=DotProduct 6033=AtomicFloat32AddEXT " +
+		"6034=AtomicFloat64AddEXT",
+}
+
+// spirvMaskGrammar lists the values of the bit mask operand kinds in the
+// notation of spirvEnumGrammar, including the name of the empty mask
+var spirvMaskGrammar = map[string]string{
+	"FPFastMathMode":   "0=None 0x1=NotNaN 0x2=NotInf 0x4=NSZ 0x8=AllowRecip 0x10=Fast",
+	"SelectionControl": "0=None 0x1=Flatten 0x2=DontFlatten",
+	"LoopControl": "0=None 0x1=Unroll 0x2=DontUnroll 0x4=DependencyInfinite 0x8=DependencyLength(LiteralInteger) " +
+		"0x10=MinIterations(LiteralInteger) 0x20=MaxIterations(LiteralInteger) 0x40=IterationMultiple(LiteralInteger) " +
+		"0x80=PeelCount(LiteralInteger) 0x100=PartialCount(LiteralInteger)",
+	"FunctionControl": "0=None 0x1=Inline 0x2=DontInline 0x4=Pure 0x8=Const",
+	"MemoryAccess": "0=None 0x1=Volatile 0x2=Aligned(LiteralInteger) 0x4=Nontemporal 0x8=MakePointerAvailable(IdScope) " +
+		"0x10=MakePointerVisible(IdScope) 0x20=NonPrivatePointer",
+	"ImageOperands": "0=None 0x1=Bias(IdRef) 0x2=Lod(IdRef) 0x4=Grad(IdRef,IdRef) 0x8=ConstOffset(IdRef) 0x10=Offset(IdRef) " +
+		"0x20=ConstOffsets(IdRef) 0x40=Sample(IdRef) 0x80=MinLod(IdRef) 0x100=MakeTexelAvailable(IdScope) " +
+		"0x200=MakeTexelVisible(IdScope) 0x400=NonPrivateTexel 0x800=VolatileTexel 0x1000=SignExtend 0x2000=ZeroExtend " +
+		"0x4000=Nontemporal 0x10000=Offsets(IdRef)",
+}
+
+// glslStd450Instructions names the instructions of the GLSL.std.450
+// extended instruction set, indexed by instruction number
+var glslStd450Instructions = strings.Fields("_ Round RoundEven Trunc FAbs SAbs FSign SSign Floor Ceil Fract Radians " +
+	"Degrees Sin Cos Tan Asin Acos Atan Sinh Cosh Tanh Asinh Acosh Atanh Atan2 Pow Exp Log Exp2 Log2 Sqrt InverseSqrt " +
+	"Determinant MatrixInverse Modf ModfStruct FMin UMin SMin FMax UMax SMax FClamp UClamp SClamp FMix IMix Step " +
+	"SmoothStep Fma Frexp FrexpStruct Ldexp PackSnorm4x8 PackUnorm4x8 PackSnorm2x16 PackUnorm2x16 PackHalf2x16 " +
+	"PackDouble2x32 UnpackSnorm2x16 UnpackUnorm2x16 UnpackHalf2x16 UnpackSnorm4x8 UnpackUnorm4x8 UnpackDouble2x32 " +
+	"Length Distance Cross Normalize FaceForward Reflect Refract FindILsb FindSMsb FindUMsb InterpolateAtCentroid " +
+	"InterpolateAtSample InterpolateAtOffset NMin NMax NClamp")
+
+var (
+	spirvOpcodes     = make(map[uint32]*spirvOpcode)
+	spirvOpcodeNames = make(map[string]uint32)
+	spirvEnums       = make(map[string]*spirvEnum)
+)
+
+func init() {
+	for _, g := range spirvGrammar {
+		spirvOpcodes[g.Opcode] = &spirvOpcode{Name: g.Name, Operands: parseSPIRVOperands(strings.Fields(g.Operands))}
+		spirvOpcodeNames[g.Name] = g.Opcode
+	}
+	for kind, values := range spirvEnumGrammar {
+		spirvEnums[kind] = parseSPIRVEnum(values, false)
+	}
+	for kind, values := range spirvMaskGrammar {
+		spirvEnums[kind] = parseSPIRVEnum(values, true)
+	}
+}
+
+func parseSPIRVOperands(kinds []string) []spirvOperand {
+	operands := make([]spirvOperand, len(kinds))
+	for i, kind := range kinds {
+		if q := kind[len(kind)-1]; q == '?' || q == '*' {
+			operands[i] = spirvOperand{Kind: kind[:len(kind)-1], Quantifier: q}
+		} else {
+			operands[i] = spirvOperand{Kind: kind}
+		}
+	}
+	return operands
+}
+
+func parseSPIRVEnum(grammar string, bitMask bool) *spirvEnum {
+	e := &spirvEnum{BitMask: bitMask, Values: make(map[uint32]spirvEnumValue), names: make(map[string]uint32)}
+	for _, field := range strings.Fields(grammar) {
+		i := strings.Index(field, "=")
+		value, err := strconv.ParseUint(field[:i], 0, 32)
+		if err != nil {
+			panic(fmt.Sprintf("bad SPIR-V grammar entry '%s'", field))
+		}
+		v := spirvEnumValue{Name: field[i+1:]}
+		if j := strings.Index(v.Name, "("); j >= 0 {
+			v.Params = strings.Split(v.Name[j+1:len(v.Name)-1], ",")
+			v.Name = v.Name[:j]
+		}
+		e.Values[uint32(value)] = v
+		e.names[v.Name] = uint32(value)
+	}
+	return e
+}
+
+// spirvNumberType is a scalar numeric type, the type of literal numbers
+// which depend on their context
+type spirvNumberType struct {
+	Width  uint32
+	Signed bool
+	Float  bool
+}
+
+// words returns how many words a literal of the type takes up
+func (t spirvNumberType) words() int {
+	if t.Width > 32 {
+		return 2
+	}
+	return 1
+}
+
+// spirvParsedOperand is an operand of an instruction, Kind is IdResult,
+// IdResultType, IdRef, IdScope, IdMemorySemantics, a literal kind or an
+// enumerated kind. Number is the type of LiteralContextDependentNumber
+// operands.
+type spirvParsedOperand struct {
+	Kind   string
+	Words  []uint32
+	Number spirvNumberType
+}
+
+// isID reports whether the operand refers to an id
+func (o *spirvParsedOperand) isID() bool {
+	switch o.Kind {
+	case "IdResult", "IdResultType", "IdRef", "IdScope", "IdMemorySemantics":
+		return true
+	}
+	return false
+}
+
+// spirvParser splits instructions into operands, it has to see every
+// instruction of a module in order to know the types of literal numbers
+type spirvParser struct {
+	numberTypes map[uint32]spirvNumberType
+	resultTypes map[uint32]uint32
+}
+
+func newSPIRVParser() *spirvParser {
+	return &spirvParser{numberTypes: make(map[uint32]spirvNumberType), resultTypes: make(map[uint32]uint32)}
+}
+
+// parse returns the operands of an instruction
+func (p *spirvParser) parse(inst spirvInstruction) ([]spirvParsedOperand, error) {
+	op, ok := spirvOpcodes[inst.Opcode]
+	if !ok {
+		return nil, fmt.Errorf("unknown opcode %d: %w", inst.Opcode, InvalidSPIRVError)
+	}
+	words := inst.Words[1:]
+	operands := []spirvParsedOperand{}
+	pending := op.Operands
+
+	take := func(kind string, n int) error {
+		if n > len(words) {
+			return fmt.Errorf("%s is missing a %s operand: %w", op.Name, kind, InvalidSPIRVError)
+		}
+		operands = append(operands, spirvParsedOperand{Kind: kind, Words: words[:n]})
+		words = words[n:]
+		return nil
+	}
+	number := func(t spirvNumberType, ok bool) error {
+		if !ok {
+			return fmt.Errorf("%s has a literal number of a non-numeric type: %w", op.Name, InvalidSPIRVError)
+		}
+		if err := take("LiteralContextDependentNumber", t.words()); err != nil {
+			return err
+		}
+		operands[len(operands)-1].Number = t
+		return nil
+	}
+
+	for len(pending) > 0 {
+		operand := pending[0]
+		pending = pending[1:]
+		if operand.Quantifier != 0 && len(words) == 0 {
+			continue
+		}
+		if operand.Quantifier == '*' {
+			pending = append([]spirvOperand{operand}, pending...)
+		}
+
+		var err error
+		switch operand.Kind {
+		case "IdResult", "IdResultType", "IdRef", "IdScope", "IdMemorySemantics", "LiteralInteger", "LiteralExtInstInteger":
+			err = take(operand.Kind, 1)
+		case "LiteralString":
+			_, n := spirvString(words)
+			if len(words) == 0 {
+				n = 1
+			}
+			err = take(operand.Kind, n)
+		case "LiteralContextDependentNumber":
+			t, ok := p.numberTypes[operands[0].Words[0]]
+			err = number(t, ok)
+		case "LiteralSpecConstantOpInteger":
+			if err = take(operand.Kind, 1); err == nil {
+				spec, ok := spirvOpcodes[operands[len(operands)-1].Words[0]]
+				if !ok {
+					return nil, fmt.Errorf("unknown OpSpecConstantOp opcode %d: %w", operands[len(operands)-1].Words[0], InvalidSPIRVError)
+				}
+				// The operation's operands follow, without its result
+				pending = nil
+				for _, o := range spec.Operands {
+					if o.Kind != "IdResult" && o.Kind != "IdResultType" {
+						pending = append(pending, o)
+					}
+				}
+			}
+		case "PairLiteralIntegerIdRef":
+			t, ok := p.numberTypes[p.resultTypes[operands[0].Words[0]]]
+			if err = number(t, ok); err == nil {
+				err = take("IdRef", 1)
+			}
+		case "PairIdRefLiteralInteger":
+			if err = take("IdRef", 1); err == nil {
+				err = take("LiteralInteger", 1)
+			}
+		case "PairIdRefIdRef":
+			if err = take("IdRef", 1); err == nil {
+				err = take("IdRef", 1)
+			}
+		default:
+			e, ok := spirvEnums[operand.Kind]
+			if !ok {
+				panic("unknown SPIR-V operand kind " + operand.Kind)
+			}
+			if err = take(operand.Kind, 1); err != nil {
+				break
+			}
+			params, perr := e.params(operands[len(operands)-1].Words[0])
+			if perr != nil {
+				return nil, fmt.Errorf("%s: %w", op.Name, perr)
+			}
+			pending = append(parseSPIRVOperands(params), pending...)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(words) > 0 {
+		return nil, fmt.Errorf("%s has %d words too many: %w", op.Name, len(words), InvalidSPIRVError)
+	}
+
+	switch inst.Opcode {
+	case opTypeInt:
+		p.numberTypes[inst.Words[1]] = spirvNumberType{Width: inst.Words[2], Signed: inst.Words[3] != 0}
+	case opTypeFloat:
+		p.numberTypes[inst.Words[1]] = spirvNumberType{Width: inst.Words[2], Float: true}
+	}
+	if len(operands) > 1 && operands[0].Kind == "IdResultType" && operands[1].Kind == "IdResult" {
+		p.resultTypes[operands[1].Words[0]] = operands[0].Words[0]
+	}
+	return operands, nil
+}
+
+// params returns the kinds of the operands following a value, for bit
+// masks the operands of each bit follow in order
+func (e *spirvEnum) params(value uint32) ([]string, error) {
+	if !e.BitMask {
+		v, ok := e.Values[value]
+		if !ok {
+			return nil, fmt.Errorf("unknown operand value %d: %w", value, InvalidSPIRVError)
+		}
+		return v.Params, nil
+	}
+	var params []string
+	for bit := uint32(1); bit != 0; bit <<= 1 {
+		if value&bit == 0 {
+			continue
+		}
+		v, ok := e.Values[bit]
+		if !ok {
+			return nil, fmt.Errorf("unknown operand bit 0x%x: %w", bit, InvalidSPIRVError)
+		}
+		params = append(params, v.Params...)
+	}
+	return params, nil
+}
+
+// name returns the text of a value, bit masks are joined with '|'
+func (e *spirvEnum) name(value uint32) string {
+	if !e.BitMask || value == 0 {
+		return e.Values[value].Name
+	}
+	var names []string
+	for bit := uint32(1); bit != 0; bit <<= 1 {
+		if value&bit != 0 {
+			names = append(names, e.Values[bit].Name)
+		}
+	}
+	return strings.Join(names, "|")
+}
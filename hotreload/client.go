@@ -0,0 +1,125 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hotreload
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Subscribe connects to an event stream and returns the events received.
+// The endpoint is either an http:// URL serving server-sent events, e.g.
+// "http://localhost:9797/events", or "unix://" followed by the path of a
+// Unix socket, e.g. "unix:///tmp/gsc.sock". The channel is closed when ctx
+// is cancelled or the connection is lost.
+func Subscribe(ctx context.Context, endpoint string) (<-chan Event, error) {
+	switch {
+	case strings.HasPrefix(endpoint, "unix://"):
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "unix", strings.TrimPrefix(endpoint, "unix://"))
+		if err != nil {
+			return nil, err
+		}
+		return stream(ctx, conn, decodeLines), nil
+
+	case strings.HasPrefix(endpoint, "http://"), strings.HasPrefix(endpoint, "https://"):
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "text/event-stream")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("error subscribing to '%s': %s", endpoint, resp.Status)
+		}
+		return stream(ctx, resp.Body, decodeServerSentEvents), nil
+	}
+	return nil, fmt.Errorf("unsupported endpoint '%s', expected http:// or unix://", endpoint)
+}
+
+// stream decodes events from r until it fails or ctx is done
+func stream(ctx context.Context, r io.ReadCloser, decode func(*bufio.Scanner, func(Event) bool) error) <-chan Event {
+	events := make(chan Event)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		r.Close()
+	}()
+	go func() {
+		defer close(events)
+		defer close(done)
+		decode(bufio.NewScanner(r), func(e Event) bool {
+			select {
+			case events <- e:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+	return events
+}
+
+func decodeLines(s *bufio.Scanner, emit func(Event) bool) error {
+	for s.Scan() {
+		var e Event
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			return err
+		}
+		if !emit(e) {
+			return nil
+		}
+	}
+	return s.Err()
+}
+
+func decodeServerSentEvents(s *bufio.Scanner, emit func(Event) bool) error {
+	var data strings.Builder
+	for s.Scan() {
+		line := s.Text()
+		switch {
+		case line == "":
+			if data.Len() > 0 {
+				var e Event
+				if err := json.Unmarshal([]byte(data.String()), &e); err != nil {
+					return err
+				}
+				if !emit(e) {
+					return nil
+				}
+				data.Reset()
+			}
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteString("\n")
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	return s.Err()
+}
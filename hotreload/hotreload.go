@@ -0,0 +1,217 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hotreload publishes and subscribes to the events gsc sends when
+// it recompiles shaders in watch mode, so running applications can reload
+// shaders without polling the filesystem.
+//
+// Events are JSON objects, sent either as HTTP server-sent events or as
+// newline delimited JSON over a stream socket such as a Unix socket:
+//
+//	events, err := hotreload.Subscribe(ctx, "unix:///tmp/gsc.sock")
+//	for event := range events {
+//		if event.Success {
+//			reloadShader(event.Output)
+//		}
+//	}
+package hotreload
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Event is sent after every compile
+type Event struct {
+	Time time.Time `json:"time"`
+	// Source is the shader which was compiled
+	Source string `json:"source"`
+	// Output is the file written, it's empty if the compile failed
+	Output string `json:"output,omitempty"`
+	// Variant is the variant key, if the shader has variants
+	Variant string `json:"variant,omitempty"`
+	// Success is true if the shader compiled and was written
+	Success bool `json:"success"`
+	// Removed is true if the source was deleted and Output removed
+	Removed bool `json:"removed,omitempty"`
+	// Diagnostics are the compiler's errors and warnings
+	Diagnostics string `json:"diagnostics,omitempty"`
+	// Hash is the hex encoded SHA-256 of the compiler output, usually
+	// SPIR-V, before it was converted into the output format
+	Hash string `json:"hash,omitempty"`
+}
+
+// subscriberBuffer is how many events a slow subscriber may fall behind
+// before events are dropped for it
+const subscriberBuffer = 64
+
+// Server sends every event published to all of its subscribers
+type Server struct {
+	mu        sync.Mutex
+	subs      map[chan Event]bool
+	listeners []net.Listener
+	closed    bool
+}
+
+// NewServer creates a server without any subscribers
+func NewServer() *Server {
+	return &Server{subs: make(map[chan Event]bool)}
+}
+
+// Publish sends an event to every subscriber, it never blocks, subscribers
+// which aren't keeping up miss events
+func (s *Server) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+func (s *Server) subscribe() chan Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch := make(chan Event, subscriberBuffer)
+	if s.closed {
+		close(ch)
+	} else {
+		s.subs[ch] = true
+	}
+	return ch
+}
+
+func (s *Server) unsubscribe(ch chan Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.subs[ch] {
+		delete(s.subs, ch)
+		close(ch)
+	}
+}
+
+// ServeHTTP streams events as server-sent events
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	ch := s.subscribe()
+	defer s.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// Serve accepts connections on l and streams events to each of them as
+// newline delimited JSON, it returns when l is closed
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	s.listeners = append(s.listeners, l)
+	s.mu.Unlock()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	ch := s.subscribe()
+	defer s.unsubscribe(ch)
+
+	// Notice the subscriber going away even when nothing is published
+	gone := make(chan struct{})
+	go func() {
+		io.Copy(ioutil.Discard, conn)
+		close(gone)
+	}()
+
+	enc := json.NewEncoder(conn)
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := enc.Encode(e); err != nil {
+				return
+			}
+		case <-gone:
+			return
+		}
+	}
+}
+
+// Close disconnects every subscriber and closes the listeners passed to
+// Serve
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for ch := range s.subs {
+		delete(s.subs, ch)
+		close(ch)
+	}
+	var err error
+	for _, l := range s.listeners {
+		if e := l.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	s.listeners = nil
+	return err
+}
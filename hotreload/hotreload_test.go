@@ -0,0 +1,107 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hotreload
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// receive publishes e until it arrives, subscribers may not be registered
+// by the time Subscribe returns
+func receive(t *testing.T, s *Server, events <-chan Event, e Event) Event {
+	for i := 0; i < 500; i++ {
+		s.Publish(e)
+		select {
+		case got, ok := <-events:
+			if !ok {
+				t.Fatal("event stream closed")
+			}
+			return got
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	t.Fatal("timed out waiting for an event")
+	return Event{}
+}
+
+func TestServerSentEvents(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := Subscribe(ctx, ts.URL+"/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := Event{Source: "a.frag", Output: "a.frag.spv", Success: true, Hash: "abcd"}
+	got := receive(t, s, events, sent)
+	if got.Source != sent.Source || got.Output != sent.Output || !got.Success || got.Hash != sent.Hash || got.Time.IsZero() {
+		t.Fatalf("unexpected event %+v", got)
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestSocketEvents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hotreload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "gsc.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewServer()
+	served := make(chan error)
+	go func() {
+		served <- s.Serve(l)
+	}()
+
+	events, err := Subscribe(context.Background(), "unix://"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := receive(t, s, events, Event{Source: "b.vert", Diagnostics: "b.vert:1: error: broken"})
+	if got.Source != "b.vert" || got.Success || got.Diagnostics != "b.vert:1: error: broken" {
+		t.Fatalf("unexpected event %+v", got)
+	}
+
+	// Closing the server ends the stream
+	s.Close()
+	for range events {
+	}
+	if err := <-served; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Subscribe(context.Background(), "ftp://example.com"); err == nil {
+		t.Fatal("expected an error for an unsupported endpoint")
+	}
+}
@@ -14,110 +14,150 @@
 
 package gshaderc
 
-// #cgo LDFLAGS: -lshaderc_combined -lstdc++ -lm
-/*
-#include <shaderc/shaderc.h>
-#include <stdlib.h>
-
-static shaderc_include_result* new_shader_include_result(){
-	return (shaderc_include_result*) malloc(sizeof(shaderc_include_result));
-}
-
-static void free_shader_include_result(void *user_data, shaderc_include_result *res){
-	free(res);
-}
-
-shaderc_include_result* cbIncludeResolver(void* user_data, char* requested_source, int type,
-    char* requesting_source, size_t include_depth);
-
-
-static void _register_callback(shaderc_compile_options_t options,void *userData){
-	shaderc_compile_options_set_include_callbacks(options, (shaderc_include_resolve_fn) cbIncludeResolver, free_shader_include_result, userData);
-}
-
-
-*/
-import "C"
 import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
-	"unsafe"
-
-	ptr "github.com/mattn/go-pointer"
+	"strings"
+	"sync"
 )
 
-type callback struct {
-	resolver IncludeResolver
-}
+type IncludeType int
 
-//export cbIncludeResolver
-func cbIncludeResolver(userData unsafe.Pointer, requestedSource *C.char, itype C.int, requestingSource *C.char, includeDepth C.ulong) *C.shaderc_include_result {
+const (
+	// IncludeRelative E.g. #include "source"
+	IncludeRelative IncludeType = iota
+	// IncludeStandard E.g. #include <source>
+	IncludeStandard
+)
 
-	rds := C.GoString(requestedSource)
-	ris := C.GoString(requestingSource)
+// CreateDefaultIncludeResolver returns a basic include resolover which looks for files in a list of specified directories,
+// relative includes ("file") are looked for next to the requesting source first. Relative includes used to be looked
+// for in the current directory only, include "." in dirs to keep finding them there.
+func CreateDefaultIncludeResolver(dirs []string) IncludeResolver {
+	return func(requestedSource string, itype IncludeType, requestingSource string, includeDepth int) (sourceName, content string, err error) {
+		for _, absp := range includeCandidates(dirs, requestedSource, itype, requestingSource) {
+			data, err := ioutil.ReadFile(absp)
+			if err == nil {
+				return absp, string(data), nil
+			}
+		}
+		return "", "", fmt.Errorf("unable to find file '%s'", requestedSource)
+	}
+}
 
-	callback := ptr.Restore(userData).(*callback)
+// CreateConfinedIncludeResolver returns a resolver which searches the same
+// places as CreateDefaultIncludeResolver but only reads files inside dirs.
+// An include escaping them with ".." isn't found, nor is a relative include
+// next to a source outside them.
+func CreateConfinedIncludeResolver(dirs []string) IncludeResolver {
+	var roots []string
+	for _, d := range dirs {
+		if abs, err := filepath.Abs(d); err == nil {
+			roots = append(roots, abs)
+		}
+	}
+	return func(requestedSource string, itype IncludeType, requestingSource string, includeDepth int) (sourceName, content string, err error) {
+		for _, absp := range includeCandidates(dirs, requestedSource, itype, requestingSource) {
+			if !insideDirs(roots, absp) {
+				continue
+			}
+			data, err := ioutil.ReadFile(absp)
+			if err == nil {
+				return absp, string(data), nil
+			}
+		}
+		return "", "", fmt.Errorf("unable to find file '%s'", requestedSource)
+	}
+}
 
-	sourceName, content, err := callback.resolver(rds, IncludeType(itype), ris, int(includeDepth))
+// insideDirs reports whether the absolute path is inside one of the
+// absolute directories
+func insideDirs(dirs []string, path string) bool {
+	for _, d := range dirs {
+		rel, err := filepath.Rel(d, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
 
-	result := C.new_shader_include_result()
+// includeCandidates returns the absolute paths an include may refer to, in
+// the order they're tried
+func includeCandidates(dirs []string, requestedSource string, itype IncludeType, requestingSource string) []string {
+	search := dirs
+	if itype == IncludeRelative {
+		search = append([]string{filepath.Dir(requestingSource)}, dirs...)
+	}
+	var candidates []string
+	for _, d := range search {
+		absp, err := filepath.Abs(filepath.Join(d, requestedSource))
+		if err == nil {
+			candidates = append(candidates, absp)
+		}
+	}
+	return candidates
+}
 
-	if err == nil {
-		result.source_name = C.CString(sourceName)
-		result.source_name_length = C.ulong(len(sourceName))
+// IncludeOverlay holds the contents of files which take precedence over
+// what's on disk, such as the unsaved buffers of an editor. It is safe for
+// concurrent use.
+type IncludeOverlay struct {
+	mu    sync.RWMutex
+	files map[string]string
+}
 
-		result.content = C.CString(content)
-		result.content_length = C.ulong(len(content))
+// NewIncludeOverlay creates an empty overlay
+func NewIncludeOverlay() *IncludeOverlay {
+	return &IncludeOverlay{files: make(map[string]string)}
+}
 
-	} else {
-		msg := err.Error()
-		result.content = C.CString(msg)
-		result.content_length = C.ulong(len(msg))
+// overlayKey returns the key a path is stored under, its absolute path
+func overlayKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
 	}
+	return filepath.Clean(path)
+}
 
-	return result
+// Set overlays the file at path with content
+func (o *IncludeOverlay) Set(path, content string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.files[overlayKey(path)] = content
 }
 
-type IncludeType int
+// Remove removes the file at path from the overlay, so it's read from disk
+// again
+func (o *IncludeOverlay) Remove(path string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.files, overlayKey(path))
+}
 
-const (
-	// IncludeRelative E.g. #include "source"
-	IncludeRelative IncludeType = IncludeType(C.shaderc_include_type_relative)
-	// IncludeStandard E.g. #include <source>
-	IncludeStandard = IncludeType(C.shaderc_include_type_standard)
-)
+// ReadFile returns the overlaid content of path, or reads it from disk
+func (o *IncludeOverlay) ReadFile(path string) (string, error) {
+	o.mu.RLock()
+	content, ok := o.files[overlayKey(path)]
+	o.mu.RUnlock()
+	if ok {
+		return content, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	return string(data), err
+}
 
-// CreateDefaultIncludeResolver returns a basic include resolover which looks for files in a list of specified directories
-func CreateDefaultIncludeResolver(dirs []string) IncludeResolver {
+// IncludeResolver returns a resolver which searches the same places as
+// CreateDefaultIncludeResolver, preferring overlaid contents to the disk
+func (o *IncludeOverlay) IncludeResolver(dirs []string) IncludeResolver {
 	return func(requestedSource string, itype IncludeType, requestingSource string, includeDepth int) (sourceName, content string, err error) {
-		if itype == IncludeRelative {
-			p := filepath.Join(".", requestedSource)
-			absp, err := filepath.Abs(p)
-			if err != nil {
-				return "", "", fmt.Errorf("error opening file '%s': %w", requestedSource, err)
-			}
-			data, err := ioutil.ReadFile(absp)
-			if err == nil {
-				return absp, string(data), nil
-			} else {
-				return "", "", fmt.Errorf("error opening file '%s': %w", requestedSource, err)
-			}
-		} else {
-
-			for _, d := range dirs {
-				p := filepath.Join(d, requestedSource)
-				absp, err := filepath.Abs(p)
-				if err != nil {
-					continue
-				}
-				data, err := ioutil.ReadFile(absp)
-				if err == nil {
-					return absp, string(data), nil
-				}
+		for _, absp := range includeCandidates(dirs, requestedSource, itype, requestingSource) {
+			if content, err := o.ReadFile(absp); err == nil {
+				return absp, content, nil
 			}
-			return "", "", fmt.Errorf("unable to find file '%s'", requestedSource)
 		}
+		return "", "", fmt.Errorf("unable to find file '%s'", requestedSource)
 	}
 }
 
@@ -132,5 +172,78 @@ type IncludeResolver func(requestedSource string, itype IncludeType, requestingS
 
 // SetIncludeCallback sets a include resolver
 func (c *CompilerOptions) SetIncludeCallback(resolver IncludeResolver) {
-	C._register_callback(c.options, ptr.Save(&callback{resolver: resolver}))
+	c.includeResolver = resolver
+}
+
+// IncludeRecorder wraps an IncludeResolver and records every source it
+// resolves, pass its Resolve method to SetIncludeCallback. It can be shared
+// by concurrent compilations.
+type IncludeRecorder struct {
+	resolver IncludeResolver
+
+	mu       sync.Mutex
+	includes []string
+	seen     map[string]bool
+}
+
+// NewIncludeRecorder creates a recorder which resolves includes with resolver
+func NewIncludeRecorder(resolver IncludeResolver) *IncludeRecorder {
+	return &IncludeRecorder{resolver: resolver, seen: make(map[string]bool)}
+}
+
+// Resolve resolves an include with the wrapped resolver, recording the
+// source name it returns
+func (r *IncludeRecorder) Resolve(requestedSource string, itype IncludeType, requestingSource string, includeDepth int) (sourceName, content string, err error) {
+	if r.resolver == nil {
+		return "", "", fmt.Errorf("unable to find file '%s', no include resolver set", requestedSource)
+	}
+	sourceName, content, err = r.resolver(requestedSource, itype, requestingSource, includeDepth)
+	if err == nil {
+		r.mu.Lock()
+		if !r.seen[sourceName] {
+			r.seen[sourceName] = true
+			r.includes = append(r.includes, sourceName)
+		}
+		r.mu.Unlock()
+	}
+	return sourceName, content, err
+}
+
+// Includes returns every source resolved so far, in the order they were
+// first resolved
+func (r *IncludeRecorder) Includes() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.includes...)
+}
+
+// ParseIncludeDirective parses a line of the form '#include "name"' or
+// '#include <name>', ok is false if the line isn't an include directive
+func ParseIncludeDirective(line string) (name string, itype IncludeType, ok bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "#") {
+		return "", 0, false
+	}
+	line = strings.TrimSpace(line[1:])
+	if !strings.HasPrefix(line, "include") {
+		return "", 0, false
+	}
+	line = strings.TrimSpace(line[len("include"):])
+	if len(line) < 2 {
+		return "", 0, false
+	}
+	var end byte
+	switch line[0] {
+	case '"':
+		end, itype = '"', IncludeRelative
+	case '<':
+		end, itype = '>', IncludeStandard
+	default:
+		return "", 0, false
+	}
+	i := strings.IndexByte(line[1:], end)
+	if i < 0 {
+		return "", 0, false
+	}
+	return line[1 : i+1], itype, true
 }
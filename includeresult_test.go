@@ -0,0 +1,143 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gshaderc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultIncludeResolver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gshaderc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range map[string]string{
+		"shaders/common.glsl": "next to it",
+		"include/common.glsl": "include path",
+		"include/only.glsl":   "only in the include path",
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resolve := CreateDefaultIncludeResolver([]string{filepath.Join(dir, "include")})
+	requesting := filepath.Join(dir, "shaders", "a.frag")
+
+	// "file" is looked for next to the including file before the include
+	// path, <file> only in the include path
+	for _, c := range []struct {
+		requested string
+		itype     IncludeType
+		content   string
+	}{
+		{"common.glsl", IncludeRelative, "next to it"},
+		{"common.glsl", IncludeStandard, "include path"},
+		{"only.glsl", IncludeRelative, "only in the include path"},
+	} {
+		if _, content, err := resolve(c.requested, c.itype, requesting, 1); err != nil || content != c.content {
+			t.Fatalf("expected %s (%d) to resolve to the file %s, got %q %v", c.requested, c.itype, c.content, content, err)
+		}
+	}
+	if _, _, err := resolve("a.frag", IncludeStandard, requesting, 1); err == nil {
+		t.Fatal("expected a standard include not to search the requesting directory")
+	}
+}
+
+func TestIncludeOverlay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gshaderc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	common := filepath.Join(dir, "common.glsl")
+	if err := ioutil.WriteFile(common, []byte("saved"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	overlay := NewIncludeOverlay()
+	resolve := overlay.IncludeResolver(nil)
+	requesting := filepath.Join(dir, "a.frag")
+	if name, content, err := resolve("common.glsl", IncludeRelative, requesting, 1); err != nil || name != common || content != "saved" {
+		t.Fatalf("unexpected include %s %q %v", name, content, err)
+	}
+
+	overlay.Set(common, "unsaved")
+	overlay.Set(filepath.Join(dir, "new.glsl"), "new")
+	if _, content, _ := resolve("common.glsl", IncludeRelative, requesting, 1); content != "unsaved" {
+		t.Fatalf("expected the overlay to win, got %q", content)
+	}
+	if _, content, err := resolve("new.glsl", IncludeRelative, requesting, 1); err != nil || content != "new" {
+		t.Fatalf("expected a file only in the overlay to resolve, got %q %v", content, err)
+	}
+	if _, _, err := resolve("new.glsl", IncludeStandard, requesting, 1); err == nil {
+		t.Fatal("expected a standard include not to search the requesting directory")
+	}
+
+	overlay.Remove(common)
+	if content, _ := overlay.ReadFile(common); content != "saved" {
+		t.Fatalf("expected the file on disk after removing it, got %q", content)
+	}
+}
+
+func TestConfinedIncludeResolver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gshaderc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range map[string]string{
+		"include/common.glsl":     "common",
+		"include/lib/light.glsl":  "light",
+		"secret.txt":              "secret",
+		"shaders/next_to_it.glsl": "next",
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	include := filepath.Join(dir, "include")
+	resolve := CreateConfinedIncludeResolver([]string{include})
+
+	if _, content, err := resolve("lib/light.glsl", IncludeStandard, "a.frag", 1); err != nil || content != "light" {
+		t.Fatalf("expected an include in the include path, got %q %v", content, err)
+	}
+	// Relative to an include inside the path is fine, as long as it stays there
+	if _, content, err := resolve("../common.glsl", IncludeRelative, filepath.Join(include, "lib", "light.glsl"), 2); err != nil || content != "common" {
+		t.Fatalf("expected a relative include inside the path, got %q %v", content, err)
+	}
+	for _, requested := range []string{"../secret.txt", "lib/../../secret.txt"} {
+		if _, _, err := resolve(requested, IncludeStandard, "a.frag", 1); err == nil {
+			t.Fatalf("expected %s to escape the include path", requested)
+		}
+	}
+	if _, _, err := resolve("next_to_it.glsl", IncludeRelative, filepath.Join(dir, "shaders", "a.frag"), 1); err == nil {
+		t.Fatal("expected a source outside the include path not to be searched")
+	}
+	if _, content, err := CreateDefaultIncludeResolver([]string{include})("../secret.txt", IncludeStandard, "a.frag", 1); err != nil || content != "secret" {
+		t.Fatalf("expected the default resolver not to be confined, got %q %v", content, err)
+	}
+}
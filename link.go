@@ -0,0 +1,344 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gshaderc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// capabilityLinkage is the capability modules with linkage declare
+const capabilityLinkage = 5
+
+// LinkOptions controls Link
+type LinkOptions struct {
+	// Library keeps the exported symbols of the result and lets imports go
+	// unresolved, so it can be linked again
+	Library bool
+}
+
+// linkSymbol is a function or variable with LinkageAttributes
+type linkSymbol struct {
+	ID   uint32
+	Type uint32
+}
+
+// linker holds what Link has learnt about the merged modules
+type linker struct {
+	options  LinkOptions
+	sections [spirvSections][]*spirvModuleInstruction
+	alias    map[uint32]uint32
+	removed  map[uint32]bool
+	types    map[uint32]uint32
+	imports  map[string][]linkSymbol
+	exports  map[string][]linkSymbol
+	problems []string
+}
+
+// Link merges modules into a single module. Functions and variables
+// decorated as LinkageAttributes imports are resolved to those exported
+// under the same name by the other modules, identical types and constants
+// are declared once and the ids are renumbered. Symbols which are never
+// exported, or are exported more than once, fail with LinkError.
+func Link(modules [][]byte, options LinkOptions) ([]byte, error) {
+	if len(modules) == 0 {
+		return nil, fmt.Errorf("no modules to link: %w", LinkError)
+	}
+	l := &linker{
+		options: options,
+		alias:   make(map[uint32]uint32),
+		removed: make(map[uint32]bool),
+		types:   make(map[uint32]uint32),
+		imports: make(map[string][]linkSymbol),
+		exports: make(map[string][]linkSymbol),
+	}
+	linked := &spirvModule{}
+	var base uint32
+	for i, data := range modules {
+		m, err := parseSPIRVModule(data)
+		if err != nil {
+			return nil, fmt.Errorf("module %d: %w", i, err)
+		}
+		if i == 0 {
+			linked.Generator = m.Generator
+		}
+		if m.Version > linked.Version {
+			linked.Version = m.Version
+		}
+		// Every module gets its own range of ids
+		offset := base
+		m.remap(func(id uint32) uint32 { return id + offset })
+		base += m.Bound
+		for s, section := range m.sections() {
+			l.sections[s] = append(l.sections[s], section...)
+		}
+	}
+
+	if err := l.mergeHeader(); err != nil {
+		return nil, err
+	}
+	l.findSymbols()
+	l.mergeTypes()
+	l.resolve()
+	if len(l.problems) > 0 {
+		sort.Strings(l.problems)
+		return nil, fmt.Errorf("%s: %w", strings.Join(l.problems, ", "), LinkError)
+	}
+	l.remove()
+
+	linked.setSections(l.sections)
+	linked.remap(l.resolveID)
+	linked.compact()
+	return linked.bytes(), nil
+}
+
+// resolveID follows the aliases of an id
+func (l *linker) resolveID(id uint32) uint32 {
+	for {
+		a, ok := l.alias[id]
+		if !ok {
+			return id
+		}
+		id = a
+	}
+}
+
+// unique keeps the first of the instructions in a section with the same key,
+// the results of the others become aliases of its result
+func (l *linker) unique(section int, key func(inst *spirvModuleInstruction) string) {
+	seen := make(map[string]*spirvModuleInstruction)
+	var kept []*spirvModuleInstruction
+	for _, inst := range l.sections[section] {
+		k := key(inst)
+		if first, ok := seen[k]; ok {
+			if inst.Result != 0 {
+				l.alias[inst.result()] = first.result()
+			}
+			continue
+		}
+		seen[k] = inst
+		kept = append(kept, inst)
+	}
+	l.sections[section] = kept
+}
+
+// mergeHeader merges the capabilities, extensions, instruction set imports
+// and memory models of the modules
+func (l *linker) mergeHeader() error {
+	var capabilities []*spirvModuleInstruction
+	for _, inst := range l.sections[spirvCapabilities] {
+		if inst.Words[1] != capabilityLinkage || l.options.Library {
+			capabilities = append(capabilities, inst)
+		}
+	}
+	l.sections[spirvCapabilities] = capabilities
+	l.unique(spirvCapabilities, func(inst *spirvModuleInstruction) string {
+		return fmt.Sprint(inst.Words[1:])
+	})
+	l.unique(spirvExtensions, func(inst *spirvModuleInstruction) string {
+		return fmt.Sprint(inst.Words[1:])
+	})
+	l.unique(spirvExtInstImports, func(inst *spirvModuleInstruction) string {
+		return fmt.Sprint(inst.Words[2:])
+	})
+	l.unique(spirvMemoryModel, func(inst *spirvModuleInstruction) string {
+		return fmt.Sprint(inst.Words[1:])
+	})
+	if len(l.sections[spirvMemoryModel]) > 1 {
+		return fmt.Errorf("the modules have different memory models: %w", LinkError)
+	}
+	return nil
+}
+
+// findSymbols finds the imported and exported symbols, and checks the entry
+// points are unique
+func (l *linker) findSymbols() {
+	for _, inst := range l.sections[spirvGlobals] {
+		if inst.Opcode == opVariable {
+			l.types[inst.Words[2]] = inst.Words[1]
+		}
+	}
+	for _, inst := range l.sections[spirvFunctions] {
+		if inst.Opcode == opFunction {
+			l.types[inst.Words[2]] = inst.Words[4]
+		}
+	}
+	for _, inst := range l.sections[spirvAnnotations] {
+		if inst.Opcode != opDecorate || inst.Words[2] != decorationLinkage {
+			continue
+		}
+		name, n := spirvString(inst.Words[3:])
+		symbol := linkSymbol{ID: inst.Words[1], Type: l.types[inst.Words[1]]}
+		if inst.Words[3+n] == 0 {
+			l.exports[name] = append(l.exports[name], symbol)
+		} else {
+			l.imports[name] = append(l.imports[name], symbol)
+		}
+	}
+	for name, symbols := range l.exports {
+		if len(symbols) > 1 {
+			l.problems = append(l.problems, fmt.Sprintf("duplicate symbol '%s'", name))
+		}
+	}
+
+	entryPoints := make(map[string]bool)
+	for _, inst := range l.sections[spirvEntryPoints] {
+		name, _ := spirvString(inst.Words[3:])
+		key := fmt.Sprint(inst.Words[1], name)
+		if entryPoints[key] {
+			l.problems = append(l.problems, fmt.Sprintf("duplicate entry point '%s'", name))
+		}
+		entryPoints[key] = true
+	}
+}
+
+// mergeTypes declares identical types and constants, which also have the
+// same decorations, once
+func (l *linker) mergeTypes() {
+	decorations := make(map[uint32][]string)
+	for _, inst := range l.sections[spirvAnnotations] {
+		switch inst.Opcode {
+		case opGroupDecorate, opGroupMemberDecorate:
+			// Ids decorated through groups are never merged
+			for _, offset := range inst.IDs[1:] {
+				id := inst.Words[offset]
+				decorations[id] = append(decorations[id], fmt.Sprint("group ", inst.Words[1]))
+			}
+		default:
+			if target := spirvTarget(inst); target != 0 && !(inst.Opcode == opDecorate && inst.Words[2] == decorationLinkage) {
+				decorations[target] = append(decorations[target], fmt.Sprint(inst.Opcode, inst.Words[2:]))
+			}
+		}
+	}
+
+	// Forward declared pointers have to be defined after their declaration,
+	// so neither they nor the types using them are merged
+	forward := make(map[uint32]bool)
+	for _, inst := range l.sections[spirvGlobals] {
+		if inst.Opcode == opTypeForwardPointer {
+			forward[inst.Words[1]] = true
+		}
+	}
+	usesForward := func(inst *spirvModuleInstruction) bool {
+		for _, offset := range inst.IDs {
+			if forward[inst.Words[offset]] {
+				return true
+			}
+		}
+		return false
+	}
+
+	l.unique(spirvGlobals, func(inst *spirvModuleInstruction) string {
+		result := inst.result()
+		if !mergeable(inst.Opcode) || result == 0 || usesForward(inst) {
+			return fmt.Sprintf("%p", inst)
+		}
+		words := append([]uint32(nil), inst.Words...)
+		for _, offset := range inst.IDs {
+			if offset == inst.Result {
+				words[offset] = 0
+			} else {
+				words[offset] = l.resolveID(words[offset])
+			}
+		}
+		d := decorations[result]
+		sort.Strings(d)
+		return fmt.Sprint(words, d)
+	})
+	for id := range l.alias {
+		l.removed[id] = true
+	}
+}
+
+// mergeable returns true for the types and constants which can be merged
+func mergeable(opcode uint32) bool {
+	switch opcode {
+	case opTypeForwardPointer:
+		return false
+	case opConstantTrue, opConstantFalse, opConstant, opConstantComposite, opConstantSampler, opConstantNull:
+		return true
+	}
+	op, ok := spirvOpcodes[opcode]
+	return ok && strings.HasPrefix(op.Name, "OpType")
+}
+
+// resolve replaces the imported symbols with their exports
+func (l *linker) resolve() {
+	for name, imports := range l.imports {
+		exports := l.exports[name]
+		if len(exports) == 0 {
+			if !l.options.Library {
+				l.problems = append(l.problems, fmt.Sprintf("unresolved symbol '%s'", name))
+			}
+			continue
+		}
+		for _, symbol := range imports {
+			if l.resolveID(symbol.Type) != l.resolveID(exports[0].Type) {
+				l.problems = append(l.problems, fmt.Sprintf("symbol '%s' is imported with a different type", name))
+				continue
+			}
+			l.alias[symbol.ID] = exports[0].ID
+			l.removed[symbol.ID] = true
+		}
+	}
+}
+
+// remove drops the merged types and constants, the resolved imports and
+// their names and decorations
+func (l *linker) remove() {
+	var functions []*spirvModuleInstruction
+	var declarations []*spirvModuleInstruction
+	var function []*spirvModuleInstruction
+	declaration := true
+	for _, inst := range l.sections[spirvFunctions] {
+		function = append(function, inst)
+		if inst.Opcode == opLabel {
+			declaration = false
+		}
+		if inst.Opcode != opFunctionEnd {
+			continue
+		}
+		switch {
+		case l.removed[function[0].result()]:
+			for _, i := range function {
+				if id := i.result(); id != 0 {
+					l.removed[id] = true
+				}
+			}
+		case declaration:
+			declarations = append(declarations, function...)
+		default:
+			functions = append(functions, function...)
+		}
+		function, declaration = nil, true
+	}
+	// Function declarations come before the definitions
+	l.sections[spirvFunctions] = append(declarations, functions...)
+
+	for _, s := range []int{spirvDebugNames, spirvAnnotations, spirvGlobals} {
+		var kept []*spirvModuleInstruction
+		for _, inst := range l.sections[s] {
+			if l.removed[spirvTarget(inst)] || l.removed[inst.result()] {
+				continue
+			}
+			if inst.Opcode == opDecorate && inst.Words[2] == decorationLinkage && !l.options.Library {
+				continue
+			}
+			kept = append(kept, inst)
+		}
+		l.sections[s] = kept
+	}
+}
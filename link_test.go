@@ -0,0 +1,211 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gshaderc
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// linkageModule exports a function scaling a float by a private variable,
+// gain, whose type is float or, if intGain is set, int
+func linkageModule(intGain bool) []byte {
+	gainType := uint32(1)
+	if intGain {
+		gainType = 11
+	}
+	return testModule(
+		[]uint32{17, 1},
+		[]uint32{17, capabilityLinkage},
+		[]uint32{opMemoryModel, 0, 1},
+		cat([]uint32{opName, 5}, testString("scale")),
+		cat([]uint32{opDecorate, 5, decorationLinkage}, testString("scale"), []uint32{0}),
+		cat([]uint32{opDecorate, 6, decorationLinkage}, testString("gain"), []uint32{0}),
+		[]uint32{opTypeFloat, 1, 32},
+		[]uint32{opTypeInt, 11, 32, 1},
+		[]uint32{opTypeFunction, 2, 1, 1},
+		[]uint32{opTypePointer, 3, 6, gainType},
+		[]uint32{opConstant, 1, 4, 0x40000000},
+		[]uint32{opVariable, 3, 6, 6, 4},
+		[]uint32{opFunction, 1, 5, 0, 2},
+		[]uint32{55, 1, 7}, // OpFunctionParameter
+		[]uint32{opLabel, 8},
+		[]uint32{61, 1, 9, 6},      // OpLoad
+		[]uint32{133, 1, 10, 7, 9}, // OpFMul
+		[]uint32{254, 10},          // OpReturnValue
+		[]uint32{opFunctionEnd},
+	)
+}
+
+// importingModule is a fragment shader calling scale and writing gain, both
+// imported
+func importingModule() []byte {
+	return testModule(
+		[]uint32{17, 1},
+		[]uint32{17, capabilityLinkage},
+		[]uint32{opMemoryModel, 0, 1},
+		cat([]uint32{opEntryPoint, 4, 12}, testString("main"), []uint32{6}),
+		[]uint32{opExecutionMode, 12, 7},
+		cat([]uint32{opName, 12}, testString("main")),
+		cat([]uint32{opName, 6}, testString("color")),
+		[]uint32{opDecorate, 6, decorationLocation, 0},
+		cat([]uint32{opDecorate, 10, decorationLinkage}, testString("scale"), []uint32{1}),
+		cat([]uint32{opDecorate, 8, decorationLinkage}, testString("gain"), []uint32{1}),
+		[]uint32{opTypeVoid, 1},
+		[]uint32{opTypeFloat, 2, 32},
+		[]uint32{opTypeFunction, 3, 1},
+		[]uint32{opTypeFunction, 4, 2, 2},
+		[]uint32{opTypePointer, 5, storageOutput, 2},
+		[]uint32{opVariable, 5, 6, storageOutput},
+		[]uint32{opTypePointer, 7, 6, 2},
+		[]uint32{opVariable, 7, 8, 6},
+		[]uint32{opConstant, 2, 9, 0x40000000},
+		[]uint32{opFunction, 2, 10, 0, 4},
+		[]uint32{55, 2, 11},
+		[]uint32{opFunctionEnd},
+		[]uint32{opFunction, 1, 12, 0, 3},
+		[]uint32{opLabel, 13},
+		[]uint32{57, 2, 14, 10, 9}, // OpFunctionCall
+		[]uint32{62, 6, 14},        // OpStore
+		[]uint32{62, 8, 14},
+		[]uint32{253}, // OpReturn
+		[]uint32{opFunctionEnd},
+	)
+}
+
+func TestLink(t *testing.T) {
+	linked, err := Link([][]byte{importingModule(), linkageModule(false)}, LinkOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := Disassemble(linked, DisassemblyOptions{NoHeader: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `               OpCapability Shader
+               OpMemoryModel Logical GLSL450
+               OpEntryPoint Fragment %main "main" %color
+               OpExecutionMode %main OriginUpperLeft
+               OpName %main "main"
+               OpName %color "color"
+               OpName %scale "scale"
+               OpDecorate %color Location 0
+       %void = OpTypeVoid
+      %float = OpTypeFloat 32
+          %6 = OpTypeFunction %void
+          %7 = OpTypeFunction %float %float
+%_ptr_Output_float = OpTypePointer Output %float
+      %color = OpVariable %_ptr_Output_float Output
+%_ptr_Private_float = OpTypePointer Private %float
+    %float_2 = OpConstant %float 2
+        %int = OpTypeInt 32 1
+         %12 = OpVariable %_ptr_Private_float Private %float_2
+       %main = OpFunction %void None %6
+         %13 = OpLabel
+         %14 = OpFunctionCall %float %scale %float_2
+               OpStore %color %14
+               OpStore %12 %14
+               OpReturn
+               OpFunctionEnd
+      %scale = OpFunction %float None %7
+         %15 = OpFunctionParameter %float
+         %16 = OpLabel
+         %17 = OpLoad %float %12
+         %18 = OpFMul %float %15 %17
+               OpReturnValue %18
+               OpFunctionEnd
+`
+	if text != expected {
+		t.Fatalf("unexpected module:\n%s", text)
+	}
+}
+
+func TestLinkLibrary(t *testing.T) {
+	linked, err := Link([][]byte{importingModule()}, LinkOptions{Library: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := Disassemble(linked, DisassemblyOptions{NoHeader: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"OpCapability Linkage",
+		`LinkageAttributes "gain" Import`,
+		`LinkageAttributes "scale" Import`,
+	} {
+		if !strings.Contains(text, line) {
+			t.Fatalf("expected '%s' in\n%s", line, text)
+		}
+	}
+}
+
+func TestLinkForwardPointers(t *testing.T) {
+	// A struct holding a pointer to another struct through a forward
+	// declaration
+	module := testModule(
+		[]uint32{17, 1},
+		[]uint32{17, 5347},                      // OpCapability PhysicalStorageBufferAddresses
+		[]uint32{opMemoryModel, 5348, 1},        // PhysicalStorageBuffer64 GLSL450
+		[]uint32{opTypeForwardPointer, 3, 5349}, // PhysicalStorageBuffer
+		[]uint32{opTypeFloat, 1, 32},
+		[]uint32{opTypeStruct, 2, 1},
+		[]uint32{opTypePointer, 3, 5349, 2},
+		[]uint32{opTypeStruct, 4, 3},
+	)
+	linked, err := Link([][]byte{module, module}, LinkOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := parseSPIRVModule(linked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defined := make(map[uint32]bool)
+	pointers := 0
+	for _, inst := range m.Instructions {
+		switch inst.Opcode {
+		case opTypeForwardPointer:
+			if defined[inst.Words[1]] {
+				t.Fatalf("pointer %d is defined before its forward declaration", inst.Words[1])
+			}
+		case opTypePointer:
+			pointers++
+		}
+		defined[inst.result()] = true
+	}
+	if pointers != 2 {
+		t.Fatalf("expected both forward declared pointers to be kept, got %d", pointers)
+	}
+}
+
+func TestLinkErrors(t *testing.T) {
+	tests := []struct {
+		modules [][]byte
+		message string
+	}{
+		{[][]byte{importingModule()}, "unresolved symbol 'gain', unresolved symbol 'scale'"},
+		{[][]byte{importingModule(), linkageModule(false), linkageModule(false)}, "duplicate symbol 'gain', duplicate symbol 'scale'"},
+		{[][]byte{importingModule(), linkageModule(true)}, "symbol 'gain' is imported with a different type"},
+		{[][]byte{importingModule(), importingModule(), linkageModule(false)}, "duplicate entry point 'main'"},
+	}
+	for _, test := range tests {
+		_, err := Link(test.modules, LinkOptions{})
+		if !errors.Is(err, LinkError) || !strings.HasPrefix(err.Error(), test.message+":") {
+			t.Errorf("expected '%s', got %v", test.message, err)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pack reads and writes shader packs, single files holding many
+// compiled shaders and their variants, reflection and source hashes.
+//
+// A pack starts with an 8 byte header, "GSPK" and the format version, and is
+// followed by the SPIR-V of every entry, each at an 8 byte aligned offset so
+// a pack mapped into memory can be used in place. The index comes after the
+// blobs and a 32 byte trailer at the very end says where the index is, so
+// packs can be written in a single pass and read with only an io.ReaderAt.
+// The index and every blob are protected by CRC-32C checksums. All integers
+// are little endian.
+//
+//	p, err := pack.Open("shaders.gspk")
+//	if err != nil {
+//		return err
+//	}
+//	defer p.Close()
+//	e, ok := p.Lookup("pbr.frag", "FOG")
+//	if !ok {
+//		return fmt.Errorf("no pbr.frag [FOG] in the pack")
+//	}
+//	spirv, err := p.SPIRV(e)
+package pack
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+
+	gs "github.com/celer/gshaderc"
+)
+
+// Magic starts and ends every pack
+const Magic = "GSPK"
+
+// Version is the version of the format written by Writer
+const Version = 1
+
+const (
+	headerSize  = 8
+	trailerSize = 32
+	alignment   = 8
+)
+
+// ErrCorrupt is returned when a pack fails its integrity checks
+var ErrCorrupt = errors.New("corrupt shader pack")
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Compression is how the SPIR-V of an entry is stored
+type Compression uint8
+
+const (
+	CompressionNone Compression = iota
+	CompressionDeflate
+)
+
+var compressionNames = []string{"none", "deflate"}
+
+func (c Compression) String() string {
+	if int(c) < len(compressionNames) {
+		return compressionNames[c]
+	}
+	return fmt.Sprintf("Compression(%d)", c)
+}
+
+// ParseCompression returns the compression with the given name
+func ParseCompression(name string) (Compression, error) {
+	for i, n := range compressionNames {
+		if n == name {
+			return Compression(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown compression '%s'", name)
+}
+
+// Entry is a shader, or a variant of one, in a pack
+type Entry struct {
+	Name string
+	// Variant is the key of the variant's macros, empty for shaders without
+	// variants
+	Variant string
+	Stage   gs.ShaderType
+	// SourceHash identifies the source and includes the entry was compiled
+	// from
+	SourceHash string
+
+	// The following are filled in by Writer.Add
+
+	Compression Compression
+	// Size is the size of the SPIR-V, StoredSize is the size it takes up
+	// in the pack
+	Size       uint64
+	StoredSize uint64
+	Offset     uint64
+	// Checksum is the CRC-32C of the stored bytes
+	Checksum uint32
+
+	reflection []byte
+}
+
+// Reflection decodes the reflection data stored with the entry, it is nil
+// if the entry was added without any
+func (e *Entry) Reflection() (*gs.Reflection, error) {
+	if len(e.reflection) == 0 {
+		return nil, nil
+	}
+	r := &gs.Reflection{}
+	if err := json.Unmarshal(e.reflection, r); err != nil {
+		return nil, fmt.Errorf("reflection of '%s': %w", e.Name, err)
+	}
+	return r, nil
+}
+
+func (e *Entry) less(name, variant string) bool {
+	if e.Name != name {
+		return e.Name < name
+	}
+	return e.Variant < variant
+}
+
+// Writer writes a pack in a single pass
+type Writer struct {
+	// Compression is used for the entries added after it's set, entries
+	// which don't get smaller are stored uncompressed
+	Compression Compression
+
+	w       io.Writer
+	offset  uint64
+	entries []*Entry
+	seen    map[[2]string]bool
+	err     error
+}
+
+// NewWriter creates a writer, the pack is complete once Close is called
+func NewWriter(w io.Writer) *Writer {
+	pw := &Writer{w: w, seen: make(map[[2]string]bool)}
+	header := make([]byte, headerSize)
+	copy(header, Magic)
+	binary.LittleEndian.PutUint32(header[4:], Version)
+	pw.write(header)
+	return pw
+}
+
+func (w *Writer) write(data []byte) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = w.w.Write(data)
+	w.offset += uint64(len(data))
+}
+
+// Add adds the SPIR-V of a shader and, if it's not nil, its reflection.
+// The name and variant of every entry must be unique.
+func (w *Writer) Add(e Entry, spirv []byte, reflection *gs.Reflection) error {
+	key := [2]string{e.Name, e.Variant}
+	if w.seen[key] {
+		return fmt.Errorf("duplicate entry '%s' [%s]", e.Name, e.Variant)
+	}
+	for _, s := range []string{e.Name, e.Variant, e.SourceHash} {
+		if len(s) > 0xffff {
+			return fmt.Errorf("'%.32s...' is too long for a shader pack", s)
+		}
+	}
+	if reflection != nil {
+		data, err := json.Marshal(reflection)
+		if err != nil {
+			return err
+		}
+		e.reflection = data
+	}
+
+	stored := spirv
+	e.Compression = CompressionNone
+	if w.Compression == CompressionDeflate {
+		var buf bytes.Buffer
+		fw, err := flate.NewWriter(&buf, flate.BestCompression)
+		if err != nil {
+			return err
+		}
+		fw.Write(spirv)
+		if err := fw.Close(); err != nil {
+			return err
+		}
+		if buf.Len() < len(spirv) {
+			stored = buf.Bytes()
+			e.Compression = CompressionDeflate
+		}
+	}
+
+	if pad := w.offset % alignment; pad != 0 {
+		w.write(make([]byte, alignment-pad))
+	}
+	e.Offset = w.offset
+	e.Size = uint64(len(spirv))
+	e.StoredSize = uint64(len(stored))
+	e.Checksum = crc32.Checksum(stored, crcTable)
+	w.write(stored)
+	if w.err != nil {
+		return w.err
+	}
+	w.seen[key] = true
+	w.entries = append(w.entries, &e)
+	return nil
+}
+
+// Close writes the index and trailer, it doesn't close the underlying
+// writer
+func (w *Writer) Close() error {
+	sort.Slice(w.entries, func(i, j int) bool {
+		return w.entries[i].less(w.entries[j].Name, w.entries[j].Variant)
+	})
+	var index bytes.Buffer
+	binary.Write(&index, binary.LittleEndian, uint32(len(w.entries)))
+	for _, e := range w.entries {
+		writeString(&index, e.Name)
+		writeString(&index, e.Variant)
+		writeString(&index, e.SourceHash)
+		binary.Write(&index, binary.LittleEndian, struct {
+			Stage       uint32
+			Compression uint32
+			Offset      uint64
+			StoredSize  uint64
+			Size        uint64
+			Checksum    uint32
+			Reflection  uint32
+		}{uint32(e.Stage), uint32(e.Compression), e.Offset, e.StoredSize, e.Size, e.Checksum, uint32(len(e.reflection))})
+		index.Write(e.reflection)
+	}
+
+	if pad := w.offset % alignment; pad != 0 {
+		w.write(make([]byte, alignment-pad))
+	}
+	trailer := make([]byte, trailerSize)
+	binary.LittleEndian.PutUint64(trailer[0:], w.offset)
+	binary.LittleEndian.PutUint64(trailer[8:], uint64(index.Len()))
+	binary.LittleEndian.PutUint32(trailer[16:], crc32.Checksum(index.Bytes(), crcTable))
+	binary.LittleEndian.PutUint32(trailer[20:], uint32(len(w.entries)))
+	binary.LittleEndian.PutUint32(trailer[24:], Version)
+	copy(trailer[28:], Magic)
+	w.write(index.Bytes())
+	w.write(trailer)
+	return w.err
+}
+
+// writeString writes a string prefixed with its 16 bit length
+func writeString(b *bytes.Buffer, s string) {
+	binary.Write(b, binary.LittleEndian, uint16(len(s)))
+	b.WriteString(s)
+}
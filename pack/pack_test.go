@@ -0,0 +1,174 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pack
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	gs "github.com/celer/gshaderc"
+)
+
+func testSPIRV(n int) []byte {
+	words := []uint32{gs.SPIRVMagic, 0x10000, 0, 1, 0}
+	for i := 0; i < n; i++ {
+		words = append(words, 2<<16|17, 1)
+	}
+	return gs.SPIRVBytes(words)
+}
+
+func writeTestPack(t *testing.T) ([]byte, map[[2]string][]byte) {
+	contents := map[[2]string][]byte{
+		{"pbr.frag", ""}:       testSPIRV(1),
+		{"pbr.frag", "FOG"}:    testSPIRV(2),
+		{"post/blur.comp", ""}: testSPIRV(500),
+	}
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, key := range [][2]string{{"post/blur.comp", ""}, {"pbr.frag", "FOG"}, {"pbr.frag", ""}} {
+		e := Entry{Name: key[0], Variant: key[1], Stage: gs.GetShaderTypeByFilename(key[0]), SourceHash: "hash-" + key[0]}
+		w.Compression = CompressionNone
+		if key[0] == "post/blur.comp" {
+			w.Compression = CompressionDeflate
+		}
+		var r *gs.Reflection
+		if key[1] == "FOG" {
+			r = &gs.Reflection{Bindings: []gs.DescriptorBinding{{Name: "fog", Binding: 2, Kind: gs.DescriptorUniformBuffer, Count: 1}}}
+		}
+		if err := w.Add(e, contents[key], r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Add(Entry{Name: "pbr.frag"}, testSPIRV(1), nil); err == nil {
+		t.Fatal("expected a duplicate entry to fail")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), contents
+}
+
+func TestPack(t *testing.T) {
+	data, contents := writeTestPack(t)
+
+	dir, err := ioutil.TempDir("", "pack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "shaders.gspk")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	fromFile, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fromFile.Close()
+	fromBytes, err := NewReaderBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []*Reader{fromFile, fromBytes} {
+		if err := p.Verify(); err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, e := range p.Entries() {
+			names = append(names, e.Name+"["+e.Variant+"]")
+		}
+		if !reflect.DeepEqual(names, []string{"pbr.frag[]", "pbr.frag[FOG]", "post/blur.comp[]"}) {
+			t.Fatalf("unexpected entries %v", names)
+		}
+		for key, want := range contents {
+			e, ok := p.Lookup(key[0], key[1])
+			if !ok {
+				t.Fatalf("missing %v", key)
+			}
+			if e.Stage != gs.GetShaderTypeByFilename(key[0]) || e.SourceHash != "hash-"+key[0] || e.Offset%alignment != 0 {
+				t.Fatalf("unexpected entry %+v", e)
+			}
+			got, err := p.SPIRV(e)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("%v: unexpected SPIR-V", key)
+			}
+		}
+		if _, ok := p.Lookup("pbr.frag", "NOPE"); ok {
+			t.Fatal("expected a missing variant not to be found")
+		}
+	}
+
+	blur, _ := fromBytes.Lookup("post/blur.comp", "")
+	if blur.Compression != CompressionDeflate || blur.StoredSize >= blur.Size {
+		t.Fatalf("expected the large module to be compressed, got %+v", blur)
+	}
+	frag, _ := fromBytes.Lookup("pbr.frag", "")
+	if frag.Compression != CompressionNone {
+		t.Fatal("expected the module to be stored uncompressed")
+	}
+	spirv, _ := fromBytes.SPIRV(frag)
+	if &spirv[0] != &data[frag.Offset] {
+		t.Fatal("expected SPIR-V to be a slice of the pack")
+	}
+
+	fog, _ := fromBytes.Lookup("pbr.frag", "FOG")
+	r, err := fog.Reflection()
+	if err != nil || len(r.Bindings) != 1 || r.Bindings[0].Name != "fog" {
+		t.Fatalf("unexpected reflection %+v %v", r, err)
+	}
+	if r, err := frag.Reflection(); r != nil || err != nil {
+		t.Fatal("expected no reflection")
+	}
+}
+
+func TestPackCorrupt(t *testing.T) {
+	data, _ := writeTestPack(t)
+	p, err := NewReaderBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frag, _ := p.Lookup("pbr.frag", "")
+
+	blob := append([]byte(nil), data...)
+	blob[frag.Offset+20] ^= 1
+	p, err = NewReaderBytes(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.SPIRV(frag); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("expected a corrupt blob to be detected, got %v", err)
+	}
+	if err := p.Verify(); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("expected Verify to detect a corrupt blob, got %v", err)
+	}
+
+	index := append([]byte(nil), data...)
+	index[len(index)-trailerSize-1] ^= 1
+	if _, err := NewReaderBytes(index); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("expected a corrupt index to be detected, got %v", err)
+	}
+	if _, err := NewReaderBytes(data[:len(data)-1]); err == nil {
+		t.Fatal("expected a truncated pack to fail")
+	}
+}
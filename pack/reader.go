@@ -0,0 +1,281 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pack
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	gs "github.com/celer/gshaderc"
+)
+
+// Reader reads a pack. Readers are safe for concurrent use.
+type Reader struct {
+	r    io.ReaderAt
+	data []byte
+	size int64
+	// indexOffset is where the blobs end
+	indexOffset uint64
+	entries     []Entry
+	closer      io.Closer
+}
+
+// Open opens the pack in a file, reading entries from it as they're needed
+func Open(path string) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r, err := NewReader(f, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("couldn't open pack '%s': %w", path, err)
+	}
+	r.closer = f
+	return r, nil
+}
+
+// NewReaderBytes reads a pack held in memory, e.g. mapped from a file or
+// embedded in the binary. SPIRV returns slices of data for entries which
+// aren't compressed.
+func NewReaderBytes(data []byte) (*Reader, error) {
+	r, err := NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	r.data = data
+	return r, nil
+}
+
+// NewReader reads a pack of the given size from r. Only the index is read
+// up front.
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	if size < headerSize+trailerSize {
+		return nil, fmt.Errorf("%d bytes is too small: %w", size, ErrCorrupt)
+	}
+	header := make([]byte, headerSize)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, err
+	}
+	trailer := make([]byte, trailerSize)
+	if _, err := r.ReadAt(trailer, size-trailerSize); err != nil {
+		return nil, err
+	}
+	if string(header[:4]) != Magic || string(trailer[28:]) != Magic {
+		return nil, fmt.Errorf("not a shader pack")
+	}
+	if v := binary.LittleEndian.Uint32(header[4:]); v != Version || binary.LittleEndian.Uint32(trailer[24:]) != v {
+		return nil, fmt.Errorf("unsupported shader pack version %d", v)
+	}
+
+	indexOffset := binary.LittleEndian.Uint64(trailer[0:])
+	indexSize := binary.LittleEndian.Uint64(trailer[8:])
+	end := uint64(size - trailerSize)
+	if indexOffset < headerSize || indexOffset > end || indexSize != end-indexOffset {
+		return nil, fmt.Errorf("index out of bounds: %w", ErrCorrupt)
+	}
+	index := make([]byte, indexSize)
+	if _, err := r.ReadAt(index, int64(indexOffset)); err != nil {
+		return nil, err
+	}
+	if crc32.Checksum(index, crcTable) != binary.LittleEndian.Uint32(trailer[16:]) {
+		return nil, fmt.Errorf("index checksum mismatch: %w", ErrCorrupt)
+	}
+
+	p := &Reader{r: r, size: size, indexOffset: indexOffset}
+	entries, err := p.parseIndex(index)
+	if err != nil {
+		return nil, err
+	}
+	if uint32(len(entries)) != binary.LittleEndian.Uint32(trailer[20:]) {
+		return nil, fmt.Errorf("index has %d entries, the trailer says %d: %w", len(entries), binary.LittleEndian.Uint32(trailer[20:]), ErrCorrupt)
+	}
+	p.entries = entries
+	return p, nil
+}
+
+// indexReader decodes the index, remembering the first error
+type indexReader struct {
+	data []byte
+	err  error
+}
+
+func (r *indexReader) bytes(n uint64) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > uint64(len(r.data)) {
+		r.err = fmt.Errorf("index truncated: %w", ErrCorrupt)
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *indexReader) uint16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *indexReader) uint32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *indexReader) uint64() uint64 {
+	if b := r.bytes(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *indexReader) string() string {
+	return string(r.bytes(uint64(r.uint16())))
+}
+
+func (p *Reader) parseIndex(data []byte) ([]Entry, error) {
+	r := &indexReader{data: data}
+	count := r.uint32()
+	// Every entry takes at least 46 bytes, which bounds the allocation
+	if uint64(count)*46 > uint64(len(data)) {
+		return nil, fmt.Errorf("index too small for %d entries: %w", count, ErrCorrupt)
+	}
+	entries := make([]Entry, count)
+	for i := range entries {
+		e := &entries[i]
+		e.Name = r.string()
+		e.Variant = r.string()
+		e.SourceHash = r.string()
+		e.Stage = gs.ShaderType(r.uint32())
+		e.Compression = Compression(r.uint32())
+		e.Offset = r.uint64()
+		e.StoredSize = r.uint64()
+		e.Size = r.uint64()
+		e.Checksum = r.uint32()
+		e.reflection = r.bytes(uint64(r.uint32()))
+		if r.err != nil {
+			return nil, r.err
+		}
+		if e.Offset < headerSize || e.Offset > p.indexOffset || e.StoredSize > p.indexOffset-e.Offset {
+			return nil, fmt.Errorf("entry '%s' out of bounds: %w", e.Name, ErrCorrupt)
+		}
+		if e.Compression > CompressionDeflate {
+			return nil, fmt.Errorf("entry '%s' has unknown compression %d: %w", e.Name, e.Compression, ErrCorrupt)
+		}
+		if e.Compression == CompressionNone && e.Size != e.StoredSize {
+			return nil, fmt.Errorf("entry '%s' has the wrong size: %w", e.Name, ErrCorrupt)
+		}
+		if i > 0 && !entries[i-1].less(e.Name, e.Variant) {
+			return nil, fmt.Errorf("index isn't sorted at '%s': %w", e.Name, ErrCorrupt)
+		}
+	}
+	if len(r.data) != 0 {
+		return nil, fmt.Errorf("%d bytes after the index: %w", len(r.data), ErrCorrupt)
+	}
+	return entries, nil
+}
+
+// Close closes the file of a pack opened with Open
+func (p *Reader) Close() error {
+	if p.closer != nil {
+		return p.closer.Close()
+	}
+	return nil
+}
+
+// Entries returns every entry, sorted by name and variant
+func (p *Reader) Entries() []Entry {
+	return p.entries
+}
+
+// Lookup finds an entry by name and variant key
+func (p *Reader) Lookup(name, variant string) (*Entry, bool) {
+	i := sort.Search(len(p.entries), func(i int) bool {
+		return !p.entries[i].less(name, variant)
+	})
+	if i < len(p.entries) && p.entries[i].Name == name && p.entries[i].Variant == variant {
+		return &p.entries[i], true
+	}
+	return nil, false
+}
+
+// SPIRV returns the SPIR-V of an entry after verifying its checksum. For
+// uncompressed entries of a pack read with NewReaderBytes it's a slice of
+// the pack's memory, which must not be modified.
+func (p *Reader) SPIRV(e *Entry) ([]byte, error) {
+	stored, err := p.stored(e)
+	if err != nil {
+		return nil, err
+	}
+	if e.Compression == CompressionNone {
+		return stored, nil
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(stored)), int64(e.Size)+1))
+	if err != nil {
+		return nil, fmt.Errorf("entry '%s': %v: %w", e.Name, err, ErrCorrupt)
+	}
+	if uint64(len(data)) != e.Size {
+		return nil, fmt.Errorf("entry '%s' decompressed to %d bytes instead of %d: %w", e.Name, len(data), e.Size, ErrCorrupt)
+	}
+	return data, nil
+}
+
+// stored returns the stored bytes of an entry after verifying them
+func (p *Reader) stored(e *Entry) ([]byte, error) {
+	var stored []byte
+	if p.data != nil {
+		stored = p.data[e.Offset : e.Offset+e.StoredSize]
+	} else {
+		stored = make([]byte, e.StoredSize)
+		if _, err := p.r.ReadAt(stored, int64(e.Offset)); err != nil {
+			return nil, err
+		}
+	}
+	if crc32.Checksum(stored, crcTable) != e.Checksum {
+		return nil, fmt.Errorf("entry '%s' [%s] checksum mismatch: %w", e.Name, e.Variant, ErrCorrupt)
+	}
+	return stored, nil
+}
+
+// Verify checks every entry of the pack, the index was already checked
+// when the pack was opened
+func (p *Reader) Verify() error {
+	for i := range p.entries {
+		if _, err := p.SPIRV(&p.entries[i]); err != nil {
+			return err
+		}
+		if _, err := p.entries[i].Reflection(); err != nil {
+			return fmt.Errorf("%v: %w", err, ErrCorrupt)
+		}
+	}
+	return nil
+}
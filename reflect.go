@@ -0,0 +1,473 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gshaderc
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DescriptorKind is the type of descriptor a binding needs
+type DescriptorKind string
+
+const (
+	DescriptorSampler               DescriptorKind = "sampler"
+	DescriptorCombinedImageSampler  DescriptorKind = "combined_image_sampler"
+	DescriptorSampledImage          DescriptorKind = "sampled_image"
+	DescriptorStorageImage          DescriptorKind = "storage_image"
+	DescriptorUniformTexelBuffer    DescriptorKind = "uniform_texel_buffer"
+	DescriptorStorageTexelBuffer    DescriptorKind = "storage_texel_buffer"
+	DescriptorUniformBuffer         DescriptorKind = "uniform_buffer"
+	DescriptorStorageBuffer         DescriptorKind = "storage_buffer"
+	DescriptorInputAttachment       DescriptorKind = "input_attachment"
+	DescriptorAccelerationStructure DescriptorKind = "acceleration_structure"
+)
+
+// Reflection describes the interface of a SPIR-V module, what a pipeline
+// needs to know to use it
+type Reflection struct {
+	EntryPoints []EntryPoint
+	// Inputs and Outputs are the user defined stage inputs and outputs,
+	// sorted by location, built-ins are left out
+	Inputs  []InterfaceVariable
+	Outputs []InterfaceVariable
+	// Bindings are sorted by set and binding
+	Bindings      []DescriptorBinding
+	PushConstants []PushConstantBlock
+}
+
+// EntryPoint is an entry point of a module
+type EntryPoint struct {
+	Name string
+	// Stage is InferFromSource for execution models gshaderc has no
+	// ShaderType for, such as ray tracing
+	Stage          ShaderType
+	ExecutionModel uint32
+	// LocalSize is the workgroup size of compute shaders
+	LocalSize [3]uint32
+}
+
+// InterfaceVariable is a stage input or output
+type InterfaceVariable struct {
+	Name     string
+	Location uint32
+}
+
+// DescriptorBinding is a resource bound through a descriptor set
+type DescriptorBinding struct {
+	Name    string
+	Set     uint32
+	Binding uint32
+	Kind    DescriptorKind
+	// Count is the number of descriptors, 1 unless the resource is an
+	// array and 0 for runtime sized arrays
+	Count uint32
+}
+
+// PushConstantBlock is a push constant block and its size in bytes
+type PushConstantBlock struct {
+	Name string
+	Size uint32
+}
+
+// SPIR-V opcodes, decorations and storage classes
+const (
+	opSourceContinued              = 2
+	opSource                       = 3
+	opSourceExtension              = 4
+	opName                         = 5
+	opMemberName                   = 6
+	opString                       = 7
+	opLine                         = 8
+	opExtension                    = 10
+	opExtInstImport                = 11
+	opExtInst                      = 12
+	opMemoryModel                  = 14
+	opEntryPoint                   = 15
+	opExecutionMode                = 16
+	opCapability                   = 17
+	opTypeVoid                     = 19
+	opTypeBool                     = 20
+	opTypeInt                      = 21
+	opTypeFloat                    = 22
+	opTypeVector                   = 23
+	opTypeMatrix                   = 24
+	opTypeImage                    = 25
+	opTypeSampler                  = 26
+	opTypeSampledImage             = 27
+	opTypeArray                    = 28
+	opTypeRuntimeArray             = 29
+	opTypeStruct                   = 30
+	opTypeOpaque                   = 31
+	opTypePointer                  = 32
+	opTypeFunction                 = 33
+	opTypeEvent                    = 34
+	opTypeDeviceEvent              = 35
+	opTypeReserveID                = 36
+	opTypeQueue                    = 37
+	opTypePipe                     = 38
+	opTypeForwardPointer           = 39
+	opConstantTrue                 = 41
+	opConstantFalse                = 42
+	opConstant                     = 43
+	opConstantComposite            = 44
+	opConstantSampler              = 45
+	opConstantNull                 = 46
+	opFunction                     = 54
+	opFunctionEnd                  = 56
+	opVariable                     = 59
+	opDecorate                     = 71
+	opMemberDecorate               = 72
+	opDecorationGroup              = 73
+	opGroupDecorate                = 74
+	opGroupMemberDecorate          = 75
+	opLabel                        = 248
+	opNoLine                       = 317
+	opTypePipeStorage              = 322
+	opTypeNamedBarrier             = 327
+	opModuleProcessed              = 330
+	opExecutionModeID              = 331
+	opDecorateID                   = 332
+	opTypeAccelerationStructureKHR = 5341
+	opDecorateString               = 5632
+	opMemberDecorateString         = 5633
+
+	decorationBlock         = 2
+	decorationBufferBlock   = 3
+	decorationArrayStride   = 6
+	decorationMatrixStride  = 7
+	decorationBuiltIn       = 11
+	decorationLocation      = 30
+	decorationBinding       = 33
+	decorationDescriptorSet = 34
+	decorationOffset        = 35
+	decorationLinkage       = 41
+
+	storageUniformConstant = 0
+	storageInput           = 1
+	storageUniform         = 2
+	storageOutput          = 3
+	storagePushConstant    = 9
+	storageStorageBuffer   = 12
+
+	executionModeLocalSize = 17
+	dimBuffer              = 5
+	dimSubpassData         = 6
+)
+
+// executionModelStages maps SPIR-V execution models to shader types
+var executionModelStages = map[uint32]ShaderType{
+	0: VertexShader,
+	1: TessControlShader,
+	2: TessEvaluationShader,
+	3: GeometryShader,
+	4: FragmentShader,
+	5: ComputeShader,
+}
+
+// reflector holds what Reflect has learnt about the ids of a module
+type reflector struct {
+	names         map[uint32]string
+	decorations   map[uint32]map[uint32]uint32
+	members       map[uint32]map[uint32]map[uint32]uint32
+	types         map[uint32][]uint32
+	constants     map[uint32]uint32
+	entryPointIDs map[uint32]int
+}
+
+// Reflect reads the entry points, stage interface, descriptor bindings and
+// push constants of a SPIR-V module
+func Reflect(spirv []byte) (*Reflection, error) {
+	words, err := SPIRVWords(spirv)
+	if err != nil {
+		return nil, err
+	}
+	instructions, err := spirvInstructions(words)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &reflector{
+		names:         make(map[uint32]string),
+		decorations:   make(map[uint32]map[uint32]uint32),
+		members:       make(map[uint32]map[uint32]map[uint32]uint32),
+		types:         make(map[uint32][]uint32),
+		constants:     make(map[uint32]uint32),
+		entryPointIDs: make(map[uint32]int),
+	}
+	reflection := &Reflection{}
+	type variable struct {
+		id, pointer, storage uint32
+	}
+	var variables []variable
+	var modes [][]uint32
+
+	for _, inst := range instructions {
+		w := inst.Words
+		if len(w) < 2 {
+			continue
+		}
+		switch inst.Opcode {
+		case opName:
+			if len(w) >= 3 {
+				r.names[w[1]], _ = spirvString(w[2:])
+			}
+		case opEntryPoint:
+			if len(w) >= 4 {
+				name, _ := spirvString(w[3:])
+				stage, ok := executionModelStages[w[1]]
+				if !ok {
+					stage = InferFromSource
+				}
+				r.entryPointIDs[w[2]] = len(reflection.EntryPoints)
+				reflection.EntryPoints = append(reflection.EntryPoints, EntryPoint{Name: name, Stage: stage, ExecutionModel: w[1]})
+			}
+		case opExecutionMode:
+			modes = append(modes, w)
+		case opDecorate:
+			if len(w) >= 3 {
+				if r.decorations[w[1]] == nil {
+					r.decorations[w[1]] = make(map[uint32]uint32)
+				}
+				value := uint32(0)
+				if len(w) >= 4 {
+					value = w[3]
+				}
+				r.decorations[w[1]][w[2]] = value
+			}
+		case opMemberDecorate:
+			if len(w) >= 4 {
+				if r.members[w[1]] == nil {
+					r.members[w[1]] = make(map[uint32]map[uint32]uint32)
+				}
+				if r.members[w[1]][w[2]] == nil {
+					r.members[w[1]][w[2]] = make(map[uint32]uint32)
+				}
+				value := uint32(0)
+				if len(w) >= 5 {
+					value = w[4]
+				}
+				r.members[w[1]][w[2]][w[3]] = value
+			}
+		case opTypeBool, opTypeInt, opTypeFloat, opTypeVector, opTypeMatrix, opTypeImage, opTypeSampler,
+			opTypeSampledImage, opTypeArray, opTypeRuntimeArray, opTypeStruct, opTypePointer, opTypeAccelerationStructureKHR:
+			r.types[w[1]] = w
+		case opConstant:
+			if len(w) >= 4 {
+				r.constants[w[2]] = w[3]
+			}
+		case opVariable:
+			if len(w) >= 4 {
+				variables = append(variables, variable{id: w[2], pointer: w[1], storage: w[3]})
+			}
+		}
+	}
+
+	for _, w := range modes {
+		i, ok := r.entryPointIDs[w[1]]
+		if ok && len(w) >= 6 && w[2] == executionModeLocalSize {
+			copy(reflection.EntryPoints[i].LocalSize[:], w[3:6])
+		}
+	}
+
+	for _, v := range variables {
+		pointer := r.types[v.pointer]
+		if len(pointer) < 4 || pointer[0]&0xffff != opTypePointer {
+			return nil, fmt.Errorf("variable %%%d doesn't have a pointer type: %w", v.id, InvalidSPIRVError)
+		}
+		typeID := pointer[3]
+		name := r.names[v.id]
+		if name == "" {
+			// Blocks without an instance name are named by their type
+			name = r.names[r.elementType(typeID)]
+		}
+		decorations := r.decorations[v.id]
+
+		switch v.storage {
+		case storageInput, storageOutput:
+			location, ok := decorations[decorationLocation]
+			if _, builtIn := decorations[decorationBuiltIn]; !ok || builtIn {
+				continue
+			}
+			iv := InterfaceVariable{Name: name, Location: location}
+			if v.storage == storageInput {
+				reflection.Inputs = append(reflection.Inputs, iv)
+			} else {
+				reflection.Outputs = append(reflection.Outputs, iv)
+			}
+		case storagePushConstant:
+			reflection.PushConstants = append(reflection.PushConstants, PushConstantBlock{Name: name, Size: r.size(typeID, 0)})
+		case storageUniformConstant, storageUniform, storageStorageBuffer:
+			if _, ok := decorations[decorationBinding]; !ok {
+				continue
+			}
+			kind := r.descriptorKind(typeID, v.storage)
+			if kind == "" {
+				continue
+			}
+			reflection.Bindings = append(reflection.Bindings, DescriptorBinding{
+				Name:    name,
+				Set:     decorations[decorationDescriptorSet],
+				Binding: decorations[decorationBinding],
+				Kind:    kind,
+				Count:   r.count(typeID),
+			})
+		}
+	}
+
+	sort.SliceStable(reflection.Inputs, func(i, j int) bool {
+		return reflection.Inputs[i].Location < reflection.Inputs[j].Location
+	})
+	sort.SliceStable(reflection.Outputs, func(i, j int) bool {
+		return reflection.Outputs[i].Location < reflection.Outputs[j].Location
+	})
+	sort.SliceStable(reflection.Bindings, func(i, j int) bool {
+		a, b := reflection.Bindings[i], reflection.Bindings[j]
+		if a.Set != b.Set {
+			return a.Set < b.Set
+		}
+		return a.Binding < b.Binding
+	})
+	return reflection, nil
+}
+
+// elementType strips arrays from a type
+func (r *reflector) elementType(id uint32) uint32 {
+	for {
+		t := r.types[id]
+		if len(t) < 3 || (t[0]&0xffff != opTypeArray && t[0]&0xffff != opTypeRuntimeArray) {
+			return id
+		}
+		id = t[2]
+	}
+}
+
+// count returns the number of descriptors in a possibly arrayed type, 0
+// for runtime sized arrays
+func (r *reflector) count(id uint32) uint32 {
+	count := uint32(1)
+	for {
+		t := r.types[id]
+		if len(t) < 3 {
+			return count
+		}
+		switch t[0] & 0xffff {
+		case opTypeArray:
+			if len(t) >= 4 {
+				count *= r.constants[t[3]]
+			}
+		case opTypeRuntimeArray:
+			return 0
+		default:
+			return count
+		}
+		id = t[2]
+	}
+}
+
+// descriptorKind returns the kind of descriptor a resource of the given
+// type and storage class needs, or "" if it isn't a descriptor
+func (r *reflector) descriptorKind(id, storage uint32) DescriptorKind {
+	id = r.elementType(id)
+	t := r.types[id]
+	if len(t) < 2 {
+		return ""
+	}
+	switch storage {
+	case storageStorageBuffer:
+		return DescriptorStorageBuffer
+	case storageUniform:
+		if _, ok := r.decorations[id][decorationBufferBlock]; ok {
+			return DescriptorStorageBuffer
+		}
+		return DescriptorUniformBuffer
+	}
+	switch t[0] & 0xffff {
+	case opTypeSampler:
+		return DescriptorSampler
+	case opTypeSampledImage:
+		return DescriptorCombinedImageSampler
+	case opTypeAccelerationStructureKHR:
+		return DescriptorAccelerationStructure
+	case opTypeImage:
+		if len(t) < 9 {
+			return ""
+		}
+		dim, sampled := t[3], t[7]
+		switch {
+		case dim == dimSubpassData:
+			return DescriptorInputAttachment
+		case dim == dimBuffer && sampled == 2:
+			return DescriptorStorageTexelBuffer
+		case dim == dimBuffer:
+			return DescriptorUniformTexelBuffer
+		case sampled == 2:
+			return DescriptorStorageImage
+		}
+		return DescriptorSampledImage
+	}
+	return ""
+}
+
+// size returns the size in bytes of a type laid out with its explicit
+// offsets and strides, matrixStride is the stride of a matrix member
+func (r *reflector) size(id, matrixStride uint32) uint32 {
+	t := r.types[id]
+	if len(t) < 2 {
+		return 0
+	}
+	switch t[0] & 0xffff {
+	case opTypeBool:
+		return 4
+	case opTypeInt, opTypeFloat:
+		if len(t) >= 3 {
+			return t[2] / 8
+		}
+	case opTypeVector:
+		if len(t) >= 4 {
+			return r.size(t[2], 0) * t[3]
+		}
+	case opTypeMatrix:
+		if len(t) >= 4 {
+			if matrixStride == 0 {
+				matrixStride = r.size(t[2], 0)
+			}
+			return matrixStride * t[3]
+		}
+	case opTypeArray:
+		if len(t) >= 4 {
+			stride, ok := r.decorations[id][decorationArrayStride]
+			if !ok {
+				stride = r.size(t[2], matrixStride)
+			}
+			return stride * r.constants[t[3]]
+		}
+	case opTypeStruct:
+		var size, end uint32
+		for i, member := range t[2:] {
+			decorations := r.members[id][uint32(i)]
+			offset, ok := decorations[decorationOffset]
+			if !ok {
+				offset = end
+			}
+			end = offset + r.size(member, decorations[decorationMatrixStride])
+			if end > size {
+				size = end
+			}
+		}
+		return size
+	}
+	return 0
+}
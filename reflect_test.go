@@ -0,0 +1,149 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gshaderc
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testModule assembles instructions, given as an opcode followed by its
+// operands, into a module
+func testModule(instructions ...[]uint32) []byte {
+	words := []uint32{SPIRVMagic, 0x10000, 0, 100, 0}
+	for _, inst := range instructions {
+		words = append(words, uint32(len(inst))<<16|inst[0])
+		words = append(words, inst[1:]...)
+	}
+	return SPIRVBytes(words)
+}
+
+// testString encodes a literal string
+func testString(s string) []uint32 {
+	b := append([]byte(s), make([]byte, 4-len(s)%4)...)
+	words := make([]uint32, len(b)/4)
+	for i := range words {
+		words[i] = uint32(b[i*4]) | uint32(b[i*4+1])<<8 | uint32(b[i*4+2])<<16 | uint32(b[i*4+3])<<24
+	}
+	return words
+}
+
+func TestReflect(t *testing.T) {
+	cat := func(parts ...[]uint32) []uint32 {
+		var w []uint32
+		for _, p := range parts {
+			w = append(w, p...)
+		}
+		return w
+	}
+	module := testModule(
+		[]uint32{17, 1}, // OpCapability Shader
+		cat([]uint32{opEntryPoint, 4, 1}, testString("main"), []uint32{20, 21}),
+		cat([]uint32{opEntryPoint, 5, 2}, testString("cull")),
+		[]uint32{opExecutionMode, 2, executionModeLocalSize, 8, 8, 1},
+		cat([]uint32{opName, 20}, testString("uv")),
+		cat([]uint32{opName, 21}, testString("color")),
+		cat([]uint32{opName, 30}, testString("Camera")),
+		cat([]uint32{opName, 33}, testString("textures")),
+		cat([]uint32{opName, 36}, testString("push")),
+		cat([]uint32{opName, 41}, testString("particles")),
+		[]uint32{opDecorate, 20, decorationLocation, 0},
+		[]uint32{opDecorate, 21, decorationLocation, 0},
+		[]uint32{opDecorate, 22, decorationBuiltIn, 15},
+		[]uint32{opDecorate, 30, decorationBlock},
+		[]uint32{opMemberDecorate, 30, 0, decorationOffset, 0},
+		[]uint32{opMemberDecorate, 30, 0, decorationMatrixStride, 16},
+		[]uint32{opMemberDecorate, 30, 1, decorationOffset, 64},
+		[]uint32{opDecorate, 32, decorationDescriptorSet, 0},
+		[]uint32{opDecorate, 32, decorationBinding, 1},
+		[]uint32{opDecorate, 33, decorationDescriptorSet, 1},
+		[]uint32{opDecorate, 33, decorationBinding, 0},
+		[]uint32{opDecorate, 35, decorationBlock},
+		[]uint32{opMemberDecorate, 35, 0, decorationOffset, 0},
+		[]uint32{opDecorate, 40, decorationBufferBlock},
+		[]uint32{opDecorate, 41, decorationDescriptorSet, 0},
+		[]uint32{opDecorate, 41, decorationBinding, 0},
+		[]uint32{opTypeFloat, 10, 32},
+		[]uint32{opTypeVector, 11, 10, 2},
+		[]uint32{opTypeVector, 12, 10, 4},
+		[]uint32{opTypeMatrix, 13, 12, 4},
+		[]uint32{opTypeInt, 14, 32, 0},
+		[]uint32{opConstant, 14, 15, 4},
+		[]uint32{opTypePointer, 16, storageInput, 11},
+		[]uint32{opTypePointer, 17, storageOutput, 12},
+		[]uint32{opVariable, 16, 20, storageInput},
+		[]uint32{opVariable, 17, 21, storageOutput},
+		[]uint32{opVariable, 17, 22, storageOutput},
+		[]uint32{opTypeStruct, 30, 13, 12},
+		[]uint32{opTypePointer, 31, storageUniform, 30},
+		[]uint32{opVariable, 31, 32, storageUniform},
+		[]uint32{opTypeImage, 23, 10, 1, 0, 0, 0, 1, 0},
+		[]uint32{opTypeSampledImage, 24, 23},
+		[]uint32{opTypeArray, 25, 24, 15},
+		[]uint32{opTypePointer, 26, storageUniformConstant, 25},
+		[]uint32{opVariable, 26, 33, storageUniformConstant},
+		[]uint32{opTypeStruct, 35, 10},
+		[]uint32{opTypePointer, 34, storagePushConstant, 35},
+		[]uint32{opVariable, 34, 36, storagePushConstant},
+		[]uint32{opTypeRuntimeArray, 37, 12},
+		[]uint32{opTypeStruct, 40, 37},
+		[]uint32{opTypePointer, 38, storageUniform, 40},
+		[]uint32{opVariable, 38, 41, storageUniform},
+	)
+
+	expected := &Reflection{
+		EntryPoints: []EntryPoint{
+			{Name: "main", Stage: FragmentShader, ExecutionModel: 4},
+			{Name: "cull", Stage: ComputeShader, ExecutionModel: 5, LocalSize: [3]uint32{8, 8, 1}},
+		},
+		Inputs:  []InterfaceVariable{{Name: "uv", Location: 0}},
+		Outputs: []InterfaceVariable{{Name: "color", Location: 0}},
+		Bindings: []DescriptorBinding{
+			{Name: "particles", Set: 0, Binding: 0, Kind: DescriptorStorageBuffer, Count: 1},
+			{Name: "Camera", Set: 0, Binding: 1, Kind: DescriptorUniformBuffer, Count: 1},
+			{Name: "textures", Set: 1, Binding: 0, Kind: DescriptorCombinedImageSampler, Count: 4},
+		},
+		PushConstants: []PushConstantBlock{{Name: "push", Size: 4}},
+	}
+	got, err := Reflect(module)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("unexpected reflection\n%+v\nexpected\n%+v", got, expected)
+	}
+
+	if _, err := Reflect(module[:len(module)-4]); err == nil {
+		t.Fatal("expected a truncated module to fail")
+	}
+}
+
+func TestReflectUniformSize(t *testing.T) {
+	r := &reflector{
+		types: map[uint32][]uint32{
+			1: {opTypeFloat, 1, 32},
+			2: {opTypeVector, 2, 1, 4},
+			3: {opTypeMatrix, 3, 2, 4},
+			4: {opTypeStruct, 4, 3, 2},
+		},
+		decorations: map[uint32]map[uint32]uint32{},
+		members: map[uint32]map[uint32]map[uint32]uint32{
+			4: {0: {decorationOffset: 0, decorationMatrixStride: 16}, 1: {decorationOffset: 64}},
+		},
+	}
+	if size := r.size(4, 0); size != 80 {
+		t.Fatalf("expected a mat4 and a vec4 to take 80 bytes, got %d", size)
+	}
+}
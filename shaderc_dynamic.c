@@ -0,0 +1,64 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build cgo && shaderc_dynamic
+// +build cgo,shaderc_dynamic
+
+#include <dlfcn.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+#include "shaderc_dynamic.h"
+
+#define GSHADERC_POINTER(ret, name, params, args) static ret(*p_##name) params;
+#define GSHADERC_VOID_POINTER(name, params, args) static void(*p_##name) params;
+GSHADERC_FUNCS(GSHADERC_POINTER)
+GSHADERC_VOID_FUNCS(GSHADERC_VOID_POINTER)
+
+#define GSHADERC_FORWARD(ret, name, params, args) \
+  ret name params { return p_##name args; }
+#define GSHADERC_VOID_FORWARD(name, params, args) \
+  void name params { p_##name args; }
+GSHADERC_FUNCS(GSHADERC_FORWARD)
+GSHADERC_VOID_FUNCS(GSHADERC_VOID_FORWARD)
+
+static char* copy_error(const char* prefix, const char* message) {
+  size_t n = strlen(prefix) + strlen(message) + 1;
+  char* error = malloc(n);
+  snprintf(error, n, "%s%s", prefix, message);
+  return error;
+}
+
+int gshaderc_load(const char* path, char** error) {
+  void* handle = dlopen(path, RTLD_NOW | RTLD_LOCAL);
+  if (handle == NULL) {
+    *error = copy_error("", dlerror());
+    return 0;
+  }
+
+#define GSHADERC_BIND(name)                             \
+  *(void**)(&p_##name) = dlsym(handle, #name);          \
+  if (p_##name == NULL) {                               \
+    *error = copy_error("missing symbol: ", #name);     \
+    dlclose(handle);                                    \
+    return 0;                                           \
+  }
+#define GSHADERC_BIND_FUNC(ret, name, params, args) GSHADERC_BIND(name)
+#define GSHADERC_BIND_VOID_FUNC(name, params, args) GSHADERC_BIND(name)
+  GSHADERC_FUNCS(GSHADERC_BIND_FUNC)
+  GSHADERC_VOID_FUNCS(GSHADERC_BIND_VOID_FUNC)
+
+  return 1;
+}
@@ -0,0 +1,82 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build cgo && shaderc_dynamic
+// +build cgo,shaderc_dynamic
+
+package gshaderc
+
+// #include <stdlib.h>
+// #include "shaderc_dynamic.h"
+import "C"
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// ShadercLibraryEnv names an environment variable which, when set, is the
+// path of the shaderc shared library to load
+const ShadercLibraryEnv = "GSHADERC_LIBSHADERC"
+
+// ShadercLibraryNames are the shared library names tried, in order, when
+// ShadercLibraryEnv isn't set. They are passed to dlopen so the usual
+// library search path applies.
+var ShadercLibraryNames = defaultShadercLibraryNames()
+
+func defaultShadercLibraryNames() []string {
+	if runtime.GOOS == "darwin" {
+		return []string{"libshaderc_shared.dylib", "libshaderc_shared.1.dylib"}
+	}
+	return []string{"libshaderc_shared.so", "libshaderc_shared.so.1"}
+}
+
+var (
+	shadercOnce sync.Once
+	shadercErr  error
+)
+
+func loadShaderc() error {
+	shadercOnce.Do(func() {
+		names := ShadercLibraryNames
+		if path := os.Getenv(ShadercLibraryEnv); path != "" {
+			names = []string{path}
+		}
+		var failures []string
+		for _, name := range names {
+			err := loadShadercLibrary(name)
+			if err == nil {
+				return
+			}
+			failures = append(failures, err.Error())
+		}
+		shadercErr = fmt.Errorf("%w: %s", ErrShadercUnavailable, strings.Join(failures, "; "))
+	})
+	return shadercErr
+}
+
+func loadShadercLibrary(path string) error {
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	var cerr *C.char
+	if C.gshaderc_load(cpath, &cerr) == 0 {
+		defer C.free(unsafe.Pointer(cerr))
+		return fmt.Errorf("%s", C.GoString(cerr))
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The subset of shaderc.h used by gshaderc, for builds which load
+// libshaderc_shared at runtime (the shaderc_dynamic build tag) and so
+// don't need the shaderc headers installed. Every function declared here
+// is defined in shaderc_dynamic.c and forwards to the loaded library.
+
+#ifndef GSHADERC_SHADERC_DYNAMIC_H
+#define GSHADERC_SHADERC_DYNAMIC_H
+
+#include <stdbool.h>
+#include <stddef.h>
+#include <stdint.h>
+
+typedef enum {
+  shaderc_vertex_shader,
+  shaderc_fragment_shader,
+  shaderc_compute_shader,
+  shaderc_geometry_shader,
+  shaderc_tess_control_shader,
+  shaderc_tess_evaluation_shader,
+  shaderc_glsl_infer_from_source,
+  shaderc_glsl_default_vertex_shader,
+  shaderc_glsl_default_fragment_shader,
+  shaderc_glsl_default_compute_shader,
+  shaderc_glsl_default_geometry_shader,
+  shaderc_glsl_default_tess_control_shader,
+  shaderc_glsl_default_tess_evaluation_shader,
+  shaderc_spirv_assembly,
+} shaderc_shader_kind;
+
+typedef enum {
+  shaderc_target_env_vulkan,
+  shaderc_target_env_opengl,
+  shaderc_target_env_opengl_compat,
+  shaderc_target_env_webgpu,
+} shaderc_target_env;
+
+typedef enum {
+  shaderc_spirv_version_1_0 = 0x010000u,
+  shaderc_spirv_version_1_1 = 0x010100u,
+  shaderc_spirv_version_1_2 = 0x010200u,
+  shaderc_spirv_version_1_3 = 0x010300u,
+  shaderc_spirv_version_1_4 = 0x010400u,
+  shaderc_spirv_version_1_5 = 0x010500u,
+} shaderc_spirv_version;
+
+typedef enum {
+  shaderc_optimization_level_zero,
+  shaderc_optimization_level_size,
+  shaderc_optimization_level_performance,
+} shaderc_optimization_level;
+
+typedef enum {
+  shaderc_uniform_kind_image,
+  shaderc_uniform_kind_sampler,
+  shaderc_uniform_kind_texture,
+  shaderc_uniform_kind_buffer,
+  shaderc_uniform_kind_storage_buffer,
+  shaderc_uniform_kind_unordered_access_view,
+} shaderc_uniform_kind;
+
+// Resource limits are passed through from ResourceLimit, which follows
+// the order of the real enum
+typedef int shaderc_limit;
+
+typedef enum {
+  shaderc_compilation_status_success = 0,
+  shaderc_compilation_status_invalid_stage = 1,
+  shaderc_compilation_status_compilation_error = 2,
+  shaderc_compilation_status_internal_error = 3,
+  shaderc_compilation_status_null_result_object = 4,
+  shaderc_compilation_status_invalid_assembly = 5,
+  shaderc_compilation_status_validation_error = 6,
+  shaderc_compilation_status_transformation_error = 7,
+  shaderc_compilation_status_configuration_error = 8,
+} shaderc_compilation_status;
+
+typedef struct shaderc_compiler* shaderc_compiler_t;
+typedef struct shaderc_compile_options* shaderc_compile_options_t;
+typedef struct shaderc_compilation_result* shaderc_compilation_result_t;
+
+typedef struct shaderc_include_result {
+  const char* source_name;
+  size_t source_name_length;
+  const char* content;
+  size_t content_length;
+  void* user_data;
+} shaderc_include_result;
+
+typedef shaderc_include_result* (*shaderc_include_resolve_fn)(
+    void* user_data, const char* requested_source, int type,
+    const char* requesting_source, size_t include_depth);
+
+typedef void (*shaderc_include_result_release_fn)(
+    void* user_data, shaderc_include_result* include_result);
+
+// GSHADERC_FUNCS lists every function which returns a value, as
+// X(return type, name, parameters, arguments)
+#define GSHADERC_FUNCS(X)                                                     \
+  X(shaderc_compiler_t, shaderc_compiler_initialize, (void), ())              \
+  X(shaderc_compile_options_t, shaderc_compile_options_initialize, (void), ()) \
+  X(shaderc_compilation_result_t, shaderc_compile_into_spv,                   \
+    (const shaderc_compiler_t c, const char* s, size_t n,                     \
+     shaderc_shader_kind k, const char* f, const char* e,                     \
+     const shaderc_compile_options_t o),                                      \
+    (c, s, n, k, f, e, o))                                                    \
+  X(shaderc_compilation_result_t, shaderc_compile_into_preprocessed_text,     \
+    (const shaderc_compiler_t c, const char* s, size_t n,                     \
+     shaderc_shader_kind k, const char* f, const char* e,                     \
+     const shaderc_compile_options_t o),                                      \
+    (c, s, n, k, f, e, o))                                                    \
+  X(shaderc_compilation_result_t, shaderc_compile_into_spv_assembly,          \
+    (const shaderc_compiler_t c, const char* s, size_t n,                     \
+     shaderc_shader_kind k, const char* f, const char* e,                     \
+     const shaderc_compile_options_t o),                                      \
+    (c, s, n, k, f, e, o))                                                    \
+  X(shaderc_compilation_result_t, shaderc_assemble_into_spv,                  \
+    (const shaderc_compiler_t c, const char* s, size_t n,                     \
+     const shaderc_compile_options_t o),                                      \
+    (c, s, n, o))                                                             \
+  X(size_t, shaderc_result_get_length,                                        \
+    (const shaderc_compilation_result_t r), (r))                              \
+  X(size_t, shaderc_result_get_num_warnings,                                  \
+    (const shaderc_compilation_result_t r), (r))                              \
+  X(size_t, shaderc_result_get_num_errors,                                    \
+    (const shaderc_compilation_result_t r), (r))                              \
+  X(shaderc_compilation_status, shaderc_result_get_compilation_status,        \
+    (const shaderc_compilation_result_t r), (r))                              \
+  X(const char*, shaderc_result_get_bytes,                                    \
+    (const shaderc_compilation_result_t r), (r))                              \
+  X(const char*, shaderc_result_get_error_message,                            \
+    (const shaderc_compilation_result_t r), (r))
+
+// GSHADERC_VOID_FUNCS lists every function which doesn't return a value,
+// as X(name, parameters, arguments)
+#define GSHADERC_VOID_FUNCS(X)                                                \
+  X(shaderc_compiler_release, (shaderc_compiler_t c), (c))                    \
+  X(shaderc_result_release, (shaderc_compilation_result_t r), (r))            \
+  X(shaderc_compile_options_release, (shaderc_compile_options_t o), (o))      \
+  X(shaderc_compile_options_add_macro_definition,                             \
+    (shaderc_compile_options_t o, const char* n, size_t nl, const char* v,    \
+     size_t vl),                                                              \
+    (o, n, nl, v, vl))                                                        \
+  X(shaderc_compile_options_set_optimization_level,                           \
+    (shaderc_compile_options_t o, shaderc_optimization_level l), (o, l))      \
+  X(shaderc_compile_options_set_include_callbacks,                            \
+    (shaderc_compile_options_t o, shaderc_include_resolve_fn r,               \
+     shaderc_include_result_release_fn f, void* u),                           \
+    (o, r, f, u))                                                             \
+  X(shaderc_compile_options_set_suppress_warnings,                            \
+    (shaderc_compile_options_t o), (o))                                       \
+  X(shaderc_compile_options_set_target_env,                                   \
+    (shaderc_compile_options_t o, shaderc_target_env t, uint32_t v),          \
+    (o, t, v))                                                                \
+  X(shaderc_compile_options_set_target_spirv,                                 \
+    (shaderc_compile_options_t o, shaderc_spirv_version v), (o, v))           \
+  X(shaderc_compile_options_set_warnings_as_errors,                           \
+    (shaderc_compile_options_t o), (o))                                       \
+  X(shaderc_compile_options_set_generate_debug_info,                          \
+    (shaderc_compile_options_t o), (o))                                       \
+  X(shaderc_compile_options_set_limit,                                        \
+    (shaderc_compile_options_t o, shaderc_limit l, int v), (o, l, v))         \
+  X(shaderc_compile_options_set_auto_bind_uniforms,                           \
+    (shaderc_compile_options_t o, bool b), (o, b))                            \
+  X(shaderc_compile_options_set_binding_base,                                 \
+    (shaderc_compile_options_t o, shaderc_uniform_kind k, uint32_t b),        \
+    (o, k, b))                                                                \
+  X(shaderc_compile_options_set_invert_y,                                     \
+    (shaderc_compile_options_t o, bool b), (o, b))                            \
+  X(shaderc_compile_options_set_nan_clamp,                                    \
+    (shaderc_compile_options_t o, bool b), (o, b))
+
+// The forwarding functions are hidden so they can never be confused with
+// the real ones exported by the library
+#define GSHADERC_DECLARE(ret, name, params, args) \
+  __attribute__((visibility("hidden"))) ret name params;
+#define GSHADERC_DECLARE_VOID(name, params, args) \
+  __attribute__((visibility("hidden"))) void name params;
+GSHADERC_FUNCS(GSHADERC_DECLARE)
+GSHADERC_VOID_FUNCS(GSHADERC_DECLARE_VOID)
+#undef GSHADERC_DECLARE
+#undef GSHADERC_DECLARE_VOID
+
+// gshaderc_load loads the library at path and binds every function above,
+// on failure it returns 0 and sets *error to a message owned by the caller
+__attribute__((visibility("hidden"))) int gshaderc_load(const char* path,
+                                                         char** error);
+
+#endif
@@ -0,0 +1,50 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build cgo && shaderc_dynamic
+// +build cgo,shaderc_dynamic
+
+package gshaderc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestShadercDynamic(t *testing.T) {
+	backend := NewShadercBackend()
+	defer backend.Release()
+
+	res := backend.Compile(&CompileJob{
+		Source:        "#version 450\nvoid main() {}",
+		ShaderType:    VertexShader,
+		InputFilename: "main.vert",
+		EntryPoint:    "main",
+		Options:       NewCompilerOptions(),
+	})
+
+	if err := LoadShaderc(); err != nil {
+		if !errors.Is(err, ErrShadercUnavailable) || !errors.Is(err, BackendUnavailableError) {
+			t.Fatalf("Expected ErrShadercUnavailable, got %v", err)
+		}
+		if res.Error() != ErrShadercUnavailable || res.ErrorMessage() == "" {
+			t.Fatal("Expected compilation to fail with ErrShadercUnavailable")
+		}
+		t.Skipf("libshaderc_shared not available: %v", err)
+	}
+
+	if res.Error() != nil || len(res.Bytes()) == 0 {
+		t.Fatalf("Didn't expect a compilation error: %s", res.ErrorMessage())
+	}
+}
@@ -0,0 +1,282 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gshaderc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// SPIRVMagic is the first word of every SPIR-V module
+const SPIRVMagic = 0x07230203
+
+// spirvHeaderWords is the number of words in the module header: magic,
+// version, generator, bound and schema
+const spirvHeaderWords = 5
+
+// spirvInstruction is a single instruction of a module, Words includes the
+// word holding the opcode and word count
+type spirvInstruction struct {
+	Opcode uint32
+	Words  []uint32
+}
+
+// SPIRVWords converts a module to words, accepting either byte order
+func SPIRVWords(data []byte) ([]uint32, error) {
+	if len(data) < spirvHeaderWords*4 || len(data)%4 != 0 {
+		return nil, fmt.Errorf("%d bytes isn't a whole SPIR-V module: %w", len(data), InvalidSPIRVError)
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if order.Uint32(data) != SPIRVMagic {
+		order = binary.BigEndian
+		if order.Uint32(data) != SPIRVMagic {
+			return nil, fmt.Errorf("bad magic number 0x%08x: %w", binary.LittleEndian.Uint32(data), InvalidSPIRVError)
+		}
+	}
+	words := make([]uint32, len(data)/4)
+	for i := range words {
+		words[i] = order.Uint32(data[i*4:])
+	}
+	return words, nil
+}
+
+// SPIRVBytes converts words to a little endian module
+func SPIRVBytes(words []uint32) []byte {
+	data := make([]byte, len(words)*4)
+	for i, w := range words {
+		binary.LittleEndian.PutUint32(data[i*4:], w)
+	}
+	return data
+}
+
+// spirvInstructions splits the words after the header into instructions
+func spirvInstructions(words []uint32) ([]spirvInstruction, error) {
+	if len(words) < spirvHeaderWords || words[0] != SPIRVMagic {
+		return nil, fmt.Errorf("missing header: %w", InvalidSPIRVError)
+	}
+	var instructions []spirvInstruction
+	for offset := spirvHeaderWords; offset < len(words); {
+		count := int(words[offset] >> 16)
+		if count == 0 || offset+count > len(words) {
+			return nil, fmt.Errorf("bad word count %d at word %d: %w", count, offset, InvalidSPIRVError)
+		}
+		instructions = append(instructions, spirvInstruction{Opcode: words[offset] & 0xffff, Words: words[offset : offset+count]})
+		offset += count
+	}
+	return instructions, nil
+}
+
+// spirvString decodes a nul terminated literal string, returning it and the
+// number of words it takes up
+func spirvString(words []uint32) (string, int) {
+	var s strings.Builder
+	for i, w := range words {
+		for j := 0; j < 4; j++ {
+			c := byte(w >> (8 * j))
+			if c == 0 {
+				return s.String(), i + 1
+			}
+			s.WriteByte(c)
+		}
+	}
+	return s.String(), len(words)
+}
+
+// spirvModule is a module split into instructions whose ids can be
+// rewritten, for the passes transforming modules
+type spirvModule struct {
+	Version      uint32
+	Generator    uint32
+	Bound        uint32
+	Instructions []*spirvModuleInstruction
+}
+
+// spirvModuleInstruction is an instruction with the offsets in Words of its
+// id operands, Result is the offset of its result id or 0 if it has none
+type spirvModuleInstruction struct {
+	spirvInstruction
+	IDs    []int
+	Result int
+}
+
+// result returns the result id of the instruction or 0
+func (i *spirvModuleInstruction) result() uint32 {
+	if i.Result == 0 {
+		return 0
+	}
+	return i.Words[i.Result]
+}
+
+// parseSPIRVModule splits a module into instructions and finds their ids
+func parseSPIRVModule(data []byte) (*spirvModule, error) {
+	words, err := SPIRVWords(data)
+	if err != nil {
+		return nil, err
+	}
+	instructions, err := spirvInstructions(words)
+	if err != nil {
+		return nil, err
+	}
+	m := &spirvModule{Version: words[1], Generator: words[2], Bound: words[3]}
+	p := newSPIRVParser()
+	sets := make(map[uint32]string)
+	for _, inst := range instructions {
+		operands, err := p.parse(inst)
+		if err != nil {
+			return nil, err
+		}
+		switch inst.Opcode {
+		case opExtInstImport:
+			sets[inst.Words[1]], _ = spirvString(inst.Words[2:])
+		case opExtInst:
+			// The operands are only known to be ids for some sets
+			if set := sets[inst.Words[3]]; !spirvIDOnlySet(set) {
+				return nil, fmt.Errorf("instructions of the '%s' instruction set can't be rewritten", set)
+			}
+		}
+		mi := &spirvModuleInstruction{spirvInstruction: inst}
+		offset := 1
+		for _, o := range operands {
+			if o.isID() {
+				mi.IDs = append(mi.IDs, offset)
+				if o.Kind == "IdResult" {
+					mi.Result = offset
+				}
+			}
+			offset += len(o.Words)
+		}
+		m.Instructions = append(m.Instructions, mi)
+	}
+	return m, nil
+}
+
+// spirvIDOnlySet returns true for the extended instruction sets whose
+// instructions only take ids
+func spirvIDOnlySet(name string) bool {
+	return name == "GLSL.std.450" || strings.HasPrefix(name, "NonSemantic.") || strings.HasPrefix(name, "SPV_AMD_")
+}
+
+// remap replaces every id in the module
+func (m *spirvModule) remap(f func(id uint32) uint32) {
+	for _, inst := range m.Instructions {
+		for _, offset := range inst.IDs {
+			inst.Words[offset] = f(inst.Words[offset])
+		}
+	}
+}
+
+// compact renumbers the ids from 1 in the order they first appear, leaving
+// the bound as low as it can be
+func (m *spirvModule) compact() {
+	ids := make(map[uint32]uint32)
+	m.remap(func(id uint32) uint32 {
+		n, ok := ids[id]
+		if !ok {
+			n = uint32(len(ids) + 1)
+			ids[id] = n
+		}
+		return n
+	})
+	m.Bound = uint32(len(ids) + 1)
+}
+
+// bytes encodes the module
+func (m *spirvModule) bytes() []byte {
+	words := []uint32{SPIRVMagic, m.Version, m.Generator, m.Bound, 0}
+	for _, inst := range m.Instructions {
+		words = append(words, inst.Words...)
+	}
+	return SPIRVBytes(words)
+}
+
+// The sections of a module, in the order they're laid out
+const (
+	spirvCapabilities = iota
+	spirvExtensions
+	spirvExtInstImports
+	spirvMemoryModel
+	spirvEntryPoints
+	spirvExecutionModes
+	spirvDebugSources
+	spirvDebugNames
+	spirvDebugProcesses
+	spirvAnnotations
+	spirvGlobals
+	spirvFunctions
+	spirvSections
+)
+
+// spirvSection returns the section an instruction outside of functions
+// belongs to
+func spirvSection(opcode uint32) int {
+	switch opcode {
+	case opCapability:
+		return spirvCapabilities
+	case opExtension:
+		return spirvExtensions
+	case opExtInstImport:
+		return spirvExtInstImports
+	case opMemoryModel:
+		return spirvMemoryModel
+	case opEntryPoint:
+		return spirvEntryPoints
+	case opExecutionMode, opExecutionModeID:
+		return spirvExecutionModes
+	case opString, opSource, opSourceContinued, opSourceExtension:
+		return spirvDebugSources
+	case opName, opMemberName:
+		return spirvDebugNames
+	case opModuleProcessed:
+		return spirvDebugProcesses
+	case opDecorate, opMemberDecorate, opDecorationGroup, opGroupDecorate, opGroupMemberDecorate,
+		opDecorateID, opDecorateString, opMemberDecorateString:
+		return spirvAnnotations
+	}
+	return spirvGlobals
+}
+
+// sections splits the instructions into the sections of the module
+func (m *spirvModule) sections() [spirvSections][]*spirvModuleInstruction {
+	var sections [spirvSections][]*spirvModuleInstruction
+	functions := false
+	for _, inst := range m.Instructions {
+		functions = functions || inst.Opcode == opFunction
+		section := spirvFunctions
+		if !functions {
+			section = spirvSection(inst.Opcode)
+		}
+		sections[section] = append(sections[section], inst)
+	}
+	return sections
+}
+
+// setSections replaces the instructions with those of the sections
+func (m *spirvModule) setSections(sections [spirvSections][]*spirvModuleInstruction) {
+	m.Instructions = nil
+	for _, section := range sections {
+		m.Instructions = append(m.Instructions, section...)
+	}
+}
+
+// spirvTarget returns the id debug names and decorations apply to, or 0 for
+// other instructions
+func spirvTarget(inst *spirvModuleInstruction) uint32 {
+	switch inst.Opcode {
+	case opName, opMemberName, opDecorate, opMemberDecorate, opDecorateID, opDecorateString, opMemberDecorateString:
+		return inst.Words[1]
+	}
+	return 0
+}
@@ -0,0 +1,522 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spirv
+
+// Op is a SPIR-V opcode. Only the instructions that go in function bodies
+// are listed, the rest are written by the methods of Module; any other opcode
+// can be converted from its number.
+type Op uint16
+
+const (
+	OpNop          Op = 0
+	OpUndef        Op = 1
+	OpExtInst      Op = 12
+	OpFunctionCall Op = 57
+
+	OpVariable            Op = 59
+	OpImageTexelPointer   Op = 60
+	OpLoad                Op = 61
+	OpStore               Op = 62
+	OpCopyMemory          Op = 63
+	OpAccessChain         Op = 65
+	OpInBoundsAccessChain Op = 66
+	OpPtrAccessChain      Op = 67
+	OpArrayLength         Op = 68
+
+	OpVectorExtractDynamic Op = 77
+	OpVectorInsertDynamic  Op = 78
+	OpVectorShuffle        Op = 79
+	OpCompositeConstruct   Op = 80
+	OpCompositeExtract     Op = 81
+	OpCompositeInsert      Op = 82
+	OpCopyObject           Op = 83
+	OpTranspose            Op = 84
+
+	OpSampledImage           Op = 86
+	OpImageSampleImplicitLod Op = 87
+	OpImageSampleExplicitLod Op = 88
+	OpImageFetch             Op = 95
+	OpImageGather            Op = 96
+	OpImageRead              Op = 98
+	OpImageWrite             Op = 99
+	OpImage                  Op = 100
+	OpImageQuerySizeLod      Op = 103
+	OpImageQuerySize         Op = 104
+
+	OpConvertFToU Op = 109
+	OpConvertFToS Op = 110
+	OpConvertSToF Op = 111
+	OpConvertUToF Op = 112
+	OpUConvert    Op = 113
+	OpSConvert    Op = 114
+	OpFConvert    Op = 115
+	OpBitcast     Op = 124
+
+	OpSNegate           Op = 126
+	OpFNegate           Op = 127
+	OpIAdd              Op = 128
+	OpFAdd              Op = 129
+	OpISub              Op = 130
+	OpFSub              Op = 131
+	OpIMul              Op = 132
+	OpFMul              Op = 133
+	OpUDiv              Op = 134
+	OpSDiv              Op = 135
+	OpFDiv              Op = 136
+	OpUMod              Op = 137
+	OpSRem              Op = 138
+	OpSMod              Op = 139
+	OpFRem              Op = 140
+	OpFMod              Op = 141
+	OpVectorTimesScalar Op = 142
+	OpMatrixTimesScalar Op = 143
+	OpVectorTimesMatrix Op = 144
+	OpMatrixTimesVector Op = 145
+	OpMatrixTimesMatrix Op = 146
+	OpOuterProduct      Op = 147
+	OpDot               Op = 148
+
+	OpAny                    Op = 154
+	OpAll                    Op = 155
+	OpIsNan                  Op = 156
+	OpIsInf                  Op = 157
+	OpLogicalEqual           Op = 164
+	OpLogicalNotEqual        Op = 165
+	OpLogicalOr              Op = 166
+	OpLogicalAnd             Op = 167
+	OpLogicalNot             Op = 168
+	OpSelect                 Op = 169
+	OpIEqual                 Op = 170
+	OpINotEqual              Op = 171
+	OpUGreaterThan           Op = 172
+	OpSGreaterThan           Op = 173
+	OpUGreaterThanEqual      Op = 174
+	OpSGreaterThanEqual      Op = 175
+	OpULessThan              Op = 176
+	OpSLessThan              Op = 177
+	OpULessThanEqual         Op = 178
+	OpSLessThanEqual         Op = 179
+	OpFOrdEqual              Op = 180
+	OpFUnordEqual            Op = 181
+	OpFOrdNotEqual           Op = 182
+	OpFUnordNotEqual         Op = 183
+	OpFOrdLessThan           Op = 184
+	OpFUnordLessThan         Op = 185
+	OpFOrdGreaterThan        Op = 186
+	OpFUnordGreaterThan      Op = 187
+	OpFOrdLessThanEqual      Op = 188
+	OpFUnordLessThanEqual    Op = 189
+	OpFOrdGreaterThanEqual   Op = 190
+	OpFUnordGreaterThanEqual Op = 191
+
+	OpShiftRightLogical    Op = 194
+	OpShiftRightArithmetic Op = 195
+	OpShiftLeftLogical     Op = 196
+	OpBitwiseOr            Op = 197
+	OpBitwiseXor           Op = 198
+	OpBitwiseAnd           Op = 199
+	OpNot                  Op = 200
+	OpBitFieldInsert       Op = 201
+	OpBitFieldSExtract     Op = 202
+	OpBitFieldUExtract     Op = 203
+	OpBitReverse           Op = 204
+	OpBitCount             Op = 205
+
+	OpDPdx   Op = 207
+	OpDPdy   Op = 208
+	OpFwidth Op = 209
+
+	OpControlBarrier Op = 224
+	OpMemoryBarrier  Op = 225
+
+	OpAtomicLoad            Op = 227
+	OpAtomicStore           Op = 228
+	OpAtomicExchange        Op = 229
+	OpAtomicCompareExchange Op = 230
+	OpAtomicIIncrement      Op = 232
+	OpAtomicIDecrement      Op = 233
+	OpAtomicIAdd            Op = 234
+	OpAtomicISub            Op = 235
+	OpAtomicSMin            Op = 236
+	OpAtomicUMin            Op = 237
+	OpAtomicSMax            Op = 238
+	OpAtomicUMax            Op = 239
+	OpAtomicAnd             Op = 240
+	OpAtomicOr              Op = 241
+	OpAtomicXor             Op = 242
+
+	OpPhi               Op = 245
+	OpLoopMerge         Op = 246
+	OpSelectionMerge    Op = 247
+	OpLabel             Op = 248
+	OpBranch            Op = 249
+	OpBranchConditional Op = 250
+	OpSwitch            Op = 251
+	OpKill              Op = 252
+	OpReturn            Op = 253
+	OpReturnValue       Op = 254
+	OpUnreachable       Op = 255
+
+	OpGroupNonUniformElect          Op = 333
+	OpGroupNonUniformBroadcast      Op = 337
+	OpGroupNonUniformBroadcastFirst Op = 338
+	OpGroupNonUniformBallot         Op = 339
+	OpGroupNonUniformIAdd           Op = 349
+	OpGroupNonUniformFAdd           Op = 350
+
+	OpTerminateInvocation      Op = 4416
+	OpDemoteToHelperInvocation Op = 5380
+)
+
+// terminator returns true for the instructions that end a block
+func (op Op) terminator() bool {
+	switch op {
+	case OpBranch, OpBranchConditional, OpSwitch, OpKill, OpReturn, OpReturnValue,
+		OpUnreachable, OpTerminateInvocation:
+		return true
+	}
+	return false
+}
+
+// Capability is something a module declares it uses
+type Capability uint32
+
+const (
+	CapabilityMatrix                         Capability = 0
+	CapabilityShader                         Capability = 1
+	CapabilityGeometry                       Capability = 2
+	CapabilityTessellation                   Capability = 3
+	CapabilityAddresses                      Capability = 4
+	CapabilityLinkage                        Capability = 5
+	CapabilityKernel                         Capability = 6
+	CapabilityFloat16                        Capability = 9
+	CapabilityFloat64                        Capability = 10
+	CapabilityInt64                          Capability = 11
+	CapabilityInt64Atomics                   Capability = 12
+	CapabilityInt16                          Capability = 22
+	CapabilityImageGatherExtended            Capability = 25
+	CapabilityStorageImageMultisample        Capability = 27
+	CapabilityClipDistance                   Capability = 32
+	CapabilityCullDistance                   Capability = 33
+	CapabilityImageCubeArray                 Capability = 34
+	CapabilitySampleRateShading              Capability = 35
+	CapabilityInt8                           Capability = 39
+	CapabilityInputAttachment                Capability = 40
+	CapabilitySampled1D                      Capability = 43
+	CapabilityImage1D                        Capability = 44
+	CapabilitySampledBuffer                  Capability = 46
+	CapabilityImageBuffer                    Capability = 47
+	CapabilityStorageImageExtendedFormats    Capability = 49
+	CapabilityImageQuery                     Capability = 50
+	CapabilityDerivativeControl              Capability = 51
+	CapabilityStorageImageReadWithoutFormat  Capability = 55
+	CapabilityStorageImageWriteWithoutFormat Capability = 56
+	CapabilityMultiViewport                  Capability = 57
+	CapabilityGroupNonUniform                Capability = 61
+	CapabilityGroupNonUniformVote            Capability = 62
+	CapabilityGroupNonUniformArithmetic      Capability = 63
+	CapabilityGroupNonUniformBallot          Capability = 64
+	CapabilityGroupNonUniformShuffle         Capability = 65
+	CapabilityDrawParameters                 Capability = 4427
+	CapabilityStorageBuffer16BitAccess       Capability = 4433
+	CapabilityMultiView                      Capability = 4439
+	CapabilityVariablePointersStorageBuffer  Capability = 4441
+	CapabilityVariablePointers               Capability = 4442
+	CapabilityStorageBuffer8BitAccess        Capability = 4448
+	CapabilityShaderNonUniform               Capability = 5301
+	CapabilityRuntimeDescriptorArray         Capability = 5302
+	CapabilityVulkanMemoryModel              Capability = 5345
+	CapabilityPhysicalStorageBufferAddresses Capability = 5347
+	CapabilityDemoteToHelperInvocation       Capability = 5379
+)
+
+// AddressingModel is the first operand of OpMemoryModel
+type AddressingModel uint32
+
+const (
+	AddressingLogical                 AddressingModel = 0
+	AddressingPhysical32              AddressingModel = 1
+	AddressingPhysical64              AddressingModel = 2
+	AddressingPhysicalStorageBuffer64 AddressingModel = 5348
+)
+
+// MemoryModel is the second operand of OpMemoryModel
+type MemoryModel uint32
+
+const (
+	MemoryModelSimple  MemoryModel = 0
+	MemoryModelGLSL450 MemoryModel = 1
+	MemoryModelOpenCL  MemoryModel = 2
+	MemoryModelVulkan  MemoryModel = 3
+)
+
+// ExecutionModel is the kind of shader an entry point is
+type ExecutionModel uint32
+
+const (
+	ExecutionModelVertex                 ExecutionModel = 0
+	ExecutionModelTessellationControl    ExecutionModel = 1
+	ExecutionModelTessellationEvaluation ExecutionModel = 2
+	ExecutionModelGeometry               ExecutionModel = 3
+	ExecutionModelFragment               ExecutionModel = 4
+	ExecutionModelGLCompute              ExecutionModel = 5
+	ExecutionModelKernel                 ExecutionModel = 6
+)
+
+// ExecutionMode is declared for an entry point with Module.ExecutionMode
+type ExecutionMode uint32
+
+const (
+	ExecutionModeInvocations         ExecutionMode = 0
+	ExecutionModeSpacingEqual        ExecutionMode = 1
+	ExecutionModeVertexOrderCw       ExecutionMode = 4
+	ExecutionModeVertexOrderCcw      ExecutionMode = 5
+	ExecutionModePixelCenterInteger  ExecutionMode = 6
+	ExecutionModeOriginUpperLeft     ExecutionMode = 7
+	ExecutionModeOriginLowerLeft     ExecutionMode = 8
+	ExecutionModeEarlyFragmentTests  ExecutionMode = 9
+	ExecutionModePointMode           ExecutionMode = 10
+	ExecutionModeDepthReplacing      ExecutionMode = 12
+	ExecutionModeDepthGreater        ExecutionMode = 14
+	ExecutionModeDepthLess           ExecutionMode = 15
+	ExecutionModeDepthUnchanged      ExecutionMode = 16
+	ExecutionModeLocalSize           ExecutionMode = 17
+	ExecutionModeInputPoints         ExecutionMode = 19
+	ExecutionModeInputLines          ExecutionMode = 20
+	ExecutionModeTriangles           ExecutionMode = 22
+	ExecutionModeQuads               ExecutionMode = 24
+	ExecutionModeIsolines            ExecutionMode = 25
+	ExecutionModeOutputVertices      ExecutionMode = 26
+	ExecutionModeOutputPoints        ExecutionMode = 27
+	ExecutionModeOutputLineStrip     ExecutionMode = 28
+	ExecutionModeOutputTriangleStrip ExecutionMode = 29
+	ExecutionModeLocalSizeID         ExecutionMode = 38
+)
+
+// StorageClass is where a variable lives
+type StorageClass uint32
+
+const (
+	StorageClassUniformConstant       StorageClass = 0
+	StorageClassInput                 StorageClass = 1
+	StorageClassUniform               StorageClass = 2
+	StorageClassOutput                StorageClass = 3
+	StorageClassWorkgroup             StorageClass = 4
+	StorageClassCrossWorkgroup        StorageClass = 5
+	StorageClassPrivate               StorageClass = 6
+	StorageClassFunction              StorageClass = 7
+	StorageClassGeneric               StorageClass = 8
+	StorageClassPushConstant          StorageClass = 9
+	StorageClassAtomicCounter         StorageClass = 10
+	StorageClassImage                 StorageClass = 11
+	StorageClassStorageBuffer         StorageClass = 12
+	StorageClassPhysicalStorageBuffer StorageClass = 5349
+)
+
+// Decoration is added to an id or a struct member with Module.Decorate and
+// Module.MemberDecorate
+type Decoration uint32
+
+const (
+	DecorationRelaxedPrecision     Decoration = 0
+	DecorationSpecID               Decoration = 1
+	DecorationBlock                Decoration = 2
+	DecorationBufferBlock          Decoration = 3
+	DecorationRowMajor             Decoration = 4
+	DecorationColMajor             Decoration = 5
+	DecorationArrayStride          Decoration = 6
+	DecorationMatrixStride         Decoration = 7
+	DecorationBuiltIn              Decoration = 11
+	DecorationNoPerspective        Decoration = 13
+	DecorationFlat                 Decoration = 14
+	DecorationPatch                Decoration = 15
+	DecorationCentroid             Decoration = 16
+	DecorationSample               Decoration = 17
+	DecorationInvariant            Decoration = 18
+	DecorationRestrict             Decoration = 19
+	DecorationAliased              Decoration = 20
+	DecorationVolatile             Decoration = 21
+	DecorationCoherent             Decoration = 23
+	DecorationNonWritable          Decoration = 24
+	DecorationNonReadable          Decoration = 25
+	DecorationLocation             Decoration = 30
+	DecorationComponent            Decoration = 31
+	DecorationIndex                Decoration = 32
+	DecorationBinding              Decoration = 33
+	DecorationDescriptorSet        Decoration = 34
+	DecorationOffset               Decoration = 35
+	DecorationLinkageAttributes    Decoration = 41
+	DecorationNoContraction        Decoration = 42
+	DecorationInputAttachmentIndex Decoration = 43
+	DecorationNonUniform           Decoration = 5300
+)
+
+// BuiltIn is the operand of DecorationBuiltIn
+type BuiltIn uint32
+
+const (
+	BuiltInPosition                  BuiltIn = 0
+	BuiltInPointSize                 BuiltIn = 1
+	BuiltInClipDistance              BuiltIn = 3
+	BuiltInCullDistance              BuiltIn = 4
+	BuiltInPrimitiveID               BuiltIn = 7
+	BuiltInInvocationID              BuiltIn = 8
+	BuiltInLayer                     BuiltIn = 9
+	BuiltInViewportIndex             BuiltIn = 10
+	BuiltInTessLevelOuter            BuiltIn = 11
+	BuiltInTessLevelInner            BuiltIn = 12
+	BuiltInTessCoord                 BuiltIn = 13
+	BuiltInFragCoord                 BuiltIn = 15
+	BuiltInPointCoord                BuiltIn = 16
+	BuiltInFrontFacing               BuiltIn = 17
+	BuiltInSampleID                  BuiltIn = 18
+	BuiltInSamplePosition            BuiltIn = 19
+	BuiltInSampleMask                BuiltIn = 20
+	BuiltInFragDepth                 BuiltIn = 22
+	BuiltInHelperInvocation          BuiltIn = 23
+	BuiltInNumWorkgroups             BuiltIn = 24
+	BuiltInWorkgroupSize             BuiltIn = 25
+	BuiltInWorkgroupID               BuiltIn = 26
+	BuiltInLocalInvocationID         BuiltIn = 27
+	BuiltInGlobalInvocationID        BuiltIn = 28
+	BuiltInLocalInvocationIndex      BuiltIn = 29
+	BuiltInSubgroupSize              BuiltIn = 36
+	BuiltInNumSubgroups              BuiltIn = 38
+	BuiltInSubgroupID                BuiltIn = 40
+	BuiltInSubgroupLocalInvocationID BuiltIn = 41
+	BuiltInVertexIndex               BuiltIn = 42
+	BuiltInInstanceIndex             BuiltIn = 43
+	BuiltInBaseVertex                BuiltIn = 4424
+	BuiltInBaseInstance              BuiltIn = 4425
+	BuiltInDrawIndex                 BuiltIn = 4426
+	BuiltInViewIndex                 BuiltIn = 4440
+)
+
+// Dim is the dimensionality of an image type
+type Dim uint32
+
+const (
+	Dim1D          Dim = 0
+	Dim2D          Dim = 1
+	Dim3D          Dim = 2
+	DimCube        Dim = 3
+	DimRect        Dim = 4
+	DimBuffer      Dim = 5
+	DimSubpassData Dim = 6
+)
+
+// ImageFormat is the texel format of a storage image type
+type ImageFormat uint32
+
+const (
+	ImageFormatUnknown ImageFormat = iota
+	ImageFormatRgba32f
+	ImageFormatRgba16f
+	ImageFormatR32f
+	ImageFormatRgba8
+	ImageFormatRgba8Snorm
+	ImageFormatRg32f
+	ImageFormatRg16f
+	ImageFormatR11fG11fB10f
+	ImageFormatR16f
+	ImageFormatRgba16
+	ImageFormatRgb10A2
+	ImageFormatRg16
+	ImageFormatRg8
+	ImageFormatR16
+	ImageFormatR8
+	ImageFormatRgba16Snorm
+	ImageFormatRg16Snorm
+	ImageFormatRg8Snorm
+	ImageFormatR16Snorm
+	ImageFormatR8Snorm
+	ImageFormatRgba32i
+	ImageFormatRgba16i
+	ImageFormatRgba8i
+	ImageFormatR32i
+	ImageFormatRg32i
+	ImageFormatRg16i
+	ImageFormatRg8i
+	ImageFormatR16i
+	ImageFormatR8i
+	ImageFormatRgba32ui
+	ImageFormatRgba16ui
+	ImageFormatRgba8ui
+	ImageFormatR32ui
+	ImageFormatRgb10a2ui
+	ImageFormatRg32ui
+	ImageFormatRg16ui
+	ImageFormatRg8ui
+	ImageFormatR16ui
+	ImageFormatR8ui
+)
+
+// FunctionControl are hints for a function
+type FunctionControl uint32
+
+const (
+	FunctionControlNone       FunctionControl = 0
+	FunctionControlInline     FunctionControl = 1
+	FunctionControlDontInline FunctionControl = 2
+	FunctionControlPure       FunctionControl = 4
+	FunctionControlConst      FunctionControl = 8
+)
+
+// SelectionControl are hints for a selection construct
+type SelectionControl uint32
+
+const (
+	SelectionControlNone        SelectionControl = 0
+	SelectionControlFlatten     SelectionControl = 1
+	SelectionControlDontFlatten SelectionControl = 2
+)
+
+// LoopControl are hints for a loop construct
+type LoopControl uint32
+
+const (
+	LoopControlNone       LoopControl = 0
+	LoopControlUnroll     LoopControl = 1
+	LoopControlDontUnroll LoopControl = 2
+)
+
+// Scope is the set of invocations a barrier or atomic applies to
+type Scope uint32
+
+const (
+	ScopeCrossDevice Scope = 0
+	ScopeDevice      Scope = 1
+	ScopeWorkgroup   Scope = 2
+	ScopeSubgroup    Scope = 3
+	ScopeInvocation  Scope = 4
+	ScopeQueueFamily Scope = 5
+)
+
+// MemorySemantics orders the memory accesses of barriers and atomics, the
+// values are bits which can be combined
+type MemorySemantics uint32
+
+const (
+	MemorySemanticsNone                   MemorySemantics = 0
+	MemorySemanticsAcquire                MemorySemantics = 0x2
+	MemorySemanticsRelease                MemorySemantics = 0x4
+	MemorySemanticsAcquireRelease         MemorySemantics = 0x8
+	MemorySemanticsSequentiallyConsistent MemorySemantics = 0x10
+	MemorySemanticsUniformMemory          MemorySemantics = 0x40
+	MemorySemanticsSubgroupMemory         MemorySemantics = 0x80
+	MemorySemanticsWorkgroupMemory        MemorySemantics = 0x100
+	MemorySemanticsCrossWorkgroupMemory   MemorySemantics = 0x200
+	MemorySemanticsImageMemory            MemorySemantics = 0x800
+)
@@ -0,0 +1,209 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spirv
+
+// Function is a function of a module
+type Function struct {
+	ID ID
+	// Params are the ids of the parameters
+	Params []ID
+
+	m          *Module
+	result     ID
+	typ        ID
+	control    FunctionControl
+	paramTypes []ID
+	variables  []uint32
+	blocks     []*Block
+}
+
+// Function adds a function returning result, params are the types of its
+// parameters
+func (m *Module) Function(result ID, control FunctionControl, params ...ID) *Function {
+	f := &Function{
+		m:          m,
+		result:     result,
+		typ:        m.TypeFunction(result, params...),
+		control:    control,
+		paramTypes: params,
+	}
+	f.ID = m.newID()
+	for _, t := range params {
+		p := m.newID()
+		if storage, ok := m.pointers[t]; ok {
+			m.storage[p] = storage
+		}
+		f.Params = append(f.Params, p)
+	}
+	m.functions = append(m.functions, f)
+	return f
+}
+
+// Variable declares a variable of type t in the Function storage class, it
+// goes at the start of the function's first block
+func (f *Function) Variable(t ID) ID {
+	id := f.m.newID()
+	f.variables = append(f.variables, instruction(OpVariable, uint32(f.m.TypePointer(StorageClassFunction, t)), uint32(id), uint32(StorageClassFunction))...)
+	f.m.storage[id] = StorageClassFunction
+	return id
+}
+
+// Block adds a block to the function. Blocks are laid out in the order
+// they're added, which has to put every block after the blocks dominating
+// it, so a block can be added before it's branched to and filled in later.
+func (f *Function) Block() *Block {
+	b := &Block{Label: f.m.newID(), f: f}
+	f.blocks = append(f.blocks, b)
+	return b
+}
+
+// Block is a basic block of a function, it has to end with a terminator
+// instruction, like Branch or Return
+type Block struct {
+	Label ID
+
+	f          *Function
+	words      []uint32
+	terminated bool
+}
+
+// Inst adds an instruction to the block, operands are encoded as they are
+// so ids and result ids have to be converted to words. It's for the
+// instructions the other methods don't cover.
+func (b *Block) Inst(op Op, operands ...uint32) {
+	if b.terminated {
+		b.f.m.fail("instruction %d after the terminator of block %d", op, b.Label)
+		return
+	}
+	b.words = append(b.words, instruction(op, operands...)...)
+	b.terminated = op.terminator()
+}
+
+// Op adds an instruction producing a value of type t and returns its id,
+// the operands are all ids
+func (b *Block) Op(op Op, t ID, operands ...ID) ID {
+	id := b.f.m.newID()
+	b.Inst(op, append([]uint32{uint32(t), uint32(id)}, ids(operands)...)...)
+	return id
+}
+
+// Load loads a value of type t
+func (b *Block) Load(t, pointer ID) ID {
+	return b.Op(OpLoad, t, pointer)
+}
+
+// Store stores a value
+func (b *Block) Store(pointer, value ID) {
+	b.Inst(OpStore, uint32(pointer), uint32(value))
+}
+
+// AccessChain returns a pointer to the element of type t of base selected
+// by indexes, which are ids of integer constants when they index a struct.
+// base has to be a variable, function parameter or access chain so its
+// storage class is known.
+func (b *Block) AccessChain(t, base ID, indexes ...ID) ID {
+	m := b.f.m
+	storage, ok := m.storage[base]
+	if !ok {
+		m.fail("access chain of %d which isn't a known pointer", base)
+	}
+	id := b.Op(OpAccessChain, m.TypePointer(storage, t), append([]ID{base}, indexes...)...)
+	m.storage[id] = storage
+	return id
+}
+
+// CompositeExtract extracts the part of type t of a composite selected by
+// literal indexes
+func (b *Block) CompositeExtract(t, composite ID, indexes ...uint32) ID {
+	id := b.f.m.newID()
+	b.Inst(OpCompositeExtract, append([]uint32{uint32(t), uint32(id), uint32(composite)}, indexes...)...)
+	return id
+}
+
+// CompositeConstruct builds a vector, matrix, array or struct
+func (b *Block) CompositeConstruct(t ID, constituents ...ID) ID {
+	return b.Op(OpCompositeConstruct, t, constituents...)
+}
+
+// ExtInst calls instruction of an extended instruction set imported with
+// Module.ExtInstImport
+func (b *Block) ExtInst(t, set ID, instruction uint32, operands ...ID) ID {
+	id := b.f.m.newID()
+	b.Inst(OpExtInst, append([]uint32{uint32(t), uint32(id), uint32(set), instruction}, ids(operands)...)...)
+	return id
+}
+
+// FunctionCall calls a function
+func (b *Block) FunctionCall(f *Function, args ...ID) ID {
+	return b.Op(OpFunctionCall, f.result, append([]ID{f.ID}, args...)...)
+}
+
+// uintConstant returns a 32 bit unsigned constant, scopes and memory
+// semantics are passed as ids of them
+func (m *Module) uintConstant(v uint32) ID {
+	return m.ConstantUint(m.TypeInt(32, false), uint64(v))
+}
+
+// ControlBarrier waits for the invocations in the execution scope and
+// orders the memory accesses in the memory scope
+func (b *Block) ControlBarrier(execution, memory Scope, semantics MemorySemantics) {
+	m := b.f.m
+	b.Inst(OpControlBarrier, uint32(m.uintConstant(uint32(execution))), uint32(m.uintConstant(uint32(memory))), uint32(m.uintConstant(uint32(semantics))))
+}
+
+// MemoryBarrier orders the memory accesses in a scope
+func (b *Block) MemoryBarrier(memory Scope, semantics MemorySemantics) {
+	m := b.f.m
+	b.Inst(OpMemoryBarrier, uint32(m.uintConstant(uint32(memory))), uint32(m.uintConstant(uint32(semantics))))
+}
+
+// Atomic adds an atomic instruction with a single memory semantics operand,
+// like OpAtomicIAdd, values are the operands after the semantics
+func (b *Block) Atomic(op Op, t, pointer ID, scope Scope, semantics MemorySemantics, values ...ID) ID {
+	m := b.f.m
+	return b.Op(op, t, append([]ID{pointer, m.uintConstant(uint32(scope)), m.uintConstant(uint32(semantics))}, values...)...)
+}
+
+// SelectionMerge declares the merge block of a selection, it has to come
+// right before the block's BranchConditional
+func (b *Block) SelectionMerge(merge *Block, control SelectionControl) {
+	b.Inst(OpSelectionMerge, uint32(merge.Label), uint32(control))
+}
+
+// LoopMerge declares the merge block and continue target of a loop, it has
+// to come right before the loop header's branch
+func (b *Block) LoopMerge(merge, continueTarget *Block, control LoopControl) {
+	b.Inst(OpLoopMerge, uint32(merge.Label), uint32(continueTarget.Label), uint32(control))
+}
+
+// Branch ends the block with a branch to target
+func (b *Block) Branch(target *Block) {
+	b.Inst(OpBranch, uint32(target.Label))
+}
+
+// BranchConditional ends the block with a branch to one of two blocks
+func (b *Block) BranchConditional(condition ID, ifTrue, ifFalse *Block) {
+	b.Inst(OpBranchConditional, uint32(condition), uint32(ifTrue.Label), uint32(ifFalse.Label))
+}
+
+// Return ends the block by returning from a void function
+func (b *Block) Return() {
+	b.Inst(OpReturn)
+}
+
+// ReturnValue ends the block by returning a value
+func (b *Block) ReturnValue(value ID) {
+	b.Inst(OpReturnValue, uint32(value))
+}
@@ -0,0 +1,571 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package spirv builds SPIR-V modules directly from Go.
+//
+// A Module hands out ids as types, constants, variables and functions are
+// added to it and lays their instructions out in the order the specification
+// requires when it's encoded. Types other than arrays and structs, and
+// constants other than specialization constants, are declared once no matter
+// how often they're asked for. Mistakes, like a float constant of an int type
+// or an instruction after a block's terminator, are reported by Bytes.
+//
+//	m := spirv.NewModule()
+//	m.Capability(spirv.CapabilityShader)
+//	main := m.Function(m.TypeVoid(), spirv.FunctionControlNone)
+//	main.Block().Return()
+//	m.EntryPoint(spirv.ExecutionModelGLCompute, main, "main")
+//	m.ExecutionMode(main, spirv.ExecutionModeLocalSize, 64, 1, 1)
+//	code, err := m.Bytes()
+package spirv
+
+import (
+	"fmt"
+	"math"
+
+	gs "github.com/celer/gshaderc"
+)
+
+// ID is the result id of an instruction
+type ID uint32
+
+const (
+	opName              Op = 5
+	opMemberName        Op = 6
+	opExtension         Op = 10
+	opExtInstImport     Op = 11
+	opMemoryModel       Op = 14
+	opEntryPoint        Op = 15
+	opExecutionMode     Op = 16
+	opCapability        Op = 17
+	opTypeVoid          Op = 19
+	opTypeBool          Op = 20
+	opTypeInt           Op = 21
+	opTypeFloat         Op = 22
+	opTypeVector        Op = 23
+	opTypeMatrix        Op = 24
+	opTypeImage         Op = 25
+	opTypeSampler       Op = 26
+	opTypeSampledImage  Op = 27
+	opTypeArray         Op = 28
+	opTypeRuntimeArray  Op = 29
+	opTypeStruct        Op = 30
+	opTypePointer       Op = 32
+	opTypeFunction      Op = 33
+	opConstantTrue      Op = 41
+	opConstantFalse     Op = 42
+	opConstant          Op = 43
+	opConstantComposite Op = 44
+	opConstantNull      Op = 46
+	opSpecConstantTrue  Op = 48
+	opSpecConstantFalse Op = 49
+	opSpecConstant      Op = 50
+	opFunction          Op = 54
+	opFunctionParameter Op = 55
+	opFunctionEnd       Op = 56
+	opDecorate          Op = 71
+	opMemberDecorate    Op = 72
+)
+
+// The sections of a module, in the order they're laid out
+const (
+	sectionCapabilities = iota
+	sectionExtensions
+	sectionExtInstImports
+	sectionMemoryModel
+	sectionEntryPoints
+	sectionExecutionModes
+	sectionDebug
+	sectionAnnotations
+	sectionGlobals
+	numSections
+)
+
+// number describes an int or float type
+type number struct {
+	width  uint32
+	signed bool
+	float  bool
+}
+
+// encode returns the words of a literal of the type, narrow signed values
+// are sign extended
+func (n number) encode(bits uint64) []uint32 {
+	if n.width > 32 {
+		return []uint32{uint32(bits), uint32(bits >> 32)}
+	}
+	if n.signed && n.width < 32 && bits>>(n.width-1)&1 == 1 {
+		bits |= ^uint64(0) << n.width
+	}
+	return []uint32{uint32(bits)}
+}
+
+// Module is a SPIR-V module under construction
+type Module struct {
+	// Version goes in the header, 1.0 if it isn't set
+	Version gs.SPIRVVersion
+	// Generator is the generator's magic number in the header
+	Generator uint32
+
+	bound       ID
+	sections    [numSections][]uint32
+	memoryModel []uint32
+	functions   []*Function
+	declared    map[string]ID
+	numbers     map[ID]number
+	pointers    map[ID]StorageClass
+	storage     map[ID]StorageClass
+	err         error
+}
+
+// NewModule creates an empty module
+func NewModule() *Module {
+	return &Module{
+		declared: make(map[string]ID),
+		numbers:  make(map[ID]number),
+		pointers: make(map[ID]StorageClass),
+		storage:  make(map[ID]StorageClass),
+	}
+}
+
+// fail records the first error, which is returned by Bytes
+func (m *Module) fail(format string, args ...interface{}) {
+	if m.err == nil {
+		m.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (m *Module) newID() ID {
+	m.bound++
+	return m.bound
+}
+
+// instruction encodes an instruction
+func instruction(op Op, operands ...uint32) []uint32 {
+	return append([]uint32{uint32(len(operands)+1)<<16 | uint32(op)}, operands...)
+}
+
+func (m *Module) emit(section int, op Op, operands ...uint32) {
+	m.sections[section] = append(m.sections[section], instruction(op, operands...)...)
+}
+
+// stringWords encodes a nul terminated literal string
+func stringWords(s string) []uint32 {
+	b := append([]byte(s), make([]byte, 4-len(s)%4)...)
+	words := make([]uint32, len(b)/4)
+	for i := range words {
+		words[i] = uint32(b[i*4]) | uint32(b[i*4+1])<<8 | uint32(b[i*4+2])<<16 | uint32(b[i*4+3])<<24
+	}
+	return words
+}
+
+func ids(list []ID) []uint32 {
+	words := make([]uint32, len(list))
+	for i, id := range list {
+		words[i] = uint32(id)
+	}
+	return words
+}
+
+// Capability declares a capability, declaring one twice has no effect
+func (m *Module) Capability(c Capability) {
+	key := fmt.Sprint("capability ", c)
+	if _, ok := m.declared[key]; !ok {
+		m.declared[key] = 0
+		m.emit(sectionCapabilities, opCapability, uint32(c))
+	}
+}
+
+// Extension declares an extension, declaring one twice has no effect
+func (m *Module) Extension(name string) {
+	key := "extension " + name
+	if _, ok := m.declared[key]; !ok {
+		m.declared[key] = 0
+		m.emit(sectionExtensions, opExtension, stringWords(name)...)
+	}
+}
+
+// ExtInstImport imports an extended instruction set, like "GLSL.std.450",
+// for use with Block.ExtInst
+func (m *Module) ExtInstImport(name string) ID {
+	key := "import " + name
+	if id, ok := m.declared[key]; ok {
+		return id
+	}
+	id := m.newID()
+	m.declared[key] = id
+	m.emit(sectionExtInstImports, opExtInstImport, append([]uint32{uint32(id)}, stringWords(name)...)...)
+	return id
+}
+
+// MemoryModel sets the addressing and memory models, modules which don't
+// set them use AddressingLogical and MemoryModelGLSL450
+func (m *Module) MemoryModel(addressing AddressingModel, memory MemoryModel) {
+	m.memoryModel = instruction(opMemoryModel, uint32(addressing), uint32(memory))
+}
+
+// EntryPoint declares a function as an entry point, interfaces are the
+// global variables it uses, which before SPIR-V 1.4 only need to include
+// its inputs and outputs
+func (m *Module) EntryPoint(model ExecutionModel, f *Function, name string, interfaces ...ID) {
+	operands := append([]uint32{uint32(model), uint32(f.ID)}, stringWords(name)...)
+	m.emit(sectionEntryPoints, opEntryPoint, append(operands, ids(interfaces)...)...)
+}
+
+// ExecutionMode declares an execution mode of an entry point
+func (m *Module) ExecutionMode(f *Function, mode ExecutionMode, literals ...uint32) {
+	m.emit(sectionExecutionModes, opExecutionMode, append([]uint32{uint32(f.ID), uint32(mode)}, literals...)...)
+}
+
+// Name gives an id a debug name
+func (m *Module) Name(id ID, name string) {
+	m.emit(sectionDebug, opName, append([]uint32{uint32(id)}, stringWords(name)...)...)
+}
+
+// MemberName gives a member of a struct type a debug name
+func (m *Module) MemberName(t ID, member uint32, name string) {
+	m.emit(sectionDebug, opMemberName, append([]uint32{uint32(t), member}, stringWords(name)...)...)
+}
+
+// Decorate decorates an id, literals are the decoration's operands
+func (m *Module) Decorate(id ID, d Decoration, literals ...uint32) {
+	m.emit(sectionAnnotations, opDecorate, append([]uint32{uint32(id), uint32(d)}, literals...)...)
+}
+
+// MemberDecorate decorates a member of a struct type
+func (m *Module) MemberDecorate(t ID, member uint32, d Decoration, literals ...uint32) {
+	m.emit(sectionAnnotations, opMemberDecorate, append([]uint32{uint32(t), member, uint32(d)}, literals...)...)
+}
+
+// declare returns the id of a type or constant, declaring it the first time
+// it's asked for. Constants pass their type as the first operand, it comes
+// before the result id.
+func (m *Module) declare(op Op, typed bool, operands ...uint32) ID {
+	key := fmt.Sprint(op, operands)
+	if id, ok := m.declared[key]; ok {
+		return id
+	}
+	id := m.newID()
+	m.declared[key] = id
+	m.global(op, typed, id, operands...)
+	return id
+}
+
+// global adds a type, constant or variable to the globals section
+func (m *Module) global(op Op, typed bool, id ID, operands ...uint32) {
+	if typed {
+		operands = append([]uint32{operands[0], uint32(id)}, operands[1:]...)
+	} else {
+		operands = append([]uint32{uint32(id)}, operands...)
+	}
+	m.emit(sectionGlobals, op, operands...)
+}
+
+// TypeVoid returns the void type
+func (m *Module) TypeVoid() ID {
+	return m.declare(opTypeVoid, false)
+}
+
+// TypeBool returns the boolean type
+func (m *Module) TypeBool() ID {
+	return m.declare(opTypeBool, false)
+}
+
+// TypeInt returns an integer type of the given width in bits
+func (m *Module) TypeInt(width uint32, signed bool) ID {
+	var s uint32
+	if signed {
+		s = 1
+	}
+	id := m.declare(opTypeInt, false, width, s)
+	m.numbers[id] = number{width: width, signed: signed}
+	return id
+}
+
+// TypeFloat returns a floating point type of the given width in bits
+func (m *Module) TypeFloat(width uint32) ID {
+	id := m.declare(opTypeFloat, false, width)
+	m.numbers[id] = number{width: width, float: true}
+	return id
+}
+
+// TypeVector returns a vector type of count components
+func (m *Module) TypeVector(component ID, count uint32) ID {
+	if count < 2 {
+		m.fail("vector of %d components", count)
+	}
+	return m.declare(opTypeVector, false, uint32(component), count)
+}
+
+// TypeMatrix returns a matrix type of count columns, column is a vector
+// type
+func (m *Module) TypeMatrix(column ID, count uint32) ID {
+	if count < 2 {
+		m.fail("matrix of %d columns", count)
+	}
+	return m.declare(opTypeMatrix, false, uint32(column), count)
+}
+
+// TypeImage returns an image type. depth, arrayed, multisampled and sampled
+// are the literal operands of OpTypeImage: sampled is 1 for images used with
+// a sampler and 2 for storage images.
+func (m *Module) TypeImage(sampledType ID, dim Dim, depth, arrayed, multisampled, sampled uint32, format ImageFormat) ID {
+	return m.declare(opTypeImage, false, uint32(sampledType), uint32(dim), depth, arrayed, multisampled, sampled, uint32(format))
+}
+
+// TypeSampler returns the sampler type
+func (m *Module) TypeSampler() ID {
+	return m.declare(opTypeSampler, false)
+}
+
+// TypeSampledImage returns the type of an image combined with a sampler
+func (m *Module) TypeSampledImage(image ID) ID {
+	return m.declare(opTypeSampledImage, false, uint32(image))
+}
+
+// TypeArray returns a new array type, length is a constant. Every call
+// declares a new type so arrays can be decorated independently.
+func (m *Module) TypeArray(element, length ID) ID {
+	id := m.newID()
+	m.global(opTypeArray, false, id, uint32(element), uint32(length))
+	return id
+}
+
+// TypeRuntimeArray returns a new array type whose length is only known at
+// run time
+func (m *Module) TypeRuntimeArray(element ID) ID {
+	id := m.newID()
+	m.global(opTypeRuntimeArray, false, id, uint32(element))
+	return id
+}
+
+// TypeStruct returns a new struct type
+func (m *Module) TypeStruct(members ...ID) ID {
+	id := m.newID()
+	m.global(opTypeStruct, false, id, ids(members)...)
+	return id
+}
+
+// TypePointer returns the type of a pointer to pointee in a storage class
+func (m *Module) TypePointer(storage StorageClass, pointee ID) ID {
+	id := m.declare(opTypePointer, false, uint32(storage), uint32(pointee))
+	m.pointers[id] = storage
+	return id
+}
+
+// TypeFunction returns the type of a function
+func (m *Module) TypeFunction(result ID, params ...ID) ID {
+	return m.declare(opTypeFunction, false, append([]uint32{uint32(result)}, ids(params)...)...)
+}
+
+// constant declares a scalar constant, or a specialization constant if spec
+// is set, of a number type
+func (m *Module) constant(t ID, bits uint64, spec bool) ID {
+	words := append([]uint32{uint32(t)}, m.numbers[t].encode(bits)...)
+	if !spec {
+		return m.declare(opConstant, true, words...)
+	}
+	id := m.newID()
+	m.global(opSpecConstant, true, id, words...)
+	return id
+}
+
+// integer checks v fits in the integer type t
+func (m *Module) integer(t ID, v uint64, negative bool) {
+	n, ok := m.numbers[t]
+	switch {
+	case !ok || n.float:
+		m.fail("constant %d of type %d which isn't an integer type", v, t)
+	case n.width >= 64 && (!negative || n.signed):
+	case negative && n.signed && int64(v) >= -1<<(n.width-1):
+	case !negative && n.signed && v < 1<<(n.width-1):
+	case !negative && !n.signed && v < 1<<n.width:
+	case negative:
+		m.fail("constant %d doesn't fit in type %d", int64(v), t)
+	default:
+		m.fail("constant %d doesn't fit in type %d", v, t)
+	}
+}
+
+// ConstantInt returns an integer constant of type t
+func (m *Module) ConstantInt(t ID, v int64) ID {
+	m.integer(t, uint64(v), v < 0)
+	return m.constant(t, uint64(v), false)
+}
+
+// ConstantUint returns an integer constant of type t
+func (m *Module) ConstantUint(t ID, v uint64) ID {
+	m.integer(t, v, false)
+	return m.constant(t, v, false)
+}
+
+// float returns the bits of v in the float type t
+func (m *Module) float(t ID, v float64) uint64 {
+	n, ok := m.numbers[t]
+	switch {
+	case !ok || !n.float:
+		m.fail("constant %g of type %d which isn't a float type", v, t)
+	case n.width == 16:
+		return uint64(float16Bits(v))
+	case n.width == 32:
+		return uint64(math.Float32bits(float32(v)))
+	case n.width == 64:
+		return math.Float64bits(v)
+	default:
+		m.fail("constant %g of %d bit float type %d", v, n.width, t)
+	}
+	return 0
+}
+
+// ConstantFloat returns a floating point constant of type t, rounded to the
+// width of the type
+func (m *Module) ConstantFloat(t ID, v float64) ID {
+	return m.constant(t, m.float(t, v), false)
+}
+
+// ConstantBool returns true or false
+func (m *Module) ConstantBool(v bool) ID {
+	if v {
+		return m.declare(opConstantTrue, true, uint32(m.TypeBool()))
+	}
+	return m.declare(opConstantFalse, true, uint32(m.TypeBool()))
+}
+
+// ConstantComposite returns a vector, matrix, array or struct constant
+func (m *Module) ConstantComposite(t ID, constituents ...ID) ID {
+	return m.declare(opConstantComposite, true, append([]uint32{uint32(t)}, ids(constituents)...)...)
+}
+
+// ConstantNull returns the zero value of a type
+func (m *Module) ConstantNull(t ID) ID {
+	return m.declare(opConstantNull, true, uint32(t))
+}
+
+// SpecConstantInt returns a new integer specialization constant with a
+// default value, its DecorationSpecID still has to be added
+func (m *Module) SpecConstantInt(t ID, v int64) ID {
+	m.integer(t, uint64(v), v < 0)
+	return m.constant(t, uint64(v), true)
+}
+
+// SpecConstantUint returns a new integer specialization constant
+func (m *Module) SpecConstantUint(t ID, v uint64) ID {
+	m.integer(t, v, false)
+	return m.constant(t, v, true)
+}
+
+// SpecConstantFloat returns a new floating point specialization constant
+func (m *Module) SpecConstantFloat(t ID, v float64) ID {
+	return m.constant(t, m.float(t, v), true)
+}
+
+// SpecConstantBool returns a new boolean specialization constant
+func (m *Module) SpecConstantBool(v bool) ID {
+	op := opSpecConstantFalse
+	if v {
+		op = opSpecConstantTrue
+	}
+	id := m.newID()
+	m.global(op, true, id, uint32(m.TypeBool()))
+	return id
+}
+
+// Variable declares a global variable of type t, which is the type of what
+// it holds rather than a pointer to it. Function variables are declared with
+// Function.Variable.
+func (m *Module) Variable(t ID, storage StorageClass) ID {
+	if storage == StorageClassFunction {
+		m.fail("global variable in the Function storage class")
+	}
+	id := m.newID()
+	m.global(OpVariable, true, id, uint32(m.TypePointer(storage, t)), uint32(storage))
+	m.storage[id] = storage
+	return id
+}
+
+// Bytes encodes the module
+func (m *Module) Bytes() ([]byte, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if len(m.sections[sectionCapabilities]) == 0 {
+		return nil, fmt.Errorf("no capabilities declared")
+	}
+	version := m.Version
+	if version == 0 {
+		version = gs.SPIRV_1_0
+	}
+	words := []uint32{gs.SPIRVMagic, uint32(version), m.Generator, uint32(m.bound) + 1, 0}
+	for i, section := range m.sections {
+		if i == sectionMemoryModel {
+			if m.memoryModel == nil {
+				m.MemoryModel(AddressingLogical, MemoryModelGLSL450)
+			}
+			section = m.memoryModel
+		}
+		words = append(words, section...)
+	}
+	for _, f := range m.functions {
+		if len(f.blocks) == 0 {
+			return nil, fmt.Errorf("function %d has no blocks", f.ID)
+		}
+		words = append(words, instruction(opFunction, uint32(f.result), uint32(f.ID), uint32(f.control), uint32(f.typ))...)
+		for i, p := range f.Params {
+			words = append(words, instruction(opFunctionParameter, uint32(f.paramTypes[i]), uint32(p))...)
+		}
+		for i, b := range f.blocks {
+			if !b.terminated {
+				return nil, fmt.Errorf("block %d of function %d isn't terminated", b.Label, f.ID)
+			}
+			words = append(words, instruction(OpLabel, uint32(b.Label))...)
+			if i == 0 {
+				words = append(words, f.variables...)
+			}
+			words = append(words, b.words...)
+		}
+		words = append(words, instruction(opFunctionEnd)...)
+	}
+	return gs.SPIRVBytes(words), nil
+}
+
+// float16Bits converts v to a half precision float, rounding to nearest even
+func float16Bits(v float64) uint16 {
+	b := math.Float32bits(float32(v))
+	sign := uint16(b>>16) & 0x8000
+	exp := int(b>>23&0xff) - 127 + 15
+	mantissa := b & 0x7fffff
+	switch {
+	case b&0x7fffffff > 0x7f800000:
+		return sign | 0x7e00
+	case exp >= 31:
+		return sign | 0x7c00
+	case exp <= 0:
+		if exp < -10 {
+			return sign
+		}
+		mantissa |= 0x800000
+		shift := uint(14 - exp)
+		half := mantissa >> shift
+		rest, middle := mantissa&(1<<shift-1), uint32(1)<<(shift-1)
+		if rest > middle || rest == middle && half&1 == 1 {
+			half++
+		}
+		return sign | uint16(half)
+	}
+	half := uint32(exp)<<10 | mantissa>>13
+	if rest := mantissa & 0x1fff; rest > 0x1000 || rest == 0x1000 && half&1 == 1 {
+		half++
+	}
+	return sign | uint16(half)
+}
@@ -0,0 +1,307 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spirv
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	gs "github.com/celer/gshaderc"
+)
+
+// testKernel builds a compute shader doubling and taking the square root of
+// the values in a storage buffer
+func testKernel() *Module {
+	m := NewModule()
+	m.Version = gs.SPIRV_1_3
+	m.Capability(CapabilityShader)
+	glsl := m.ExtInstImport("GLSL.std.450")
+	void := m.TypeVoid()
+	uint := m.TypeInt(32, false)
+	float := m.TypeFloat(32)
+	uvec3 := m.TypeVector(uint, 3)
+
+	values := m.TypeRuntimeArray(float)
+	m.Decorate(values, DecorationArrayStride, 4)
+	data := m.TypeStruct(values)
+	m.Name(data, "Data")
+	m.MemberName(data, 0, "values")
+	m.Decorate(data, DecorationBlock)
+	m.MemberDecorate(data, 0, DecorationOffset, 0)
+	buffer := m.Variable(data, StorageClassStorageBuffer)
+	m.Name(buffer, "buffer")
+	m.Decorate(buffer, DecorationDescriptorSet, 0)
+	m.Decorate(buffer, DecorationBinding, 1)
+	invocation := m.Variable(uvec3, StorageClassInput)
+	m.Decorate(invocation, DecorationBuiltIn, uint32(BuiltInGlobalInvocationID))
+	count := m.SpecConstantUint(uint, 1024)
+	m.Name(count, "count")
+	m.Decorate(count, DecorationSpecID, 0)
+
+	main := m.Function(void, FunctionControlNone)
+	m.Name(main.ID, "main")
+	entry, body, merge := main.Block(), main.Block(), main.Block()
+	i := entry.CompositeExtract(uint, entry.Load(uvec3, invocation), 0)
+	entry.SelectionMerge(merge, SelectionControlNone)
+	entry.BranchConditional(entry.Op(OpULessThan, m.TypeBool(), i, count), body, merge)
+	p := body.AccessChain(float, buffer, m.ConstantInt(m.TypeInt(32, true), 0), i)
+	doubled := body.Op(OpFMul, float, body.Load(float, p), m.ConstantFloat(float, 2))
+	body.Store(p, body.ExtInst(float, glsl, 31, doubled))
+	body.Branch(merge)
+	merge.Return()
+
+	m.EntryPoint(ExecutionModelGLCompute, main, "main", invocation)
+	m.ExecutionMode(main, ExecutionModeLocalSize, 64, 1, 1)
+	return m
+}
+
+func TestModule(t *testing.T) {
+	code, err := testKernel().Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := gs.Disassemble(code, gs.DisassemblyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `; SPIR-V
+; Version: 1.3
+; Generator: Khronos; 0
+; Bound: 30
+; Schema: 0
+               OpCapability Shader
+          %1 = OpExtInstImport "GLSL.std.450"
+               OpMemoryModel Logical GLSL450
+               OpEntryPoint GLCompute %main "main" %gl_GlobalInvocationID
+               OpExecutionMode %main LocalSize 64 1 1
+               OpName %Data "Data"
+               OpMemberName %Data 0 "values"
+               OpName %buffer "buffer"
+               OpName %count "count"
+               OpName %main "main"
+               OpDecorate %_runtimearr_float ArrayStride 4
+               OpDecorate %Data Block
+               OpMemberDecorate %Data 0 Offset 0
+               OpDecorate %buffer DescriptorSet 0
+               OpDecorate %buffer Binding 1
+               OpDecorate %gl_GlobalInvocationID BuiltIn GlobalInvocationId
+               OpDecorate %count SpecId 0
+       %void = OpTypeVoid
+       %uint = OpTypeInt 32 0
+      %float = OpTypeFloat 32
+     %v3uint = OpTypeVector %uint 3
+%_runtimearr_float = OpTypeRuntimeArray %float
+       %Data = OpTypeStruct %_runtimearr_float
+%_ptr_StorageBuffer_Data = OpTypePointer StorageBuffer %Data
+     %buffer = OpVariable %_ptr_StorageBuffer_Data StorageBuffer
+%_ptr_Input_v3uint = OpTypePointer Input %v3uint
+%gl_GlobalInvocationID = OpVariable %_ptr_Input_v3uint Input
+      %count = OpSpecConstant %uint 1024
+         %13 = OpTypeFunction %void
+       %bool = OpTypeBool
+        %int = OpTypeInt 32 1
+      %int_0 = OpConstant %int 0
+%_ptr_StorageBuffer_float = OpTypePointer StorageBuffer %float
+    %float_2 = OpConstant %float 2
+       %main = OpFunction %void None %13
+         %15 = OpLabel
+         %18 = OpLoad %v3uint %gl_GlobalInvocationID
+         %19 = OpCompositeExtract %uint %18 0
+               OpSelectionMerge %17 None
+         %21 = OpULessThan %bool %19 %count
+               OpBranchConditional %21 %16 %17
+         %16 = OpLabel
+         %25 = OpAccessChain %_ptr_StorageBuffer_float %buffer %int_0 %19
+         %26 = OpLoad %float %25
+         %28 = OpFMul %float %26 %float_2
+         %29 = OpExtInst %float %1 Sqrt %28
+               OpStore %25 %29
+               OpBranch %17
+         %17 = OpLabel
+               OpReturn
+               OpFunctionEnd
+`
+	if text != expected {
+		t.Fatalf("unexpected module:\n%s", text)
+	}
+
+	r, err := gs.Reflect(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := r.EntryPoints; len(e) != 1 || e[0].Name != "main" || e[0].Stage != gs.ComputeShader || e[0].LocalSize != [3]uint32{64, 1, 1} {
+		t.Fatalf("unexpected entry points %+v", e)
+	}
+	binding := []gs.DescriptorBinding{{Name: "buffer", Set: 0, Binding: 1, Kind: gs.DescriptorStorageBuffer, Count: 1}}
+	if !reflect.DeepEqual(r.Bindings, binding) {
+		t.Fatalf("unexpected bindings %+v", r.Bindings)
+	}
+}
+
+func TestModuleDeclaresOnce(t *testing.T) {
+	m := NewModule()
+	float := m.TypeFloat(32)
+	if m.TypeFloat(32) != float || m.TypeVector(float, 4) != m.TypeVector(m.TypeFloat(32), 4) {
+		t.Fatal("expected types to be declared once")
+	}
+	if m.TypeFloat(16) == float || m.TypeInt(32, true) == m.TypeInt(32, false) {
+		t.Fatal("expected different types to have different ids")
+	}
+	if m.ConstantFloat(float, 1) != m.ConstantFloat(float, 1) || m.ConstantBool(true) == m.ConstantBool(false) {
+		t.Fatal("expected constants to be declared once")
+	}
+	if m.TypeStruct(float) == m.TypeStruct(float) || m.SpecConstantFloat(float, 1) == m.SpecConstantFloat(float, 1) {
+		t.Fatal("expected structs and specialization constants to be declared every time")
+	}
+	if m.ExtInstImport("GLSL.std.450") != m.ExtInstImport("GLSL.std.450") {
+		t.Fatal("expected instruction sets to be imported once")
+	}
+}
+
+func TestModuleErrors(t *testing.T) {
+	tests := map[string]func(m *Module){
+		"no capabilities declared": func(m *Module) {},
+		"isn't terminated": func(m *Module) {
+			m.Function(m.TypeVoid(), FunctionControlNone).Block()
+		},
+		"after the terminator": func(m *Module) {
+			b := m.Function(m.TypeVoid(), FunctionControlNone).Block()
+			b.Return()
+			b.Return()
+		},
+		"isn't a float type": func(m *Module) {
+			m.ConstantFloat(m.TypeInt(32, true), 1)
+		},
+		"doesn't fit": func(m *Module) {
+			m.ConstantInt(m.TypeInt(8, true), 128)
+		},
+		"isn't a known pointer": func(m *Module) {
+			b := m.Function(m.TypeVoid(), FunctionControlNone).Block()
+			b.AccessChain(m.TypeFloat(32), m.ConstantNull(m.TypeFloat(32)))
+			b.Return()
+		},
+	}
+	for message, test := range tests {
+		m := NewModule()
+		if message != "no capabilities declared" {
+			m.Capability(CapabilityShader)
+		}
+		test(m)
+		if _, err := m.Bytes(); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("expected an error containing '%s', got %v", message, err)
+		}
+	}
+}
+
+func TestModuleConstants(t *testing.T) {
+	m := NewModule()
+	last := func() []uint32 {
+		globals := m.sections[sectionGlobals]
+		for i := 0; ; i += int(globals[i] >> 16) {
+			if i+int(globals[i]>>16) == len(globals) {
+				return globals[i+3:]
+			}
+		}
+	}
+	tests := []struct {
+		declare  func() ID
+		expected []uint32
+	}{
+		{func() ID { return m.ConstantInt(m.TypeInt(8, true), -1) }, []uint32{0xffffffff}},
+		{func() ID { return m.ConstantUint(m.TypeInt(16, false), 0xffff) }, []uint32{0xffff}},
+		{func() ID { return m.ConstantInt(m.TypeInt(64, true), -2) }, []uint32{0xfffffffe, 0xffffffff}},
+		{func() ID { return m.ConstantFloat(m.TypeFloat(16), 1.5) }, []uint32{0x3e00}},
+		{func() ID { return m.ConstantFloat(m.TypeFloat(64), 0.5) }, []uint32{0, 0x3fe00000}},
+		{func() ID { return m.SpecConstantInt(m.TypeInt(32, true), -3) }, []uint32{0xfffffffd}},
+	}
+	for i, test := range tests {
+		test.declare()
+		if got := last(); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%d: expected %#x, got %#x", i, test.expected, got)
+		}
+	}
+	if m.err != nil {
+		t.Fatal(m.err)
+	}
+}
+
+func TestFloat16Bits(t *testing.T) {
+	for v, expected := range map[float64]uint16{
+		1:               0x3c00,
+		-2:              0xc000,
+		65504:           0x7bff,
+		65520:           0x7c00,
+		1e10:            0x7c00,
+		1 + 1.0/1024:    0x3c01,
+		1 + 1.0/2048:    0x3c00,
+		1 + 3.0/2048:    0x3c02,
+		1.0 / (1 << 24): 0x0001,
+		3.0 / (1 << 25): 0x0002,
+		1.0 / (1 << 14): 0x0400,
+		1e-9:            0,
+	} {
+		if got := float16Bits(v); got != expected {
+			t.Errorf("%g: expected %#04x, got %#04x", v, expected, got)
+		}
+	}
+}
+
+func TestModuleRoundTrip(t *testing.T) {
+	code, err := testKernel().Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := gs.Disassemble(code, gs.DisassemblyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	compiler := gs.NewCompiler()
+	defer compiler.Release()
+	options := gs.NewCompilerOptions()
+	defer options.Release()
+	options.SetTargetEnv(gs.Vulkan, gs.Vulkan_1_1)
+	res := compiler.AssembleIntoSPV(text, options)
+	defer res.Release()
+	if errors.Is(res.Error(), gs.BackendUnavailableError) {
+		t.Skip("requires libshaderc or glslc to assemble")
+	}
+	if res.Error() != nil {
+		t.Fatalf("%s\n%s", res.ErrorMessage(), text)
+	}
+	roundTrip, err := gs.Disassemble(res.Bytes(), gs.DisassemblyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The assembler may number the ids differently
+	ids := regexp.MustCompile(`%[0-9]+`)
+	normalize := func(text string) string {
+		numbers := map[string]string{}
+		text = regexp.MustCompile(`(?m)^;.*\n`).ReplaceAllString(text, "")
+		return ids.ReplaceAllStringFunc(text, func(id string) string {
+			if _, ok := numbers[id]; !ok {
+				numbers[id] = fmt.Sprint("%", len(numbers)+1)
+			}
+			return numbers[id]
+		})
+	}
+	if normalize(text) != normalize(roundTrip) {
+		t.Fatalf("round trip changed the module:\n%s\n%s", text, roundTrip)
+	}
+}
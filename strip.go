@@ -0,0 +1,127 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gshaderc
+
+import (
+	"strings"
+)
+
+// Decorations which only carry reflection information
+const (
+	decorationCounterBuffer  = 5634
+	decorationUserSemantic   = 5635
+	decorationUserTypeGOOGLE = 5636
+)
+
+// nonSemanticExtensions are only needed by the instructions and decorations
+// StripOptions.NonSemantic removes
+var nonSemanticExtensions = map[string]bool{
+	"SPV_KHR_non_semantic_info":      true,
+	"SPV_GOOGLE_hlsl_functionality1": true,
+	"SPV_GOOGLE_user_type":           true,
+}
+
+// StripOptions selects the debug information Strip removes
+type StripOptions struct {
+	// Names removes OpName and OpMemberName
+	Names bool
+	// Lines removes OpLine and OpNoLine
+	Lines bool
+	// Sources removes OpSource, OpSourceContinued, OpSourceExtension and
+	// OpModuleProcessed
+	Sources bool
+	// NonSemantic removes the instructions of NonSemantic extended
+	// instruction sets, such as the debug information of
+	// NonSemantic.Shader.DebugInfo.100, and the UserSemantic, UserTypeGOOGLE
+	// and CounterBuffer decorations
+	NonSemantic bool
+}
+
+// StripAll removes all the debug information
+var StripAll = StripOptions{Names: true, Lines: true, Sources: true, NonSemantic: true}
+
+// Strip removes debug information from a module and compacts its ids so
+// the bound is as low as it can be. Stripping lines, sources or non-semantic
+// instructions also removes the OpStrings nothing uses any more. Without any
+// options set only the ids are compacted.
+func Strip(spirv []byte, options StripOptions) ([]byte, error) {
+	m, err := parseSPIRVModule(spirv)
+	if err != nil {
+		return nil, err
+	}
+	nonSemantic := make(map[uint32]bool)
+	if options.NonSemantic {
+		for _, inst := range m.Instructions {
+			if inst.Opcode == opExtInstImport {
+				if name, _ := spirvString(inst.Words[2:]); strings.HasPrefix(name, "NonSemantic.") {
+					nonSemantic[inst.result()] = true
+				}
+			}
+		}
+	}
+
+	stripped := func(inst *spirvModuleInstruction) bool {
+		switch inst.Opcode {
+		case opName, opMemberName:
+			return options.Names
+		case opLine, opNoLine:
+			return options.Lines
+		case opSource, opSourceContinued, opSourceExtension, opModuleProcessed:
+			return options.Sources
+		case opExtInstImport:
+			return nonSemantic[inst.result()]
+		case opExtInst:
+			return nonSemantic[inst.Words[3]]
+		case opExtension:
+			name, _ := spirvString(inst.Words[1:])
+			return options.NonSemantic && nonSemanticExtensions[name]
+		case opDecorate, opDecorateID, opDecorateString:
+			return options.NonSemantic && reflectionDecoration(inst.Words[2])
+		case opMemberDecorate, opMemberDecorateString:
+			return options.NonSemantic && reflectionDecoration(inst.Words[3])
+		}
+		return false
+	}
+	var kept []*spirvModuleInstruction
+	for _, inst := range m.Instructions {
+		if !stripped(inst) {
+			kept = append(kept, inst)
+		}
+	}
+
+	// Strings are only used by debug instructions
+	used := make(map[uint32]bool)
+	for _, inst := range kept {
+		for _, offset := range inst.IDs {
+			if offset != inst.Result {
+				used[inst.Words[offset]] = true
+			}
+		}
+	}
+	m.Instructions = kept[:0]
+	for _, inst := range kept {
+		if inst.Opcode != opString || used[inst.result()] || !(options.Lines || options.Sources || options.NonSemantic) {
+			m.Instructions = append(m.Instructions, inst)
+		}
+	}
+	m.compact()
+	return m.bytes(), nil
+}
+
+// reflectionDecoration returns true for the decorations StripOptions.NonSemantic
+// removes
+func reflectionDecoration(decoration uint32) bool {
+	return decoration == decorationCounterBuffer || decoration == decorationUserSemantic || decoration == decorationUserTypeGOOGLE
+}
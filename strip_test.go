@@ -0,0 +1,116 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gshaderc
+
+import (
+	"strings"
+	"testing"
+)
+
+// debugModule is a fragment shader with every kind of debug information
+func debugModule(set string) []byte {
+	return testModule(
+		[]uint32{opCapability, 1},
+		cat([]uint32{opExtension}, testString("SPV_KHR_non_semantic_info")),
+		cat([]uint32{opExtInstImport, 40}, testString("GLSL.std.450")),
+		cat([]uint32{opExtInstImport, 41}, testString(set)),
+		[]uint32{opMemoryModel, 0, 1},
+		cat([]uint32{opEntryPoint, 4, 50}, testString("main")),
+		[]uint32{opExecutionMode, 50, 7},
+		cat([]uint32{opString, 42}, testString("a.frag")),
+		cat([]uint32{opString, 43}, testString("unused")),
+		[]uint32{opSource, 2, 450, 42},
+		cat([]uint32{opSourceExtension}, testString("GL_GOOGLE_include_directive")),
+		cat([]uint32{opName, 50}, testString("main")),
+		cat([]uint32{opModuleProcessed}, testString("client vulkan100")),
+		cat([]uint32{opDecorateString, 50, decorationUserSemantic}, testString("MAIN")),
+		[]uint32{opTypeVoid, 60},
+		[]uint32{opTypeFunction, 61, 60},
+		[]uint32{opExtInst, 60, 70, 41, 35, 42}, // DebugSource
+		[]uint32{opFunction, 60, 50, 0, 61},
+		[]uint32{opLabel, 80},
+		[]uint32{opLine, 42, 3, 1},
+		[]uint32{opExtInst, 60, 81, 41, 103, 70}, // DebugLine
+		[]uint32{opNoLine},
+		[]uint32{253}, // OpReturn
+		[]uint32{opFunctionEnd},
+	)
+}
+
+func TestStrip(t *testing.T) {
+	stripped, err := Strip(debugModule("NonSemantic.Shader.DebugInfo.100"), StripAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := Disassemble(stripped, DisassemblyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `; SPIR-V
+; Version: 1.0
+; Generator: Khronos; 0
+; Bound: 6
+; Schema: 0
+               OpCapability Shader
+          %1 = OpExtInstImport "GLSL.std.450"
+               OpMemoryModel Logical GLSL450
+               OpEntryPoint Fragment %2 "main"
+               OpExecutionMode %2 OriginUpperLeft
+       %void = OpTypeVoid
+          %4 = OpTypeFunction %void
+          %2 = OpFunction %void None %4
+          %5 = OpLabel
+               OpReturn
+               OpFunctionEnd
+`
+	if text != expected {
+		t.Fatalf("unexpected module:\n%s", text)
+	}
+
+	for _, test := range []struct {
+		options StripOptions
+		removed []string
+		kept    []string
+	}{
+		{StripOptions{Names: true}, []string{"OpName"}, []string{"OpLine", "OpSource ", "OpString \"a.frag\"", "OpString \"unused\"", "OpExtInst %void %2 103"}},
+		{StripOptions{Lines: true}, []string{"OpLine", "OpNoLine", "OpString \"unused\""}, []string{"OpName", "OpString \"a.frag\""}},
+		{StripOptions{Sources: true}, []string{"OpSource", "OpModuleProcessed"}, []string{"OpLine", "OpString \"a.frag\""}},
+		{StripOptions{NonSemantic: true}, []string{"NonSemantic", "UserSemantic", "OpExtension"}, []string{"OpName", "OpLine", "GLSL.std.450"}},
+		{StripOptions{}, nil, []string{"OpString \"unused\"", "; Bound: 11\n"}},
+	} {
+		stripped, err := Strip(debugModule("NonSemantic.Shader.DebugInfo.100"), test.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text, err := Disassemble(stripped, DisassemblyOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range test.removed {
+			if strings.Contains(text, s) {
+				t.Errorf("%+v: expected %s to be removed:\n%s", test.options, s, text)
+			}
+		}
+		for _, s := range test.kept {
+			if !strings.Contains(text, s) {
+				t.Errorf("%+v: expected %s to be kept:\n%s", test.options, s, text)
+			}
+		}
+	}
+
+	if _, err := Strip(debugModule("OpenCL.DebugInfo.100"), StripAll); err == nil {
+		t.Fatal("expected instructions with literal operands to fail")
+	}
+}
@@ -14,77 +14,77 @@
 
 package gshaderc
 
-// #cgo LDFLAGS: -lshaderc_combined -lstdc++ -lm
-// #include <shaderc/shaderc.h>
-import "C"
+import "fmt"
 
 type ShaderType int
 
 const (
 	// Forced shader kinds. These shader kinds force the compiler to compile the
 	// source code as the specified kind of shader.
-	VertexShader         ShaderType = ShaderType(C.shaderc_vertex_shader)
-	FragmentShader                  = ShaderType(C.shaderc_fragment_shader)
-	ComputeShader                   = ShaderType(C.shaderc_compute_shader)
-	GeometryShader                  = ShaderType(C.shaderc_geometry_shader)
-	TessControlShader               = ShaderType(C.shaderc_tess_control_shader)
-	TessEvaluationShader            = ShaderType(C.shaderc_tess_evaluation_shader)
+	VertexShader ShaderType = iota
+	FragmentShader
+	ComputeShader
+	GeometryShader
+	TessControlShader
+	TessEvaluationShader
 	// Deduce the shader kind from #pragma annotation in the source code. Compiler
 	// will emit error if #pragma annotation is not found.
-	InferFromSource = ShaderType(C.shaderc_glsl_infer_from_source)
+	InferFromSource
 	// Default shader kinds. Compiler will fall back to compile the source code as
 	// the specified kind of shader when #pragma annotation is not found in the
 	// source code.
-	DefaultVertexShader         = ShaderType(C.shaderc_glsl_default_vertex_shader)
-	DefaultFragmentShader       = ShaderType(C.shaderc_glsl_default_fragment_shader)
-	DefaultComputeShader        = ShaderType(C.shaderc_glsl_default_compute_shader)
-	DefaultGeometryShader       = ShaderType(C.shaderc_glsl_default_geometry_shader)
-	DefaultTessControlShader    = ShaderType(C.shaderc_glsl_default_tess_control_shader)
-	DefaultTessEcaluationShader = ShaderType(C.shaderc_glsl_default_tess_evaluation_shader)
-
-	SPIRVAssembly = ShaderType(C.shaderc_spirv_assembly)
+	DefaultVertexShader
+	DefaultFragmentShader
+	DefaultComputeShader
+	DefaultGeometryShader
+	DefaultTessControlShader
+	DefaultTessEcaluationShader
+
+	SPIRVAssembly
 )
 
 type Target int
 
 const (
-	Vulkan Target = Target(C.shaderc_target_env_vulkan) // SPIR-V under Vulkan semantics
-	OpenGL        = Target(C.shaderc_target_env_opengl) // SPIR-V under OpenGL semantics
+	Vulkan Target = iota // SPIR-V under Vulkan semantics
+	OpenGL               // SPIR-V under OpenGL semantics
 	// NOTE: SPIR-V code generation is not supported for shaders under OpenGL
 	// compatibility profile.
-	OpenGLCompat = Target(C.shaderc_target_env_opengl_compat) // SPIR-V under OpenGL semantics,
+	OpenGLCompat // SPIR-V under OpenGL semantics,
 	// including compatibility profile
 	// functions
-	WebGPU  = Target(C.shaderc_target_env_webgpu) // SPIR-V under WebGPU semantics
+	WebGPU  // SPIR-V under WebGPU semantics
 	Default = Vulkan
 )
 
 type EnvVersion int
 
 const (
-	Vulkan_1_0 EnvVersion = EnvVersion(C.shaderc_env_version_vulkan_1_0)
-	Vulkan_1_1            = EnvVersion(C.shaderc_env_version_vulkan_1_1)
-	OpenGL_4_5            = EnvVersion(C.shaderc_env_version_opengl_4_5)
-	WebGPUAll             = EnvVersion(C.shaderc_env_version_webgpu)
+	// Values match shaderc_env_version, Vulkan versions are encoded the
+	// same way as VK_MAKE_VERSION
+	Vulkan_1_0 EnvVersion = 1 << 22
+	Vulkan_1_1 EnvVersion = 1<<22 | 1<<12
+	OpenGL_4_5 EnvVersion = 450
+	WebGPUAll  EnvVersion = 451
 )
 
 type SPIRVVersion int
 
 const (
-	SPIRV_1_0 SPIRVVersion = SPIRVVersion(C.shaderc_spirv_version_1_0)
-	SPIRV_1_1 SPIRVVersion = SPIRVVersion(C.shaderc_spirv_version_1_1)
-	SPIRV_1_2 SPIRVVersion = SPIRVVersion(C.shaderc_spirv_version_1_2)
-	SPIRV_1_3 SPIRVVersion = SPIRVVersion(C.shaderc_spirv_version_1_3)
-	SPIRV_1_4 SPIRVVersion = SPIRVVersion(C.shaderc_spirv_version_1_4)
-	SPIRV_1_5 SPIRVVersion = SPIRVVersion(C.shaderc_spirv_version_1_5)
+	SPIRV_1_0 SPIRVVersion = 0x010000
+	SPIRV_1_1 SPIRVVersion = 0x010100
+	SPIRV_1_2 SPIRVVersion = 0x010200
+	SPIRV_1_3 SPIRVVersion = 0x010300
+	SPIRV_1_4 SPIRVVersion = 0x010400
+	SPIRV_1_5 SPIRVVersion = 0x010500
 )
 
 type OptimizationLevel int
 
 const (
-	Zero        OptimizationLevel = OptimizationLevel(C.shaderc_optimization_level_zero) // no optimization
-	Size                          = OptimizationLevel(C.shaderc_optimization_level_size)
-	Performance                   = OptimizationLevel(C.shaderc_optimization_level_performance)
+	Zero OptimizationLevel = iota // no optimization
+	Size
+	Performance
 )
 
 // Uniform resource kinds.
@@ -94,111 +94,212 @@ type UniformKind int
 
 const (
 	// Image and image buffer.
-	UniformKindImage UniformKind = UniformKind(C.shaderc_uniform_kind_image)
+	UniformKindImage UniformKind = iota
 	// Pure sampler.
-	UniformKindSampler = UniformKind(C.shaderc_uniform_kind_sampler)
+	UniformKindSampler
 	// Sampled texture in GLSL, and Shader Resource View in HLSL.
-	UniformKindTexture = UniformKind(C.shaderc_uniform_kind_texture)
+	UniformKindTexture
 	// Uniform Buffer Object (UBO) in GLSL.  Cbuffer in HLSL.
-	UniformKindBuffer = UniformKind(C.shaderc_uniform_kind_buffer)
+	UniformKindBuffer
 	// Shader Storage Buffer Object (SSBO) in GLSL.
-	UniformKindStorageBuffer = UniformKind(C.shaderc_uniform_kind_storage_buffer)
+	UniformKindStorageBuffer
 	// Unordered Access View, in HLSL.  (Writable storage image or storage
 	// buffer.)
-	UniformKindUnorderedAccessView = UniformKind(C.shaderc_uniform_kind_unordered_access_view)
+	UniformKindUnorderedAccessView
 )
 
 type ResourceLimit int
 
-/*
-	Some vim regex foo, incase this list ever needs to be regenerated
-
-	s/\(shaderc_\(.*\)\),/\2=ResourceLimit(C.\1)/g
-	s/^\s\+limit_max_\([a-z]\+\)/Max\u\1/g
-	s/^\s\+limit_max_\([a-z]\+\)_\([a-z]\+\)_\([a-z]\+\)_\([a-z]\+\)_\([a-z]\+\)/Max\u\1\u\2\u\3\u\4\u\5/g
-*/
+// The resource limits are declared in the same order as shaderc_limit in
+// shaderc.h so that their values line up with the C enum.
 const (
-	MaxLights                                 ResourceLimit = ResourceLimit(C.shaderc_limit_max_lights)
-	MaxClipPlanes                                           = ResourceLimit(C.shaderc_limit_max_clip_planes)
-	MaxTextureUnits                                         = ResourceLimit(C.shaderc_limit_max_texture_units)
-	MaxTextureCoords                                        = ResourceLimit(C.shaderc_limit_max_texture_coords)
-	MaxVertexAttribs                                        = ResourceLimit(C.shaderc_limit_max_vertex_attribs)
-	MaxVertexUniformComponents                              = ResourceLimit(C.shaderc_limit_max_vertex_uniform_components)
-	MaxVaryingFloats                                        = ResourceLimit(C.shaderc_limit_max_varying_floats)
-	MaxVertexTextureImageUnits                              = ResourceLimit(C.shaderc_limit_max_vertex_texture_image_units)
-	MaxCombinedTextureImageUnits                            = ResourceLimit(C.shaderc_limit_max_combined_texture_image_units)
-	MaxTextureImageUnits                                    = ResourceLimit(C.shaderc_limit_max_texture_image_units)
-	MaxFragmentUniformComponents                            = ResourceLimit(C.shaderc_limit_max_fragment_uniform_components)
-	MaxDrawBuffers                                          = ResourceLimit(C.shaderc_limit_max_draw_buffers)
-	MaxVertexUniformVectors                                 = ResourceLimit(C.shaderc_limit_max_vertex_uniform_vectors)
-	MaxVaryingVectors                                       = ResourceLimit(C.shaderc_limit_max_varying_vectors)
-	MaxFragmentUniformVectors                               = ResourceLimit(C.shaderc_limit_max_fragment_uniform_vectors)
-	MaxVertexOutputVectors                                  = ResourceLimit(C.shaderc_limit_max_vertex_output_vectors)
-	MaxFragmentInputVectors                                 = ResourceLimit(C.shaderc_limit_max_fragment_input_vectors)
-	MinProgramTexelOffset                                   = ResourceLimit(C.shaderc_limit_min_program_texel_offset)
-	MaxProgramTexelOffset                                   = ResourceLimit(C.shaderc_limit_max_program_texel_offset)
-	MaxClipDistances                                        = ResourceLimit(C.shaderc_limit_max_clip_distances)
-	MaxComputeWorkGroupCountX                               = ResourceLimit(C.shaderc_limit_max_compute_work_group_count_x)
-	MaxComputeWorkGroupCountY                               = ResourceLimit(C.shaderc_limit_max_compute_work_group_count_y)
-	MaxComputeWorkGroupCountZ                               = ResourceLimit(C.shaderc_limit_max_compute_work_group_count_z)
-	MaxComputeWorkGroupSizeX                                = ResourceLimit(C.shaderc_limit_max_compute_work_group_size_x)
-	MaxComputeWorkGroupSizeY                                = ResourceLimit(C.shaderc_limit_max_compute_work_group_size_y)
-	MaxComputeWorkGroupSizeZ                                = ResourceLimit(C.shaderc_limit_max_compute_work_group_size_z)
-	MaxComputeUniformComponents                             = ResourceLimit(C.shaderc_limit_max_compute_uniform_components)
-	MaxComputeTextureImageUnits                             = ResourceLimit(C.shaderc_limit_max_compute_texture_image_units)
-	MaxComputeImageUniforms                                 = ResourceLimit(C.shaderc_limit_max_compute_image_uniforms)
-	MaxComputeAtomicCounters                                = ResourceLimit(C.shaderc_limit_max_compute_atomic_counters)
-	MaxComputeAtomicCounterBuffers                          = ResourceLimit(C.shaderc_limit_max_compute_atomic_counter_buffers)
-	MaxVaryingComponents                                    = ResourceLimit(C.shaderc_limit_max_varying_components)
-	MaxVertexOutputComponents                               = ResourceLimit(C.shaderc_limit_max_vertex_output_components)
-	MaxGeometryInputComponents                              = ResourceLimit(C.shaderc_limit_max_geometry_input_components)
-	MaxGeometryOutputComponents                             = ResourceLimit(C.shaderc_limit_max_geometry_output_components)
-	MaxFragmentInputComponents                              = ResourceLimit(C.shaderc_limit_max_fragment_input_components)
-	MaxImageUnits                                           = ResourceLimit(C.shaderc_limit_max_image_units)
-	MaxCombinedImageUnitsAndFragment_outputs                = ResourceLimit(C.shaderc_limit_max_combined_image_units_and_fragment_outputs)
-	MaxCombinedShaderOutputResources                        = ResourceLimit(C.shaderc_limit_max_combined_shader_output_resources)
-	MaxImageSamples                                         = ResourceLimit(C.shaderc_limit_max_image_samples)
-	MaxVertexImageUniforms                                  = ResourceLimit(C.shaderc_limit_max_vertex_image_uniforms)
-	MaxTessControlImageUniforms                             = ResourceLimit(C.shaderc_limit_max_tess_control_image_uniforms)
-	MaxTessEvaluationImageUniforms                          = ResourceLimit(C.shaderc_limit_max_tess_evaluation_image_uniforms)
-	MaxGeometryImageUniforms                                = ResourceLimit(C.shaderc_limit_max_geometry_image_uniforms)
-	MaxFragmentImageUniforms                                = ResourceLimit(C.shaderc_limit_max_fragment_image_uniforms)
-	MaxCombinedImageUniforms                                = ResourceLimit(C.shaderc_limit_max_combined_image_uniforms)
-	MaxGeometryTextureImageUnits                            = ResourceLimit(C.shaderc_limit_max_geometry_texture_image_units)
-	MaxGeometryOutputVertices                               = ResourceLimit(C.shaderc_limit_max_geometry_output_vertices)
-	MaxGeometryTotalOutputComponents                        = ResourceLimit(C.shaderc_limit_max_geometry_total_output_components)
-	MaxGeometryUniformComponents                            = ResourceLimit(C.shaderc_limit_max_geometry_uniform_components)
-	MaxGeometryVaryingComponents                            = ResourceLimit(C.shaderc_limit_max_geometry_varying_components)
-	MaxTessControlInputComponents                           = ResourceLimit(C.shaderc_limit_max_tess_control_input_components)
-	MaxTessControlOutputComponents                          = ResourceLimit(C.shaderc_limit_max_tess_control_output_components)
-	MaxTessControlTextureImageUnits                         = ResourceLimit(C.shaderc_limit_max_tess_control_texture_image_units)
-	MaxTessControlUniformComponents                         = ResourceLimit(C.shaderc_limit_max_tess_control_uniform_components)
-	MaxTessControlTotalOutputComponents                     = ResourceLimit(C.shaderc_limit_max_tess_control_total_output_components)
-	MaxTessEvaluationInputComponents                        = ResourceLimit(C.shaderc_limit_max_tess_evaluation_input_components)
-	MaxTessEvaluationOutputComponents                       = ResourceLimit(C.shaderc_limit_max_tess_evaluation_output_components)
-	MaxTessEvaluationTextureImageUnits                      = ResourceLimit(C.shaderc_limit_max_tess_evaluation_texture_image_units)
-	MaxTessEvaluationUniformComponents                      = ResourceLimit(C.shaderc_limit_max_tess_evaluation_uniform_components)
-	MaxTessPatchComponents                                  = ResourceLimit(C.shaderc_limit_max_tess_patch_components)
-	MaxPatchVertices                                        = ResourceLimit(C.shaderc_limit_max_patch_vertices)
-	MaxTessGenLevel                                         = ResourceLimit(C.shaderc_limit_max_tess_gen_level)
-	MaxViewports                                            = ResourceLimit(C.shaderc_limit_max_viewports)
-	MaxVertexAtomicCounters                                 = ResourceLimit(C.shaderc_limit_max_vertex_atomic_counters)
-	MaxTessControlAtomicCounters                            = ResourceLimit(C.shaderc_limit_max_tess_control_atomic_counters)
-	MaxTessEvaluationAtomicCounters                         = ResourceLimit(C.shaderc_limit_max_tess_evaluation_atomic_counters)
-	MaxGeometryAtomicCounters                               = ResourceLimit(C.shaderc_limit_max_geometry_atomic_counters)
-	MaxFragmentAtomicCounters                               = ResourceLimit(C.shaderc_limit_max_fragment_atomic_counters)
-	MaxCombinedAtomicCounters                               = ResourceLimit(C.shaderc_limit_max_combined_atomic_counters)
-	MaxAtomicCounterBindings                                = ResourceLimit(C.shaderc_limit_max_atomic_counter_bindings)
-	MaxVertexAtomicCounterBuffers                           = ResourceLimit(C.shaderc_limit_max_vertex_atomic_counter_buffers)
-	MaxTessControlAtomicCounterBuffers                      = ResourceLimit(C.shaderc_limit_max_tess_control_atomic_counter_buffers)
-	MaxTessEvaluationAtomicCounterBuffers                   = ResourceLimit(C.shaderc_limit_max_tess_evaluation_atomic_counter_buffers)
-	MaxGeometryAtomicCounterBuffers                         = ResourceLimit(C.shaderc_limit_max_geometry_atomic_counter_buffers)
-	MaxFragmentAtomicCounterBuffers                         = ResourceLimit(C.shaderc_limit_max_fragment_atomic_counter_buffers)
-	MaxCombinedAtomicCounterBuffers                         = ResourceLimit(C.shaderc_limit_max_combined_atomic_counter_buffers)
-	MaxAtomicCounterBufferSize                              = ResourceLimit(C.shaderc_limit_max_atomic_counter_buffer_size)
-	MaxTransformFeedbackBuffers                             = ResourceLimit(C.shaderc_limit_max_transform_feedback_buffers)
-	MaxTransformFeedbackInterleavedComponents               = ResourceLimit(C.shaderc_limit_max_transform_feedback_interleaved_components)
-	MaxCullDistances                                        = ResourceLimit(C.shaderc_limit_max_cull_distances)
-	MaxCombinedClipAndCullDistances                         = ResourceLimit(C.shaderc_limit_max_combined_clip_and_cull_distances)
-	MaxSamples                                              = ResourceLimit(C.shaderc_limit_max_samples)
+	MaxLights ResourceLimit = iota
+	MaxClipPlanes
+	MaxTextureUnits
+	MaxTextureCoords
+	MaxVertexAttribs
+	MaxVertexUniformComponents
+	MaxVaryingFloats
+	MaxVertexTextureImageUnits
+	MaxCombinedTextureImageUnits
+	MaxTextureImageUnits
+	MaxFragmentUniformComponents
+	MaxDrawBuffers
+	MaxVertexUniformVectors
+	MaxVaryingVectors
+	MaxFragmentUniformVectors
+	MaxVertexOutputVectors
+	MaxFragmentInputVectors
+	MinProgramTexelOffset
+	MaxProgramTexelOffset
+	MaxClipDistances
+	MaxComputeWorkGroupCountX
+	MaxComputeWorkGroupCountY
+	MaxComputeWorkGroupCountZ
+	MaxComputeWorkGroupSizeX
+	MaxComputeWorkGroupSizeY
+	MaxComputeWorkGroupSizeZ
+	MaxComputeUniformComponents
+	MaxComputeTextureImageUnits
+	MaxComputeImageUniforms
+	MaxComputeAtomicCounters
+	MaxComputeAtomicCounterBuffers
+	MaxVaryingComponents
+	MaxVertexOutputComponents
+	MaxGeometryInputComponents
+	MaxGeometryOutputComponents
+	MaxFragmentInputComponents
+	MaxImageUnits
+	MaxCombinedImageUnitsAndFragment_outputs
+	MaxCombinedShaderOutputResources
+	MaxImageSamples
+	MaxVertexImageUniforms
+	MaxTessControlImageUniforms
+	MaxTessEvaluationImageUniforms
+	MaxGeometryImageUniforms
+	MaxFragmentImageUniforms
+	MaxCombinedImageUniforms
+	MaxGeometryTextureImageUnits
+	MaxGeometryOutputVertices
+	MaxGeometryTotalOutputComponents
+	MaxGeometryUniformComponents
+	MaxGeometryVaryingComponents
+	MaxTessControlInputComponents
+	MaxTessControlOutputComponents
+	MaxTessControlTextureImageUnits
+	MaxTessControlUniformComponents
+	MaxTessControlTotalOutputComponents
+	MaxTessEvaluationInputComponents
+	MaxTessEvaluationOutputComponents
+	MaxTessEvaluationTextureImageUnits
+	MaxTessEvaluationUniformComponents
+	MaxTessPatchComponents
+	MaxPatchVertices
+	MaxTessGenLevel
+	MaxViewports
+	MaxVertexAtomicCounters
+	MaxTessControlAtomicCounters
+	MaxTessEvaluationAtomicCounters
+	MaxGeometryAtomicCounters
+	MaxFragmentAtomicCounters
+	MaxCombinedAtomicCounters
+	MaxAtomicCounterBindings
+	MaxVertexAtomicCounterBuffers
+	MaxTessControlAtomicCounterBuffers
+	MaxTessEvaluationAtomicCounterBuffers
+	MaxGeometryAtomicCounterBuffers
+	MaxFragmentAtomicCounterBuffers
+	MaxCombinedAtomicCounterBuffers
+	MaxAtomicCounterBufferSize
+	MaxTransformFeedbackBuffers
+	MaxTransformFeedbackInterleavedComponents
+	MaxCullDistances
+	MaxCombinedClipAndCullDistances
+	MaxSamples
 )
+
+// resourceLimitNames maps each ResourceLimit to the name glslang uses for it
+// in resource limit files and glslc's -flimit option
+var resourceLimitNames = map[ResourceLimit]string{
+	MaxLights:                                 "MaxLights",
+	MaxClipPlanes:                             "MaxClipPlanes",
+	MaxTextureUnits:                           "MaxTextureUnits",
+	MaxTextureCoords:                          "MaxTextureCoords",
+	MaxVertexAttribs:                          "MaxVertexAttribs",
+	MaxVertexUniformComponents:                "MaxVertexUniformComponents",
+	MaxVaryingFloats:                          "MaxVaryingFloats",
+	MaxVertexTextureImageUnits:                "MaxVertexTextureImageUnits",
+	MaxCombinedTextureImageUnits:              "MaxCombinedTextureImageUnits",
+	MaxTextureImageUnits:                      "MaxTextureImageUnits",
+	MaxFragmentUniformComponents:              "MaxFragmentUniformComponents",
+	MaxDrawBuffers:                            "MaxDrawBuffers",
+	MaxVertexUniformVectors:                   "MaxVertexUniformVectors",
+	MaxVaryingVectors:                         "MaxVaryingVectors",
+	MaxFragmentUniformVectors:                 "MaxFragmentUniformVectors",
+	MaxVertexOutputVectors:                    "MaxVertexOutputVectors",
+	MaxFragmentInputVectors:                   "MaxFragmentInputVectors",
+	MinProgramTexelOffset:                     "MinProgramTexelOffset",
+	MaxProgramTexelOffset:                     "MaxProgramTexelOffset",
+	MaxClipDistances:                          "MaxClipDistances",
+	MaxComputeWorkGroupCountX:                 "MaxComputeWorkGroupCountX",
+	MaxComputeWorkGroupCountY:                 "MaxComputeWorkGroupCountY",
+	MaxComputeWorkGroupCountZ:                 "MaxComputeWorkGroupCountZ",
+	MaxComputeWorkGroupSizeX:                  "MaxComputeWorkGroupSizeX",
+	MaxComputeWorkGroupSizeY:                  "MaxComputeWorkGroupSizeY",
+	MaxComputeWorkGroupSizeZ:                  "MaxComputeWorkGroupSizeZ",
+	MaxComputeUniformComponents:               "MaxComputeUniformComponents",
+	MaxComputeTextureImageUnits:               "MaxComputeTextureImageUnits",
+	MaxComputeImageUniforms:                   "MaxComputeImageUniforms",
+	MaxComputeAtomicCounters:                  "MaxComputeAtomicCounters",
+	MaxComputeAtomicCounterBuffers:            "MaxComputeAtomicCounterBuffers",
+	MaxVaryingComponents:                      "MaxVaryingComponents",
+	MaxVertexOutputComponents:                 "MaxVertexOutputComponents",
+	MaxGeometryInputComponents:                "MaxGeometryInputComponents",
+	MaxGeometryOutputComponents:               "MaxGeometryOutputComponents",
+	MaxFragmentInputComponents:                "MaxFragmentInputComponents",
+	MaxImageUnits:                             "MaxImageUnits",
+	MaxCombinedImageUnitsAndFragment_outputs:  "MaxCombinedImageUnitsAndFragmentOutputs",
+	MaxCombinedShaderOutputResources:          "MaxCombinedShaderOutputResources",
+	MaxImageSamples:                           "MaxImageSamples",
+	MaxVertexImageUniforms:                    "MaxVertexImageUniforms",
+	MaxTessControlImageUniforms:               "MaxTessControlImageUniforms",
+	MaxTessEvaluationImageUniforms:            "MaxTessEvaluationImageUniforms",
+	MaxGeometryImageUniforms:                  "MaxGeometryImageUniforms",
+	MaxFragmentImageUniforms:                  "MaxFragmentImageUniforms",
+	MaxCombinedImageUniforms:                  "MaxCombinedImageUniforms",
+	MaxGeometryTextureImageUnits:              "MaxGeometryTextureImageUnits",
+	MaxGeometryOutputVertices:                 "MaxGeometryOutputVertices",
+	MaxGeometryTotalOutputComponents:          "MaxGeometryTotalOutputComponents",
+	MaxGeometryUniformComponents:              "MaxGeometryUniformComponents",
+	MaxGeometryVaryingComponents:              "MaxGeometryVaryingComponents",
+	MaxTessControlInputComponents:             "MaxTessControlInputComponents",
+	MaxTessControlOutputComponents:            "MaxTessControlOutputComponents",
+	MaxTessControlTextureImageUnits:           "MaxTessControlTextureImageUnits",
+	MaxTessControlUniformComponents:           "MaxTessControlUniformComponents",
+	MaxTessControlTotalOutputComponents:       "MaxTessControlTotalOutputComponents",
+	MaxTessEvaluationInputComponents:          "MaxTessEvaluationInputComponents",
+	MaxTessEvaluationOutputComponents:         "MaxTessEvaluationOutputComponents",
+	MaxTessEvaluationTextureImageUnits:        "MaxTessEvaluationTextureImageUnits",
+	MaxTessEvaluationUniformComponents:        "MaxTessEvaluationUniformComponents",
+	MaxTessPatchComponents:                    "MaxTessPatchComponents",
+	MaxPatchVertices:                          "MaxPatchVertices",
+	MaxTessGenLevel:                           "MaxTessGenLevel",
+	MaxViewports:                              "MaxViewports",
+	MaxVertexAtomicCounters:                   "MaxVertexAtomicCounters",
+	MaxTessControlAtomicCounters:              "MaxTessControlAtomicCounters",
+	MaxTessEvaluationAtomicCounters:           "MaxTessEvaluationAtomicCounters",
+	MaxGeometryAtomicCounters:                 "MaxGeometryAtomicCounters",
+	MaxFragmentAtomicCounters:                 "MaxFragmentAtomicCounters",
+	MaxCombinedAtomicCounters:                 "MaxCombinedAtomicCounters",
+	MaxAtomicCounterBindings:                  "MaxAtomicCounterBindings",
+	MaxVertexAtomicCounterBuffers:             "MaxVertexAtomicCounterBuffers",
+	MaxTessControlAtomicCounterBuffers:        "MaxTessControlAtomicCounterBuffers",
+	MaxTessEvaluationAtomicCounterBuffers:     "MaxTessEvaluationAtomicCounterBuffers",
+	MaxGeometryAtomicCounterBuffers:           "MaxGeometryAtomicCounterBuffers",
+	MaxFragmentAtomicCounterBuffers:           "MaxFragmentAtomicCounterBuffers",
+	MaxCombinedAtomicCounterBuffers:           "MaxCombinedAtomicCounterBuffers",
+	MaxAtomicCounterBufferSize:                "MaxAtomicCounterBufferSize",
+	MaxTransformFeedbackBuffers:               "MaxTransformFeedbackBuffers",
+	MaxTransformFeedbackInterleavedComponents: "MaxTransformFeedbackInterleavedComponents",
+	MaxCullDistances:                          "MaxCullDistances",
+	MaxCombinedClipAndCullDistances:           "MaxCombinedClipAndCullDistances",
+	MaxSamples:                                "MaxSamples",
+}
+
+// String returns the glslang name of the resource limit, e.g. "MaxLights"
+func (r ResourceLimit) String() string {
+	if name, ok := resourceLimitNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("ResourceLimit(%d)", int(r))
+}
+
+// ParseResourceLimit returns the resource limit with the given glslang name
+func ParseResourceLimit(name string) (ResourceLimit, error) {
+	for limit, n := range resourceLimitNames {
+		if n == name {
+			return limit, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown resource limit: %s", name)
+}
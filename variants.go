@@ -0,0 +1,314 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gshaderc
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// VariantAxis is a macro which takes each of a set of values, one per
+// variant. If Optional is set there is also a variant where the macro isn't
+// defined at all, so an axis with Optional set and a single empty value
+// toggles an #ifdef.
+type VariantAxis struct {
+	Name     string   `json:"name"`
+	Values   []string `json:"values,omitempty"`
+	Optional bool     `json:"optional,omitempty"`
+}
+
+// VariantRule excludes every variant which defines all of the macros in
+// the rule with the given values
+type VariantRule map[string]string
+
+// VariantSet describes a matrix of macro axes and the combinations to exclude
+type VariantSet struct {
+	Axes     []VariantAxis `json:"axes"`
+	Excludes []VariantRule `json:"excludes,omitempty"`
+}
+
+// Variant is the set of macros which define a single permutation
+type Variant []Macro
+
+// Key returns a stable key for the variant, the macros sorted by name
+// in the form "A=1,B=2". The variant with no macros has an empty key.
+func (v Variant) Key() string {
+	parts := make([]string, len(v))
+	for i, m := range v {
+		if m.Value == "" {
+			parts[i] = m.Name
+		} else {
+			parts[i] = m.Name + "=" + m.Value
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// Value returns the value of the named macro, ok is false if the variant
+// doesn't define it
+func (v Variant) Value(name string) (value string, ok bool) {
+	for _, m := range v {
+		if m.Name == name {
+			return m.Value, true
+		}
+	}
+	return "", false
+}
+
+// Matches returns true if the variant defines every macro in the rule with
+// the given value
+func (r VariantRule) Matches(v Variant) bool {
+	for name, value := range r {
+		if actual, ok := v.Value(name); !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks that every variant of the set has a different key: axis
+// names must be unique, values must be unique within their axis and
+// neither may contain ',' or '='
+func (s *VariantSet) Validate() error {
+	names := make(map[string]bool)
+	for _, axis := range s.Axes {
+		if err := axis.validate(); err != nil {
+			return err
+		}
+		if names[axis.Name] {
+			return fmt.Errorf("duplicate variant axis '%s'", axis.Name)
+		}
+		names[axis.Name] = true
+	}
+	return nil
+}
+
+func (a VariantAxis) validate() error {
+	if a.Name == "" || strings.ContainsAny(a.Name, ",=") {
+		return fmt.Errorf("invalid variant axis name '%s'", a.Name)
+	}
+	values := make(map[string]bool)
+	for _, value := range a.Values {
+		if strings.ContainsAny(value, ",=") {
+			return fmt.Errorf("invalid value '%s' for variant axis '%s'", value, a.Name)
+		}
+		if values[value] {
+			return fmt.Errorf("duplicate value '%s' for variant axis '%s'", value, a.Name)
+		}
+		values[value] = true
+	}
+	return nil
+}
+
+// Variants returns every permutation of the axes which isn't excluded, in
+// a stable order. Variants of a set which doesn't pass Validate may share
+// keys.
+func (s *VariantSet) Variants() []Variant {
+	variants := []Variant{nil}
+	for _, axis := range s.Axes {
+		var next []Variant
+		for _, v := range variants {
+			if axis.Optional {
+				next = append(next, v)
+			}
+			for _, value := range axis.Values {
+				nv := append(append(Variant(nil), v...), Macro{Name: axis.Name, Value: value})
+				next = append(next, nv)
+			}
+		}
+		variants = next
+	}
+
+	var out []Variant
+	for _, v := range variants {
+		excluded := false
+		for _, rule := range s.Excludes {
+			if rule.Matches(v) {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// ParseVariantAxis parses an axis of the form "NAME=V1,V2", or just "NAME"
+// for an optional axis which toggles whether NAME is defined
+func ParseVariantAxis(s string) (VariantAxis, error) {
+	parts := strings.SplitN(s, "=", 2)
+	name := strings.TrimSpace(parts[0])
+	if name == "" {
+		return VariantAxis{}, fmt.Errorf("invalid variant axis '%s'", s)
+	}
+	axis := VariantAxis{Name: name, Values: []string{""}, Optional: true}
+	if len(parts) == 2 {
+		axis = VariantAxis{Name: name, Values: strings.Split(parts[1], ",")}
+	}
+	if err := axis.validate(); err != nil {
+		return VariantAxis{}, fmt.Errorf("invalid variant axis '%s': %w", s, err)
+	}
+	return axis, nil
+}
+
+// ParseVariantRule parses a rule of the form "A=1,B" where "B" means B is
+// defined without a value
+func ParseVariantRule(s string) (VariantRule, error) {
+	rule := VariantRule{}
+	for _, part := range strings.Split(s, ",") {
+		kv := strings.SplitN(part, "=", 2)
+		name := strings.TrimSpace(kv[0])
+		if name == "" {
+			return nil, fmt.Errorf("invalid variant rule '%s'", s)
+		}
+		if len(kv) == 1 {
+			rule[name] = ""
+		} else {
+			rule[name] = kv[1]
+		}
+	}
+	return rule, nil
+}
+
+// VariantResult is the result of compiling a single variant
+type VariantResult struct {
+	Variant      Variant
+	Key          string
+	Data         []byte
+	Err          error
+	ErrorMessage string
+	NumErrors    int
+	NumWarnings  int
+	// DuplicateOf is the key of the first variant which produced identical
+	// SPIR-V, Data is shared with that variant. It is empty if the output
+	// is unique.
+	DuplicateOf string
+}
+
+// VariantResults holds the result of compiling every variant in a set
+type VariantResults struct {
+	// Keys are the variant keys in the order returned by VariantSet.Variants
+	Keys    []string
+	Results map[string]*VariantResult
+
+	err error
+}
+
+// Err returns the error of the first variant which failed to compile, or
+// the error which kept the variants from being compiled at all, if any
+func (r *VariantResults) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+	for _, key := range r.Keys {
+		if res := r.Results[key]; res.Err != nil {
+			return fmt.Errorf("variant '%s': %w", key, res.Err)
+		}
+	}
+	return nil
+}
+
+// Unique returns the successfully compiled variants which aren't
+// duplicates of another variant
+func (r *VariantResults) Unique() []*VariantResult {
+	var unique []*VariantResult
+	for _, key := range r.Keys {
+		if res := r.Results[key]; res.Err == nil && res.DuplicateOf == "" {
+			unique = append(unique, res)
+		}
+	}
+	return unique
+}
+
+// CompileVariants compiles the source once per variant in the set into
+// SPIR-V, each variant's macros are added to a clone of the given options.
+// Variants are compiled concurrently, so the include resolver must be safe
+// to call from multiple goroutines. Variants which produce identical SPIR-V
+// are detected and marked with DuplicateOf. Nothing is compiled if the set
+// doesn't pass Validate, Err returns why.
+func (c *Compiler) CompileVariants(source string, shaderType ShaderType, inputFilename string, entryPoint string, options *CompilerOptions, set *VariantSet) *VariantResults {
+	if options == nil {
+		options = NewCompilerOptions()
+	}
+	failed := func(err error) *VariantResults {
+		return &VariantResults{Results: map[string]*VariantResult{}, err: err}
+	}
+	if err := set.Validate(); err != nil {
+		return failed(err)
+	}
+	variants := set.Variants()
+	results := &VariantResults{
+		Keys:    make([]string, len(variants)),
+		Results: make(map[string]*VariantResult, len(variants)),
+	}
+	ordered := make([]*VariantResult, len(variants))
+	for i, v := range variants {
+		ordered[i] = &VariantResult{Variant: v, Key: v.Key()}
+		if _, ok := results.Results[ordered[i].Key]; ok {
+			return failed(fmt.Errorf("more than one variant has the key '%s'", ordered[i].Key))
+		}
+		results.Keys[i] = ordered[i].Key
+		results.Results[ordered[i].Key] = ordered[i]
+	}
+
+	jobs := make(chan *VariantResult)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for vr := range jobs {
+				vopts := options.Clone()
+				for _, m := range vr.Variant {
+					vopts.AddMacroDefinition(m.Name, m.Value)
+				}
+				res := c.CompileIntoSPV(source, shaderType, inputFilename, entryPoint, vopts)
+				vr.Data = res.Bytes()
+				vr.Err = res.Error()
+				vr.ErrorMessage = res.ErrorMessage()
+				vr.NumErrors = res.NumErrors()
+				vr.NumWarnings = res.NumWarnings()
+				res.Release()
+			}
+		}()
+	}
+	for _, vr := range ordered {
+		jobs <- vr
+	}
+	close(jobs)
+	wg.Wait()
+
+	seen := make(map[[sha256.Size]byte]*VariantResult)
+	for _, vr := range ordered {
+		if vr.Err != nil {
+			continue
+		}
+		sum := sha256.Sum256(vr.Data)
+		if first, ok := seen[sum]; ok {
+			vr.DuplicateOf = first.Key
+			vr.Data = first.Data
+		} else {
+			seen[sum] = vr
+		}
+	}
+	return results
+}
@@ -0,0 +1,108 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gshaderc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVariantSet(t *testing.T) {
+	shadows, err := ParseVariantAxis("SHADOWS=0,1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fog, err := ParseVariantAxis("FOG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule, err := ParseVariantRule("SHADOWS=1,FOG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := &VariantSet{
+		Axes:     []VariantAxis{shadows, fog},
+		Excludes: []VariantRule{rule},
+	}
+
+	var keys []string
+	for _, v := range set.Variants() {
+		keys = append(keys, v.Key())
+	}
+	if strings.Join(keys, " ") != "SHADOWS=0 FOG,SHADOWS=0 SHADOWS=1" {
+		t.Fatalf("Unexpected variants %v", keys)
+	}
+	if err := set.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{"A=1,1", "A=1=2", "A,B=1"} {
+		if _, err := ParseVariantAxis(s); err == nil {
+			t.Errorf("Expected an error parsing axis %q", s)
+		}
+	}
+	for _, axes := range [][]VariantAxis{
+		{{Name: "A", Values: []string{"1"}}, {Name: "A", Values: []string{"2"}}},
+		{{Name: "A", Values: []string{"1", "1"}}},
+		{{Name: "A", Values: []string{"1,B=2"}}, {Name: "B", Values: []string{"2"}}},
+		{{Name: "A", Values: []string{"x=y"}}},
+	} {
+		if err := (&VariantSet{Axes: axes}).Validate(); err == nil {
+			t.Errorf("Expected %v to be invalid", axes)
+		}
+	}
+}
+
+func TestCompileVariants(t *testing.T) {
+	compiler := NewCompilerWithBackend(NewFakeBackend())
+	defer compiler.Release()
+
+	source := "#version 450\n#if QUALITY > 1\n#error unsupported\n#endif\n#ifdef FOG\nfog();\n#endif\nvoid main(){}"
+	set := &VariantSet{
+		Axes: []VariantAxis{
+			{Name: "FOG", Values: []string{""}, Optional: true},
+			{Name: "UNUSED", Values: []string{"1", "2"}},
+		},
+	}
+
+	results := compiler.CompileVariants(source, FragmentShader, "main.frag", "main", nil, set)
+	if err := results.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Keys) != 4 {
+		t.Fatalf("Expected 4 variants, got %v", results.Keys)
+	}
+	if results.Results["UNUSED=2"].DuplicateOf != "UNUSED=1" || results.Results["FOG,UNUSED=2"].DuplicateOf != "FOG,UNUSED=1" {
+		t.Fatal("Expected variants which only differ by UNUSED to be duplicates")
+	}
+	if len(results.Unique()) != 2 {
+		t.Fatalf("Expected 2 unique variants, got %d", len(results.Unique()))
+	}
+
+	set.Axes = append(set.Axes, VariantAxis{Name: "QUALITY", Values: []string{"1", "2"}})
+	results = compiler.CompileVariants(source, FragmentShader, "main.frag", "main", nil, set)
+	if results.Err() == nil || results.Results["QUALITY=2,UNUSED=1"].Err != CompilationError {
+		t.Fatal("Expected QUALITY=2 variants to fail")
+	}
+	if results.Results["QUALITY=1,UNUSED=1"].Err != nil {
+		t.Fatal("Didn't expect QUALITY=1 variants to fail")
+	}
+
+	set.Axes = append(set.Axes, VariantAxis{Name: "QUALITY", Values: []string{"3"}})
+	results = compiler.CompileVariants(source, FragmentShader, "main.frag", "main", nil, set)
+	if results.Err() == nil || len(results.Keys) != 0 {
+		t.Fatal("Expected variants of an invalid set not to be compiled")
+	}
+}
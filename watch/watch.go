@@ -0,0 +1,511 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package watch recompiles shaders when they, or anything they include,
+// change on disk, so applications can reload shaders while they run.
+//
+//	w, err := watch.NewShaderWatcher("shaders", options, gs.CreateDefaultIncludeResolver(nil))
+//	if err != nil {
+//		return err
+//	}
+//	defer w.Close()
+//	for event := range w.Start(ctx) {
+//		switch e := event.(type) {
+//		case *watch.Compiled:
+//			reloadShader(e.Path, e.SPIRV)
+//		case *watch.Diagnostics:
+//			log.Printf("%s: %s", e.Path, e.Message)
+//		}
+//	}
+package watch
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	gs "github.com/celer/gshaderc"
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultDebounce is how long the watcher waits for a burst of events to
+// finish before compiling
+const DefaultDebounce = 100 * time.Millisecond
+
+// Event is sent by a ShaderWatcher, it's one of *Compiled, *Diagnostics or
+// *Removed
+type Event interface {
+	event()
+}
+
+// Compiled is sent when a shader compiles
+type Compiled struct {
+	Path  string
+	Stage gs.ShaderType
+	// SPIRV is the compiled module, it's nil if a Compile func which doesn't
+	// return SPIR-V is used
+	SPIRV []byte
+	// Warnings are the compiler's warnings, if any
+	Warnings string
+	// Includes are the files the shader included
+	Includes []string
+}
+
+// Diagnostics is sent when a shader fails to compile, or something else
+// goes wrong while watching, in which case Path may be empty
+type Diagnostics struct {
+	Path string
+	// Message is the compiler's error messages
+	Message string
+	Err     error
+}
+
+// Removed is sent when a shader is deleted or renamed away
+type Removed struct {
+	Path string
+}
+
+func (*Compiled) event()    {}
+func (*Diagnostics) event() {}
+func (*Removed) event()     {}
+
+// ShaderWatcher watches a directory tree and compiles shaders when they
+// change. It tracks what every shader includes, through the include
+// resolver, so changing an included file recompiles the shaders using it,
+// even when it lives outside the tree.
+//
+// Events are collected until none arrive for Debounce, then every file
+// touched is looked at once. This copes with editors which save by writing
+// a new file and renaming it over the old one.
+type ShaderWatcher struct {
+	// Debounce is how long to wait for events to stop before compiling
+	Debounce time.Duration
+	// EntryPoint is the entry point shaders are compiled with
+	EntryPoint string
+	// Compile, if set, replaces compiling shaders into SPIR-V. options
+	// resolve includes through the watcher so it learns what the shader
+	// includes. It should return a *Compiled or a *Diagnostics.
+	Compile func(path string, options *gs.CompilerOptions) Event
+
+	compiler    *gs.Compiler
+	ownCompiler bool
+	options     *gs.CompilerOptions
+	watcher     *fsnotify.Watcher
+
+	// graph maps the absolute path of each shader to what it includes
+	graph map[string]*watchedShader
+	// dirs are the directories being watched
+	dirs map[string]bool
+
+	// emit sends an event, it reports false once the watcher is stopping
+	emit func(Event) bool
+
+	startOnce sync.Once
+	closeOnce sync.Once
+	events    chan Event
+	done      chan struct{}
+	stopped   chan struct{}
+}
+
+// watchedShader is a shader and the absolute paths of the files it includes
+type watchedShader struct {
+	path     string
+	includes map[string]bool
+}
+
+// NewShaderWatcher creates a watcher for the shaders below root, compiling
+// them with a new compiler which is released by Close. If resolver is nil
+// the include callback of options is used.
+func NewShaderWatcher(root string, options *gs.CompilerOptions, resolver gs.IncludeResolver) (*ShaderWatcher, error) {
+	compiler := gs.NewCompiler()
+	w, err := NewShaderWatcherWithCompiler(compiler, root, options, resolver)
+	if err != nil {
+		compiler.Release()
+		return nil, err
+	}
+	w.ownCompiler = true
+	return w, nil
+}
+
+// NewShaderWatcherWithCompiler is like NewShaderWatcher but compiles with
+// the given compiler, which the watcher doesn't release
+func NewShaderWatcherWithCompiler(compiler *gs.Compiler, root string, options *gs.CompilerOptions, resolver gs.IncludeResolver) (*ShaderWatcher, error) {
+	if options == nil {
+		options = gs.NewCompilerOptions()
+	}
+	options = options.Clone()
+	if resolver != nil {
+		options.SetIncludeCallback(resolver)
+	}
+
+	w := &ShaderWatcher{
+		Debounce:   DefaultDebounce,
+		EntryPoint: "main",
+		compiler:   compiler,
+		options:    options,
+		emit:       func(Event) bool { return true },
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+
+	var err error
+	w.watcher, err = fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Add(root); err != nil {
+		w.watcher.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Add watches another directory tree, it must be called before Start
+func (w *ShaderWatcher) Add(root string) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return fmt.Errorf("error watching directory '%s': %w", root, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("error watching directory '%s': not a directory", root)
+	}
+	_, err = w.addTree(root)
+	return err
+}
+
+// Start starts watching, events are sent on the returned channel until the
+// watcher is closed or ctx is cancelled, then the channel is closed. The
+// channel must be drained, the watcher waits for events to be received.
+// Calling Start again returns the same channel.
+func (w *ShaderWatcher) Start(ctx context.Context) <-chan Event {
+	w.startOnce.Do(func() {
+		w.events = make(chan Event)
+		w.emit = func(e Event) bool {
+			select {
+			case w.events <- e:
+				return true
+			case <-w.done:
+			case <-ctx.Done():
+			}
+			return false
+		}
+		go w.run(ctx)
+	})
+	return w.events
+}
+
+// Close stops the watcher, waiting for it to finish. It should be called
+// even if the context passed to Start was cancelled.
+func (w *ShaderWatcher) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		close(w.done)
+		// Starting after Close returns a closed channel
+		w.startOnce.Do(func() {
+			w.events = make(chan Event)
+			close(w.events)
+			close(w.stopped)
+			err = w.watcher.Close()
+		})
+		<-w.stopped
+		if w.ownCompiler {
+			w.compiler.Release()
+		}
+	})
+	return err
+}
+
+func (w *ShaderWatcher) run(ctx context.Context) {
+	defer close(w.stopped)
+	defer close(w.events)
+	defer w.watcher.Close()
+
+	pending := make(map[string]bool)
+	var settled <-chan time.Time
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) != 0 {
+				pending[event.Name] = true
+				settled = time.After(w.Debounce)
+			}
+
+		case <-settled:
+			paths := make([]string, 0, len(pending))
+			for path := range pending {
+				paths = append(paths, path)
+			}
+			pending = make(map[string]bool)
+			settled = nil
+			w.flush(paths)
+
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.emit(&Diagnostics{Message: err.Error(), Err: err})
+
+		case <-w.done:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// under reports whether path is dir or inside it
+func under(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// watch starts watching a directory, if it isn't already
+func (w *ShaderWatcher) watch(dir string) error {
+	abs := absPath(dir)
+	if w.dirs == nil {
+		w.dirs = make(map[string]bool)
+	}
+	if w.dirs[abs] {
+		return nil
+	}
+	if w.watcher != nil {
+		if err := w.watcher.Add(dir); err != nil {
+			return fmt.Errorf("error watching directory '%s': %w", dir, err)
+		}
+	}
+	w.dirs[abs] = true
+	return nil
+}
+
+// addTree watches dir and every directory below it, other than hidden ones,
+// and records what the shaders found include. It returns the shaders found.
+func (w *ShaderWatcher) addTree(dir string) ([]string, error) {
+	var shaders []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != dir && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return w.watch(path)
+		}
+		if gs.GetShaderTypeByFilename(path) != gs.InferFromSource {
+			// Broken shaders still report the includes found before the error
+			includes, _ := w.dependencies(path)
+			w.track(path, includes)
+			shaders = append(shaders, path)
+		}
+		return nil
+	})
+	return shaders, err
+}
+
+// recordIncludes returns a copy of the watcher's options which records
+// every include resolved
+func (w *ShaderWatcher) recordIncludes() (*gs.CompilerOptions, *gs.IncludeRecorder) {
+	options := w.options.Clone()
+	recorder := gs.NewIncludeRecorder(options.IncludeResolver())
+	options.SetIncludeCallback(recorder.Resolve)
+	return options, recorder
+}
+
+// dependencies returns every file a shader includes, it only preprocesses
+// the shader
+func (w *ShaderWatcher) dependencies(path string) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	options, recorder := w.recordIncludes()
+	result := w.compiler.CompileIntoPreProcessedText(string(data), gs.GetShaderTypeByFilename(path), path, w.EntryPoint, options)
+	defer result.Release()
+	return recorder.Includes(), result.Error()
+}
+
+// track records the includes of a shader, replacing what it included before,
+// and watches the directories of the includes
+func (w *ShaderWatcher) track(path string, includes []string) {
+	if w.graph == nil {
+		w.graph = make(map[string]*watchedShader)
+	}
+	shader := &watchedShader{path: path, includes: make(map[string]bool)}
+	for _, include := range includes {
+		abs := absPath(include)
+		shader.includes[abs] = true
+		if err := w.watch(filepath.Dir(abs)); err != nil {
+			w.emit(&Diagnostics{Path: path, Message: err.Error(), Err: err})
+		}
+	}
+	w.graph[absPath(path)] = shader
+}
+
+// dependents returns the shaders which include a file
+func (w *ShaderWatcher) dependents(path string) []string {
+	abs := absPath(path)
+	var shaders []string
+	for _, shader := range w.graph {
+		if shader.includes[abs] {
+			shaders = append(shaders, shader.path)
+		}
+	}
+	sort.Strings(shaders)
+	return shaders
+}
+
+// affected returns the shaders to recompile when a file changes, the file
+// itself if it's a shader and every shader which includes it
+func (w *ShaderWatcher) affected(path string) []string {
+	var shaders []string
+	if gs.GetShaderTypeByFilename(path) != gs.InferFromSource {
+		shaders = append(shaders, path)
+	}
+	return append(shaders, w.dependents(path)...)
+}
+
+// flush handles a settled burst of events. Files which still exist have
+// changed, new directories are watched and compiled, and anything which no
+// longer exists has been removed or renamed away.
+func (w *ShaderWatcher) flush(paths []string) {
+	sort.Strings(paths)
+	var shaders []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		switch {
+		case err == nil && info.IsDir():
+			if w.dirs[absPath(path)] {
+				continue
+			}
+			found, err := w.addTree(path)
+			if err != nil && !w.emit(&Diagnostics{Path: path, Message: err.Error(), Err: err}) {
+				return
+			}
+			shaders = append(shaders, found...)
+		case err == nil:
+			shaders = append(shaders, w.affected(path)...)
+		default:
+			removed, ok := w.removed(path)
+			if !ok {
+				return
+			}
+			shaders = append(shaders, removed...)
+		}
+	}
+
+	done := make(map[string]bool)
+	for _, shader := range shaders {
+		abs := absPath(shader)
+		if done[abs] {
+			continue
+		}
+		done[abs] = true
+		if _, err := os.Stat(shader); err == nil {
+			if !w.compile(shader) {
+				return
+			}
+		}
+	}
+}
+
+// removed forgets a file or directory which no longer exists, sending a
+// Removed event for every shader deleted. It returns the shaders which
+// included what was removed, so that they can be recompiled, ok is false
+// if the watcher is stopping.
+func (w *ShaderWatcher) removed(path string) (dependents []string, ok bool) {
+	abs := absPath(path)
+	for dir := range w.dirs {
+		if under(dir, abs) {
+			delete(w.dirs, dir)
+			if w.watcher != nil {
+				w.watcher.Remove(dir)
+			}
+		}
+	}
+
+	var deleted []string
+	for key, shader := range w.graph {
+		if under(key, abs) {
+			deleted = append(deleted, shader.path)
+			delete(w.graph, key)
+		}
+	}
+	if len(deleted) == 0 && gs.GetShaderTypeByFilename(path) != gs.InferFromSource {
+		deleted = append(deleted, path)
+	}
+	sort.Strings(deleted)
+	for _, shader := range deleted {
+		if !w.emit(&Removed{Path: shader}) {
+			return nil, false
+		}
+	}
+
+	for _, shader := range w.graph {
+		for include := range shader.includes {
+			if under(include, abs) {
+				dependents = append(dependents, shader.path)
+				break
+			}
+		}
+	}
+	sort.Strings(dependents)
+	return dependents, true
+}
+
+// compile compiles a shader, updating what it includes, it reports false
+// if the watcher is stopping
+func (w *ShaderWatcher) compile(path string) bool {
+	options, recorder := w.recordIncludes()
+	compile := w.Compile
+	if compile == nil {
+		compile = w.compileSPV
+	}
+	event := compile(path, options)
+	includes := recorder.Includes()
+	w.track(path, includes)
+	if c, ok := event.(*Compiled); ok {
+		c.Includes = includes
+	}
+	return w.emit(event)
+}
+
+// compileSPV compiles a shader into SPIR-V
+func (w *ShaderWatcher) compileSPV(path string, options *gs.CompilerOptions) Event {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return &Diagnostics{Path: path, Message: err.Error(), Err: err}
+	}
+	stage := gs.GetShaderTypeByFilename(path)
+	result := w.compiler.CompileIntoSPV(string(data), stage, path, w.EntryPoint, options)
+	defer result.Release()
+	if result.Error() != nil {
+		return &Diagnostics{Path: path, Message: result.ErrorMessage(), Err: result.Error()}
+	}
+	return &Compiled{Path: path, Stage: stage, SPIRV: result.Bytes(), Warnings: result.ErrorMessage()}
+}
@@ -0,0 +1,292 @@
+// Copyright 2020 celer. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watch
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	gs "github.com/celer/gshaderc"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// recording returns a watcher which hasn't been started and records the
+// events it sends
+func recording(t *testing.T, compiler *gs.Compiler, root string, resolver gs.IncludeResolver) (*ShaderWatcher, *[]Event) {
+	w, err := NewShaderWatcherWithCompiler(compiler, root, nil, resolver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []Event
+	w.emit = func(e Event) bool {
+		events = append(events, e)
+		return true
+	}
+	return w, &events
+}
+
+func TestShaderWatcherIncludeGraph(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"shaders/a.frag":         "#version 450\n#include <lighting.glsl>\nvoid main() {}\n",
+		"shaders/b.frag":         "#version 450\n#include \"common.glsl\"\nvoid main() {}\n",
+		"shaders/c.frag":         "#version 450\nvoid main() {}\n",
+		"shaders/common.glsl":    "#include <lighting.glsl>\n",
+		"include/lighting.glsl":  "float light;\n",
+		"include/unrelated.glsl": "",
+	})
+	shaders := filepath.Join(dir, "shaders")
+	lighting := filepath.Join(dir, "include/lighting.glsl")
+
+	compiler := gs.NewCompilerWithBackend(gs.NewFakeBackend())
+	defer compiler.Release()
+	w, events := recording(t, compiler, shaders, gs.CreateDefaultIncludeResolver([]string{filepath.Join(dir, "include")}))
+	defer w.Close()
+
+	compiled := func(names ...string) {
+		t.Helper()
+		var got []string
+		for _, e := range *events {
+			c, ok := e.(*Compiled)
+			if !ok {
+				t.Fatalf("unexpected event %#v", e)
+			}
+			if len(c.SPIRV) == 0 || c.Stage != gs.FragmentShader {
+				t.Fatalf("unexpected compile %+v", c)
+			}
+			got = append(got, filepath.Base(c.Path))
+		}
+		var expected []string
+		for _, name := range names {
+			expected = append(expected, name+".frag")
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("expected %v compiled, got %v", expected, got)
+		}
+		*events = nil
+	}
+
+	if !w.dirs[absPath(filepath.Join(dir, "include"))] {
+		t.Fatal("expected the include directory to be watched")
+	}
+
+	w.flush([]string{lighting})
+	compiled("a", "b")
+	w.flush([]string{filepath.Join(dir, "include/unrelated.glsl")})
+	compiled()
+	w.flush([]string{filepath.Join(shaders, "common.glsl")})
+	compiled("b")
+
+	// Removing the include updates the graph
+	writeFiles(t, dir, map[string]string{"shaders/b.frag": "#version 450\nvoid main() {}\n"})
+	w.flush([]string{filepath.Join(shaders, "b.frag")})
+	compiled("b")
+	w.flush([]string{lighting})
+	compiled("a")
+
+	// As does adding one
+	writeFiles(t, dir, map[string]string{"shaders/c.frag": "#version 450\n#include <lighting.glsl>\nvoid main() {}\n"})
+	w.flush([]string{filepath.Join(shaders, "c.frag")})
+	compiled("c")
+	w.flush([]string{lighting})
+	compiled("a", "c")
+}
+
+func TestShaderWatcherEvents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"a.frag":             "#version 450\n#include \"common.glsl\"\nvoid main() {}\n",
+		"common.glsl":        "",
+		"nested/b.vert":      "#version 450\nvoid main() {}\n",
+		".hidden/c.vert":     "#version 450\nvoid main() {}\n",
+		"nested/deep/d.comp": "#version 450\nvoid main() {}\n",
+		"broken.frag":        "void main() {}\n",
+	})
+
+	compiler := gs.NewCompilerWithBackend(gs.NewFakeBackend())
+	defer compiler.Release()
+	w, events := recording(t, compiler, dir, gs.CreateDefaultIncludeResolver(nil))
+	defer w.Close()
+
+	for _, d := range []string{"", "nested", "nested/deep"} {
+		if !w.dirs[absPath(filepath.Join(dir, d))] {
+			t.Fatalf("expected %s to be watched", d)
+		}
+	}
+	if w.dirs[absPath(filepath.Join(dir, ".hidden"))] {
+		t.Fatal("didn't expect hidden directories to be watched")
+	}
+
+	next := func() Event {
+		t.Helper()
+		if len(*events) == 0 {
+			t.Fatal("expected an event")
+		}
+		e := (*events)[0]
+		*events = (*events)[1:]
+		return e
+	}
+
+	// An editor saving by writing a temporary file and renaming it over the
+	// original produces events for both names
+	source := filepath.Join(dir, "a.frag")
+	tmp := filepath.Join(dir, ".a.frag.swp")
+	writeFiles(t, dir, map[string]string{".a.frag.swp": "#version 450\nvoid main() {}\n"})
+	if err := os.Rename(tmp, source); err != nil {
+		t.Fatal(err)
+	}
+	w.flush([]string{source, tmp, source})
+	if c, ok := next().(*Compiled); !ok || c.Path != source || len(c.Includes) != 0 || len(*events) != 0 {
+		t.Fatal("expected a.frag to be compiled once after an atomic save")
+	}
+	if len(w.dependents(filepath.Join(dir, "common.glsl"))) != 0 {
+		t.Fatal("expected the removed include to be dropped from the graph")
+	}
+
+	// Broken shaders send diagnostics
+	w.flush([]string{filepath.Join(dir, "broken.frag")})
+	if d, ok := next().(*Diagnostics); !ok || d.Message == "" || d.Err == nil {
+		t.Fatal("expected diagnostics for broken.frag")
+	}
+
+	// New directories are watched and compiled
+	writeFiles(t, dir, map[string]string{"added/sub/e.frag": "#version 450\nvoid main() {}\n"})
+	w.flush([]string{filepath.Join(dir, "added")})
+	if _, ok := next().(*Compiled); !ok || !w.dirs[absPath(filepath.Join(dir, "added/sub"))] {
+		t.Fatal("expected the new directory to be watched and compiled")
+	}
+
+	// Deleting a source sends Removed
+	if err := os.Remove(filepath.Join(dir, "nested/b.vert")); err != nil {
+		t.Fatal(err)
+	}
+	w.flush([]string{filepath.Join(dir, "nested/b.vert")})
+	if r, ok := next().(*Removed); !ok || r.Path != filepath.Join(dir, "nested/b.vert") || w.graph[absPath(r.Path)] != nil {
+		t.Fatal("expected the deleted shader to be removed")
+	}
+
+	// As does deleting a directory
+	if err := os.RemoveAll(filepath.Join(dir, "nested/deep")); err != nil {
+		t.Fatal(err)
+	}
+	w.flush([]string{filepath.Join(dir, "nested/deep")})
+	if _, ok := next().(*Removed); !ok || w.dirs[absPath(filepath.Join(dir, "nested/deep"))] || len(w.graph) != 3 {
+		t.Fatal("expected the deleted directory to be forgotten")
+	}
+}
+
+func TestShaderWatcherStart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"sub/a.frag":  "#version 450\nvoid main() {}\n",
+		"common.glsl": "",
+	})
+
+	compiler := gs.NewCompilerWithBackend(gs.NewFakeBackend())
+	defer compiler.Release()
+	w, err := NewShaderWatcherWithCompiler(compiler, dir, nil, gs.CreateDefaultIncludeResolver(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Debounce = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	events := w.Start(ctx)
+
+	// Save like vim does, write a new file and rename it over the old one
+	tmp := filepath.Join(dir, "sub/a.frag.tmp")
+	if err := ioutil.WriteFile(tmp, []byte("#version 450\n#include \"../common.glsl\"\nvoid main() { }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, "sub/a.frag")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case e := <-events:
+		c, ok := e.(*Compiled)
+		if !ok || len(c.SPIRV) == 0 || len(c.Includes) != 1 || c.Includes[0] != absPath(filepath.Join(dir, "common.glsl")) {
+			t.Fatalf("unexpected event %#v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the shader to compile")
+	}
+
+	// Cancelling the context stops the watcher and closes the channel
+	cancel()
+	for range events {
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Close stops a running watcher, even if nobody is receiving
+	w, err = NewShaderWatcherWithCompiler(compiler, dir, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events = w.Start(context.Background())
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-events; ok {
+		t.Fatal("expected Close to close the channel")
+	}
+
+	// Closing a watcher which was never started
+	w, err = NewShaderWatcherWithCompiler(compiler, dir, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-w.Start(context.Background()); ok {
+		t.Fatal("expected a closed channel when starting after Close")
+	}
+
+	if _, err := NewShaderWatcherWithCompiler(compiler, filepath.Join(dir, "missing"), nil, nil); err == nil {
+		t.Fatal("expected an error for a missing root")
+	}
+}